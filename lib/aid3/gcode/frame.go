@@ -0,0 +1,387 @@
+package gcode
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/geom"
+	"math"
+)
+
+// Frame
+// The transforms a Fanuc control makes of the program before its work
+// offsets: G16 polar coordinates, the G51.1 mirror, G51 scaling and G68
+// rotation, in that order.  They are modal, the moves after them come
+// out of Apply in work coordinates and the work offset of G54 to G59
+// then goes on top as it would without them, the moves out of Apply
+// are in machine coordinates.  G10 L2 sets an offset, G10 L20 sets it
+// so the program is where it is now, G53 leaves the offset off the
+// moves of its block.  The centers are in program coordinates.  On a
+// lathe G50 is the spindle's, on a mill it ends the scaling.
+type Frame struct {
+	lathe bool
+	plane *plane
+
+	polar bool
+
+	mirror   [3]bool
+	mirrorAt [3]float64
+
+	scale   [3]float64
+	scaleAt [3]float64
+	scaling bool
+
+	rotate   float64 // degrees
+	rotateAt [3]float64
+	rotatePl *plane
+	rotating bool
+
+	work        int // G54 is 0 to G59 at 5
+	offsets     [6]geom.Point
+	machine     int // the line of the last G53
+	incremental bool
+
+	a    *geom.Affine
+	prev *Coords // the last position, in program coordinates
+}
+
+func NewFrame(lathe bool) *Frame {
+	return &Frame{
+		lathe:   lathe,
+		plane:   planes[0],
+		machine: -1,
+		a:       geom.Identity(),
+		prev:    &Coords{},
+	}
+}
+
+// Active
+// True when a transform is on, the moves are not as written.
+func (f *Frame) Active() bool {
+	return f.polar || f.scaling || f.rotating || f.mirror != [3]bool{}
+}
+
+// SetOffset
+// The work offset n, 0 for G54 through 5 for G59, as the machine is
+// set up before the program.
+func (f *Frame) SetOffset(n int, p *geom.Point) {
+	f.offsets[n] = *p
+}
+
+// Offset
+// The work offset in use, G54 unless the program chose another.
+func (f *Frame) Offset() *geom.Point {
+	ret := f.offsets[f.work]
+	return &ret
+}
+
+// Apply
+// The command as the control moves it.  A frame command changes the
+// frame, a move comes back with its X, Y, Z and arc center through it,
+// any other command as it is.  The error names a command the frame
+// cannot carry: an arc made elliptical by unequal scaling or turned out
+// of its plane, or a frame command on a lathe.
+func (f *Frame) Apply(cn *CmdNode) (*CmdNode, error) {
+	c := cn.Cmd
+	switch c.c {
+	case CMD_PLANE_XY, CMD_PLANE_XZ, CMD_PLANE_YZ:
+		f.plane = planeOf(c.c)
+	case CMD_MAX_SPINDLE:
+		if f.lathe {
+			break
+		}
+		f.scaling = false
+		f.a = f.affine()
+		return &CmdNode{Cmd: f.renamed(c, CMD_SCALE_OFF), Next: cn.Next}, nil
+	case CMD_ROTATE, CMD_ROTATE_OFF, CMD_SCALE, CMD_SCALE_OFF, CMD_MIRROR, CMD_MIRROR_OFF, CMD_POLAR, CMD_POLAR_OFF:
+		if f.lathe {
+			return nil, f.refuse(c, "is not a lathe's")
+		}
+		if err := f.set(c); err != nil {
+			return nil, err
+		}
+		f.a = f.affine()
+		return cn, nil
+	case CMD_ABSOLUTE, CMD_INCREMENTAL:
+		f.incremental = c.c == CMD_INCREMENTAL
+	case CMD_WORK_OFFSET:
+		f.moveZero(func() { f.work = int(c.Src()[2] - '4') })
+		return cn, nil
+	case CMD_MACHINE_COORDS:
+		f.machine = c.Line()
+		return cn, nil
+	case CMD_RETRACT:
+		if c.coords.Has('L') {
+			var err error
+			f.moveZero(func() { err = f.setOffset(c) })
+			return cn, err
+		}
+	}
+
+	at := f.cartesian(c.coords)
+	defer func() { f.prev = at }()
+	if c.Line() == f.machine && isMove(c) {
+		return f.machineMove(cn, at), nil
+	}
+	if !f.polar {
+		// the axes left out are where the program is, after G53 not
+		// where the words carried
+		at.X, at.Y, at.Z = f.given(at, 'X', f.prev.X), f.given(at, 'Y', f.prev.Y), f.given(at, 'Z', f.prev.Z)
+	}
+	offset := f.offsets[f.work] != geom.Point{} && c.Line() != f.machine
+	if !f.Active() && !offset {
+		return cn, nil
+	}
+	switch c.c {
+	case CMD_FAST, CMD_LINEAR, CMD_CW_ARC, CMD_CCW_ARC, CMD_PROBE:
+	case CMD_FINISH_CYCLE, CMD_TURN_CYCLE, CMD_FACE_CYCLE, CMD_PATTERN_CYCLE,
+		CMD_FACE_PECK_CYCLE, CMD_GROOVE_CYCLE, CMD_THREAD_CYCLE:
+		if offset {
+			return nil, f.refuse(c, "is not carried through a work offset")
+		}
+		return cn, nil
+	default:
+		return cn, nil
+	}
+	nc := *c
+	coords := *at
+	nc.coords = &coords
+	a := f.a
+	if offset {
+		a = f.offsetAffine()
+	}
+	x := &transformer{a: a, prev: f.prev, plane: f.plane, outPl: f.plane}
+	if nc.c == CMD_CW_ARC || nc.c == CMD_CCW_ARC {
+		if to := x.image(f.plane); to != nil && to != f.plane {
+			return nil, x.refuse(c, "arc in %v would turn into %v", f.plane.src, to.src)
+		}
+		if err := x.arc(&nc); err != nil {
+			return nil, err
+		}
+	}
+	x.point(at, nc.coords)
+	return &CmdNode{Cmd: &nc, Next: cn.Next}, nil
+}
+
+// machineMove
+// A G53 move, its axes in machine coordinates and those left out where
+// the machine is.  The program is then back through the frame.
+func (f *Frame) machineMove(cn *CmdNode, at *Coords) *CmdNode {
+	m := f.offsetAffine().MultiplyPoint(&geom.Point{X: f.prev.X, Y: f.prev.Y, Z: f.prev.Z})
+	m.X, m.Y, m.Z = f.given(at, 'X', m.X), f.given(at, 'Y', m.Y), f.given(at, 'Z', m.Z)
+	p := f.Program(m)
+	at.X, at.Y, at.Z = p.X, p.Y, p.Z
+	nc := *cn.Cmd
+	coords := *cn.Cmd.coords
+	coords.X, coords.Y, coords.Z = m.X, m.Y, m.Z
+	nc.coords = &coords
+	return &CmdNode{Cmd: &nc, Next: cn.Next}
+}
+
+// given
+// The word when the command has it, v when not.
+func (f *Frame) given(co *Coords, w byte, v float64) float64 {
+	if co.Has(w) {
+		return co.value(w)
+	}
+	return v
+}
+
+// Program
+// The machine position in program coordinates, back through the work
+// offset and the frame.  A polar position stays as X, Y and Z.
+func (f *Frame) Program(p *geom.Point) *geom.Point {
+	inv, err := f.offsetAffine().Inverse()
+	if err != nil {
+		return p
+	}
+	return inv.MultiplyPoint(p)
+}
+
+func (f *Frame) refuse(c *Cmd, msg string) error {
+	return fmt.Errorf("line %v: %v %v", c.Line(), c.Src(), msg)
+}
+
+func (f *Frame) renamed(c *Cmd, cmd int) *Cmd {
+	nc := *c
+	nc.c = cmd
+	return &nc
+}
+
+// set
+// The frame after the frame command.  Centers left out are where the
+// program is, P, I, J, K and R without a decimal point are in 0.001.
+func (f *Frame) set(c *Cmd) error {
+	co := c.coords
+	switch c.c {
+	case CMD_ROTATE:
+		if co.Has('I') || co.Has('J') || co.Has('K') {
+			return f.refuse(c, "about a vector is not supported, only in the plane")
+		}
+		f.rotating = true
+		f.rotatePl = f.plane
+		f.rotate = thousandths(co, 'R', co.R)
+		f.rotateAt = f.center(co)
+	case CMD_ROTATE_OFF:
+		f.rotating = false
+	case CMD_SCALE:
+		f.scaling = true
+		f.scaleAt = f.center(co)
+		p := 1.0
+		if co.Has('P') {
+			p = thousandths(co, 'P', co.P)
+		}
+		f.scale = [3]float64{p, p, p}
+		for i, w := range []byte("IJK") {
+			if co.Has(w) {
+				f.scale[i] = thousandths(co, w, co.value(w))
+			}
+		}
+		if f.scale[0] == 0 || f.scale[1] == 0 || f.scale[2] == 0 {
+			return f.refuse(c, "scales an axis to nothing")
+		}
+	case CMD_SCALE_OFF:
+		f.scaling = false
+	case CMD_MIRROR, CMD_MIRROR_OFF:
+		given := false
+		for i, w := range []byte("XYZ") {
+			if co.Has(w) {
+				given = true
+				f.mirror[i] = c.c == CMD_MIRROR
+				f.mirrorAt[i] = co.value(w)
+			}
+		}
+		if !given && c.c == CMD_MIRROR_OFF {
+			f.mirror = [3]bool{}
+		}
+	case CMD_POLAR:
+		f.polar = true
+	case CMD_POLAR_OFF:
+		f.polar = false
+	}
+	return nil
+}
+
+// thousandths
+// A Fanuc word without a decimal point is in the least increment.
+func thousandths(co *Coords, w byte, v float64) float64 {
+	if co.Decimal(w) {
+		return v
+	}
+	return v / 1000
+}
+
+// center
+// The X, Y, Z of a frame command, where the program is for those left
+// out.
+func (f *Frame) center(co *Coords) [3]float64 {
+	ret := [3]float64{f.prev.X, f.prev.Y, f.prev.Z}
+	for i, w := range []byte("XYZ") {
+		if co.Has(w) {
+			ret[i] = co.value(w)
+		}
+	}
+	return ret
+}
+
+// setOffset
+// G10 L2 P1 to P6 sets the axes given of G54 to G59, added to them in
+// G91.  G10 L20 sets them so where the program is now is the axes
+// given in that offset.  G10 with another L is a tool's offset.
+func (f *Frame) setOffset(c *Cmd) error {
+	co := c.coords
+	l := int(co.L)
+	if l != 2 && l != 20 {
+		return f.refuse(c, "sets a tool offset, only the work offsets of L2 and L20 are supported")
+	}
+	n := int(co.P) - 1
+	if !co.Has('P') || n < 0 || n >= len(f.offsets) {
+		return f.refuse(c, "needs P1 to P6 for G54 to G59")
+	}
+	at := f.offsetAffine().MultiplyPoint(&geom.Point{X: f.prev.X, Y: f.prev.Y, Z: f.prev.Z})
+	o := &f.offsets[n]
+	for i, axis := range []*float64{&o.X, &o.Y, &o.Z} {
+		w := "XYZ"[i]
+		if !co.Has(w) {
+			continue
+		}
+		switch {
+		case l == 20:
+			// the machine is where the program is now
+			*axis = []float64{at.X, at.Y, at.Z}[i] - co.value(w)
+		case f.incremental:
+			*axis += co.value(w)
+		default:
+			*axis = co.value(w)
+		}
+	}
+	return nil
+}
+
+// moveZero
+// The program zero changed by set, the machine stays where it is and
+// the program is there in the new one.
+func (f *Frame) moveZero(set func()) {
+	m := f.offsetAffine().MultiplyPoint(&geom.Point{X: f.prev.X, Y: f.prev.Y, Z: f.prev.Z})
+	set()
+	p := f.Program(m)
+	prev := *f.prev
+	prev.X, prev.Y, prev.Z = p.X, p.Y, p.Z
+	f.prev = &prev
+}
+
+// offsetAffine
+// The frame and then the work offset in use.
+func (f *Frame) offsetAffine() *geom.Affine {
+	o := f.offsets[f.work]
+	return geom.Compose(f.a, geom.Translate(o.X, o.Y, o.Z))
+}
+
+// affine
+// Mirror, then scale, then rotate, each about its center.
+func (f *Frame) affine() *geom.Affine {
+	about := func(at [3]float64, a *geom.Affine) *geom.Affine {
+		return geom.Compose(geom.Translate(-at[0], -at[1], -at[2]), a, geom.Translate(at[0], at[1], at[2]))
+	}
+	steps := []*geom.Affine{geom.Identity()}
+	for i := range f.mirror {
+		if f.mirror[i] {
+			steps = append(steps, about(f.mirrorAt, geom.Mirror(i)))
+		}
+	}
+	if f.scaling {
+		steps = append(steps, about(f.scaleAt, geom.Scale(f.scale[0], f.scale[1], f.scale[2])))
+	}
+	if f.rotating {
+		var r *geom.Affine
+		switch f.rotatePl.cmd {
+		case CMD_PLANE_XZ:
+			r = geom.RotateY(f.rotate)
+		case CMD_PLANE_YZ:
+			r = geom.RotateX(f.rotate)
+		default:
+			r = geom.RotateZ(f.rotate)
+		}
+		steps = append(steps, about(f.rotateAt, r))
+	}
+	return geom.Compose(steps...)
+}
+
+// cartesian
+// The coordinates with a polar radius and angle as the axes of the
+// plane, both given when either was.
+func (f *Frame) cartesian(co *Coords) *Coords {
+	ret := *co
+	if !f.polar {
+		return &ret
+	}
+	p := f.plane
+	v := [3]float64{co.X, co.Y, co.Z}
+	r, a := v[p.u], v[p.v]*math.Pi/180
+	v[p.u], v[p.v] = r*math.Cos(a), r*math.Sin(a)
+	ret.X, ret.Y, ret.Z = v[0], v[1], v[2]
+	u, w := "XYZ"[p.u], "XYZ"[p.v]
+	if co.Has(u) || co.Has(w) {
+		ret.mark(u, ".")
+		ret.mark(w, ".")
+	}
+	return &ret
+}
@@ -0,0 +1,204 @@
+package gcode
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/geom"
+	"strings"
+	"testing"
+)
+
+// framed
+// The program as the frame moves it.
+func framed(t *testing.T, tree *ParseTree, lathe bool) *ParseTree {
+	f := NewFrame(lathe)
+	out := &ParseTree{settings: tree.settings, nodes: tree.nodes, stk: &Stk{}, cmds: &CmdList{}}
+	if err := tree.TraverseCmds(func(cn *CmdNode) error {
+		fn, err := f.Apply(cn)
+		if err != nil {
+			return err
+		}
+		out.cmds.AddCmd(fn.Cmd)
+		return nil
+	}); err != nil {
+		t.Fatalf("Apply → %v", err)
+	}
+	return out
+}
+
+func TestFrame(t *testing.T) {
+	shape := "G0 X0 Y0 Z5\nG1 Z-1 F300\nG1 X10\nG2 X20 Y0 I5 J0\nG3 X30 Y10 R10\nG1 Y20\n"
+	for _, c := range []struct {
+		name  string
+		on    string
+		off   string
+		a     *geom.Affine
+		flips bool
+	}{
+		{"G68", "G68 X10 Y0 R90.", "G69", geom.Compose(geom.Translate(-10, 0, 0), geom.RotateZ(90), geom.Translate(10, 0, 0)), false},
+		{"G68 thousandths", "G68 X0 Y0 R30000", "G69", geom.RotateZ(30), false},
+		{"G51 P", "G51 X0 Y0 Z0 P2000", "G50", geom.Scale(2, 2, 2), false},
+		{"G51 I J", "G51 X0 Y0 Z0 I-1. J1. K1.", "G50", geom.Mirror(geom.X), true},
+		{"G51.1", "G51.1 Y5", "G50.1 Y0", geom.Compose(geom.Translate(0, -5, 0), geom.Mirror(geom.Y), geom.Translate(0, 5, 0)), true},
+		{"Mirror, scale, rotate", "G51.1 X0\nG51 X0 Y0 Z0 P.5\nG68 X0 Y0 R90.", "G69\nG50\nG50.1",
+			geom.Compose(geom.Mirror(geom.X), geom.Scale(.5, .5, .5), geom.RotateZ(90)), true},
+	} {
+		tree := framed(t, parseSrc(t, "G21 G90 G17\n"+c.on+"\n"+shape+c.off+"\nG1 X0 Y0 Z5\n"), false)
+		plain := parseSrc(t, "G21 G90 G17\n"+shape)
+		var want [][3]float64
+		for _, p := range pathPoints(plain)[1:] {
+			q := c.a.MultiplyPoint(&geom.Point{X: p[0], Y: p[1], Z: p[2]})
+			want = append(want, [3]float64{q.X, q.Y, q.Z})
+		}
+		// the frame is off for the last move, back as written
+		want = append(want, [3]float64{0, 0, 5})
+		if d := strays(want, pathPoints(tree)[1:]); d > 1e-3 {
+			t.Errorf("%v → Expected: the path through the frame, Got: %v off", c.name, d)
+		}
+		for _, a := range cmdsOf(tree) {
+			if a.Src() == "G2" && (a.c == CMD_CCW_ARC) != c.flips {
+				t.Errorf("%v → Expected: G2 flipped %v, Got: %v", c.name, c.flips, cmdWord(a))
+			}
+		}
+	}
+}
+
+func TestFramePolar(t *testing.T) {
+	tree := framed(t, parseSrc(t, "G21 G90 G17\nG16\nG0 X10. Y90. Z5\nG1 Y180.\nZ-1\nG15\nG1 X3\n"), false)
+	var got []string
+	for _, c := range cmdsOf(tree) {
+		if isMove(c) {
+			got = append(got, fmt.Sprintf("%v %v %v", c.coords.X, c.coords.Y, c.coords.Z))
+		}
+	}
+	want := []string{"0 10 5", "-10 0 5", "-10 0 -1"}
+	if strings.Join(got[:3], "|") != strings.Join(want, "|") {
+		t.Errorf("Polar → Expected: %q, Got: %q", want, got)
+	}
+	// off again X is X, Y stays where the polar move left it
+	if last := cmdsOf(tree)[len(cmdsOf(tree))-1]; last.coords.X != 3 || last.coords.Has('Y') {
+		t.Errorf("G15 → Expected: X3 alone, Got: %+v", *last.coords)
+	}
+}
+
+func TestFrameWords(t *testing.T) {
+	// the center of G68 is not where the program is
+	tree := parseSrc(t, "G0 X1 Y2\nG68 X50 Y50 R0\nG1 Z-1\nG69\n")
+	for _, c := range cmdsOf(tree) {
+		if c.c == CMD_LINEAR && (c.coords.X != 1 || c.coords.Y != 2) {
+			t.Errorf("Carried → Expected: X1 Y2, Got: X%v Y%v", c.coords.X, c.coords.Y)
+		}
+	}
+
+	// G50 is the spindle's on a lathe
+	src := "G50 S2000\nG0 X1\n"
+	if c := cmdsOf(framed(t, parseSrc(t, src), true))[0]; c.c != CMD_MAX_SPINDLE {
+		t.Errorf("Lathe G50 → Expected: the spindle limit, Got: %v", c.c)
+	}
+	if c := cmdsOf(framed(t, parseSrc(t, src), false))[0]; c.c != CMD_SCALE_OFF {
+		t.Errorf("Mill G50 → Expected: scaling off, Got: %v", c.c)
+	}
+
+	f := NewFrame(false)
+	tree = parseSrc(t, "G0 X0 Y0\nG51 X0 Y0 I2. J1.\nG2 X10 Y0 I5 J0\n")
+	var err error
+	tree.TraverseCmds(func(cn *CmdNode) error {
+		_, err = f.Apply(cn)
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "line 3: G2 arc in G17 would be stretched") {
+		t.Errorf("Unequal scaling → Expected: the arc refused, Got: %v", err)
+	}
+	if _, err = NewFrame(true).Apply(cmdsNodes(parseSrc(t, "G68 X0 Y0 R90.\n"))[0]); err == nil {
+		t.Errorf("Lathe G68 → Expected: an error")
+	}
+}
+
+func TestFrameOffsets(t *testing.T) {
+	src := `G21 G90 G17
+G10 L2 P1 X10 Y20 Z-5
+G10 L2 P2 X100 Y50 Z-10
+G0 X0 Y0 Z5
+G55
+G0 X0 Y0 Z5
+G68 X0 Y0 R90.
+G1 X10 Y0 F300
+G69
+G0 X0 Y10
+G53 G0 Z0
+G0 X2
+G91 G10 L2 P2 X1
+G90 G0 X0
+G10 L20 P1 X0 Y0
+G54
+G0 X1 Z1
+`
+	f := NewFrame(false)
+	var got []string
+	for _, cn := range cmdsNodes(parseSrc(t, src)) {
+		fn, err := f.Apply(cn)
+		if err != nil {
+			t.Fatalf("Apply → %v", err)
+		}
+		if c := fn.Cmd; isMove(c) {
+			got = append(got, fmt.Sprintf("%.6g %.6g %.6g", c.coords.X, c.coords.Y, c.coords.Z))
+		}
+	}
+	want := []string{
+		"10 20 0",   // G54
+		"100 50 -5", // G55
+		"100 60 -5", // G68 turns the move, the offset goes on top
+		"100 60 -5", // the same place without it
+		"100 60 0",  // G53 Z0 is the machine's
+		"102 60 0",  // Z stays where G53 left it
+		"101 60 0",  // G91 G10 adds to the offset
+		"102 60 -4", // G54 from L20, Y stays put
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Offsets → Expected: %q, Got: %q", want, got)
+	}
+	if p := f.Program(&geom.Point{X: 102, Y: 60, Z: -4}); !nearPoint(p, &geom.Point{X: 1, Y: 0, Z: 1}) {
+		t.Errorf("Program through G54 → Expected: X1 Y0 Z1, Got: %v", p)
+	}
+	if o := f.Offset(); *o != (geom.Point{X: 101, Y: 60, Z: -5}) {
+		t.Errorf("G54 after L20 → Expected: X101 Y60 Z-5, Got: %v", o)
+	}
+
+	for _, c := range []struct {
+		src  string
+		want string
+	}{
+		{"G10 L1 P1 R3\n", "tool offset"},
+		{"G10 L2 P7 X1\n", "P1 to P6"},
+	} {
+		_, err := NewFrame(false).Apply(cmdsNodes(parseSrc(t, c.src))[0])
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%q → Expected: an error of %q, Got: %v", c.src, c.want, err)
+		}
+	}
+
+	// a lathe cycle follows its own profile, not the offset
+	lathe := NewFrame(true)
+	lathe.SetOffset(0, &geom.Point{Z: -100})
+	var err error
+	for _, cn := range cmdsNodes(parseSrc(t, "G0 X20 Z2\nG71 U1 R0.5\n")) {
+		if _, err = lathe.Apply(cn); err != nil {
+			break
+		}
+	}
+	if err == nil || !strings.Contains(err.Error(), "work offset") {
+		t.Errorf("Lathe cycle with an offset → Expected: an error, Got: %v", err)
+	}
+}
+
+func nearPoint(a *geom.Point, b *geom.Point) bool {
+	return a.Dist(b) < 1e-9
+}
+
+func cmdsNodes(tree *ParseTree) []*CmdNode {
+	var ret []*CmdNode
+	tree.TraverseCmds(func(cn *CmdNode) error {
+		ret = append(ret, cn)
+		return nil
+	})
+	return ret
+}
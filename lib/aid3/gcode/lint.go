@@ -0,0 +1,438 @@
+package gcode
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	SEVERITY_OFF = iota
+	SEVERITY_INFO
+	SEVERITY_WARNING
+	SEVERITY_ERROR
+)
+
+var severities = []string{"off", "info", "warning", "error"}
+
+// ParseSeverity
+// The severity by name, off, info, warning or error.
+func ParseSeverity(name string) (int, error) {
+	for i, s := range severities {
+		if strings.EqualFold(name, s) {
+			return i, nil
+		}
+	}
+	return SEVERITY_OFF, fmt.Errorf("unknown severity %q, one of %v", name, strings.Join(severities, ", "))
+}
+
+// SeverityName
+// The name of the severity, as ParseSeverity reads it.
+func SeverityName(severity int) string {
+	if severity < 0 || severity >= len(severities) {
+		return "unknown"
+	}
+	return severities[severity]
+}
+
+// Diagnostic
+// What a rule found, at the line and column of the command.
+type Diagnostic struct {
+	Rule     string `json:"rule" yaml:"rule"`
+	Severity int    `json:"severity" yaml:"severity"`
+	Line     int    `json:"line" yaml:"line"`
+	Column   int    `json:"column" yaml:"column"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%v:%v: %v: %v [%v]", d.Line, d.Column, SeverityName(d.Severity), d.Message, d.Rule)
+}
+
+// Rule
+// A check run on each command in program order, with the state of the
+// program before the command.  Severity is what it reports at unless
+// the config says otherwise.
+type Rule struct {
+	Name     string
+	Severity int
+	Doc      string
+	check    func(l *linter, cn *CmdNode)
+}
+
+var rules = []*Rule{
+	{"feed-unset", SEVERITY_ERROR, "a feed move without F, or without F on its line in G93", lintFeedUnset},
+	{"plunge-feed", SEVERITY_WARNING, "a plunge at the full feed of the tool's side cuts", lintPlungeFeed},
+	{"rapid-into-stock", SEVERITY_ERROR, "a rapid across below the safe Z, or down into the stock", lintRapidIntoStock},
+	{"arc-radius", SEVERITY_ERROR, "an arc whose start and end are not the same distance from the center", lintArcRadius},
+	{"units-preamble", SEVERITY_WARNING, "the first move before G20 or G21", lintUnitsPreamble},
+	{"distance-preamble", SEVERITY_WARNING, "the first move before G90 or G91, or G91 without a G90 after it", lintDistancePreamble},
+	{"tool-before-change", SEVERITY_WARNING, "cutting before any tool change", lintToolBeforeChange},
+	{"spindle-off-cut", SEVERITY_ERROR, "cutting with the spindle off", lintSpindleOffCut},
+	{"unreachable", SEVERITY_WARNING, "commands after M2 or M30 with no subprogram calls", lintUnreachable},
+}
+
+// Rules
+// Every rule the linter has, in the order they run.
+func Rules() []*Rule {
+	return rules
+}
+
+// RuleSets
+// The rules which make sense on each kind of machine.  A lathe programs
+// X in diameters so arcs are left alone, and safe Z and plunges are
+// milling ideas.  A beam cutter or printer has no tool or spindle to
+// check.
+var RuleSets = map[string][]string{
+	"mill": {"feed-unset", "plunge-feed", "rapid-into-stock", "arc-radius", "units-preamble",
+		"distance-preamble", "tool-before-change", "spindle-off-cut", "unreachable"},
+	"lathe": {"feed-unset", "units-preamble", "distance-preamble", "tool-before-change",
+		"spindle-off-cut", "unreachable"},
+	"laser":   {"feed-unset", "arc-radius", "units-preamble", "distance-preamble", "unreachable"},
+	"plasma":  {"feed-unset", "arc-radius", "units-preamble", "distance-preamble", "unreachable"},
+	"printer": {"feed-unset", "arc-radius", "unreachable"},
+}
+
+// LintConfig
+// Which rules run and at what severity, by rule name.  SafeZ and
+// StockTop are in program units, a rapid across below SafeZ or down
+// below StockTop is into the stock.  ArcTolerance is in mm.
+type LintConfig struct {
+	Rules        map[string]int
+	SafeZ        float64
+	StockTop     float64
+	ArcTolerance float64
+}
+
+// MakeLintConfig
+// The rules of the set at their own severities, the stock top at Z0
+// and the safe Z above it.
+func MakeLintConfig(set string) (*LintConfig, error) {
+	names, ok := RuleSets[strings.ToLower(set)]
+	if !ok {
+		keys := make([]string, 0, len(RuleSets))
+		for k := range RuleSets {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("unknown rule set %q, one of %v", set, strings.Join(keys, ", "))
+	}
+	ret := &LintConfig{Rules: make(map[string]int), SafeZ: 1, ArcTolerance: 0.005}
+	for _, nm := range names {
+		ret.Rules[nm] = lookupRule(nm).Severity
+	}
+	return ret, nil
+}
+
+func lookupRule(name string) *Rule {
+	for _, r := range rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Set
+// Change severities from rule=severity pairs split by commas,
+// plunge-feed=error,unreachable=off.  A rule not in the set is added.
+func (lc *LintConfig) Set(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%q is not rule=severity", pair)
+		}
+		name := strings.TrimSpace(kv[0])
+		if lookupRule(name) == nil {
+			return fmt.Errorf("unknown rule %q", name)
+		}
+		sev, err := ParseSeverity(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return err
+		}
+		lc.Rules[name] = sev
+	}
+	return nil
+}
+
+// linter
+// The program state the rules see, as it is before the command.
+type linter struct {
+	cfg   *LintConfig
+	rule  *Rule
+	diags []*Diagnostic
+
+	prev       *Coords
+	zKnown     bool
+	warnedFeed bool
+	plane      int
+	inch       bool
+	unitsSet   bool
+	distSet    bool
+	g91        *CmdNode // the G91 in force, nil in G90
+	warnedG91  bool
+	moved      bool
+	inverse    bool
+	spindleOn  bool
+	warnedOff  bool
+	tool       string
+	changed    bool
+	warnedTool bool
+	ended      bool
+	warnedEnd  bool
+	calls      bool
+	sideFeed   map[string]float64
+}
+
+func (l *linter) report(cn *CmdNode, format string, args ...interface{}) {
+	l.diags = append(l.diags, &Diagnostic{
+		Rule:     l.rule.Name,
+		Severity: l.cfg.Rules[l.rule.Name],
+		Line:     cn.Cmd.Line(),
+		Column:   cn.Cmd.Column(),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// fromMm
+// A length in mm in the program units.
+func (l *linter) fromMm(v float64) float64 {
+	if l.inch {
+		return v / 25.4
+	}
+	return v
+}
+
+// Lint
+// Run the rules of the config over the program, the diagnostics in
+// program order.
+func (t *ParseTree) Lint(cfg *LintConfig) []*Diagnostic {
+	l := &linter{cfg: cfg, plane: CMD_PLANE_XY, prev: &Coords{}, sideFeed: sideFeeds(t)}
+	t.TraverseCmds(func(cn *CmdNode) error {
+		if cn.Cmd.c == CMD_SUBPROGRAM_CALL {
+			l.calls = true
+		}
+		return nil
+	})
+	t.TraverseCmds(func(cn *CmdNode) error {
+		for _, r := range rules {
+			if cfg.Rules[r.Name] == SEVERITY_OFF {
+				continue
+			}
+			l.rule = r
+			r.check(l, cn)
+		}
+		l.update(cn)
+		return nil
+	})
+	return l.diags
+}
+
+// update
+// The state after the command.
+func (l *linter) update(cn *CmdNode) {
+	c := cn.Cmd
+	switch c.c {
+	case CMD_PLANE_XY, CMD_PLANE_XZ, CMD_PLANE_YZ:
+		l.plane = c.c
+	case CMD_INCH:
+		l.inch, l.unitsSet = true, true
+	case CMD_MM:
+		l.inch, l.unitsSet = false, true
+	case CMD_ABSOLUTE:
+		l.distSet, l.g91 = true, nil
+	case CMD_INCREMENTAL:
+		l.distSet, l.g91 = true, cn
+	case CMD_INVERSE_TIME_FEED:
+		l.inverse = true
+	case CMD_FEED_PER_MIN_MODE, CMD_FEED_PER_REVOLUTION:
+		l.inverse = false
+	case CMD_SPINDLE_CW, CMD_SPINDLE_CCW:
+		l.spindleOn, l.warnedOff = true, false
+	case CMD_SPINDLE_OFF:
+		l.spindleOn = false
+	case CMD_TOOL_CHANGE:
+		l.tool, l.changed = c.Src(), true
+	case CMD_PROGRAM_END:
+		l.ended = true
+	case CMD_FAST, CMD_LINEAR, CMD_CW_ARC, CMD_CCW_ARC:
+		l.moved = true
+		l.zKnown = l.zKnown || c.coords.Has('Z')
+	case CMD_HOME:
+		l.zKnown = false
+	}
+	if c.coords.F > 0 {
+		l.warnedFeed = false
+	}
+	l.prev = c.coords
+}
+
+// unknown
+// The start or end of the command reads a parameter, where it is is
+// only known as the program runs.
+func (l *linter) unknown(c *Cmd) bool {
+	return c.coords.Reads() || l.prev.Reads()
+}
+
+func isMove(c *Cmd) bool {
+	return c.c == CMD_FAST || isCut(c)
+}
+
+func isCut(c *Cmd) bool {
+	return c.c == CMD_LINEAR || c.c == CMD_CW_ARC || c.c == CMD_CCW_ARC
+}
+
+// sideFeeds
+// The most feed of each tool's level moves, the feed it cuts with.
+func sideFeeds(t *ParseTree) map[string]float64 {
+	ret := make(map[string]float64)
+	tool := ""
+	prev := &Coords{}
+	t.TraverseCmds(func(cn *CmdNode) error {
+		c := cn.Cmd
+		if c.c == CMD_TOOL_CHANGE {
+			tool = c.Src()
+		}
+		if c.c == CMD_LINEAR && !c.coords.Reads() && !prev.Reads() && c.coords.Z == prev.Z && (c.coords.X != prev.X || c.coords.Y != prev.Y) {
+			ret[tool] = math.Max(ret[tool], c.coords.F)
+		}
+		prev = c.coords
+		return nil
+	})
+	return ret
+}
+
+func lintFeedUnset(l *linter, cn *CmdNode) {
+	c := cn.Cmd
+	if !isCut(c) {
+		return
+	}
+	if l.inverse && !c.coords.Has('F') {
+		l.report(cn, "%v in inverse time has no F", c.Src())
+		return
+	}
+	if c.coords.F <= 0 && !l.warnedFeed {
+		l.warnedFeed = true
+		l.report(cn, "%v with no feed set", c.Src())
+	}
+}
+
+func lintPlungeFeed(l *linter, cn *CmdNode) {
+	c := cn.Cmd
+	if c.c != CMD_LINEAR || l.inverse || !l.zKnown || l.unknown(c) {
+		return
+	}
+	down := l.prev.Z - c.coords.Z
+	across := math.Hypot(c.coords.X-l.prev.X, c.coords.Y-l.prev.Y)
+	side := l.sideFeed[l.tool]
+	if down > 0 && across < down && side > 0 && c.coords.F >= side {
+		l.report(cn, "plunges %v at F%v, the full feed of the tool", down, c.coords.F)
+	}
+}
+
+func lintRapidIntoStock(l *linter, cn *CmdNode) {
+	c := cn.Cmd
+	if c.c != CMD_FAST || !l.zKnown && !c.coords.Has('Z') || l.unknown(c) {
+		return
+	}
+	to := c.coords
+	across := to.X != l.prev.X || to.Y != l.prev.Y
+	low := math.Min(to.Z, l.prev.Z)
+	switch {
+	case to.Z < l.cfg.StockTop && to.Z < l.prev.Z:
+		l.report(cn, "rapid down to Z%v, below the stock top at Z%v", to.Z, l.cfg.StockTop)
+	case across && low < l.cfg.SafeZ:
+		l.report(cn, "rapid across at Z%v, below the safe Z%v", low, l.cfg.SafeZ)
+	}
+}
+
+func lintArcRadius(l *linter, cn *CmdNode) {
+	c := cn.Cmd
+	if c.c != CMD_CW_ARC && c.c != CMD_CCW_ARC || l.unknown(c) {
+		return
+	}
+	// the plane as u, v and the center offsets along them
+	su, sv, eu, ev, ou, ov := l.prev.X, l.prev.Y, c.coords.X, c.coords.Y, c.coords.I, c.coords.J
+	hasCenter := c.coords.Has('I') || c.coords.Has('J')
+	switch l.plane {
+	case CMD_PLANE_XZ:
+		su, sv, eu, ev, ou, ov = l.prev.Z, l.prev.X, c.coords.Z, c.coords.X, c.coords.K, c.coords.I
+		hasCenter = c.coords.Has('K') || c.coords.Has('I')
+	case CMD_PLANE_YZ:
+		su, sv, eu, ev, ou, ov = l.prev.Y, l.prev.Z, c.coords.Y, c.coords.Z, c.coords.J, c.coords.K
+		hasCenter = c.coords.Has('J') || c.coords.Has('K')
+	}
+	tol := l.fromMm(l.cfg.ArcTolerance)
+	if c.coords.Has('R') {
+		chord := math.Hypot(eu-su, ev-sv)
+		if chord > 2*math.Abs(c.coords.R)+tol {
+			l.report(cn, "R%v is too small for the %.4f chord", c.coords.R, chord)
+		}
+		return
+	}
+	if !hasCenter {
+		l.report(cn, "%v has neither a center nor R", c.Src())
+		return
+	}
+	r0 := math.Hypot(ou, ov)
+	r1 := math.Hypot(eu-(su+ou), ev-(sv+ov))
+	if math.Abs(r0-r1) > tol {
+		l.report(cn, "starts %.4f and ends %.4f from the center", r0, r1)
+	}
+}
+
+func lintUnitsPreamble(l *linter, cn *CmdNode) {
+	if isMove(cn.Cmd) && !l.moved && !l.unitsSet {
+		l.report(cn, "moves before G20 or G21, the units are whatever the control was left in")
+	}
+}
+
+func lintDistancePreamble(l *linter, cn *CmdNode) {
+	c := cn.Cmd
+	if isMove(c) && !l.moved && !l.distSet {
+		l.report(cn, "moves before G90 or G91, the distance mode is whatever the control was left in")
+	}
+	// a G91 still in force at M2, M30 or the last command is reported
+	// where it was given
+	g91 := l.g91
+	switch c.c {
+	case CMD_ABSOLUTE:
+		g91 = nil
+	case CMD_INCREMENTAL:
+		g91 = cn
+	}
+	if g91 != nil && !l.warnedG91 && (c.c == CMD_PROGRAM_END || cn.Next == nil) {
+		l.warnedG91 = true
+		l.report(g91, "G91 without a G90 after it, the program ends incremental")
+	}
+}
+
+func lintToolBeforeChange(l *linter, cn *CmdNode) {
+	if isCut(cn.Cmd) && !l.changed && !l.warnedTool {
+		l.warnedTool = true
+		l.report(cn, "cuts before any tool change, with whatever tool is in the spindle")
+	}
+}
+
+func lintSpindleOffCut(l *linter, cn *CmdNode) {
+	c := cn.Cmd
+	if !isCut(c) || l.spindleOn || l.warnedOff {
+		return
+	}
+	if c.coords.X == l.prev.X && c.coords.Y == l.prev.Y && c.coords.Z == l.prev.Z && c.c == CMD_LINEAR && !l.unknown(c) {
+		return
+	}
+	l.warnedOff = true
+	l.report(cn, "cuts with the spindle off")
+}
+
+func lintUnreachable(l *linter, cn *CmdNode) {
+	if l.ended && !l.calls && !l.warnedEnd {
+		l.warnedEnd = true
+		l.report(cn, "%v is after the end of the program and never runs", cn.Cmd.Src())
+	}
+}
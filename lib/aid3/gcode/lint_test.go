@@ -0,0 +1,149 @@
+package gcode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func lintSrc(t *testing.T, set string, src string) []*Diagnostic {
+	fileNm := filepath.Join(t.TempDir(), "p.nc")
+	if err := os.WriteFile(fileNm, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := Parse(fileNm)
+	if err != nil {
+		t.Fatalf("Parse → %v", err)
+	}
+	cfg, err := MakeLintConfig(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree.Lint(cfg)
+}
+
+func byRule(diags []*Diagnostic) map[string][]*Diagnostic {
+	ret := make(map[string][]*Diagnostic)
+	for _, d := range diags {
+		ret[d.Rule] = append(ret[d.Rule], d)
+	}
+	return ret
+}
+
+func TestLintClean(t *testing.T) {
+	src := `G21 G90 G17
+T1 M6
+S10000 M3
+G0 Z5
+G0 X0 Y0
+G1 Z-1 F200
+G1 X10 F800
+G2 X20 Y0 I5 J0
+G0 Z5
+M5
+M30
+`
+	if diags := lintSrc(t, "mill", src); len(diags) != 0 {
+		t.Errorf("Clean program → Expected no diagnostics, Got: %v", diags)
+	}
+}
+
+func TestLintParams(t *testing.T) {
+	// where a probe tripped is only known as the program runs
+	src := `G21 G90 G17
+T1 M6
+S10000 M3
+G0 Z5
+G0 X3 Y0
+G38.2 Z-10 F100
+G0 Z#5063
+G1 Z-1 F200
+G2 X#5061 Y0 I5 J0
+G0 Z5
+M5
+M30
+`
+	if diags := lintSrc(t, "mill", src); len(diags) != 0 {
+		t.Errorf("Reads → Expected no diagnostics, Got: %v", diags)
+	}
+}
+
+func TestLintRules(t *testing.T) {
+	src := `G0 Z5
+G0 X0 Y0
+G1 Z-1
+G1 X10 F800
+G1 Z-3 F800
+G0 X20 Z-0.5
+G0 Z5
+S1000 M3
+G2 X30 Y0 I6 J0
+G3 X40 Y0 R2
+M5
+G1 X50
+M30
+G0 X0
+`
+	got := byRule(lintSrc(t, "mill", src))
+	expect := map[string]int{
+		"units-preamble":     1,
+		"distance-preamble":  1,
+		"feed-unset":         1,
+		"tool-before-change": 1,
+		"plunge-feed":        1,
+		"rapid-into-stock":   1,
+		"arc-radius":         2,
+		"spindle-off-cut":    2,
+		"unreachable":        1,
+	}
+	for rule, n := range expect {
+		if len(got[rule]) != n {
+			t.Errorf("%v → Expected: %v, Got: %v", rule, n, got[rule])
+		}
+	}
+	if d := got["feed-unset"]; len(d) == 1 && (d[0].Line != 3 || d[0].Column != 1 || d[0].Severity != SEVERITY_ERROR) {
+		t.Errorf("Position → Expected: 3:1 error, Got: %v", d[0])
+	}
+	if d := got["unreachable"]; len(d) == 1 && d[0].Line != 14 {
+		t.Errorf("Unreachable → Expected: line 14, Got: %v", d[0])
+	}
+}
+
+func TestLintIncremental(t *testing.T) {
+	// G91 sets the distance mode, then is left on past M30
+	got := byRule(lintSrc(t, "mill", "G21 G17\nG91\nG0 Z5\nG90\nG0 X0\nG91\nG0 Z5\nM30\n"))
+	d := got["distance-preamble"]
+	if len(d) != 1 || d[0].Line != 6 {
+		t.Errorf("G91 left on → Expected: the G91 at line 6 only, Got: %v", d)
+	}
+
+	// returned with G90, or a program without M30 ending in G91
+	if d := byRule(lintSrc(t, "mill", "G21 G17 G91\nG0 Z5\nG90\nM30\n"))["distance-preamble"]; len(d) != 0 {
+		t.Errorf("G91 returned → Expected: nothing, Got: %v", d)
+	}
+	if d := byRule(lintSrc(t, "mill", "G21 G17 G90\nG0 Z5\nG91 G0 Z5\n"))["distance-preamble"]; len(d) != 1 || d[0].Line != 3 {
+		t.Errorf("Ends in G91 → Expected: the G91 at line 3, Got: %v", d)
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	cfg, err := MakeLintConfig("printer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cfg.Set("unreachable=off,plunge-feed=error"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Rules["unreachable"] != SEVERITY_OFF || cfg.Rules["plunge-feed"] != SEVERITY_ERROR {
+		t.Errorf("Set → Expected unreachable off and plunge-feed error, Got: %v", cfg.Rules)
+	}
+	if err = cfg.Set("no-such-rule=error"); err == nil {
+		t.Errorf("Unknown rule → Expected an error")
+	}
+	if err = cfg.Set("unreachable=loud"); err == nil {
+		t.Errorf("Unknown severity → Expected an error")
+	}
+	if _, err = MakeLintConfig("waterjet"); err == nil {
+		t.Errorf("Unknown set → Expected an error")
+	}
+}
@@ -0,0 +1,210 @@
+package gcode
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Meta
+// What the slicer or CAM comments say a command is part of.  Layer
+// counts from 0, -1 before the first.  Z and Height are the layer as
+// PrusaSlicer gives it, 0 when unknown.  Feature is the ;TYPE: of Cura
+// and PrusaSlicer, WALL-OUTER or External perimeter, Operation the CAM
+// operation and Tool the CAM description of the tool in the spindle.
+// Commands share a Meta until something changes.
+type Meta struct {
+	Layer     int
+	Z         float64
+	Height    float64
+	Feature   string
+	Operation string
+	Tool      *ToolInfo
+}
+
+// ToolInfo
+// A tool as Fusion or Mastercam describe it in a comment,
+// (T1 D=6 CR=0 - ZMIN=-10 - flat end mill).  Diameter and CornerRadius
+// are in program units, Fields holds every KEY=value given.
+type ToolInfo struct {
+	Number       int
+	Diameter     float64
+	CornerRadius float64
+	Description  string
+	Fields       map[string]string
+}
+
+// extractor
+// Reads one comment into the meta, true when it was its convention.
+type extractor func(t *ParseTree, m *Meta, text string) bool
+
+var extractors = []extractor{
+	extractLayer,
+	extractFeature,
+	extractOperation,
+	extractTool,
+	extractSetting,
+}
+
+// ExtractMeta
+// Walk the commands reading their comments, each command gets the
+// meta in force when it runs.  A tool described in a comment becomes
+// the command's tool at its tool change.
+func (t *ParseTree) ExtractMeta() {
+	t.slicer = make(map[string]string)
+	t.tools = make(map[int]*ToolInfo)
+	cur := &Meta{Layer: -1}
+	for n := t.cmds.head; n != nil; n = n.Next {
+		for _, text := range n.Cmd.comments {
+			next := *cur
+			for _, ex := range extractors {
+				if ex(t, &next, text) {
+					break
+				}
+			}
+			if next != *cur {
+				cur = &next
+			}
+		}
+		if n.Cmd.c == CMD_TOOL_CHANGE {
+			if num, err := strconv.Atoi(n.Cmd.Src()[1:]); err == nil && t.tools[num] != cur.Tool {
+				next := *cur
+				next.Tool = t.tools[num]
+				cur = &next
+			}
+		}
+		n.Cmd.meta = cur
+	}
+}
+
+// SlicerSettings
+// The settings a slicer listed, the PrusaSlicer block at the end and
+// the Cura header, by the name it gave them.
+func (t *ParseTree) SlicerSettings() map[string]string {
+	return t.slicer
+}
+
+// Tool
+// The tool described in the comments by number, nil when there is
+// none.
+func (t *ParseTree) Tool(num int) *ToolInfo {
+	return t.tools[num]
+}
+
+// extractLayer
+// Cura gives ;LAYER:n, PrusaSlicer ;LAYER_CHANGE then ;Z: and
+// ;HEIGHT: for the new layer.
+func extractLayer(t *ParseTree, m *Meta, text string) bool {
+	if v, ok := commentValue(text, "LAYER:"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			m.Layer = n
+			return true
+		}
+	}
+	if text == "LAYER_CHANGE" {
+		m.Layer++
+		return true
+	}
+	if v, ok := commentValue(text, "Z:"); ok {
+		if z, err := strconv.ParseFloat(v, 64); err == nil {
+			m.Z = z
+			return true
+		}
+	}
+	if v, ok := commentValue(text, "HEIGHT:"); ok {
+		if h, err := strconv.ParseFloat(v, 64); err == nil {
+			m.Height = h
+			return true
+		}
+	}
+	return false
+}
+
+func extractFeature(t *ParseTree, m *Meta, text string) bool {
+	if v, ok := commentValue(text, "TYPE:"); ok {
+		m.Feature = v
+		return true
+	}
+	return false
+}
+
+// extractOperation
+// (OPERATION 2: POCKET), with or without a number.
+func extractOperation(t *ParseTree, m *Meta, text string) bool {
+	if !strings.HasPrefix(strings.ToUpper(text), "OPERATION") {
+		return false
+	}
+	name := strings.TrimSpace(text[len("OPERATION"):])
+	if i := strings.Index(name, ":"); i >= 0 {
+		if _, err := strconv.Atoi(strings.TrimSpace(name[:i])); err == nil || i == 0 {
+			name = strings.TrimSpace(name[i+1:])
+		}
+	}
+	m.Operation = name
+	return true
+}
+
+// extractTool
+// (T1 D=6 CR=0 - ZMIN=-10 - flat end mill), the words after the last
+// dash without an = are the description.
+func extractTool(t *ParseTree, m *Meta, text string) bool {
+	if len(text) < 2 || (text[0] != 'T' && text[0] != 't') {
+		return false
+	}
+	parts := strings.Split(text, " - ")
+	words := strings.Fields(parts[0])
+	num, err := strconv.Atoi(words[0][1:])
+	if err != nil || len(words) < 2 {
+		return false
+	}
+	info := &ToolInfo{Number: num, Fields: make(map[string]string)}
+	fields := words[1:]
+	for i, p := range parts[1:] {
+		if i == len(parts)-2 && !strings.Contains(p, "=") {
+			info.Description = strings.TrimSpace(p)
+			continue
+		}
+		fields = append(fields, strings.Fields(p)...)
+	}
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return false
+		}
+		info.Fields[strings.ToUpper(kv[0])] = kv[1]
+	}
+	info.Diameter, _ = strconv.ParseFloat(info.Fields["D"], 64)
+	info.CornerRadius, _ = strconv.ParseFloat(info.Fields["CR"], 64)
+	t.tools[num] = info
+	return true
+}
+
+// extractSetting
+// ; layer_height = 0.2 of PrusaSlicer and ;Layer height: 0.2 or
+// ;FLAVOR:Marlin of the Cura header.
+func extractSetting(t *ParseTree, m *Meta, text string) bool {
+	sep := " = "
+	i := strings.Index(text, sep)
+	if i < 0 {
+		sep = ":"
+		i = strings.Index(text, sep)
+	}
+	if i <= 0 {
+		return false
+	}
+	key := strings.TrimSpace(text[:i])
+	if strings.HasSuffix(key, "_config") {
+		// the begin and end of the PrusaSlicer block
+		return true
+	}
+	t.slicer[key] = strings.TrimSpace(text[i+len(sep):])
+	return true
+}
+
+// commentValue
+// The rest of the comment after the prefix, matched in any case.
+func commentValue(text string, prefix string) (string, bool) {
+	if len(text) < len(prefix) || !strings.EqualFold(text[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(text[len(prefix):]), true
+}
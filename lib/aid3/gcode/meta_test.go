@@ -0,0 +1,179 @@
+package gcode
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// metaOf
+// The command's source then its layer, Z, height, feature, operation
+// and tool number, T0 with no tool described.
+func metaOf(c *Cmd) string {
+	m := c.Meta()
+	tool := 0
+	if m.Tool != nil {
+		tool = m.Tool.Number
+	}
+	return fmt.Sprintf("%v %v %v %v %v|%v|T%v", c.Src(), m.Layer, m.Z, m.Height, m.Feature, m.Operation, tool)
+}
+
+func TestExtractMeta(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		metas    []string
+		settings map[string]string
+		tools    map[int]ToolInfo
+	}{
+		{
+			name: "cura",
+			src: `;FLAVOR:Marlin
+;TIME:1234
+;Layer height: 0.2
+;Generated with Cura_SteamEngine 5.0.0
+M140 S60
+;LAYER_COUNT:2
+;LAYER:0
+G0 X10 Y10 Z0.2
+;TYPE:WALL-OUTER
+G1 X20 Y10 E1
+;LAYER:1
+G0 Z0.4
+G1 X20 Y20 E2
+`,
+			metas: []string{
+				"M140 -1 0 0 ||T0",
+				"G0 0 0 0 ||T0",
+				"G1 0 0 0 WALL-OUTER||T0",
+				"G0 1 0 0 WALL-OUTER||T0",
+				"G1 1 0 0 WALL-OUTER||T0",
+			},
+			settings: map[string]string{"FLAVOR": "Marlin", "TIME": "1234", "Layer height": "0.2", "LAYER_COUNT": "2"},
+		},
+		{
+			name: "prusaslicer",
+			src: `; generated by PrusaSlicer 2.6.0
+;LAYER_CHANGE
+;Z:0.2
+;HEIGHT:0.2
+G1 Z.2 F720
+;TYPE:External perimeter
+G1 X10 Y10 E.5
+;LAYER_CHANGE
+;Z:0.4
+;HEIGHT:0.2
+G1 Z.4
+M107
+; prusaslicer_config = begin
+; layer_height = 0.2
+; nozzle_diameter = 0.4
+; prusaslicer_config = end
+`,
+			metas: []string{
+				"G1 0 0.2 0.2 ||T0",
+				"G1 0 0.2 0.2 External perimeter||T0",
+				"G1 1 0.4 0.2 External perimeter||T0",
+				"M107 1 0.4 0.2 External perimeter||T0",
+			},
+			settings: map[string]string{"layer_height": "0.2", "nozzle_diameter": "0.4"},
+		},
+		{
+			name: "fusion",
+			src: `%
+O1001
+(T1 D=6 CR=0 - ZMIN=-10 - flat end mill)
+(T2 D=3 CR=1.5 - ball end mill)
+G90 G94 G17
+G21
+(OPERATION 1: FACE)
+T1 M6
+S10000 M3
+G0 X0 Y0
+(OPERATION 2: POCKET)
+T2 M6
+G1 Z-1 F100
+M30
+%
+`,
+			metas: []string{
+				"O1001 -1 0 0 ||T0",
+				"G90 -1 0 0 ||T0",
+				"G94 -1 0 0 ||T0",
+				"G17 -1 0 0 ||T0",
+				"G21 -1 0 0 ||T0",
+				"T1 -1 0 0 |FACE|T1",
+				"M6 -1 0 0 |FACE|T1",
+				"S10000 -1 0 0 |FACE|T1",
+				"M3 -1 0 0 |FACE|T1",
+				"G0 -1 0 0 |FACE|T1",
+				"T2 -1 0 0 |POCKET|T2",
+				"M6 -1 0 0 |POCKET|T2",
+				"G1 -1 0 0 |POCKET|T2",
+				"M30 -1 0 0 |POCKET|T2",
+			},
+			settings: map[string]string{},
+			tools: map[int]ToolInfo{
+				1: {Number: 1, Diameter: 6, Description: "flat end mill", Fields: map[string]string{"D": "6", "CR": "0", "ZMIN": "-10"}},
+				2: {Number: 2, Diameter: 3, CornerRadius: 1.5, Description: "ball end mill", Fields: map[string]string{"D": "3", "CR": "1.5"}},
+			},
+		},
+		{
+			name: "none",
+			src: `G21 G90
+G0 X0 Y0 Z5
+G1 Z-1 F100
+`,
+			metas: []string{
+				"G21 -1 0 0 ||T0",
+				"G90 -1 0 0 ||T0",
+				"G0 -1 0 0 ||T0",
+				"G1 -1 0 0 ||T0",
+			},
+			settings: map[string]string{},
+		},
+		{
+			name: "plain comments",
+			src: `(rough the pocket, keep it slow)
+G21 G90
+G0 X0 Y0 Z5 ; over the corner
+G1 Z-1 F100
+`,
+			metas: []string{
+				"G21 -1 0 0 ||T0",
+				"G90 -1 0 0 ||T0",
+				"G0 -1 0 0 ||T0",
+				"G1 -1 0 0 ||T0",
+			},
+			settings: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := parseSrc(t, tt.src)
+			var metas []string
+			for _, c := range cmdsOf(tree) {
+				metas = append(metas, metaOf(c))
+			}
+			if !reflect.DeepEqual(metas, tt.metas) {
+				t.Errorf("Metas → Expected: %q, Got: %q", tt.metas, metas)
+			}
+			if !reflect.DeepEqual(tree.SlicerSettings(), tt.settings) {
+				t.Errorf("SlicerSettings → Expected: %v, Got: %v", tt.settings, tree.SlicerSettings())
+			}
+			for num := 1; num <= 2; num++ {
+				want, ok := tt.tools[num]
+				got := tree.Tool(num)
+				if !ok {
+					if got != nil {
+						t.Errorf("Tool(%v) → Expected: nil, Got: %+v", num, got)
+					}
+					continue
+				}
+				if got == nil || !reflect.DeepEqual(*got, want) {
+					t.Errorf("Tool(%v) → Expected: %+v, Got: %+v", num, want, got)
+				}
+			}
+		})
+	}
+}
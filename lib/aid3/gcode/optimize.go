@@ -0,0 +1,507 @@
+package gcode
+
+import (
+	"math"
+)
+
+// OptimizeConfig
+// How far the optimized path may stray from the one it replaces, in
+// mm.  Arcs are fitted over MinSegments or more G1 segments, the ones
+// flatter than MaxRadius are left to be merged as lines.
+type OptimizeConfig struct {
+	Tolerance   float64
+	Arcs        bool
+	MinSegments int
+	MaxRadius   float64
+	Decimals    int // of the center offsets and extrusion an optimization writes
+}
+
+func MakeOptimizeConfig() *OptimizeConfig {
+	return &OptimizeConfig{Tolerance: 0.01, Arcs: true, MinSegments: 3, MaxRadius: 5000, Decimals: 4}
+}
+
+// OptimizeStats
+// The commands before and after, and the segments merged into lines
+// and fitted as arcs.
+type OptimizeStats struct {
+	Before   int `json:"before"`
+	After    int `json:"after"`
+	Segments int `json:"segments"`
+	Lines    int `json:"lines"`
+	Arcs     int `json:"arcs"`
+}
+
+// an arc ending near its start could be read as a full circle
+const maxSweep = 1.9 * math.Pi
+
+// plane
+// The axes of a plane as u, v and the normal n, u × v along n so a
+// positive turn in u, v is G3.
+type plane struct {
+	u, v, n int
+	cmd     int
+	src     string
+}
+
+var planes = []*plane{
+	{0, 1, 2, CMD_PLANE_XY, "G17"},
+	{2, 0, 1, CMD_PLANE_XZ, "G18"},
+	{1, 2, 0, CMD_PLANE_YZ, "G19"},
+}
+
+// optimizer
+// The G1 moves waiting to be replaced and the program state they are
+// read in.
+type optimizer struct {
+	cfg   *OptimizeConfig
+	out   *ParseTree
+	stats *OptimizeStats
+
+	run   []*Cmd
+	start *Coords
+	pts   [][3]float64
+	ext   []float64 // the extrusion at each point, summed in M83
+	hasE  bool
+
+	known     bool // the position before the command is the last move's
+	plane     int
+	outPlane  int // the plane as the optimized program has it
+	inch      bool
+	inverse   bool
+	diameter  bool
+	relativeE bool
+	e         float64
+	prev      *Coords
+	machine   int // the line of the last G53
+}
+
+// Optimize
+// An equivalent program with the runs of G1 moves merged where they
+// are collinear and fitted with arcs in any of the planes where they
+// are circular, each within the tolerance of the points and segments
+// it replaces.  A run keeps to one feed and one slicer layer or CAM
+// operation, the comments of its first move stay with the first move
+// made of it, and it stops at an N block, where a cycle may refer to
+// it.  In G93 each move has its own time and nothing is merged, in G7
+// X is a diameter and arcs are not fitted.
+func (t *ParseTree) Optimize(cfg *OptimizeConfig) (*ParseTree, *OptimizeStats) {
+	o := &optimizer{
+		cfg:   cfg,
+		stats: &OptimizeStats{},
+		out: &ParseTree{
+			settings: t.settings,
+			nodes:    t.nodes,
+			stk:      &Stk{},
+			cmds:     &CmdList{},
+			slicer:   t.slicer,
+			tools:    t.tools,
+		},
+		plane:    CMD_PLANE_XY,
+		outPlane: CMD_PLANE_XY,
+		prev:     &Coords{},
+		known:    true,
+		machine:  -1,
+	}
+	t.TraverseCmds(func(cn *CmdNode) error {
+		c := cn.Cmd
+		o.stats.Before++
+		switch {
+		case o.joins(c):
+			o.add(c)
+		case o.starts(c):
+			o.flush()
+			o.start = o.prev
+			o.pts = [][3]float64{{o.prev.X, o.prev.Y, o.prev.Z}}
+			o.ext = []float64{o.e}
+			o.add(c)
+		default:
+			o.flush()
+			o.emit(c)
+		}
+		o.update(c)
+		return nil
+	})
+	o.flush()
+	return o.out, o.stats
+}
+
+// onlyWords
+// True when the command gives no words but these.
+func onlyWords(c *Coords, ws string) bool {
+	mask := uint32(0)
+	for i := 0; i < len(ws); i++ {
+		mask |= 1 << (ws[i] - 'A')
+	}
+	return c.words&^mask == 0
+}
+
+// starts
+// A G1 which can start a run, from where the last move left off.
+func (o *optimizer) starts(c *Cmd) bool {
+	return c.c == CMD_LINEAR && o.known && !o.inverse && !c.coords.Reads() && onlyWords(c.coords, "XYZEF")
+}
+
+// joins
+// A G1 which continues the run.
+func (o *optimizer) joins(c *Cmd) bool {
+	if len(o.run) == 0 || !o.starts(c) || len(c.comments) > 0 || c.seq != 0 {
+		return false
+	}
+	first := o.run[0]
+	return c.coords.F == first.coords.F && c.meta == first.meta
+}
+
+func (o *optimizer) add(c *Cmd) {
+	o.run = append(o.run, c)
+	o.pts = append(o.pts, [3]float64{c.coords.X, c.coords.Y, c.coords.Z})
+	o.ext = append(o.ext, o.extrusion(c))
+	o.hasE = o.hasE || c.coords.Has('E')
+}
+
+// extrusion
+// Where the extruder is after the command.
+func (o *optimizer) extrusion(c *Cmd) float64 {
+	if !c.coords.Has('E') {
+		return o.e
+	}
+	if o.relativeE {
+		return o.e + c.coords.E
+	}
+	return c.coords.E
+}
+
+// update
+// The state after the command.
+func (o *optimizer) update(c *Cmd) {
+	switch c.c {
+	case CMD_PLANE_XY, CMD_PLANE_XZ, CMD_PLANE_YZ:
+		o.plane = c.c
+	case CMD_INCH:
+		o.inch = true
+	case CMD_MM:
+		o.inch = false
+	case CMD_INVERSE_TIME_FEED:
+		o.inverse = true
+	case CMD_FEED_PER_MIN_MODE, CMD_FEED_PER_REVOLUTION:
+		o.inverse = false
+	case CMD_DIAMETER_MODE:
+		o.diameter = true
+	case CMD_RADIUS_MODE:
+		o.diameter = false
+	case CMD_EXTRUDE_ABSOLUTE:
+		o.relativeE = false
+	case CMD_EXTRUDE_RELATIVE:
+		o.relativeE = true
+	case CMD_FAST, CMD_LINEAR, CMD_CW_ARC, CMD_CCW_ARC:
+		// U and W move from where the lathe is, not to X and Z, G53 to
+		// where the machine is and a parameter to where a probe tripped
+		o.known = !c.coords.Has('U') && !c.coords.Has('W') && c.Line() != o.machine && !c.coords.Reads()
+		o.e = o.extrusion(c)
+	case CMD_SET_POSITION:
+		if c.coords.Has('E') {
+			o.e = c.coords.E
+		}
+		o.known = o.known && !c.coords.Has('X') && !c.coords.Has('Y') && !c.coords.Has('Z')
+	case CMD_WORK_OFFSET, CMD_MACHINE_COORDS:
+		// the same words go somewhere else
+		o.known = false
+		if c.c == CMD_MACHINE_COORDS {
+			o.machine = c.Line()
+		}
+	case CMD_RETRACT:
+		o.known = o.known && !c.coords.Has('L')
+	case CMD_PASS:
+		// a drilling cycle ends where its retract leaves it
+		o.known = o.known && !c.coords.Has('X') && !c.coords.Has('Y') && !c.coords.Has('Z')
+	case CMD_HOME, CMD_BED_LEVEL, CMD_PROBE, CMD_FINISH_CYCLE, CMD_TURN_CYCLE, CMD_FACE_CYCLE, CMD_PATTERN_CYCLE,
+		CMD_FACE_PECK_CYCLE, CMD_GROOVE_CYCLE, CMD_THREAD_CYCLE:
+		o.known = false
+	}
+	o.prev = c.coords
+}
+
+func (o *optimizer) emit(c *Cmd) {
+	switch c.c {
+	case CMD_PLANE_XY, CMD_PLANE_XZ, CMD_PLANE_YZ:
+		o.outPlane = c.c
+	}
+	o.out.cmds.AddCmd(c)
+	o.stats.After++
+}
+
+// fromMm
+// A length in mm in the program units.
+func (o *optimizer) fromMm(v float64) float64 {
+	if o.inch {
+		return v / 25.4
+	}
+	return v
+}
+
+// flush
+// Replace the run with as few moves as fit it.
+func (o *optimizer) flush() {
+	if len(o.run) == 0 {
+		return
+	}
+	n := len(o.run)
+	for i := 0; i < n; {
+		j := o.fitLine(i)
+		if o.cfg.Arcs && !o.diameter {
+			if k, p, center, ccw := o.fitArc(i); k > j {
+				o.emitArc(i, k, p, center, ccw)
+				i = k
+				continue
+			}
+		}
+		if j == i+1 {
+			o.emit(o.run[i])
+		} else {
+			o.emitLine(i, j)
+		}
+		i = j
+	}
+	if o.outPlane != o.plane {
+		// back to the program's plane for the arcs of its own
+		for _, p := range planes {
+			if p.cmd == o.plane {
+				o.emit(o.madeCmd(p.cmd, p.src, 0, 0, carryForward(o.run[n-1].coords)))
+			}
+		}
+	}
+	o.run, o.pts, o.ext, o.hasE = nil, nil, nil, false
+}
+
+// madeCmd
+// A command of the optimization, at the line and column of a command
+// it replaces.
+func (o *optimizer) madeCmd(kind int, src string, line int, col int, coords *Coords) *Cmd {
+	return &Cmd{
+		c:      kind,
+		t:      &Tok{src: src, tokType: TOK_G, lnPos: line, stPos: col},
+		coords: coords,
+	}
+}
+
+// spanCmd
+// The move to point j, from point i, with the words that changed.
+// The first move made of the run has the comments and block number of
+// the run's first command.
+func (o *optimizer) spanCmd(kind int, src string, i int, j int) *Cmd {
+	last := o.run[j-1]
+	coords := carryForward(last.coords)
+	for a, w := range []byte("XYZ") {
+		if o.pts[j][a] != o.pts[i][a] {
+			coords.mark(w, ".")
+		}
+	}
+	extrudes := false
+	for _, c := range o.run[i:j] {
+		if c.coords.Has('F') {
+			coords.words |= 1 << ('F' - 'A')
+			coords.points |= c.coords.points & (1 << ('F' - 'A'))
+		}
+		extrudes = extrudes || c.coords.Has('E')
+	}
+	if extrudes {
+		coords.E = o.ext[j]
+		if o.relativeE {
+			coords.E = o.round(o.ext[j] - o.ext[i])
+		}
+		coords.mark('E', ".")
+	}
+	first := o.run[i]
+	ret := o.madeCmd(kind, src, first.Line(), first.Column(), coords)
+	ret.meta = first.meta
+	if i == 0 {
+		ret.comments = first.comments
+		ret.seq = first.seq
+	}
+	o.stats.Segments += j - i
+	return ret
+}
+
+func (o *optimizer) round(v float64) float64 {
+	return roundTo(v, o.cfg.Decimals)
+}
+
+// roundTo
+// v to the decimal places, as it is written.
+func roundTo(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	if r := math.Round(v*scale) / scale; r != 0 {
+		return r
+	}
+	// not -0
+	return 0
+}
+
+func (o *optimizer) emitLine(i int, j int) {
+	o.emit(o.spanCmd(CMD_LINEAR, "G1", i, j))
+	o.stats.Lines++
+}
+
+func (o *optimizer) emitArc(i int, j int, p *plane, center [3]float64, ccw bool) {
+	if o.outPlane != p.cmd {
+		at := o.start
+		if i > 0 {
+			at = o.run[i-1].coords
+		}
+		o.emit(o.madeCmd(p.cmd, p.src, 0, 0, carryForward(at)))
+	}
+	kind, src := CMD_CW_ARC, "G2"
+	if ccw {
+		kind, src = CMD_CCW_ARC, "G3"
+	}
+	c := o.spanCmd(kind, src, i, j)
+	offsets := []float64{0, 0, 0}
+	for _, a := range []int{p.u, p.v} {
+		offsets[a] = o.round(center[a] - o.pts[i][a])
+		c.coords.mark("IJK"[a], ".")
+	}
+	c.coords.I, c.coords.J, c.coords.K = offsets[0], offsets[1], offsets[2]
+	o.emit(c)
+	o.stats.Arcs++
+}
+
+// sameRate
+// True when the segments from point i to j extrude alike for their
+// length, or none do.
+func (o *optimizer) sameRate(i int, j int) bool {
+	if !o.hasE {
+		return true
+	}
+	total := dist(o.pts[i], o.pts[j])
+	rate := 0.0
+	if total > 0 {
+		rate = (o.ext[j] - o.ext[i]) / total
+	}
+	for k := i; k < j; k++ {
+		want := rate * dist(o.pts[k], o.pts[k+1])
+		if math.Abs(o.ext[k+1]-o.ext[k]-want) > 0.02*math.Abs(want)+1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+// fitLine
+// The furthest point a line from point i passes within tolerance of,
+// going one way.  The next point at least.
+func (o *optimizer) fitLine(i int) int {
+	tol := o.fromMm(o.cfg.Tolerance)
+	best := i + 1
+	for j := i + 2; j < len(o.pts); j++ {
+		if !o.onLine(i, j, tol) || !o.sameRate(i, j) {
+			break
+		}
+		best = j
+	}
+	return best
+}
+
+func (o *optimizer) onLine(i int, j int, tol float64) bool {
+	a, b := o.pts[i], o.pts[j]
+	d := sub(b, a)
+	l := math.Sqrt(dot(d, d))
+	along := 0.0
+	for k := i + 1; k < j; k++ {
+		t := 0.0
+		if l > 0 {
+			t = dot(sub(o.pts[k], a), d) / (l * l)
+		}
+		// no further back than the tolerance, nor past the end
+		if (along-t)*l > tol || t > 1 {
+			return false
+		}
+		along = math.Max(along, t)
+		on := [3]float64{a[0] + t*d[0], a[1] + t*d[1], a[2] + t*d[2]}
+		if dist(on, o.pts[k]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// fitArc
+// The furthest point an arc from point i fits to, in the plane its
+// points keep to.  The arc passes within tolerance of each point and
+// of each segment, which keeps it tangent to the path, and turns one
+// way less than a full circle.  i when no arc fits.
+func (o *optimizer) fitArc(i int) (int, *plane, [3]float64, bool) {
+	tol := o.fromMm(o.cfg.Tolerance)
+	maxR := o.fromMm(o.cfg.MaxRadius)
+	best, bestPlane, bestCenter, bestCcw := i, (*plane)(nil), [3]float64{}, false
+	for _, p := range planes {
+		for j := i + o.cfg.MinSegments; j < len(o.pts); j++ {
+			center, ccw, ok := o.arcThrough(p, i, j, tol, maxR)
+			if !ok || !o.sameRate(i, j) {
+				break
+			}
+			if j > best {
+				best, bestPlane, bestCenter, bestCcw = j, p, center, ccw
+			}
+		}
+	}
+	return best, bestPlane, bestCenter, bestCcw
+}
+
+// arcThrough
+// The circle through points i, j and one between, when the points from
+// i to j keep to it in the plane.
+func (o *optimizer) arcThrough(p *plane, i int, j int, tol float64, maxR float64) ([3]float64, bool, bool) {
+	var center [3]float64
+	a, b, c := o.pts[i], o.pts[(i+j)/2], o.pts[j]
+	ax, ay := a[p.u], a[p.v]
+	bx, by := b[p.u], b[p.v]
+	cx, cy := c[p.u], c[p.v]
+	d := 2 * (ax*(by-cy) + bx*(cy-ay) + cx*(ay-by))
+	if d == 0 {
+		return center, false, false
+	}
+	a2, b2, c2 := ax*ax+ay*ay, bx*bx+by*by, cx*cx+cy*cy
+	ux := (a2*(by-cy) + b2*(cy-ay) + c2*(ay-by)) / d
+	uy := (a2*(cx-bx) + b2*(ax-cx) + c2*(bx-ax)) / d
+	r := math.Hypot(ax-ux, ay-uy)
+	if r > maxR || r <= tol {
+		return center, false, false
+	}
+	sweep, dir := 0.0, 0.0
+	for k := i; k < j; k++ {
+		sx, sy := o.pts[k][p.u]-ux, o.pts[k][p.v]-uy
+		ex, ey := o.pts[k+1][p.u]-ux, o.pts[k+1][p.v]-uy
+		if o.pts[k+1][p.n] != a[p.n] || math.Abs(math.Hypot(ex, ey)-r) > tol {
+			return center, false, false
+		}
+		// the middle of the segment, the chord sags inside the arc
+		if r-math.Hypot((sx+ex)/2, (sy+ey)/2) > tol {
+			return center, false, false
+		}
+		turn := math.Atan2(sx*ey-sy*ex, sx*ex+sy*ey)
+		if turn*dir < 0 {
+			return center, false, false
+		}
+		if turn != 0 {
+			dir = turn
+		}
+		sweep += math.Abs(turn)
+	}
+	if dir == 0 || sweep > maxSweep {
+		return center, false, false
+	}
+	center[p.u], center[p.v], center[p.n] = ux, uy, a[p.n]
+	return center, dir > 0, true
+}
+
+func sub(a [3]float64, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func dot(a [3]float64, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func dist(a [3]float64, b [3]float64) float64 {
+	d := sub(a, b)
+	return math.Sqrt(dot(d, d))
+}
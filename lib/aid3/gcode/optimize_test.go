@@ -0,0 +1,256 @@
+package gcode
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func parseSrc(t *testing.T, src string) *ParseTree {
+	fileNm := filepath.Join(t.TempDir(), "p.nc")
+	if err := os.WriteFile(fileNm, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := Parse(fileNm)
+	if err != nil {
+		t.Fatalf("Parse → %v", err)
+	}
+	return tree
+}
+
+func writeSrc(t *testing.T, tree *ParseTree) string {
+	var b bytes.Buffer
+	if err := tree.Write(&b); err != nil {
+		t.Fatalf("Write → %v", err)
+	}
+	return b.String()
+}
+
+func cmdsOf(tree *ParseTree) []*Cmd {
+	var ret []*Cmd
+	tree.TraverseCmds(func(cn *CmdNode) error {
+		ret = append(ret, cn.Cmd)
+		return nil
+	})
+	return ret
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	src := `%
+O1000 (T1 D=6 FLAT)
+N10 G21 G90 G17 G54
+T1 M6
+S10000 M3 ; spindle
+G0 X0 Y0 Z5.
+G81 X1 Y1 Z-3 R1 F100
+X2 Y2
+G80
+G1 Z-1 F200
+X10 Y2
+G2 X20 Y2 I5. J0
+G4 P1.
+M117 Done soon
+G28 X Y
+M30
+`
+	tree := parseSrc(t, src)
+	out := writeSrc(t, tree)
+	again := parseSrc(t, out)
+	a, b := cmdsOf(tree), cmdsOf(again)
+	if len(a) != len(b) {
+		t.Fatalf("Commands → Expected: %v, Got: %v\n%v", len(a), len(b), out)
+	}
+	for i := range a {
+		ca, cb := a[i], b[i]
+		if ca.c != cb.c || ca.seq != cb.seq || ca.text != cb.text || *ca.coords != *cb.coords ||
+			strings.Join(ca.comments, "|") != strings.Join(cb.comments, "|") {
+			t.Errorf("Command %v → Expected: %v %+v %q, Got: %v %+v %q", i, ca.Src(), *ca.coords, ca.comments, cb.Src(), *cb.coords, cb.comments)
+		}
+	}
+	if twice := writeSrc(t, again); twice != out {
+		t.Errorf("Rewrite → Expected:\n%v\nGot:\n%v", out, twice)
+	}
+	for _, w := range []string{"O1000\n", "N10 G21 G90 G17 G54\n", "T1 M6\n", "\nX2 Y2\n", "G28 X Y\n", "G4 P1.\n"} {
+		if !strings.Contains(out, w) {
+			t.Errorf("Text → Expected: %q in\n%v", w, out)
+		}
+	}
+}
+
+// pathPoints
+// The path of the moves, the arcs in small steps.
+func pathPoints(tree *ParseTree) [][3]float64 {
+	pl := planes[0]
+	at := [3]float64{}
+	ret := [][3]float64{at}
+	tree.TraverseCmds(func(cn *CmdNode) error {
+		c := cn.Cmd
+		to := [3]float64{c.coords.X, c.coords.Y, c.coords.Z}
+		switch c.c {
+		case CMD_PLANE_XY, CMD_PLANE_XZ, CMD_PLANE_YZ:
+			for _, p := range planes {
+				if p.cmd == c.c {
+					pl = p
+				}
+			}
+		case CMD_LINEAR, CMD_FAST:
+			ret = append(ret, to)
+		case CMD_CW_ARC, CMD_CCW_ARC:
+			off := [3]float64{c.coords.I, c.coords.J, c.coords.K}
+			cu, cv := at[pl.u]+off[pl.u], at[pl.v]+off[pl.v]
+			a0 := math.Atan2(at[pl.v]-cv, at[pl.u]-cu)
+			a1 := math.Atan2(to[pl.v]-cv, to[pl.u]-cu)
+			r := math.Hypot(at[pl.u]-cu, at[pl.v]-cv)
+			if c.c == CMD_CCW_ARC && a1 <= a0 {
+				a1 += 2 * math.Pi
+			}
+			if c.c == CMD_CW_ARC && a1 >= a0 {
+				a1 -= 2 * math.Pi
+			}
+			for k := 1; k <= 200; k++ {
+				a := a0 + (a1-a0)*float64(k)/200
+				p := at
+				p[pl.u], p[pl.v] = cu+r*math.Cos(a), cv+r*math.Sin(a)
+				ret = append(ret, p)
+			}
+		default:
+			return nil
+		}
+		at = to
+		return nil
+	})
+	return ret
+}
+
+// strays
+// How far the furthest point and segment middle of the one path is
+// from the other path.
+func strays(from [][3]float64, to [][3]float64) float64 {
+	near := func(p [3]float64) float64 {
+		best := math.Inf(1)
+		for k := 0; k+1 < len(to); k++ {
+			a, d := to[k], sub(to[k+1], to[k])
+			t := 0.0
+			if l2 := dot(d, d); l2 > 0 {
+				t = math.Max(0, math.Min(1, dot(sub(p, a), d)/l2))
+			}
+			best = math.Min(best, dist(p, [3]float64{a[0] + t*d[0], a[1] + t*d[1], a[2] + t*d[2]}))
+		}
+		return best
+	}
+	worst := 0.0
+	for k := range from {
+		worst = math.Max(worst, near(from[k]))
+		if k+1 < len(from) {
+			mid := [3]float64{(from[k][0] + from[k+1][0]) / 2, (from[k][1] + from[k+1][1]) / 2, (from[k][2] + from[k+1][2]) / 2}
+			worst = math.Max(worst, near(mid))
+		}
+	}
+	return worst
+}
+
+// segments
+// G1 moves along a circle about cu, cv of radius r in the plane, then
+// a line.
+func segments(p *plane, cu float64, cv float64, r float64, from float64, to float64, n int) string {
+	var b strings.Builder
+	for k := 0; k <= n; k++ {
+		a := (from + (to-from)*float64(k)/float64(n)) * math.Pi / 180
+		pt := [3]float64{}
+		pt[p.u], pt[p.v], pt[p.n] = cu+r*math.Cos(a), cv+r*math.Sin(a), -1
+		word := "G1"
+		if k == 0 {
+			word = "G0"
+		}
+		fmt.Fprintf(&b, "%v X%.4f Y%.4f Z%.4f\n", word, pt[0], pt[1], pt[2])
+	}
+	return b.String()
+}
+
+func TestOptimizeArcs(t *testing.T) {
+	src := "G21 G90 G17\nG0 Z5\n" + segments(planes[0], 0, 0, 10, 0, 270, 90) + "G1 F300\n"
+	for k := 1; k <= 10; k++ {
+		src += fmt.Sprintf("G1 X%v Y-10\n", k*2)
+	}
+	src += "G1 X20 Y-5\nM30\n"
+	tree := parseSrc(t, src)
+	cfg := MakeOptimizeConfig()
+	opt, stats := tree.Optimize(cfg)
+	if stats.Arcs < 1 || stats.Lines < 1 || stats.After*4 > stats.Before {
+		t.Errorf("Stats → Expected: arcs, a line and a quarter the commands, Got: %+v", stats)
+	}
+	again := parseSrc(t, writeSrc(t, opt))
+	for _, c := range cmdsOf(again) {
+		if c.c == CMD_CW_ARC {
+			t.Errorf("Direction → Expected: G3, Got: G2 at %+v", *c.coords)
+		}
+	}
+	if d := strays(pathPoints(tree), pathPoints(again)); d > cfg.Tolerance+1e-4 {
+		t.Errorf("Strays → Expected: under %v, Got: %v", cfg.Tolerance, d)
+	}
+	if d := strays(pathPoints(again), pathPoints(tree)); d > cfg.Tolerance+1e-4 {
+		t.Errorf("Strays back → Expected: under %v, Got: %v", cfg.Tolerance, d)
+	}
+
+	cfg.Arcs = false
+	_, stats = tree.Optimize(cfg)
+	if stats.Arcs != 0 || stats.Lines != 1 {
+		t.Errorf("Lines only → Expected: 0 arcs 1 line, Got: %+v", stats)
+	}
+}
+
+func TestOptimizePlanes(t *testing.T) {
+	for _, p := range planes[1:] {
+		src := "G21 G90 G17\n" + segments(p, 0, 0, 5, 180, 0, 36) + "M30\n"
+		tree := parseSrc(t, src)
+		opt, stats := tree.Optimize(MakeOptimizeConfig())
+		out := writeSrc(t, opt)
+		if stats.Arcs != 1 || !strings.Contains(out, p.src+"\nG2 ") || !strings.HasSuffix(out, "G17\nM30\n") {
+			t.Errorf("%v → Expected: one G2 in the plane and G17 after, Got: %+v\n%v", p.src, stats, out)
+		}
+		if d := strays(pathPoints(tree), pathPoints(parseSrc(t, out))); d > 0.01+1e-4 {
+			t.Errorf("%v strays → Expected: under 0.01, Got: %v", p.src, d)
+		}
+	}
+}
+
+func TestOptimizeKeeps(t *testing.T) {
+	src := `G21 G90
+G0 X0 Y0
+G1 X1 F100
+G1 X2
+G1 X3 F200
+G1 X4
+;keep me
+G1 X5
+G1 X6
+G93
+G1 X7 F10
+G1 X8 F10
+G1 X9 F10
+G94
+M83
+G1 X10 E0.5 F100
+G1 X11 E0.5
+G1 X12 E0.5
+G1 X13 E1
+M30
+`
+	tree := parseSrc(t, src)
+	opt, stats := tree.Optimize(MakeOptimizeConfig())
+	out := writeSrc(t, opt)
+	want := []string{"G1 X2. F100\n", "G1 X4. F200\n", ";keep me\nG1 X6.\n", "G1 X7 F10\nG1 X8 F10\nG1 X9 F10\n",
+		"G1 X12. E1.5 F100\nG1 X13 E1\n"}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("Output → Expected: %q in\n%v", w, out)
+		}
+	}
+	if stats.Arcs != 0 || stats.Lines != 4 {
+		t.Errorf("Stats → Expected: 4 lines, Got: %+v", stats)
+	}
+}
@@ -0,0 +1,137 @@
+package gcode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The numbered parameters a word can read, where the last probe
+// tripped and 1 when it did.
+const (
+	PARAM_PROBE_X  = 5061
+	PARAM_PROBE_Y  = 5062
+	PARAM_PROBE_Z  = 5063
+	PARAM_PROBE_OK = 5070
+)
+
+// the words which can read a parameter
+const paramWords = "XYZABCIJKRUW"
+
+// readable
+// The parameters a word can read, those a probe sets.
+func readable(n int) bool {
+	return (n >= PARAM_PROBE_X && n <= PARAM_PROBE_Z) || n == PARAM_PROBE_OK
+}
+
+// readsParam
+// X#5061 or X-#5061, a word reading a probe parameter.  The word is
+// given as 0 and the parameter is added in as the program runs, only in
+// G90 as what G91 would add it to is not known until then.
+func readsParam(tree *ParseTree, n *Node) error {
+	t := n.t
+	i := strings.IndexByte(t.src, '#')
+	sign := 1
+	if i == 2 && t.src[1] == '-' {
+		sign = -1
+	} else if i != 1 {
+		return genErr(fmt.Sprintf("Could not parse %v @ %v, a parameter is read as a word's value", t.src, t.lnPos))
+	}
+	w := t.src[0]
+	num, err := strconv.Atoi(t.src[i+1:])
+	if err != nil || !readable(num) || strings.IndexByte(paramWords, w) < 0 {
+		return genErr(fmt.Sprintf("Could not parse %v @ %v, only %v read #5061 to #5063 and #5070", t.src, t.lnPos, paramWords))
+	}
+	if tree.settings.incremental {
+		return genErr(fmt.Sprintf("Could not parse %v @ %v, parameters are read in G90", t.src, t.lnPos))
+	}
+	word := &Tok{src: string(w) + "0", tokType: t.tokType, lnPos: t.lnPos, stPos: t.stPos}
+	if err := HandleToken(tree, &Node{t: word}); err != nil {
+		return genErr(fmt.Sprintf("Could not parse %v @ %v", t.src, t.lnPos))
+	}
+	tree.curCmd.coords.read(w, sign*num)
+	return nil
+}
+
+// read
+// The word reads the parameter, negated when num is negative.
+func (c *Coords) read(w byte, num int) {
+	c.params[strings.IndexByte(paramWords, w)] = num
+	c.reads |= 1 << (w - 'A')
+}
+
+// unread
+// The word is a number again.
+func (c *Coords) unread(w byte) {
+	c.params[strings.IndexByte(paramWords, w)] = 0
+	c.reads &^= 1 << (w - 'A')
+}
+
+// Param
+// The parameter the word reads, given or carried, negative when it is
+// read negated and 0 when the word is a number.
+func (c *Coords) Param(w byte) int {
+	if i := strings.IndexByte(paramWords, w); i >= 0 {
+		return c.params[i]
+	}
+	return 0
+}
+
+// Reads
+// True when a word, given or carried from an earlier command, reads a
+// parameter, where the command goes is only known as it runs.
+func (c *Coords) Reads() bool {
+	return c.params != [len(paramWords)]int{}
+}
+
+// Read
+// The command with the parameters its words read added in, itself when
+// it reads none.
+func (c *Cmd) Read(params map[int]float64) *Cmd {
+	if !c.coords.Reads() {
+		return c
+	}
+	nc := *c
+	co := *c.coords
+	for i, num := range c.coords.params {
+		v := params[num]
+		if num < 0 {
+			v = -params[-num]
+		}
+		co.set(paramWords[i], co.value(paramWords[i])+v)
+	}
+	co.params, co.reads = [len(paramWords)]int{}, 0
+	nc.coords = &co
+	return &nc
+}
+
+// set
+// The value of a word which can read a parameter.
+func (c *Coords) set(w byte, v float64) {
+	switch w {
+	case 'X':
+		c.X = v
+	case 'Y':
+		c.Y = v
+	case 'Z':
+		c.Z = v
+	case 'A':
+		c.A = v
+	case 'B':
+		c.B = v
+	case 'C':
+		c.C = v
+	case 'I':
+		c.I = v
+	case 'J':
+		c.J = v
+	case 'K':
+		c.K = v
+	case 'R':
+		c.R = v
+	case 'U':
+		c.U = v
+	case 'W':
+		c.W = v
+	}
+}
@@ -28,15 +28,21 @@ const (
 	TOK_I
 	TOK_J
 	TOK_K
+	TOK_L
 	TOK_M
 	TOK_N
 	TOK_O
+	TOK_P
+	TOK_Q
 	TOK_R
 	TOK_S
 	TOK_T
+	TOK_U
+	TOK_W
 	TOK_X
 	TOK_Y
 	TOK_Z
+	TOK_MESSAGE
 )
 const (
 	CLS_WS = iota
@@ -51,6 +57,7 @@ const (
 	CMD_UNKN = iota
 	CMD_META
 	CMD_ABSOLUTE
+	CMD_INCREMENTAL
 	CMD_FAST
 	CMD_LINEAR
 	CMD_CW_ARC
@@ -70,16 +77,82 @@ const (
 	CMD_PLANE_YZ
 	CMD_INCH
 	CMD_MM
+	CMD_SPINDLE_ORIENT
+	CMD_SPINDLE_GEAR
+	CMD_COOLANT_MIST
+	CMD_COOLANT_THROUGH
+	CMD_COOLANT_THROUGH_OFF
+	CMD_AIR_ON
+	CMD_AIR_OFF
+	CMD_VACUUM_ON
+	CMD_VACUUM_OFF
+	CMD_DIGITAL_OUT
+	CMD_ANALOG_OUT
+	CMD_WAIT_INPUT
+	CMD_TOOL_LENGTH
+	CMD_TOOL_LENGTH_OFF
+	CMD_TCP
+	CMD_DIAMETER_MODE
+	CMD_RADIUS_MODE
+	CMD_MAX_SPINDLE
+	CMD_CSS
+	CMD_CSS_OFF
+	CMD_FINISH_CYCLE
+	CMD_TURN_CYCLE
+	CMD_FACE_CYCLE
+	CMD_PATTERN_CYCLE
+	CMD_FACE_PECK_CYCLE
+	CMD_GROOVE_CYCLE
+	CMD_THREAD_CYCLE
+	CMD_EXTRUDE_ABSOLUTE
+	CMD_EXTRUDE_RELATIVE
+	CMD_SET_POSITION
+	CMD_HOTEND_TEMP
+	CMD_HOTEND_WAIT
+	CMD_BED_TEMP
+	CMD_BED_WAIT
+	CMD_FAN_ON
+	CMD_FAN_OFF
+	CMD_HOME
+	CMD_BED_LEVEL
+	CMD_LEVELING
+	CMD_MESH_POINT
+	CMD_RETRACT
+	CMD_UNRETRACT
+	CMD_ACCEL
+	CMD_JERK
+	CMD_FEED_OVERRIDE
+	CMD_FLOW_OVERRIDE
+	CMD_MESSAGE
+	CMD_DWELL
+	CMD_PROGRAM_END
+	CMD_SUBPROGRAM_CALL
+	CMD_ROTATE
+	CMD_ROTATE_OFF
+	CMD_SCALE
+	CMD_SCALE_OFF
+	CMD_MIRROR
+	CMD_MIRROR_OFF
+	CMD_POLAR
+	CMD_POLAR_OFF
+	CMD_WORK_OFFSET
+	CMD_MACHINE_COORDS
+	CMD_PROBE
+	CMD_PASS // nothing to simulate, kept to write the program back
 )
 
 var debugTokenize = false
 var debugGcode = false
 
 type Cmd struct {
-	c      int
-	t      *Tok
-	sibs   *Tok
-	coords *Coords
+	c        int
+	t        *Tok
+	sibs     *Tok
+	coords   *Coords
+	seq      int
+	text     string
+	comments []string
+	meta     *Meta
 }
 
 func (c *Cmd) CmdType() int {
@@ -92,6 +165,50 @@ func (c *Cmd) Coords() *Coords {
 	return c.coords
 }
 
+// Line
+// The source line of the command, 0 when unknown.
+func (c *Cmd) Line() int {
+	if c.t == nil {
+		return 0
+	}
+	return c.t.lnPos
+}
+
+// Column
+// Where the command starts on its line, from 1, 0 when unknown.
+func (c *Cmd) Column() int {
+	if c.t == nil {
+		return 0
+	}
+	return c.t.stPos
+}
+
+// Text
+// The message of M117 and M118.
+func (c *Cmd) Text() string {
+	return c.text
+}
+
+// Comments
+// The text of the comments on the line of the command, and on the
+// lines of their own before it.
+func (c *Cmd) Comments() []string {
+	return c.comments
+}
+
+// Meta
+// The layer, feature, operation and tool the command is in, as the
+// slicer or CAM comments put it.
+func (c *Cmd) Meta() *Meta {
+	return c.meta
+}
+
+// Seq
+// The N sequence number of the block, 0 when it has none.
+func (c *Cmd) Seq() int {
+	return c.seq
+}
+
 func (c *Cmd) String() string {
 	if c == nil {
 		return "nil"
@@ -111,7 +228,7 @@ func (c *Cmd) String() string {
 }
 
 type Settings struct {
-	absoluteCoords bool
+	incremental bool // G91, the axis words of a move are from where the program is
 }
 
 type Coords struct {
@@ -132,7 +249,44 @@ type Coords struct {
 	I float64
 	J float64
 	K float64
+	L float64 // what G10 sets, 2 and 20 a work offset
 	R float64
+	S float64 // temperature, fan speed or percentage of a printer command
+	T float64 // travel acceleration of M204, the hotend of M104 and M109
+
+	P float64 // dwell, output pin
+	Q float64 // output value
+
+	U float64 // incremental X on a lathe
+	W float64 // incremental Z on a lathe
+
+	words  uint32 // the letters given on this command
+	points uint32 // the letters written with a decimal point
+	bare   uint32 // the letters given without a value, G28 X
+	reads  uint32 // the letters written reading a parameter, X#5061
+
+	params [len(paramWords)]int // the parameters the words read, given or carried, added to their values
+}
+
+func (c *Coords) mark(w byte, src string) {
+	c.words |= 1 << (w - 'A')
+	if strings.ContainsRune(src, '.') {
+		c.points |= 1 << (w - 'A')
+	}
+}
+
+// Has
+// True when the word was given on the command, rather than carried
+// forward from an earlier one.
+func (c *Coords) Has(w byte) bool {
+	return c.words&(1<<(w-'A')) != 0
+}
+
+// Decimal
+// True when the word was written with a decimal point.  Fanuc reads
+// some cycle words without one in the least increment, µm.
+func (c *Coords) Decimal(w byte) bool {
+	return c.points&(1<<(w-'A')) != 0
 }
 
 type ParseTree struct {
@@ -141,6 +295,15 @@ type ParseTree struct {
 	stk      *Stk
 	cmds     *CmdList
 	curCmd   *Cmd
+
+	seq      int      // the N number of the line being parsed
+	motion   int      // the last of G0 to G3, repeated by lines of only coordinates
+	pending  *Cmd     // the command of a line without a G or M word yet
+	comments []string // comments waiting for the next command
+	held     *Coords  // where the program was before a command whose words are not a position
+
+	slicer map[string]string
+	tools  map[int]*ToolInfo
 }
 
 func (t *ParseTree) TraverseCmds(f func(cn *CmdNode) error) error {
@@ -151,9 +314,69 @@ func (t *ParseTree) TraverseCmds(f func(cn *CmdNode) error) error {
 }
 
 func (t *ParseTree) AddCmd(c *Cmd) {
+	c.seq = t.seq
+	c.comments = append(t.comments, c.comments...)
+	t.comments = nil
 	t.cmds.AddCmd(c)
 }
 
+// comment
+// A comment on the line of a command is the command's, one on a line
+// of its own waits for the next command.
+func (t *ParseTree) comment(tok *Tok) {
+	text := commentText(tok.src)
+	if text == "" {
+		return
+	}
+	if t.cmds.last != nil && t.cmds.last.Cmd == t.curCmd && t.curCmd.Line() == tok.lnPos {
+		t.curCmd.comments = append(t.curCmd.comments, text)
+		return
+	}
+	t.comments = append(t.comments, text)
+}
+
+// commentText
+// The comment without its ; or parentheses.
+func commentText(src string) string {
+	if strings.HasPrefix(src, ";") {
+		return strings.TrimSpace(src[1:])
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(src, "("), ")"))
+}
+
+// Blocks
+// The commands of the blocks from N first through N last, as canned
+// cycles refer to a profile.  Nil when either block is missing.
+func (t *ParseTree) Blocks(first int, last int) []*CmdNode {
+	var ret []*CmdNode
+	cur := t.cmds.head
+	for cur != nil && cur.Cmd.seq != first {
+		cur = cur.Next
+	}
+	for ; cur != nil; cur = cur.Next {
+		if ret != nil && cur.Cmd.seq != last && ret[len(ret)-1].Cmd.seq == last {
+			return ret
+		}
+		ret = append(ret, cur)
+	}
+	if ret == nil || ret[len(ret)-1].Cmd.seq != last {
+		return nil
+	}
+	return ret
+}
+
+// modalMove
+// A line of only coordinates repeats the motion of the last G0 to G3.
+func (t *ParseTree) modalMove(tok *Tok) {
+	if t.curCmd != t.pending || t.motion == CMD_UNKN {
+		return
+	}
+	t.curCmd.c = t.motion
+	t.curCmd.t = tok
+	t.AddCmd(t.curCmd)
+	t.pending = nil
+}
+
 type ParseError struct {
 	msg string
 }
@@ -192,6 +415,11 @@ func Parse(srcFileNm string) (*ParseTree, error) {
 	if err := MakeGcodeCommands(tree); err != nil {
 		return nil, err
 	}
+	if last := tree.cmds.last; last != nil {
+		last.Cmd.comments = append(last.Cmd.comments, tree.comments...)
+		tree.comments = nil
+	}
+	tree.ExtractMeta()
 
 	return tree, nil
 }
@@ -213,12 +441,96 @@ func carryForward(from *Coords) *Coords {
 			F: 0,
 		}
 	}
-	return &Coords{
+	ret := &Coords{
 		X: from.X,
 		Y: from.Y,
 		Z: from.Z,
+		A: from.A,
+		B: from.B,
+		C: from.C,
 		F: from.F,
 	}
+	// an axis which read a parameter is still there
+	for i := 0; i < len(axisWords); i++ {
+		w := axisWords[i]
+		ret.params[strings.IndexByte(paramWords, w)] = from.Param(w)
+	}
+	return ret
+}
+
+// carried
+// The coordinates the next command starts from, those before the last
+// command when its words were a center or an axis rather than a move.
+func (t *ParseTree) carried() *Coords {
+	if t.held != nil {
+		from := t.held
+		t.held = nil
+		return carryForward(from)
+	}
+	return carryForward(t.curCmd.coords)
+}
+
+// hold
+// The words of the command are not where the program is.
+func (t *ParseTree) hold() {
+	t.held = carryForward(t.curCmd.coords)
+}
+
+// takes
+// Printer commands take S and T as their values, elsewhere they are
+// the spindle speed and a tool, as they are on a line of their own.
+func takes(c *Cmd, w byte) bool {
+	if c == nil {
+		return false
+	}
+	switch w {
+	case 'S':
+		switch c.c {
+		case CMD_HOTEND_TEMP, CMD_HOTEND_WAIT, CMD_BED_TEMP, CMD_BED_WAIT, CMD_FAN_ON,
+			CMD_LEVELING, CMD_ACCEL, CMD_FEED_OVERRIDE, CMD_FLOW_OVERRIDE, CMD_DWELL:
+			return true
+		}
+	case 'T':
+		switch c.c {
+		case CMD_ACCEL, CMD_HOTEND_TEMP, CMD_HOTEND_WAIT:
+			return true
+		}
+	}
+	return false
+}
+
+// holds
+// The words of the command are a center or the axes it acts on, not
+// where the program goes.
+func holds(c *Cmd) bool {
+	switch c.c {
+	case CMD_ROTATE, CMD_SCALE, CMD_MIRROR, CMD_MIRROR_OFF, CMD_RETRACT:
+		return true
+	}
+	return false
+}
+
+// goesTo
+// The axis words of the command are where the program goes, those
+// G91 gives from where it was.  A line of only coordinates is a move
+// once it has them.
+func goesTo(c *Cmd) bool {
+	switch c.c {
+	case CMD_FAST, CMD_LINEAR, CMD_CW_ARC, CMD_CCW_ARC, CMD_PROBE, CMD_HOME:
+		return true
+	}
+	return false
+}
+
+// axis
+// The value of an axis word, in G91 added to where the program was
+// for a command going there, and to the parameter it read.
+func (t *ParseTree) axis(w byte, at float64, v float64) float64 {
+	if t.settings.incremental && t.held == nil && (goesTo(t.curCmd) || t.curCmd == t.pending) {
+		return at + v
+	}
+	t.curCmd.coords.unread(w)
+	return v
 }
 
 func HandleToken(tree *ParseTree, n *Node) error {
@@ -239,9 +551,18 @@ func HandleToken(tree *ParseTree, n *Node) error {
 	if debugGcode {
 		log.Printf("Seeing %v\n", t.src)
 	}
+	if homesAxis(tree, t) {
+		return nil
+	}
+	if strings.IndexByte(t.src, '#') >= 0 && t.tokType != TOK_COMMENT && t.tokType != TOK_META && t.tokType != TOK_MESSAGE {
+		return readsParam(tree, n)
+	}
 	switch t.tokType {
 	case TOK_N:
 		// This is the Nth part of the line.
+		if n, err := strconv.Atoi(t.src[1:]); err == nil {
+			tree.seq = n
+		}
 		break
 
 	case TOK_BREAK:
@@ -256,8 +577,10 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			c:      prevType,
 			t:      refTok,
 			sibs:   nil,
-			coords: carryForward(tree.curCmd.coords),
+			coords: tree.carried(),
 		}
+		tree.pending = tree.curCmd
+		tree.seq = 0
 		break
 
 	case TOK_M:
@@ -265,7 +588,7 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			c:      CMD_UNKN,
 			t:      t,
 			sibs:   nil,
-			coords: carryForward(tree.curCmd.coords),
+			coords: tree.carried(),
 		}
 		switch t.src {
 		case "M5", "M05": // Spindle off
@@ -279,7 +602,12 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			break
 
 		case "M1", "M01": // Optional program stop
+			tree.curCmd.c = CMD_PASS
+			tree.AddCmd(tree.curCmd)
+			break
 		case "M2", "M02": // end of program
+			tree.curCmd.c = CMD_PROGRAM_END
+			tree.AddCmd(tree.curCmd)
 			break
 
 		case "M3", "M03": // Spindle on clockwise
@@ -292,27 +620,162 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			tree.AddCmd(tree.curCmd)
 			break
 
-		case "M6", "M06": // Manual tool change
+		case "M6", "M06": // Manual tool change, the T word changes the tool
+			tree.curCmd.c = CMD_PASS
+			tree.AddCmd(tree.curCmd)
 			break
 
 		case "M7", "M07": // Coolant on (mist)
+			tree.curCmd.c = CMD_COOLANT_MIST
+			tree.AddCmd(tree.curCmd)
+			break
+
 		case "M8", "M08": // Coolant on
 			tree.curCmd.c = CMD_COOLANT_ON
 			tree.AddCmd(tree.curCmd)
 			break
 
 		case "M9", "M09": // Coolant off
-			tree.curCmd.c = CMD_COOLANT_ON
+			tree.curCmd.c = CMD_COOLANT_OFF
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M10": // Clamp on, vacuum hold down on a router
+			tree.curCmd.c = CMD_VACUUM_ON
 			tree.AddCmd(tree.curCmd)
 			break
 
-		case "M10": // Clamp on
 		case "M11": // Clamp off
+			tree.curCmd.c = CMD_VACUUM_OFF
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M73": // Tool air blast on
+			tree.curCmd.c = CMD_AIR_ON
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M74": // Tool air blast off
+			tree.curCmd.c = CMD_AIR_OFF
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M88": // Through spindle coolant on
+			tree.curCmd.c = CMD_COOLANT_THROUGH
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M89": // Through spindle coolant off
+			tree.curCmd.c = CMD_COOLANT_THROUGH_OFF
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M62", "M63", "M64", "M65": // Digital output P on, off, synced with motion or now
+			tree.curCmd.c = CMD_DIGITAL_OUT
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M66": // Wait on input P or E
+			tree.curCmd.c = CMD_WAIT_INPUT
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M67", "M68": // Analog output E to Q, synced with motion or now
+			tree.curCmd.c = CMD_ANALOG_OUT
+			tree.AddCmd(tree.curCmd)
+			break
+
 		case "M19": // Spindle orientation
+			tree.curCmd.c = CMD_SPINDLE_ORIENT
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M40", "M41", "M42", "M43", "M44": // Spindle gear middle, low, high...
+			tree.curCmd.c = CMD_SPINDLE_GEAR
+			tree.AddCmd(tree.curCmd)
+			break
+
+		//
+		// Printers
+		//
+		case "M82": // Absolute extrusion
+			tree.curCmd.c = CMD_EXTRUDE_ABSOLUTE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M83": // Relative extrusion
+			tree.curCmd.c = CMD_EXTRUDE_RELATIVE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M104": // Hotend temperature S
+			tree.curCmd.c = CMD_HOTEND_TEMP
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M109": // Hotend temperature S, or R, and wait for it
+			tree.curCmd.c = CMD_HOTEND_WAIT
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M140": // Bed temperature S
+			tree.curCmd.c = CMD_BED_TEMP
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M190": // Bed temperature S, or R, and wait for it
+			tree.curCmd.c = CMD_BED_WAIT
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M106": // Fan on at S of 255
+			tree.curCmd.c = CMD_FAN_ON
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M107": // Fan off
+			tree.curCmd.c = CMD_FAN_OFF
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "M420": // Bed leveling on with S1, off with S0, Z the fade height
+			tree.curCmd.c = CMD_LEVELING
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M421": // Set the bed mesh point I J to Z
+			tree.curCmd.c = CMD_MESH_POINT
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M204": // Acceleration P printing, T travel, R retract, S printing and travel
+			tree.curCmd.c = CMD_ACCEL
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M205": // Jerk X and Y
+			tree.curCmd.c = CMD_JERK
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M220": // Feed override S percent
+			tree.curCmd.c = CMD_FEED_OVERRIDE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M221": // Flow override S percent
+			tree.curCmd.c = CMD_FLOW_OVERRIDE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M117", "M118": // Message on the display, to the host
+			tree.curCmd.c = CMD_MESSAGE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "M18", "M84", "M400", "M105", "M114", "M115", "M500", "M501", "M502", "M503":
+			// Motors off, wait for moves, reports and settings, nothing to simulate
+			tree.curCmd.c = CMD_PASS
+			tree.AddCmd(tree.curCmd)
+			break
+
 		case "M30": // Program end, return to start
-		case "M40": // Spindle gear at middle
+			tree.curCmd.c = CMD_PROGRAM_END
+			tree.AddCmd(tree.curCmd)
+			break
 		case "M98": // Subprogram call
+			tree.curCmd.c = CMD_SUBPROGRAM_CALL
+			tree.AddCmd(tree.curCmd)
+			break
 		case "M99": // Subprogram end
+			tree.curCmd.c = CMD_PASS
+			tree.AddCmd(tree.curCmd)
 			break
 
 		default:
@@ -323,7 +786,7 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			c:      CMD_UNKN,
 			t:      t,
 			sibs:   nil,
-			coords: carryForward(tree.curCmd.coords),
+			coords: tree.carried(),
 		}
 
 		switch t.src {
@@ -350,31 +813,53 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			break
 		case "G00", "G0": // Rapid Positioning of Machine Tool
 			tree.curCmd.c = CMD_FAST
+			tree.motion = CMD_FAST
 			tree.AddCmd(tree.curCmd)
 			break
 
 		case "G01", "G1": // Linear Interpolation
 			tree.curCmd.c = CMD_LINEAR
+			tree.motion = CMD_LINEAR
 			tree.AddCmd(tree.curCmd)
 			break
 
 		case "G02", "G2": // Clockwise Arc Interpolation
 			tree.curCmd.c = CMD_CW_ARC
+			tree.motion = CMD_CW_ARC
 			tree.AddCmd(tree.curCmd)
 			break
 
 		case "G03", "G3": // Counter-clockwise Interpolation
 			tree.curCmd.c = CMD_CCW_ARC
+			tree.motion = CMD_CCW_ARC
 			tree.AddCmd(tree.curCmd)
 			break
 
 		case "G90": // Use absolute coordinates
 			tree.curCmd.c = CMD_ABSOLUTE
+			tree.settings.incremental = false
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "G91": // Use incremental coordinates
+			tree.curCmd.c = CMD_INCREMENTAL
+			tree.settings.incremental = true
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "G07", "G7": // Lathe diameter mode
+			tree.curCmd.c = CMD_DIAMETER_MODE
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "G08", "G8": // Lathe radius mode
+			tree.curCmd.c = CMD_RADIUS_MODE
 			tree.AddCmd(tree.curCmd)
 			break
 
-		case "G08", "G8": // Increment Speed
 		case "G09", "G9": // Decrement Speed (exact stop?)
+			tree.curCmd.c = CMD_PASS
+			tree.AddCmd(tree.curCmd)
 			break
 		//
 		// Speed
@@ -390,26 +875,124 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			break
 
 		case "G95": // Linear Feed Units
-			tree.curCmd.c = CMD_INVERSE_TIME_FEED
+			tree.curCmd.c = CMD_FEED_PER_REVOLUTION
 			tree.AddCmd(tree.curCmd)
 			break
 
 		case "G96": // Constant Surface Speed
+			tree.curCmd.c = CMD_CSS
+			tree.AddCmd(tree.curCmd)
+			break
+
 		case "G97": // Constant Spindle Speed
+			tree.curCmd.c = CMD_CSS_OFF
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "G50": // Maximum spindle speed on a lathe, with S, scaling off on a mill
+			tree.curCmd.c = CMD_MAX_SPINDLE
+			tree.AddCmd(tree.curCmd)
+			break
+
+		//
+		// Controller transforms, modal until cancelled
+		//
+		case "G68": // Rotation about X Y by R degrees in the plane
+			tree.curCmd.c = CMD_ROTATE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G69": // Rotation off
+			tree.curCmd.c = CMD_ROTATE_OFF
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G51": // Scaling about X Y Z by P, or by I J K along each
+			tree.curCmd.c = CMD_SCALE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G51.1": // Mirror the axes given about their values
+			tree.curCmd.c = CMD_MIRROR
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G50.1": // Mirror off for the axes given
+			tree.curCmd.c = CMD_MIRROR_OFF
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G16": // Polar coordinates, the radius and angle on the axes of the plane
+			tree.curCmd.c = CMD_POLAR
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G15": // Polar coordinates off
+			tree.curCmd.c = CMD_POLAR_OFF
+			tree.AddCmd(tree.curCmd)
+			break
+
+		//
+		// Lathe cycles, on a mill G73, G74 and G76 are drilling cycles
+		// which are not simulated
+		//
+		case "G70": // Finishing
+			tree.curCmd.c = CMD_FINISH_CYCLE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G71": // Stock removal in turning
+			tree.curCmd.c = CMD_TURN_CYCLE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G72": // Stock removal in facing
+			tree.curCmd.c = CMD_FACE_CYCLE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G73": // Pattern repeating
+			tree.curCmd.c = CMD_PATTERN_CYCLE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G74": // End face peck drilling
+			tree.curCmd.c = CMD_FACE_PECK_CYCLE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G75": // Outer or inner diameter grooving
+			tree.curCmd.c = CMD_GROOVE_CYCLE
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G76": // Multiple threading
+			tree.curCmd.c = CMD_THREAD_CYCLE
+			tree.AddCmd(tree.curCmd)
+			break
+
 		case "G61": // Exact Stop Mode
-		case "G04": // Wait time
+			tree.curCmd.c = CMD_PASS
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G04", "G4": // Dwell for P seconds, milliseconds with S seconds on a printer
+			tree.curCmd.c = CMD_DWELL
+			tree.AddCmd(tree.curCmd)
+			break
 		//
-		// Drilling
+		// Drilling, the holes of a cycle are not moves
 		//
-		case "G81": // Simple drilling
-		case "G82": // Simple drilling with dwell
-		case "G83": // Deep hole drilling
-		case "G84": // Tapping
+		case "G81", "G82", "G83", "G84": // Simple, dwell, deep hole drilling and tapping
+			// the holes on the lines which follow are kept, not moved to
+			tree.curCmd.c = CMD_PASS
+			tree.motion = CMD_PASS
+			tree.AddCmd(tree.curCmd)
+			break
 		case "G40", "G41", "G42": // Tool Offset Values
+			tree.curCmd.c = CMD_PASS
+			tree.AddCmd(tree.curCmd)
 			break
 
 		case "G43": // Tool Offset Values
-			tree.curCmd.c = CMD_INVERSE_TIME_FEED
+			tree.curCmd.c = CMD_TOOL_LENGTH
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "G43.4", "G43.5": // Tool center point control
+			tree.curCmd.c = CMD_TCP
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "G49": // Cancel tool length offset and tool center point control
+			tree.curCmd.c = CMD_TOOL_LENGTH_OFF
 			tree.AddCmd(tree.curCmd)
 			break
 
@@ -418,25 +1001,84 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			tree.AddCmd(tree.curCmd)
 			break
 
-		case "G53", "G54", "G55", "G56", "G57", "G58", "G59": // Zero Offset Value
-		case "G80", "G85", "G86", "G87", "G88", "G89": // Process Description
+		case "G38.2", "G38.3", "G38.4", "G38.5": // Probe toward the work, the same without an error, away, away without an error
+			// a probe is not repeated by the lines which follow
+			tree.curCmd.c = CMD_PROBE
+			tree.motion = CMD_UNKN
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "G28": // Home, on a mill through the X Y Z given
+			tree.curCmd.c = CMD_HOME
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G29": // Probe the bed for leveling
+			tree.curCmd.c = CMD_BED_LEVEL
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G10": // Firmware retract on a printer, setting offsets with L elsewhere, G10 L2 P1 X Y Z
+			tree.curCmd.c = CMD_RETRACT
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G11": // Firmware recover from a retract
+			tree.curCmd.c = CMD_UNRETRACT
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "G92": // Set the position, on a printer mostly E0
+			tree.curCmd.c = CMD_SET_POSITION
+			tree.AddCmd(tree.curCmd)
+			break
+
+		case "G53": // Machine coordinates for the moves of the block
+			tree.curCmd.c = CMD_MACHINE_COORDS
+			tree.motion = CMD_UNKN
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G54", "G55", "G56", "G57", "G58", "G59": // Zero Offset Value, the work offset in use
+			tree.curCmd.c = CMD_WORK_OFFSET
+			tree.motion = CMD_UNKN
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G80": // Cancel the drilling cycle
+			tree.curCmd.c = CMD_PASS
+			tree.motion = CMD_UNKN
+			tree.AddCmd(tree.curCmd)
+			break
+		case "G85", "G86", "G87", "G88", "G89": // Boring cycles
+			tree.curCmd.c = CMD_PASS
+			tree.motion = CMD_PASS
+			tree.AddCmd(tree.curCmd)
 			break
 
 		default:
 			return genErr(fmt.Sprintf("Unknown G code %v @ %v", t.src, t.lnPos))
 		}
+		if holds(tree.curCmd) {
+			tree.hold()
+		}
 	case TOK_O:
+		// the program number
+		tree.curCmd = &Cmd{
+			c:      CMD_PASS,
+			t:      t,
+			sibs:   nil,
+			coords: tree.carried(),
+		}
+		tree.AddCmd(tree.curCmd)
 		break
-	case TOK_COMMENT:
+	case TOK_COMMENT, TOK_META:
+		tree.comment(t)
 		break
-	case TOK_META:
-		tree.curCmd.c = CMD_META
+	case TOK_MESSAGE:
+		tree.curCmd.text = t.src
 		break
 	case TOK_E:
 		if e, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
 			tree.curCmd.coords.E = e
+			tree.curCmd.coords.mark('E', t.src)
 		}
 		break
 
@@ -445,6 +1087,7 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
 			tree.curCmd.coords.F = f
+			tree.curCmd.coords.mark('F', t.src)
 		}
 		break
 
@@ -453,6 +1096,7 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
 			tree.curCmd.coords.H = h
+			tree.curCmd.coords.mark('H', t.src)
 		}
 		break
 
@@ -461,6 +1105,7 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
 			tree.curCmd.coords.I = i
+			tree.curCmd.coords.mark('I', t.src)
 		}
 		break
 
@@ -469,6 +1114,7 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
 			tree.curCmd.coords.J = j
+			tree.curCmd.coords.mark('J', t.src)
 		}
 		break
 
@@ -477,6 +1123,7 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
 			tree.curCmd.coords.K = k
+			tree.curCmd.coords.mark('K', t.src)
 		}
 		break
 
@@ -484,7 +1131,9 @@ func HandleToken(tree *ParseTree, n *Node) error {
 		if a, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
-			tree.curCmd.coords.A = a
+			tree.curCmd.coords.A = tree.axis('A', tree.curCmd.coords.A, a)
+			tree.curCmd.coords.mark('A', t.src)
+			tree.modalMove(t)
 		}
 		break
 
@@ -492,7 +1141,9 @@ func HandleToken(tree *ParseTree, n *Node) error {
 		if b, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
-			tree.curCmd.coords.B = b
+			tree.curCmd.coords.B = tree.axis('B', tree.curCmd.coords.B, b)
+			tree.curCmd.coords.mark('B', t.src)
+			tree.modalMove(t)
 		}
 		break
 
@@ -500,7 +1151,9 @@ func HandleToken(tree *ParseTree, n *Node) error {
 		if c, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
-			tree.curCmd.coords.C = c
+			tree.curCmd.coords.C = tree.axis('C', tree.curCmd.coords.C, c)
+			tree.curCmd.coords.mark('C', t.src)
+			tree.modalMove(t)
 		}
 		break
 
@@ -508,7 +1161,9 @@ func HandleToken(tree *ParseTree, n *Node) error {
 		if x, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
-			tree.curCmd.coords.X = x
+			tree.curCmd.coords.X = tree.axis('X', tree.curCmd.coords.X, x)
+			tree.curCmd.coords.mark('X', t.src)
+			tree.modalMove(t)
 		}
 		break
 
@@ -516,7 +1171,9 @@ func HandleToken(tree *ParseTree, n *Node) error {
 		if y, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
-			tree.curCmd.coords.Y = y
+			tree.curCmd.coords.Y = tree.axis('Y', tree.curCmd.coords.Y, y)
+			tree.curCmd.coords.mark('Y', t.src)
+			tree.modalMove(t)
 		}
 		break
 
@@ -524,7 +1181,56 @@ func HandleToken(tree *ParseTree, n *Node) error {
 		if z, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
-			tree.curCmd.coords.Z = z
+			tree.curCmd.coords.Z = tree.axis('Z', tree.curCmd.coords.Z, z)
+			tree.curCmd.coords.mark('Z', t.src)
+			tree.modalMove(t)
+		}
+		break
+
+	case TOK_U:
+		if u, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
+			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
+		} else {
+			tree.curCmd.coords.U = u
+			tree.curCmd.coords.mark('U', t.src)
+			tree.modalMove(t)
+		}
+		break
+
+	case TOK_W:
+		if w, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
+			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
+		} else {
+			tree.curCmd.coords.W = w
+			tree.curCmd.coords.mark('W', t.src)
+			tree.modalMove(t)
+		}
+		break
+
+	case TOK_L:
+		if l, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
+			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
+		} else {
+			tree.curCmd.coords.L = l
+			tree.curCmd.coords.mark('L', t.src)
+		}
+		break
+
+	case TOK_P:
+		if p, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
+			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
+		} else {
+			tree.curCmd.coords.P = p
+			tree.curCmd.coords.mark('P', t.src)
+		}
+		break
+
+	case TOK_Q:
+		if q, err := strconv.ParseFloat(t.src[1:], 64); tree.curCmd == nil || err != nil {
+			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
+		} else {
+			tree.curCmd.coords.Q = q
+			tree.curCmd.coords.mark('Q', t.src)
 		}
 		break
 
@@ -533,25 +1239,44 @@ func HandleToken(tree *ParseTree, n *Node) error {
 			return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
 		} else {
 			tree.curCmd.coords.R = r
+			tree.curCmd.coords.mark('R', t.src)
 		}
 		break
 
 	case TOK_T:
+		if tree.curCmd != tree.pending && takes(tree.curCmd, 'T') {
+			if v, err := strconv.ParseFloat(t.src[1:], 64); err != nil {
+				return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
+			} else {
+				tree.curCmd.coords.T = v
+				tree.curCmd.coords.mark('T', t.src)
+			}
+			break
+		}
 		tree.curCmd = &Cmd{
 			c:      CMD_TOOL_CHANGE,
 			t:      t,
 			sibs:   nil,
-			coords: carryForward(tree.curCmd.coords),
+			coords: tree.carried(),
 		}
 		tree.AddCmd(tree.curCmd)
 		break
 
 	case TOK_S:
+		if tree.curCmd != tree.pending && takes(tree.curCmd, 'S') {
+			if v, err := strconv.ParseFloat(t.src[1:], 64); err != nil {
+				return genErr(fmt.Sprintf("Could not parse %v @ %v : %v", t.src, t.lnPos, err))
+			} else {
+				tree.curCmd.coords.S = v
+				tree.curCmd.coords.mark('S', t.src)
+			}
+			break
+		}
 		tree.curCmd = &Cmd{
 			c:      CMD_SPINDLE_SPEED,
 			t:      t,
 			sibs:   nil,
-			coords: carryForward(tree.curCmd.coords),
+			coords: tree.carried(),
 		}
 		tree.AddCmd(tree.curCmd)
 		break
@@ -569,7 +1294,7 @@ func HandleToken(tree *ParseTree, n *Node) error {
 func Tokenize(t *ParseTree, srcFileNm string) error {
 	lines, err := readLines(srcFileNm)
 	if err != nil {
-		log.Printf("Could not open %v: %v\n", srcFileNm, err)
+		return err
 	}
 
 	for i := range lines {
@@ -585,7 +1310,15 @@ func Tokenize(t *ParseTree, srcFileNm string) error {
 
 func parseLine(tree *ParseTree, ln string, lnMarker int) error {
 
-	ln = strings.ToUpper(ln)
+	// the text of a message is not words, and keeps its case
+	msg := ""
+	if i := messageAt(ln); i >= 0 {
+		msg = strings.TrimSpace(ln[i:])
+		if c := strings.IndexRune(msg, ';'); c >= 0 {
+			msg = strings.TrimSpace(msg[:c])
+		}
+		ln = ln[:i]
+	}
 
 	position := 0
 	stPos := 0
@@ -614,6 +1347,8 @@ func parseLine(tree *ParseTree, ln string, lnMarker int) error {
 			}
 			continue
 		}
+		// words are read in upper case, comments keep theirs
+		r = unicode.ToUpper(r)
 		switch cls {
 		case CLS_WS:
 			if curI > 0 {
@@ -634,6 +1369,14 @@ func parseLine(tree *ParseTree, ln string, lnMarker int) error {
 		case CLS_PUNCT:
 			switch r {
 			case ';':
+				// the rest of the line, kept as a comment token
+				if curI > 0 {
+					buildTok(cur, curI, lnMarker, position, stPos, nl)
+					curI = 0
+				}
+				stPos = position
+				cur[curI] = r
+				curI++
 				lineComment = true
 				break
 
@@ -647,6 +1390,12 @@ func parseLine(tree *ParseTree, ln string, lnMarker int) error {
 				curI++
 				break
 
+			case '#':
+				// a parameter read as the value of the word
+				cur[curI] = r
+				curI++
+				break
+
 			case '%':
 				//if lnMarker > 1 {
 				//	t := stk.Pop()
@@ -683,6 +1432,14 @@ func parseLine(tree *ParseTree, ln string, lnMarker int) error {
 	if curI > 0 {
 		buildTok(cur, curI, lnMarker, position, stPos, nl)
 	}
+	if msg != "" {
+		nl.Add(&Tok{
+			src:     msg,
+			tokType: TOK_MESSAGE,
+			lnPos:   lnMarker,
+			stPos:   position,
+		})
+	}
 	//
 	t := &Tok{
 		src:     "_NL_",
@@ -695,6 +1452,39 @@ func parseLine(tree *ParseTree, ln string, lnMarker int) error {
 	return nil
 }
 
+// homesAxis
+// G28 X homes X, the axis needs no value.
+func homesAxis(tree *ParseTree, t *Tok) bool {
+	if len(t.src) != 1 || tree.curCmd == nil || tree.curCmd.c != CMD_HOME {
+		return false
+	}
+	switch t.tokType {
+	case TOK_X, TOK_Y, TOK_Z:
+		tree.curCmd.coords.mark(t.src[0], t.src)
+		tree.curCmd.coords.bare |= 1 << (t.src[0] - 'A')
+		return true
+	}
+	return false
+}
+
+// messageAt
+// Where the text of M117 or M118 starts on the line, -1 when there is
+// none.
+func messageAt(ln string) int {
+	up := strings.ToUpper(ln)
+	for _, code := range []string{"M117", "M118"} {
+		i := strings.Index(up, code)
+		if i < 0 || (i > 0 && !unicode.IsSpace(rune(up[i-1]))) || strings.ContainsAny(up[:i], ";(") {
+			continue
+		}
+		end := i + len(code)
+		if end == len(up) || unicode.IsSpace(rune(up[end])) {
+			return end
+		}
+	}
+	return -1
+}
+
 func readLines(fileNm string) ([]string, error) {
 	file, err := os.Open(fileNm)
 	if err != nil {
@@ -763,6 +1553,8 @@ func tokenType(tok string) int {
 		return TOK_B
 	case 'C':
 		return TOK_C
+	case 'E':
+		return TOK_E
 	case 'F':
 		return TOK_F
 	case 'G':
@@ -775,16 +1567,28 @@ func tokenType(tok string) int {
 		return TOK_J
 	case 'K':
 		return TOK_K
+	case 'L':
+		return TOK_L
 	case 'M':
 		return TOK_M
 	case 'N':
 		return TOK_N
 	case 'O':
 		return TOK_O
+	case 'P':
+		return TOK_P
+	case 'Q':
+		return TOK_Q
+	case 'R':
+		return TOK_R
 	case 'S':
 		return TOK_S
 	case 'T':
 		return TOK_T
+	case 'U':
+		return TOK_U
+	case 'W':
+		return TOK_W
 	case 'X':
 		return TOK_X
 	case 'Y':
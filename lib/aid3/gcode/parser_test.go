@@ -0,0 +1,153 @@
+package gcode
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/geom"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncremental(t *testing.T) {
+	src := `G21 G90
+G0 X1 Y2 Z3
+G91
+G1 X1 Z-1 F100
+Y2.5
+G68 X10 Y10 R90
+G1 X-1
+G28 Z0
+G90
+G1 X0
+`
+	want := [][3]float64{{1, 2, 3}, {2, 2, 2}, {2, 4.5, 2}, {1, 4.5, 2}, {1, 4.5, 2}, {0, 4.5, 2}}
+	check := func(name string, tree *ParseTree) {
+		var got [][3]float64
+		tree.TraverseCmds(func(cn *CmdNode) error {
+			if goesTo(cn.Cmd) {
+				c := cn.Cmd.Coords()
+				got = append(got, [3]float64{c.X, c.Y, c.Z})
+			}
+			if cn.Cmd.CmdType() == CMD_ROTATE && (cn.Cmd.Coords().X != 10 || cn.Cmd.Coords().Y != 10) {
+				t.Errorf("%v G68 → Expected: the center as written, Got: %v", name, cn.Cmd)
+			}
+			return nil
+		})
+		if len(got) != len(want) {
+			t.Fatalf("%v moves → Expected: %v, Got: %v", name, want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%v move %v → Expected: %v, Got: %v", name, i, want[i], got[i])
+			}
+		}
+	}
+	tree := parseSrc(t, src)
+	check("Parsed", tree)
+
+	// written back the moves are from where the program was again
+	out := writeSrc(t, tree)
+	check("Written", parseSrc(t, out))
+	if want := "G1 X1 Z-1 F100"; !strings.Contains(out, want) {
+		t.Errorf("Written → Expected: %q, Got: %v", want, out)
+	}
+}
+
+func TestPrinterWords(t *testing.T) {
+	tree := parseSrc(t, "M104 S200 T0\nM109 S210 T1\nM204 P500 T1000\nT2\nM104 S200\nS1000\n")
+	var types []int
+	var tools []float64
+	tree.TraverseCmds(func(cn *CmdNode) error {
+		types = append(types, cn.Cmd.CmdType())
+		tools = append(tools, cn.Cmd.Coords().T)
+		return nil
+	})
+	wantTypes := []int{CMD_HOTEND_TEMP, CMD_HOTEND_WAIT, CMD_ACCEL, CMD_TOOL_CHANGE, CMD_HOTEND_TEMP, CMD_SPINDLE_SPEED}
+	if len(types) != len(wantTypes) {
+		t.Fatalf("Commands → Expected: %v, Got: %v", wantTypes, types)
+	}
+	for i := range wantTypes {
+		if types[i] != wantTypes[i] {
+			t.Errorf("Command %v → Expected: %v, Got: %v", i, wantTypes[i], types[i])
+		}
+	}
+	if tools[0] != 0 || tools[1] != 1 || tools[2] != 1000 {
+		t.Errorf("T → Expected: the hotends 0 and 1 and the travel acceleration, Got: %v", tools)
+	}
+}
+
+func TestWorkOffsetWords(t *testing.T) {
+	tree := parseSrc(t, "G0 X1 Y2\nG10 L2 P1 X10 Y20\nG55\nG53 G0 Z0\nG1 Z-1 F100\n")
+	var cmds []int
+	for _, c := range cmdsOf(tree) {
+		cmds = append(cmds, c.CmdType())
+		if c.CmdType() == CMD_LINEAR && (c.Coords().X != 1 || c.Coords().Y != 2) {
+			t.Errorf("After G10 → Expected: X1 Y2 carried, Got: X%v Y%v", c.Coords().X, c.Coords().Y)
+		}
+	}
+	want := []int{CMD_FAST, CMD_RETRACT, CMD_WORK_OFFSET, CMD_MACHINE_COORDS, CMD_FAST, CMD_LINEAR}
+	if len(cmds) != len(want) {
+		t.Fatalf("Commands → Expected: %v, Got: %v", want, cmds)
+	}
+	for i := range want {
+		if cmds[i] != want[i] {
+			t.Errorf("Command %v → Expected: %v, Got: %v", i, want[i], cmds[i])
+		}
+	}
+	if out := writeSrc(t, tree); !strings.Contains(out, "G10 L2 X10 Y20 P1\n") {
+		t.Errorf("Written → Expected: G10 L2 X10 Y20 P1, Got: %q", out)
+	}
+}
+
+func TestParamWords(t *testing.T) {
+	tree := parseSrc(t, "G21 G90\nG0 X#5061 Y-#5062 Z5\nG1 Z#5063 F100\nG1 X10\nG91\nG1 Y2\nG90\n")
+	cmds := cmdsOf(tree)
+	reads := func(c *Cmd) string {
+		return fmt.Sprintf("%v %v %v", c.Coords().Param('X'), c.Coords().Param('Y'), c.Coords().Param('Z'))
+	}
+	want := []string{"5061 -5062 0", "5061 -5062 5063", "0 -5062 5063", "0 -5062 5063"}
+	for i, c := range []*Cmd{cmds[2], cmds[3], cmds[4], cmds[6]} {
+		if got := reads(c); got != want[i] {
+			t.Errorf("Reads %v → Expected: %v, Got: %v", c.Src(), want[i], got)
+		}
+	}
+
+	params := map[int]float64{5061: 1.5, 5062: 2, 5063: -3}
+	for i, w := range []string{"1.5 -2 5", "1.5 -2 -3", "10 -2 -3", "10 0 -3"} {
+		c := []*Cmd{cmds[2], cmds[3], cmds[4], cmds[6]}[i].Read(params)
+		if got := fmt.Sprintf("%v %v %v", c.Coords().X, c.Coords().Y, c.Coords().Z); got != w || c.Coords().Reads() {
+			t.Errorf("Read %v → Expected: %v, Got: %v", c.Src(), w, got)
+		}
+	}
+	if cmds[0].Read(params) != cmds[0] {
+		t.Errorf("Read G21 → Expected: the command itself")
+	}
+
+	out := writeSrc(t, tree)
+	for _, line := range []string{"G0 X#5061 Y-#5062 Z5\n", "G1 Z#5063 F100\n", "G1 X10\n", "G1 Y2\n"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Written → Expected: %q, Got: %q", line, out)
+		}
+	}
+
+	// runs of G1 neither start nor go through a read, a transform refuses it
+	tree = parseSrc(t, "G21 G90\nG0 X0 Y0\nG1 X1 F100\nG1 X#5061\nG1 X3\nG1 X4\n")
+	opt, _ := tree.Optimize(MakeOptimizeConfig())
+	if out := writeSrc(t, opt); !strings.Contains(out, "G1 X1 F100\nG1 X#5061\nG1 X3\nG1 X4\n") {
+		t.Errorf("Optimized → Expected: the moves kept, Got: %q", out)
+	}
+	if _, err := tree.Transform(geom.Translate(1, 0, 0)); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("Transform → Expected: line 4 refused, Got: %v", err)
+	}
+
+	for _, src := range []string{"G0 X#100\n", "G0 F#5061\n", "G0 #5061\n", "G0 X#\n", "G91 G0 X#5061\n"} {
+		fileNm := filepath.Join(t.TempDir(), "p.nc")
+		if err := os.WriteFile(fileNm, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Parse(fileNm); err == nil {
+			t.Errorf("Parse %q → Expected: an error, Got: none", src)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package gcode
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/geom"
+	"math"
+)
+
+// the places transformed values are rounded to, a nanometer in mm
+const transformDecimals = 6
+
+// transformer
+// The affine and the state of the program as it is transformed.
+type transformer struct {
+	a       *geom.Affine
+	moves   bool // the affine turns, scales or mirrors
+	out     *ParseTree
+	prev    *Coords // the command before, untransformed
+	plane   *plane  // the program's plane
+	outPl   *plane  // the plane of the transformed program
+	drills  bool    // in a drilling cycle, the lines of holes repeat it
+	machine int     // the line of the last G53
+}
+
+// Transform
+// The program with every point of every command through the affine, in
+// program units.  A word is written where an axis it moves was given,
+// the axes carried forward are carried through the affine alike.  An
+// arc keeps its form when its plane maps onto one of G17 to G19 without
+// being stretched, it turns the other way through a mirror, its center
+// and radius go with it and the plane commands follow their planes.
+// The error names the first command the affine cannot carry: an arc
+// made elliptical or tilted out of the planes, a drilling cycle whose Z
+// is no longer the tool axis, rotary axes, G16 polar coordinates, lathe
+// U and W, cycles and the control's G68, G51 and G51.1 under anything
+// but a translation, or an affine which is not one or cannot be undone.
+// G53 moves and the work offsets of G10 are the machine's, they stay
+// as written.
+func (t *ParseTree) Transform(a *geom.Affine) (*ParseTree, error) {
+	if !a.Affine3d() {
+		return nil, fmt.Errorf("the transform has a perspective, it is not an affine")
+	}
+	if math.Abs(a.Determinant()) < 1e-12 {
+		return nil, fmt.Errorf("the transform flattens the program, it cannot be undone")
+	}
+	x := &transformer{
+		a:     a,
+		moves: !a.Translation(),
+		out: &ParseTree{
+			settings: t.settings,
+			nodes:    t.nodes,
+			stk:      &Stk{},
+			cmds:     &CmdList{},
+			slicer:   t.slicer,
+			tools:    t.tools,
+		},
+		prev:    &Coords{},
+		plane:   planes[0],
+		outPl:   planes[0],
+		machine: -1,
+	}
+	if err := t.TraverseCmds(x.cmd); err != nil {
+		return nil, err
+	}
+	return x.out, nil
+}
+
+func (x *transformer) cmd(cn *CmdNode) error {
+	c := cn.Cmd
+	nc := *c
+	coords := *c.coords
+	nc.coords = &coords
+	co := &coords
+
+	if c.c == CMD_MACHINE_COORDS {
+		x.machine = c.Line()
+	}
+	if (c.Line() == x.machine && isMove(c)) || (c.c == CMD_RETRACT && co.Has('L')) {
+		x.out.cmds.AddCmd(&nc)
+		return nil
+	}
+	if co.Reads() {
+		return x.refuse(c, "reads a parameter, where it goes is only known as it runs")
+	}
+	if x.moves || x.a.At(0, 3) != 0 || x.a.At(1, 3) != 0 || x.a.At(2, 3) != 0 {
+		if co.Has('A') || co.Has('B') || co.Has('C') {
+			return x.refuse(c, "rotary axes turn about the machine's pivots, not the program's")
+		}
+		if c.c == CMD_POLAR {
+			return x.refuse(c, "X and Y are a radius and an angle about the work zero, they do not move with the program")
+		}
+	}
+	if x.moves {
+		if co.Has('U') || co.Has('W') {
+			return x.refuse(c, "U and W move from where the lathe is, only a translation carries them")
+		}
+		switch c.c {
+		case CMD_FINISH_CYCLE, CMD_TURN_CYCLE, CMD_FACE_CYCLE, CMD_PATTERN_CYCLE,
+			CMD_FACE_PECK_CYCLE, CMD_GROOVE_CYCLE, CMD_THREAD_CYCLE:
+			return x.refuse(c, "a lathe cycle cuts along Z, only a translation carries it")
+		case CMD_ROTATE, CMD_SCALE, CMD_MIRROR:
+			return x.refuse(c, "is the control's transform of the program, only a translation carries it")
+		}
+	}
+
+	switch c.c {
+	case CMD_PLANE_XY, CMD_PLANE_XZ, CMD_PLANE_YZ:
+		x.plane = planeOf(c.c)
+		x.outPl = x.plane
+		if to := x.image(x.plane); to != nil {
+			nc.c, nc.t = to.cmd, &Tok{src: to.src, tokType: TOK_G, lnPos: c.Line(), stPos: c.Column()}
+			x.outPl = to
+		}
+	case CMD_CW_ARC, CMD_CCW_ARC:
+		if err := x.arc(&nc); err != nil {
+			return err
+		}
+	case CMD_FAST, CMD_LINEAR:
+		x.drills = false
+	case CMD_PASS:
+		if src := c.Src(); src == "G80" {
+			x.drills = false
+		} else if len(src) == 3 && src[:2] == "G8" {
+			x.drills = true
+		}
+		if x.drills {
+			if err := x.drill(c, co); err != nil {
+				return err
+			}
+		}
+	}
+	x.point(c.coords, co)
+	if c.c == CMD_HOME && co.bare != 0 {
+		// homing is the machine's, the axes named stay as they are
+		co.words, co.points = c.coords.words, c.coords.points
+	}
+	x.prev = c.coords
+	x.out.cmds.AddCmd(&nc)
+	return nil
+}
+
+func (x *transformer) refuse(c *Cmd, format string, args ...interface{}) error {
+	return fmt.Errorf("line %v: %v %v", c.Line(), c.Src(), fmt.Sprintf(format, args...))
+}
+
+// point
+// X, Y and Z through the affine, a word written where the axes it moves
+// with were given.
+func (x *transformer) point(from *Coords, to *Coords) {
+	p := x.a.MultiplyPoint(&geom.Point{X: from.X, Y: from.Y, Z: from.Z})
+	p.X, p.Y, p.Z = roundTo(p.X, transformDecimals), roundTo(p.Y, transformDecimals), roundTo(p.Z, transformDecimals)
+	given := [3]bool{}
+	for i := range 3 {
+		for j, w := range []byte("XYZ") {
+			if x.a.At(i, j) != 0 && from.Has(w) {
+				given[i] = true
+			}
+		}
+	}
+	to.X, to.Y, to.Z = p.X, p.Y, p.Z
+	for i, w := range []byte("XYZ") {
+		to.unmark(w)
+		if given[i] {
+			to.mark(w, ".")
+		}
+	}
+}
+
+// axis
+// The unit vector along the axis through the affine, without moving.
+func (x *transformer) axis(i int) [3]float64 {
+	e := [3]float64{}
+	e[i] = 1
+	v := x.a.MultiplyVector(&geom.Point{X: e[0], Y: e[1], Z: e[2]})
+	return [3]float64{v.X, v.Y, v.Z}
+}
+
+// image
+// The plane the affine maps the plane onto, nil when it lands between
+// the planes.
+func (x *transformer) image(p *plane) *plane {
+	u, v := x.axis(p.u), x.axis(p.v)
+	n := [3]float64{u[1]*v[2] - u[2]*v[1], u[2]*v[0] - u[0]*v[2], u[0]*v[1] - u[1]*v[0]}
+	for _, to := range planes {
+		if math.Abs(n[to.u]) < 1e-9*math.Abs(n[to.n]) && math.Abs(n[to.v]) < 1e-9*math.Abs(n[to.n]) {
+			return to
+		}
+	}
+	return nil
+}
+
+func planeOf(cmd int) *plane {
+	for _, p := range planes {
+		if p.cmd == cmd {
+			return p
+		}
+	}
+	return planes[0]
+}
+
+// arc
+// The arc's center, radius, direction and plane through the affine.
+func (x *transformer) arc(nc *Cmd) error {
+	x.drills = false
+	c := nc.coords
+	p := x.plane
+	to := x.image(p)
+	if to == nil {
+		return x.refuse(nc, "arc in %v is tilted between the planes", p.src)
+	}
+	u, v := x.axis(p.u), x.axis(p.v)
+	lu, lv := math.Sqrt(dot(u, u)), math.Sqrt(dot(v, v))
+	if math.Abs(lu-lv) > 1e-9*lu || math.Abs(dot(u, v)) > 1e-9*lu*lv {
+		return x.refuse(nc, "arc in %v would be stretched into an ellipse", p.src)
+	}
+	start := [3]float64{x.prev.X, x.prev.Y, x.prev.Z}
+	end := [3]float64{c.X, c.Y, c.Z}
+	if start[p.n] != end[p.n] {
+		// a helix climbs along the normal, it has to stay the normal
+		n := x.axis(p.n)
+		if math.Abs(n[to.u]) > 1e-9 || math.Abs(n[to.v]) > 1e-9 {
+			return x.refuse(nc, "helix in %v would lean off its axis", p.src)
+		}
+	}
+	if det := u[to.u]*v[to.v] - u[to.v]*v[to.u]; det < 0 {
+		// mirrored, the arc turns the other way
+		if nc.c == CMD_CW_ARC {
+			nc.c = CMD_CCW_ARC
+		} else {
+			nc.c = CMD_CW_ARC
+		}
+	}
+	if x.outPl != to {
+		pc := &Cmd{c: to.cmd, t: &Tok{src: to.src, tokType: TOK_G}, coords: carryForward(x.prev)}
+		x.point(x.prev, pc.coords)
+		pc.coords.words, pc.coords.points = 0, 0
+		x.out.cmds.AddCmd(pc)
+		x.outPl = to
+	}
+	if c.Has('R') {
+		c.R = roundTo(c.R*lu, transformDecimals)
+		return nil
+	}
+	offsets := [3]float64{c.I, c.J, c.K}
+	center := start
+	center[p.u] += offsets[p.u]
+	center[p.v] += offsets[p.v]
+	s := x.a.MultiplyPoint(&geom.Point{X: start[0], Y: start[1], Z: start[2]})
+	m := x.a.MultiplyPoint(&geom.Point{X: center[0], Y: center[1], Z: center[2]})
+	moved := [3]float64{m.X - s.X, m.Y - s.Y, m.Z - s.Z}
+	c.I, c.J, c.K = 0, 0, 0
+	for _, w := range []byte("IJK") {
+		c.unmark(w)
+	}
+	for _, a := range []int{to.u, to.v} {
+		off := roundTo(moved[a], transformDecimals)
+		switch a {
+		case 0:
+			c.I = off
+		case 1:
+			c.J = off
+		case 2:
+			c.K = off
+		}
+		c.mark("IJK"[a], ".")
+	}
+	return nil
+}
+
+// drill
+// A drilling cycle works down Z to its depth from R, Z has to stay the
+// tool axis, up still up.
+func (x *transformer) drill(c *Cmd, co *Coords) error {
+	if x.a.At(0, 2) != 0 || x.a.At(1, 2) != 0 || x.a.At(2, 0) != 0 || x.a.At(2, 1) != 0 || x.a.At(2, 2) <= 0 {
+		return x.refuse(c, "drilling cycle would no longer drill down Z")
+	}
+	if co.Has('R') {
+		co.R = roundTo(co.R*x.a.At(2, 2)+x.a.At(2, 3), transformDecimals)
+	}
+	if co.Has('Q') {
+		co.Q = roundTo(co.Q*x.a.At(2, 2), transformDecimals)
+	}
+	return nil
+}
+
+func (c *Coords) unmark(w byte) {
+	c.words &^= 1 << (w - 'A')
+	c.points &^= 1 << (w - 'A')
+}
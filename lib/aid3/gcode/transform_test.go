@@ -0,0 +1,133 @@
+package gcode
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/geom"
+	"strings"
+	"testing"
+)
+
+const transformSrc = `G21 G90 G17
+G0 X0 Y0 Z5
+G1 Z-1 F300
+G1 X10
+G2 X20 Y0 I5 J0
+G3 X30 Y10 R10
+G1 Y20 Z-2
+G2 X30 Y20 Z-3 I-5 J0
+G0 Z5
+`
+
+func TestTransform(t *testing.T) {
+	tree := parseSrc(t, transformSrc)
+	for _, c := range []struct {
+		name string
+		a    *geom.Affine
+		flip bool
+	}{
+		{"Translate", geom.Translate(100, -50, 2), false},
+		{"RotateZ 90", geom.RotateZ(90), false},
+		{"RotateZ 30", geom.Compose(geom.RotateZ(30), geom.Translate(5, 5, 0)), false},
+		{"Mirror X", geom.Mirror(geom.X), true},
+		{"Scale", geom.Scale(2, 2, 0.5), false},
+		// seen from -Y in G18 the arc turns the other way
+		{"RotateX 90", geom.RotateX(90), true},
+		{"RotateY -90 Mirror Z", geom.Compose(geom.RotateY(-90), geom.Mirror(geom.Z)), false},
+	} {
+		moved, err := tree.Transform(c.a)
+		if err != nil {
+			t.Errorf("%v → %v", c.name, err)
+			continue
+		}
+		again := parseSrc(t, writeSrc(t, moved))
+		var want [][3]float64
+		for _, p := range pathPoints(tree) {
+			q := c.a.MultiplyPoint(&geom.Point{X: p[0], Y: p[1], Z: p[2]})
+			want = append(want, [3]float64{q.X, q.Y, q.Z})
+		}
+		// the first point is where the program starts, not a move
+		got := pathPoints(again)
+		if d := strays(want[1:], got[1:]); d > 1e-3 {
+			t.Errorf("%v → Expected: the path through the affine, Got: %v off\n%v", c.name, d, writeSrc(t, moved))
+		}
+		for _, a := range cmdsOf(again) {
+			if a.Line() == 5 && (a.c == CMD_CCW_ARC) != c.flip {
+				t.Errorf("%v → Expected: G2 flipped %v, Got: %v", c.name, c.flip, cmdWord(a))
+			}
+		}
+	}
+}
+
+func TestTransformPlanes(t *testing.T) {
+	tree := parseSrc(t, transformSrc)
+	moved, err := tree.Transform(geom.RotateX(90))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := writeSrc(t, moved)
+	if !strings.HasPrefix(out, "G21 G90 G18\n") || strings.Contains(out, "G17") {
+		t.Errorf("Plane → Expected: G17 as G18, Got:\n%v", out)
+	}
+	if !strings.Contains(out, "G3 X20. Z0. I5. K0.\n") {
+		t.Errorf("Center → Expected: I and K, Got:\n%v", out)
+	}
+
+	// arcs in a program with no plane start in G17
+	tree = parseSrc(t, "G0 X0 Y0\nG2 X10 Y0 I5 J0\n")
+	moved, err = tree.Transform(geom.RotateY(90))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := writeSrc(t, moved); !strings.Contains(out, "G19\nG2 ") {
+		t.Errorf("Plane → Expected: G19 before the arc, Got:\n%v", out)
+	}
+}
+
+func TestTransformRefused(t *testing.T) {
+	tree := parseSrc(t, transformSrc)
+	for _, c := range []struct {
+		name string
+		a    *geom.Affine
+		want string
+	}{
+		{"Stretch", geom.Scale(2, 1, 1), "line 5: G2 arc in G17 would be stretched into an ellipse"},
+		{"Tilt", geom.RotateX(30), "line 5: G2 arc in G17 is tilted between the planes"},
+		{"Flat", geom.Scale(1, 0, 1), "flattens"},
+	} {
+		if _, err := tree.Transform(c.a); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%v → Expected: %q, Got: %v", c.name, c.want, err)
+		}
+	}
+	// lines alone go anywhere
+	lines := parseSrc(t, "G0 X0 Y0 Z5\nG1 Z-1 F100\nG1 X10 Y5\n")
+	if _, err := lines.Transform(geom.Compose(geom.RotateX(30), geom.Scale(2, 1, 1))); err != nil {
+		t.Errorf("Lines → Expected: no error, Got: %v", err)
+	}
+	rotary := parseSrc(t, "G0 X0 Y0 A90\n")
+	if _, err := rotary.Transform(geom.Translate(1, 0, 0)); err == nil {
+		t.Errorf("Rotary → Expected: an error")
+	}
+	if _, err := rotary.Transform(geom.Identity()); err != nil {
+		t.Errorf("Rotary identity → Expected: no error, Got: %v", err)
+	}
+	drill := parseSrc(t, "G0 X0 Y0 Z5\nG81 X1 Y1 Z-3 R1 F100\nX2 Y2\nG80\n")
+	if _, err := drill.Transform(geom.RotateY(90)); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Drill → Expected: an error at line 2, Got: %v", err)
+	}
+	moved, err := drill.Transform(geom.Translate(0, 0, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := writeSrc(t, moved); !strings.Contains(out, "G81 X1. Y1. Z7. R11 F100\n") {
+		t.Errorf("Drill → Expected: Z and R raised, Got:\n%v", out)
+	}
+
+	// the machine's coordinates and offsets stay where they are
+	machine := parseSrc(t, "G10 L2 P1 X5 Y5\nG0 X1 Y1 Z5\nG53 G0 Z0\n")
+	moved, err = machine.Transform(geom.Translate(10, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := writeSrc(t, moved); out != "G10 L2 X5 Y5 P1\nG0 X11. Y1. Z5.\nG53 G0 Z0\n" {
+		t.Errorf("G10 and G53 → Expected: as written, Got:\n%v", out)
+	}
+}
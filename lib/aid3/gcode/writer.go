@@ -0,0 +1,240 @@
+package gcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// the order words are written in after the command
+const wordOrder = "LXYZUWABCIJKRHPQSTEF"
+
+// the words G91 writes from where the program was
+const axisWords = "XYZABC"
+
+// Writer
+// Commands back to G-code text.  The commands of a source line are
+// written on one line, each move starting its own but the one of a
+// G53, and a command's comments go on lines of their own before it.
+// Words are the ones given on the command, with a decimal point when
+// they had one, so the text parses back to the same commands.  After
+// G91 the axis words of a move are written from where the program was.
+type Writer struct {
+	w           *bufio.Writer
+	line        int  // the source line of the line being written
+	open        bool // a line is being written
+	machine     bool // the line has a G53, its move goes on it
+	incremental bool
+	at          *Coords // where the program is, for G91
+	err         error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+func (w *Writer) print(s string) {
+	if w.err == nil {
+		_, w.err = w.w.WriteString(s)
+	}
+}
+
+func (w *Writer) endLine() {
+	if w.open {
+		w.print("\n")
+		w.open = false
+	}
+}
+
+// WriteCmd
+// The command, on the open line when it is from the same source line.
+func (w *Writer) WriteCmd(c *Cmd) error {
+	if !w.open || c.Line() == 0 || c.Line() != w.line || (isMove(c) && !w.machine) || len(c.comments) > 0 {
+		w.endLine()
+	}
+	for _, text := range c.comments {
+		w.print(";" + text + "\n")
+	}
+	if w.open {
+		w.print(" ")
+	} else if c.seq > 0 {
+		w.print(fmt.Sprintf("N%v ", c.seq))
+	}
+	if !w.open {
+		w.machine = false
+	}
+	w.open, w.line = true, c.Line()
+	w.machine = c.c == CMD_MACHINE_COORDS || (w.machine && !isMove(c))
+	var from *Coords
+	if w.incremental && goesTo(c) {
+		from = w.at
+		if from == nil {
+			from = &Coords{}
+		}
+	}
+	w.print(c.block(from))
+	switch c.c {
+	case CMD_ABSOLUTE:
+		w.incremental = false
+	case CMD_INCREMENTAL:
+		w.incremental = true
+	}
+	if !holds(c) {
+		w.at = c.coords
+	}
+	if c.c == CMD_MESSAGE {
+		// the text runs to the end of the line
+		w.print(" " + c.text)
+		w.endLine()
+	}
+	return w.err
+}
+
+// Block
+// The command and its words as they are written, without its comments
+// or sequence number.
+func (c *Cmd) Block() string {
+	return c.block(nil)
+}
+
+// block
+// The command with its axis words from where the program was, as
+// absolute when from is nil.
+func (c *Cmd) block(from *Coords) string {
+	var words []string
+	if cw := cmdWord(c); cw != "" {
+		words = append(words, cw)
+	}
+	for i := 0; i < len(wordOrder); i++ {
+		w := wordOrder[i]
+		if c.coords == nil || !c.coords.Has(w) {
+			continue
+		}
+		if from != nil && strings.IndexByte(axisWords, w) >= 0 {
+			// rounded, the sum parsed back is not always exact
+			words = append(words, c.coords.format(w, math.Round((c.coords.value(w)-from.value(w))*1e9)/1e9))
+			continue
+		}
+		words = append(words, c.coords.word(w))
+	}
+	return strings.Join(words, " ")
+}
+
+// Flush
+// End the last line and write out what is buffered.
+func (w *Writer) Flush() error {
+	w.endLine()
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// Write
+// The program as G-code text.
+func (t *ParseTree) Write(w io.Writer) error {
+	gw := NewWriter(w)
+	if err := t.TraverseCmds(func(cn *CmdNode) error {
+		return gw.WriteCmd(cn.Cmd)
+	}); err != nil {
+		return err
+	}
+	return gw.Flush()
+}
+
+// cmdWord
+// The G or M word of the command.  A move on a line of only coordinates
+// has the first of them as its source, it is written with its G, and a
+// hole of a drilling cycle is written as its coordinates.
+func cmdWord(c *Cmd) string {
+	switch c.c {
+	case CMD_PASS:
+		if c.t.tokType != TOK_G && c.t.tokType != TOK_M && c.t.tokType != TOK_O {
+			return ""
+		}
+	case CMD_FAST:
+		return "G0"
+	case CMD_LINEAR:
+		return "G1"
+	case CMD_CW_ARC:
+		return "G2"
+	case CMD_CCW_ARC:
+		return "G3"
+	}
+	return c.Src()
+}
+
+// word
+// The word as written, the letter alone for an axis G28 homes and the
+// parameter for a word reading one.
+func (c *Coords) word(w byte) string {
+	if c.bare&(1<<(w-'A')) != 0 {
+		return string(w)
+	}
+	if c.reads&(1<<(w-'A')) != 0 {
+		if num := c.Param(w); num < 0 {
+			return fmt.Sprintf("%c-#%v", w, -num)
+		}
+		return fmt.Sprintf("%c#%v", w, c.Param(w))
+	}
+	return c.format(w, c.value(w))
+}
+
+// format
+// The word with the value, a decimal point when it was written with one.
+func (c *Coords) format(w byte, v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if c.Decimal(w) && !strings.Contains(s, ".") {
+		s += "."
+	}
+	return string(w) + s
+}
+
+func (c *Coords) value(w byte) float64 {
+	switch w {
+	case 'X':
+		return c.X
+	case 'Y':
+		return c.Y
+	case 'Z':
+		return c.Z
+	case 'A':
+		return c.A
+	case 'B':
+		return c.B
+	case 'C':
+		return c.C
+	case 'E':
+		return c.E
+	case 'F':
+		return c.F
+	case 'H':
+		return c.H
+	case 'I':
+		return c.I
+	case 'J':
+		return c.J
+	case 'K':
+		return c.K
+	case 'L':
+		return c.L
+	case 'R':
+		return c.R
+	case 'S':
+		return c.S
+	case 'T':
+		return c.T
+	case 'P':
+		return c.P
+	case 'Q':
+		return c.Q
+	case 'U':
+		return c.U
+	case 'W':
+		return c.W
+	}
+	return 0
+}
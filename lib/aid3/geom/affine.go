@@ -0,0 +1,237 @@
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	TRANS_ROW = 3
+	TRANS_X   = 0
+	TRANS_Y   = 1
+	TRANS_Z   = 2
+
+	X = 0
+	Y = 1
+	Z = 2
+)
+
+// Affine
+// A 4x4 matrix [row, col] for 3d affines
+type Affine struct {
+	m [][]float64
+}
+
+func (a *Affine) String() string {
+	ln1 := fmt.Sprintf("%v %v %v %v", a.m[0][0], a.m[0][1], a.m[0][2], a.m[0][3])
+	ln2 := fmt.Sprintf("%v %v %v %v", a.m[1][0], a.m[1][1], a.m[1][2], a.m[1][3])
+	ln3 := fmt.Sprintf("%v %v %v %v", a.m[2][0], a.m[2][1], a.m[2][2], a.m[2][3])
+	ln4 := fmt.Sprintf("%v %v %v %v", a.m[3][0], a.m[3][1], a.m[3][2], a.m[3][3])
+	return fmt.Sprintf("%v\n%v\n%v\n%v", ln1, ln2, ln3, ln4)
+
+}
+
+func Identity() *Affine {
+	m := make([][]float64, 4)
+
+	for i := range 4 {
+		m[i] = make([]float64, 4)
+		for j := range 4 {
+			if i == j {
+				m[i][j] = 1
+			} else {
+				m[i][j] = 0
+			}
+		}
+	}
+
+	return &Affine{
+		m: m,
+	}
+}
+
+func Translate(x float64, y float64, z float64) *Affine {
+	id := Identity()
+	id.m[TRANS_X][TRANS_ROW] = x
+	id.m[TRANS_Y][TRANS_ROW] = y
+	id.m[TRANS_Z][TRANS_ROW] = z
+	return id
+}
+
+func (a *Affine) MultiplyPoint(p *Point) *Point {
+	var ret Point
+	ret.X = a.m[0][0]*p.X + a.m[0][1]*p.Y + a.m[0][2]*p.Z + a.m[0][3]
+	ret.Y = a.m[1][0]*p.X + a.m[1][1]*p.Y + a.m[1][2]*p.Z + a.m[1][3]
+	ret.Z = a.m[2][0]*p.X + a.m[2][1]*p.Y + a.m[2][2]*p.Z + a.m[2][3]
+	W := a.m[3][0]*p.X + a.m[3][1]*p.Y + a.m[3][2]*p.Z + a.m[3][3]
+
+	ret.X = ret.X / W
+	ret.Y = ret.Y / W
+	ret.Z = ret.Z / W
+
+	return &ret
+}
+
+// MultiplyVector
+// The direction or offset p through the affine, without its translation.
+func (a *Affine) MultiplyVector(p *Point) *Point {
+	return &Point{
+		X: a.m[0][0]*p.X + a.m[0][1]*p.Y + a.m[0][2]*p.Z,
+		Y: a.m[1][0]*p.X + a.m[1][1]*p.Y + a.m[1][2]*p.Z,
+		Z: a.m[2][0]*p.X + a.m[2][1]*p.Y + a.m[2][2]*p.Z,
+	}
+}
+
+// At
+// The entry of the matrix at [row, col].
+func (a *Affine) At(row int, col int) float64 {
+	return a.m[row][col]
+}
+
+// RotateX
+// Rotate degrees about X, counterclockwise looking down from +X.
+func RotateX(degrees float64) *Affine {
+	return rotate(Y, Z, degrees)
+}
+
+// RotateY
+// Rotate degrees about Y, counterclockwise looking down from +Y.
+func RotateY(degrees float64) *Affine {
+	return rotate(Z, X, degrees)
+}
+
+// RotateZ
+// Rotate degrees about Z, counterclockwise looking down from +Z.
+func RotateZ(degrees float64) *Affine {
+	return rotate(X, Y, degrees)
+}
+
+// rotate
+// Turn the u axis toward v.  Right angles are kept exact so a quarter
+// turn maps the axes onto each other.
+func rotate(u int, v int, degrees float64) *Affine {
+	c, s := math.Cos(degrees*math.Pi/180), math.Sin(degrees*math.Pi/180)
+	if math.Mod(degrees, 90) == 0 {
+		c, s = math.Round(c), math.Round(s)
+	}
+	id := Identity()
+	id.m[u][u], id.m[u][v] = c, -s
+	id.m[v][u], id.m[v][v] = s, c
+	return id
+}
+
+// Scale
+// Scale along each axis about the origin.
+func Scale(x float64, y float64, z float64) *Affine {
+	id := Identity()
+	id.m[X][X] = x
+	id.m[Y][Y] = y
+	id.m[Z][Z] = z
+	return id
+}
+
+// Mirror
+// Mirror the axis, X, Y or Z, about the origin.
+func Mirror(axis int) *Affine {
+	id := Identity()
+	id.m[axis][axis] = -1
+	return id
+}
+
+// Multiply
+// The affine of b and then a.
+func (a *Affine) Multiply(b *Affine) *Affine {
+	ret := Identity()
+	for i := range 4 {
+		for j := range 4 {
+			sum := 0.0
+			for k := range 4 {
+				sum += a.m[i][k] * b.m[k][j]
+			}
+			ret.m[i][j] = sum
+		}
+	}
+	return ret
+}
+
+// Compose
+// The affines one after another, the first applied first.
+func Compose(affines ...*Affine) *Affine {
+	ret := Identity()
+	for _, a := range affines {
+		ret = a.Multiply(ret)
+	}
+	return ret
+}
+
+// Inverse
+// The affine undoing this one, an error when it flattens space and
+// cannot be undone.
+func (a *Affine) Inverse() (*Affine, error) {
+	// Gauss-Jordan on [a | I], pivoting on the largest entry
+	m := make([][]float64, 4)
+	for i := range 4 {
+		m[i] = make([]float64, 8)
+		copy(m[i], a.m[i])
+		m[i][4+i] = 1
+	}
+	for col := range 4 {
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, fmt.Errorf("the affine is singular and has no inverse")
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		d := m[col][col]
+		for j := range 8 {
+			m[col][j] /= d
+		}
+		for row := range 4 {
+			if row == col || m[row][col] == 0 {
+				continue
+			}
+			f := m[row][col]
+			for j := range 8 {
+				m[row][j] -= f * m[col][j]
+			}
+		}
+	}
+	ret := Identity()
+	for i := range 4 {
+		copy(ret.m[i], m[i][4:])
+	}
+	return ret, nil
+}
+
+// Determinant
+// Of the linear part, negative when the affine mirrors.
+func (a *Affine) Determinant() float64 {
+	m := a.m
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
+
+// Affine3d
+// True when the bottom row is 0 0 0 1, no perspective.
+func (a *Affine) Affine3d() bool {
+	return a.m[3][0] == 0 && a.m[3][1] == 0 && a.m[3][2] == 0 && a.m[3][3] == 1
+}
+
+// Translation
+// True when the affine only moves, without turning, scaling or
+// mirroring.
+func (a *Affine) Translation() bool {
+	for i := range 3 {
+		for j := range 3 {
+			if (i == j && a.m[i][j] != 1) || (i != j && a.m[i][j] != 0) {
+				return false
+			}
+		}
+	}
+	return a.Affine3d()
+}
@@ -0,0 +1,56 @@
+package geom
+
+import (
+	"testing"
+)
+
+func near(a *Point, b *Point) bool {
+	return a.Dist(b) < 1e-9
+}
+
+func TestAffineRotate(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		a    *Affine
+		p    *Point
+		want *Point
+	}{
+		{"RotateZ 90", RotateZ(90), &Point{X: 1}, &Point{Y: 1}},
+		{"RotateX 90", RotateX(90), &Point{Y: 1}, &Point{Z: 1}},
+		{"RotateY 90", RotateY(90), &Point{Z: 1}, &Point{X: 1}},
+		{"RotateZ -90", RotateZ(-90), &Point{X: 2, Z: 3}, &Point{Y: -2, Z: 3}},
+		{"Scale", Scale(2, 3, 4), &Point{X: 1, Y: 1, Z: 1}, &Point{X: 2, Y: 3, Z: 4}},
+		{"Mirror Y", Mirror(Y), &Point{X: 1, Y: 2, Z: 3}, &Point{X: 1, Y: -2, Z: 3}},
+	} {
+		if got := c.a.MultiplyPoint(c.p); !near(got, c.want) {
+			t.Errorf("%v → Expected: %v, Got: %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestAffineCompose(t *testing.T) {
+	// a quarter turn about X10 Y0, then up 5
+	a := Compose(Translate(-10, 0, 0), RotateZ(90), Translate(10, 0, 5))
+	if got := a.MultiplyPoint(&Point{X: 20}); !near(got, &Point{X: 10, Y: 10, Z: 5}) {
+		t.Errorf("Compose → Expected: X10 Y10 Z5, Got: %v", got)
+	}
+	if got := a.MultiplyVector(&Point{X: 1}); !near(got, &Point{Y: 1}) {
+		t.Errorf("MultiplyVector → Expected: Y1, Got: %v", got)
+	}
+	inv, err := a.Inverse()
+	if err != nil {
+		t.Fatalf("Inverse → %v", err)
+	}
+	if got := inv.MultiplyPoint(&Point{X: 10, Y: 10, Z: 5}); !near(got, &Point{X: 20}) {
+		t.Errorf("Inverse → Expected: X20, Got: %v", got)
+	}
+	if d := Compose(Mirror(X), Scale(2, 2, 1)).Determinant(); d != -4 {
+		t.Errorf("Determinant → Expected: -4, Got: %v", d)
+	}
+	if _, err := Scale(1, 0, 1).Inverse(); err == nil {
+		t.Errorf("Inverse of a flattening → Expected: an error")
+	}
+	if !Translate(1, 2, 3).Translation() || RotateZ(10).Translation() {
+		t.Errorf("Translation → Expected: only the translate")
+	}
+}
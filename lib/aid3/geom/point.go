@@ -0,0 +1,43 @@
+package geom
+
+import (
+	"fmt"
+	"math"
+)
+
+// Point
+// A position or a direction in millimeters.
+type Point struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+func (p *Point) String() string {
+	return fmt.Sprintf("X:%4.3f, Y:%4.3f, Z:%4.3f", p.X, p.Y, p.Z)
+}
+
+func (p *Point) Dist(to *Point) float64 {
+	diffX := p.X - to.X
+	diffY := p.Y - to.Y
+	diffZ := p.Z - to.Z
+	return math.Sqrt((diffX * diffX) + (diffY * diffY) + (diffZ * diffZ))
+}
+
+func PointAt(center *Point, radius float64, angle float64) *Point {
+	ret := &Point{
+		X: center.X + radius*math.Cos(angle),
+		Y: center.Y + radius*math.Sin(angle),
+		Z: center.Z,
+	}
+
+	return ret
+}
+
+func MidPoint(fr *Point, to *Point) *Point {
+	return &Point{
+		X: (fr.X + to.X) / 2,
+		Y: (fr.Y + to.Y) / 2,
+		Z: (fr.Z + to.Z) / 2,
+	}
+}
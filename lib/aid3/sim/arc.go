@@ -12,11 +12,12 @@ import (
 // (G18) G02/03 X__ Z__ I__ K__ F__
 // (G19) G02/03 Y__ Z__ J__ K__ F__
 //
-// I,J,K specify the current plane analogous to X,Y,Z
-// Only one of the three planes is used at any one time
-// X/Y/Z I/J -> xy plane and z == k
-// X/Y/Z I/K -> xz plane and y == j
-// X/Y/Z J/K -> yz plane and x == i
+// I,J,K are the center from the start in the current plane, analogous
+// to X,Y,Z.  Only one of the three planes is used at any one time, the
+// axis normal to it moves along the arc as a helix.
+// X/Y/Z I/J -> xy plane, z climbs
+// X/Y/Z I/K -> xz plane, y climbs
+// X/Y/Z J/K -> yz plane, x climbs
 //
 
 // Format 2:
@@ -24,249 +25,69 @@ import (
 // (G18)G02/03 X__ Z__ R__ F__
 // (G19)G02/03 Y__ Z__ R__ F__
 //
-// Same game.
-// X/Y/Z X/Y/R xy plane z is constant
-// X/Y/Z X/Z/R xz plane y is constant
-// X/Y/Z Y/Z/R yz plane x is constant
+// Same game, R is the radius, negative for over half a circle.
+// X/Y/Z X/Y/R xy plane, z climbs
+// X/Y/Z X/Z/R xz plane, y climbs
+// X/Y/Z Y/Z/R yz plane, x climbs
 //
 
-const (
-	planeUnknownConst = 0
-	planeXConst       = 1
-	planeYConst       = 2
-	planeZConst       = 3
-
-	formatCoords = 1
-	formatRadius = 2
-)
-
-const (
-	ClockWise        = 1.0
-	CounterClockWise = -1.0
-)
-
 func cmdCwArch(s *Sim, cn *gcode.CmdNode) {
-
-	coords := cn.Cmd.Coords()
-	plane := s.Tool.Plane()
-
-	gcodeForm := figureOutArcCmdFormat(coords)
-	planeConst := establishPlaneConstant(plane)
-
-	pointsAlongCurve(s, coords, gcodeForm, planeConst, ClockWise)
+	if l := s.lathe(); l != nil {
+		cmdTurnArc(s, cn, l, false)
+		return
+	}
+	if len(s.Tool.Kinematics().Rotaries) > 0 {
+		cmdMultiAxisArc(s, cn, false)
+		return
+	}
+	pointsAlongCurve(s, cn, false)
 }
 
 func cmdCcwArch(s *Sim, cn *gcode.CmdNode) {
-	coords := cn.Cmd.Coords()
-	plane := s.Tool.Plane()
-
-	gcodeForm := figureOutArcCmdFormat(coords)
-	planeConst := establishPlaneConstant(plane)
-
-	pointsAlongCurve(s, coords, gcodeForm, planeConst, CounterClockWise)
-}
-
-func establishPlaneConstant(plane int) int {
-	switch plane {
-	case tooling.PLANE_XY:
-		return planeZConst
-	case tooling.PLANE_XZ:
-		return planeYConst
-	case tooling.PLANE_YZ:
-		return planeXConst
+	if l := s.lathe(); l != nil {
+		cmdTurnArc(s, cn, l, true)
+		return
 	}
-	return planeUnknownConst
-}
-
-// figureOutArchMode
-// return 1 for form 1 and return 2 for form 2.
-// format 2 uses radians, as a sweep amount
-// format 1 uses coords, and a sweep is calculated.
-func figureOutArcCmdFormat(c *gcode.Coords) int {
-	if c.R != 0 {
-		return formatRadius
-	} else {
-		return formatCoords
+	if len(s.Tool.Kinematics().Rotaries) > 0 {
+		cmdMultiAxisArc(s, cn, true)
+		return
 	}
+	pointsAlongCurve(s, cn, true)
 }
 
-// Given the start and coords, find the start and end angles and the angle increment to match the given
-// tolerance.
-func pointsAlongCurve(s *Sim, c *gcode.Coords, cmdForm int, planeConst int, dir float64) {
-	outOfTolerance := true
-	var midAngle float64
-	var radius float64
-	var arc *tooling.Point
+// pointsAlongCurve
+// The arc or helix from the head in chords within the tolerance of the
+// arc, a point posted each time slice of feed.  I, J and K are from the
+// start to the center, the axis normal to the plane climbs along it.
+func pointsAlongCurve(s *Sim, cn *gcode.CmdNode, ccw bool) {
+	c := cn.Cmd.Coords()
+	plane := s.Tool.Plane()
 	fr := s.ToolHead.Pos()
-	tolerance := s.Tolerance
-
-	curFeedRate := s.Tool.FeedRate() // mm/s?
-	slice := s.TimeSlice             // s
-	distPerSlice := curFeedRate * slice
-
-	to := &tooling.Point{
-		X: c.X,
-		Y: c.Y,
-		Z: c.Z,
-	}
-
-	// abs or offset from cur pos?
-	center := &tooling.Point{}
-	//center.X = fr.X + c.I
-	//center.Y = fr.Y + c.J
-	//center.Z = fr.Z + c.K
-	center.X = c.I
-	center.Y = c.J
-	center.Z = c.K
-
-	switch planeConst {
-	case planeXConst:
-		to.X = fr.X
-		center.X = fr.X
-		break
-	case planeYConst:
-		to.Y = fr.Y
-		center.Y = fr.Y
-		break
-	case planeZConst:
-		to.Z = fr.Z
-		center.Z = fr.Z
-		break
-	}
-
-	switch cmdForm {
-	case formatRadius:
-		radius = math.Abs(c.R)
-		if c.R < 0 {
-			//big = true
-		}
-		break
-	case formatCoords:
-		rX := fr.X - center.X
-		rY := fr.Y - center.Y
-		rZ := fr.Z - center.Z
-		radius = math.Sqrt(rX*rX + rY*rY + rZ*rZ)
-		break
-	}
-
-	cnt := 1000
-
-	diffPt := &tooling.Point{}
-	diffPt.X = to.X
-	diffPt.Y = to.Y
-	diffPt.Z = to.Z
+	to := CmdToXYZ(c, fr)
+	center := arcCenter(c, fr, to, plane, ccw)
 
-	if debugArc {
-		log.Printf("ARC fr %v to %v center %v", fr, to, center)
-	}
-
-	startAng := math.Atan2(fr.Y-center.Y, fr.X-center.X)
-	endAng := math.Atan2(to.Y-center.Y, to.X-center.X)
-	conv := 180 / math.Pi
+	m := s.beginMove(MOVE_ARC, cn)
+	m.Center = center
+	m.Ccw = ccw
 
+	radius, start, sweep := tooling.ArcAngles(fr, to, center, plane, ccw)
 	if debugArc {
-		log.Printf("ARC START %3.2f END %3.2f", startAng*conv, endAng*conv)
-	}
-
-	for outOfTolerance {
-		cnt--
-		if cnt <= 0 {
-			break
-		}
-
-		midPt := tooling.MidPoint(fr, diffPt)
-
-		//
-		// Given a middle angle and the point along the arc
-		// Find the distance between the point on the arc and
-		// the line segment estimating the curve.  When it goes
-		// below the given tolerance, use that incremental angle.
-		// 'max' is used to stop bugs from doing too much damange
-		// during running.  That is dividing things by 1/2 1000 times
-		// is probably always within tolerance.
-		switch planeConst {
-		case planeXConst:
-			midAngle = math.Atan2(midPt.X-center.X, midPt.Z-center.Z)
-			arc = tooling.PointAt(center, radius, midAngle)
-			break
-		case planeYConst:
-			midAngle = math.Atan2(midPt.Z-center.Z, midPt.X-center.X)
-			arc = tooling.PointAt(center, radius, midAngle)
-			break
-
-		case planeZConst:
-			midAngle = math.Atan2(midPt.Y-center.Y, midPt.X-center.X)
-			arc = tooling.PointAt(center, radius, midAngle)
-			break
-		}
-
-		arcSegmentDiff := arc.Dist(diffPt)
-
-		if debugArc {
-			log.Printf("ARC TOL @%v DIFF: %3.4f ANGLE: %3.2f\n", cnt, arcSegmentDiff, midAngle*conv)
-			log.Printf("ARC     DIFF: %v <-- ARC: %v\n", diffPt, arc)
-		}
-
-		diffPt.X = arc.X
-		diffPt.Y = arc.Y
-		diffPt.Z = arc.Z
-
-		if arcSegmentDiff > tolerance {
-			outOfTolerance = true
-		} else {
-			outOfTolerance = false
-		}
+		log.Printf("ARC fr %v to %v center %v radius %3.4f start %3.2f sweep %3.2f", fr, to, center, radius, start*180/math.Pi, sweep*180/math.Pi)
 	}
 
-	midAngle = math.Abs(midAngle - startAng)
-	approx := math.Abs(startAng-endAng) / math.Abs(midAngle)
-	if debugArc {
-		log.Printf("ARC angle %3.6f ~# %v", midAngle, approx)
+	// the chord angle whose sagitta is the tolerance
+	step := arcChord
+	if s.Tolerance > 0 && s.Tolerance < radius {
+		step = 2 * math.Acos(1-s.Tolerance/radius)
 	}
+	n := int(math.Max(1, math.Ceil(math.Abs(sweep)/step)))
 
-	cnt = 0
+	distPerSlice := s.Tool.FeedRate() * s.TimeSlice
 	dist := 0.0
 	prev := fr
-	var p *tooling.Point
 	posted := 0
-
-	//
-	// This is a bit excessive.
-	// We know the arc length, we know the angle increment
-	// We could calculate the number of arc segments per
-	// distPerSlice and just post those points directly.
-	//
-	for curAng := startAng; ; curAng += dir * midAngle {
-		if dir > 0 && curAng > endAng {
-			break
-		}
-		if dir < 0 && curAng < endAng {
-			break
-		}
-
-		cnt++
-
-		switch planeConst {
-		case planeXConst:
-			y := center.Y + radius*math.Cos(curAng)
-			z := center.Z + radius*math.Sin(curAng)
-			p = &tooling.Point{X: fr.X, Y: y, Z: z}
-			break
-
-		case planeYConst:
-			x := center.X + radius*math.Cos(curAng)
-			z := center.Z + radius*math.Sin(curAng)
-			p = &tooling.Point{X: x, Y: fr.Y, Z: z}
-			break
-
-		case planeZConst:
-			x := center.X + radius*math.Cos(curAng)
-			y := center.Y + radius*math.Sin(curAng)
-			p = &tooling.Point{X: x, Y: y, Z: fr.Z}
-			break
-
-		}
-
+	for i := 1; i < n; i++ {
+		p := tooling.ArcAt(fr, to, center, plane, ccw, float64(i)/float64(n))
 		dist += prev.Dist(p)
 		if dist > distPerSlice {
 			s.ToolHead.MoveTo(p)
@@ -275,14 +96,11 @@ func pointsAlongCurve(s *Sim, c *gcode.Coords, cmdForm int, planeConst int, dir
 		}
 		prev = p
 	}
-
-	if dist > 0 {
-		s.ToolHead.MoveTo(p)
-		posted++
-	}
+	s.ToolHead.MoveTo(to)
+	posted++
+	s.endMove(m, to)
 
 	if debugPts {
-		log.Printf("ARC PTs simmed %3v posted %v DIST %v", cnt, posted, distPerSlice)
+		log.Printf("ARC PTs simmed %3v posted %v DIST %v", n, posted, distPerSlice)
 	}
-
 }
@@ -0,0 +1,99 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"math"
+	"testing"
+)
+
+func TestArc(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		center *tooling.Point
+		radius float64
+		via    *tooling.Point
+		to     *tooling.Point
+	}{
+		{
+			name:   "radius",
+			src:    "G0 Z30\nG0 X-5 Y30\nG2 X5 Y30 R5 F500\n",
+			center: &tooling.Point{X: 0, Y: 30, Z: 30},
+			radius: 5,
+			via:    &tooling.Point{X: 0, Y: 35, Z: 30},
+			to:     &tooling.Point{X: 5, Y: 30, Z: 30},
+		},
+		{
+			name:   "ijk",
+			src:    "G0 Z30\nG0 X10 Y-10\nG2 X10 Y10 I0 J10 F500\n",
+			center: &tooling.Point{X: 10, Y: 0, Z: 30},
+			radius: 10,
+			via:    &tooling.Point{X: 0, Y: 0, Z: 30},
+			to:     &tooling.Point{X: 10, Y: 10, Z: 30},
+		},
+		{
+			name:   "helix",
+			src:    "G0 Z30\nG0 X10 Y0\nG3 X-10 Y0 Z20 I-10 J0 F500\n",
+			center: &tooling.Point{X: 0, Y: 0, Z: 30},
+			radius: 10,
+			via:    &tooling.Point{X: 0, Y: 10, Z: 25},
+			to:     &tooling.Point{X: -10, Y: 0, Z: 20},
+		},
+		{
+			name:   "xz",
+			src:    "G0 Z30\nG0 X10 Y0\nG18\nG2 X-10 Z30 I-10 K0 F500\n",
+			center: &tooling.Point{X: 0, Y: 0, Z: 30},
+			radius: 10,
+			via:    &tooling.Point{X: 0, Y: 0, Z: 40},
+			to:     &tooling.Point{X: -10, Y: 0, Z: 30},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := runSrc(t, tt.src)
+			var arc *Move
+			for _, m := range s.Moves {
+				if m.Kind == MOVE_ARC {
+					arc = m
+				}
+			}
+			if arc == nil {
+				t.Fatalf("Arc → Expected: a move, Got: none")
+			}
+			if arc.Center.Dist(tt.center) > 1e-6 {
+				t.Errorf("Center → Expected: %v, Got: %v", tt.center, arc.Center)
+			}
+			if arc.To.Dist(tt.to) > 1e-6 || s.ToolHead.Pos().Dist(tt.to) > 1e-6 {
+				t.Errorf("End → Expected: %v, Got: %v with the head at %v", tt.to, arc.To, s.ToolHead.Pos())
+			}
+
+			var pts []*tooling.Point
+			s.ToolHead.Path(func(p *tooling.Point) {
+				pts = append(pts, p)
+			})
+			near := math.Inf(1)
+			for _, p := range pts[arc.First:arc.Last] {
+				u, v := p.X-tt.center.X, p.Y-tt.center.Y
+				if s.Tool.Plane() == tooling.PLANE_XZ {
+					u, v = p.X-tt.center.X, p.Z-tt.center.Z
+				}
+				if r := math.Hypot(u, v); math.Abs(r-tt.radius) > 1e-6 {
+					t.Errorf("Radius → Expected: %v, Got: %v at %v", tt.radius, r, p)
+				}
+				near = math.Min(near, p.Dist(tt.via))
+			}
+			// points are a time slice of feed apart, 500 mm/min
+			if near > 0.5 {
+				t.Errorf("Sweep → Expected: through %v, Got: %v away", tt.via, near)
+			}
+		})
+	}
+
+	// the next move starts where the arc ended
+	s := runSrc(t, "G0 Z30\nG0 X-5 Y30\nG2 X5 Y30 R5 F500\nG1 X5 Y20\n")
+	last := s.Moves[len(s.Moves)-1]
+	if last.Node.Cmd.CmdType() != gcode.CMD_LINEAR || last.From.Dist(&tooling.Point{X: 5, Y: 30, Z: 30}) > 1e-6 {
+		t.Errorf("Next → Expected: from X5 Y30 Z30, Got: %v", last.From)
+	}
+}
@@ -0,0 +1,305 @@
+package sim
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"log"
+	"math"
+	"os"
+)
+
+// beamStep is the longest piece of a cut in mm given its own speed,
+// the mean over beamSamples along it.
+const beamStep = 0.5
+const beamSamples = 10
+
+// BeamCut
+// A piece of a laser or plasma cut.  Feed and Speed are mm/min, the
+// speed under the feed where the gantry slows for a corner.  Power is
+// the beam in W and Energy the J/mm it puts along the cut, high where
+// it burns the edge.  Locked is where the torch height control holds,
+// slower than ThcLock of the feed.
+type BeamCut struct {
+	Line   int
+	From   *tooling.Point
+	To     *tooling.Point
+	Feed   float64
+	Speed  float64
+	Power  float64
+	Energy float64
+	Locked bool
+}
+
+// Pierce
+// The beam going through the sheet before a cut.  Time and Duration
+// are seconds, Energy J.
+type Pierce struct {
+	Line     int
+	At       *tooling.Point
+	Time     float64
+	Duration float64
+	Energy   float64
+}
+
+// BeamReport
+// What a laser or plasma program cut, Length in mm.
+type BeamReport struct {
+	Length  float64
+	Pierces []*Pierce
+	Cuts    []*BeamCut
+}
+
+// beamRun
+// A feed move with the beam on, as chords, and the beam power in W
+// at the feed in mm/s.
+type beamRun struct {
+	line    int
+	pts     []*tooling.Point
+	feed    float64
+	power   float64
+	dynamic bool
+	pierce  bool
+}
+
+func (s *Sim) beam() *tooling.BeamCutter {
+	b, _ := s.Tool.(*tooling.BeamCutter)
+	return b
+}
+
+// beamOn
+// M3 holds the power, M4 follows the speed.  A beam coming on pierces
+// before it cuts.
+func beamOn(s *Sim, b *tooling.BeamCutter, direction int) {
+	if b.BeamMode() == tooling.BEAM_OFF {
+		s.pierced = false
+	}
+	if direction == tooling.SPINDLE_CCW {
+		b.Beam(tooling.BEAM_DYNAMIC)
+	} else {
+		b.Beam(tooling.BEAM_CONSTANT)
+	}
+}
+
+func beamOff(s *Sim, b *tooling.BeamCutter) {
+	b.Beam(tooling.BEAM_OFF)
+	s.pierced = false
+}
+
+// pierce
+// The first feed after the beam comes on or after a rapid waits for
+// the pierce and for the torch to come down to cutting height.
+func (s *Sim) pierce(b *tooling.BeamCutter, kind int, cn *gcode.CmdNode) {
+	if kind == MOVE_RAPID {
+		s.pierced = false
+		return
+	}
+	if s.pierced || b.BeamMode() == tooling.BEAM_OFF {
+		return
+	}
+	p := &Pierce{
+		Line:     cn.Cmd.Line(),
+		At:       s.ToolHead.Pos(),
+		Time:     s.Clock,
+		Duration: b.PierceTime(),
+	}
+	p.Energy = b.PowerAt(1, 1) * b.PierceDelay
+	s.pierces = append(s.pierces, p)
+	s.Clock += p.Duration
+	s.pierced = true
+	s.freshPierce = true
+}
+
+// beamMove
+// Cut the kerf along a feed with the beam on.  A plasma torch stays lit
+// on a rapid, which is left as a warning.
+func (s *Sim) beamMove(m *Move) {
+	b := s.beam()
+	m.Dry = false
+	if b.BeamMode() == tooling.BEAM_OFF {
+		return
+	}
+	if m.Kind == MOVE_RAPID {
+		if b.Type() == tooling.CncPlasmaCutter {
+			s.warn(m.Node, m.Start, "rapid with the torch lit")
+		}
+		return
+	}
+	pts := movePoints(m)
+	m.Removed = &tooling.Removal{}
+	for i := 1; i < len(pts); i++ {
+		m.Removed.Add(s.Sheet.Cut(pts[i-1], pts[i], b.Kerf))
+	}
+	feed := 0.0
+	if m.Duration > 0 {
+		feed = m.Length() / m.Duration
+	}
+	s.beamRuns = append(s.beamRuns, &beamRun{
+		line:    m.Node.Cmd.Line(),
+		pts:     pts,
+		feed:    feed,
+		power:   b.PowerAt(1, 1),
+		dynamic: b.BeamMode() == tooling.BEAM_DYNAMIC,
+		pierce:  s.freshPierce,
+	})
+	s.freshPierce = false
+}
+
+// beamReport
+// Cuts which run on from each other without a pierce are planned
+// together, slowing for the corners between them.
+func beamReport(s *Sim) *BeamReport {
+	b := s.beam()
+	if b == nil {
+		return nil
+	}
+	ret := &BeamReport{Pierces: s.pierces}
+	start := 0
+	for i, r := range s.beamRuns {
+		if i+1 < len(s.beamRuns) {
+			next := s.beamRuns[i+1]
+			if !next.pierce && r.pts[len(r.pts)-1].Dist(next.pts[0]) < 1e-6 {
+				continue
+			}
+		}
+		ret.Cuts = append(ret.Cuts, beamChain(b, s.beamRuns[start:i+1])...)
+		start = i + 1
+	}
+	for _, c := range ret.Cuts {
+		ret.Length += c.From.Dist(c.To)
+	}
+	return ret
+}
+
+type beamSegment struct {
+	run *beamRun
+	fr  *tooling.Point
+	dir *tooling.Point
+	l   float64
+}
+
+func (sg *beamSegment) at(t float64) *tooling.Point {
+	return &tooling.Point{
+		X: sg.fr.X + sg.dir.X*sg.l*t,
+		Y: sg.fr.Y + sg.dir.Y*sg.l*t,
+		Z: sg.fr.Z + sg.dir.Z*sg.l*t,
+	}
+}
+
+// beamChain
+// The speed along a chain of cuts which starts and ends still.  Each
+// corner is taken no faster than the junction deviation allows, and
+// the speed between them ramps at the acceleration.
+func beamChain(b *tooling.BeamCutter, runs []*beamRun) []*BeamCut {
+	segs := make([]*beamSegment, 0)
+	for _, r := range runs {
+		for i := 1; i < len(r.pts); i++ {
+			fr, to := r.pts[i-1], r.pts[i]
+			l := fr.Dist(to)
+			if l < 1e-9 || r.feed <= 0 {
+				continue
+			}
+			dir := &tooling.Point{X: (to.X - fr.X) / l, Y: (to.Y - fr.Y) / l, Z: (to.Z - fr.Z) / l}
+			segs = append(segs, &beamSegment{run: r, fr: fr, dir: dir, l: l})
+		}
+	}
+	n := len(segs)
+	if n == 0 {
+		return nil
+	}
+
+	v := make([]float64, n+1)
+	for i := 1; i < n; i++ {
+		feed := math.Min(segs[i-1].run.feed, segs[i].run.feed)
+		v[i] = b.JunctionSpeed(segs[i-1].dir, segs[i].dir, feed)
+	}
+	for i := n - 1; i >= 0; i-- {
+		v[i] = math.Min(v[i], math.Sqrt(v[i+1]*v[i+1]+2*b.Accel*segs[i].l))
+	}
+	for i := 0; i < n; i++ {
+		v[i+1] = math.Min(v[i+1], math.Sqrt(v[i]*v[i]+2*b.Accel*segs[i].l))
+	}
+
+	ret := make([]*BeamCut, 0)
+	for i, sg := range segs {
+		pieces := int(math.Max(1, math.Ceil(sg.l/beamStep)))
+		f := sg.run.feed
+		for k := 0; k < pieces; k++ {
+			t0 := float64(k) / float64(pieces)
+			t1 := float64(k+1) / float64(pieces)
+			// the time through the piece, as the speed changes along it
+			time := 0.0
+			for j := 0; j < beamSamples; j++ {
+				x := sg.l * (t0 + (t1-t0)*(float64(j)+0.5)/beamSamples)
+				speed := math.Min(f, math.Min(
+					math.Sqrt(v[i]*v[i]+2*b.Accel*x),
+					math.Sqrt(v[i+1]*v[i+1]+2*b.Accel*(sg.l-x))))
+				time += sg.l * (t1 - t0) / beamSamples / speed
+			}
+			speed := sg.l * (t1 - t0) / time
+			power := sg.run.power
+			if sg.run.dynamic {
+				power *= speed / f
+			}
+			ret = append(ret, &BeamCut{
+				Line:   sg.run.line,
+				From:   sg.at(t0),
+				To:     sg.at(t1),
+				Feed:   f * 60,
+				Speed:  speed * 60,
+				Power:  power,
+				Energy: power / speed,
+				Locked: b.ThcLock > 0 && speed < b.ThcLock*f,
+			})
+		}
+	}
+	return ret
+}
+
+func logBeam(s *Sim) {
+	if s.Beam == nil {
+		return
+	}
+	log.Printf("Cut %3.1f mm with %v pierces\n", s.Beam.Length, len(s.Beam.Pierces))
+	var hottest *BeamCut
+	locked := 0.0
+	for _, c := range s.Beam.Cuts {
+		if hottest == nil || c.Energy > hottest.Energy {
+			hottest = c
+		}
+		if c.Locked {
+			locked += c.From.Dist(c.To)
+		}
+	}
+	if hottest != nil {
+		log.Printf("Most energy %3.2f J/mm at line %v, %3.0f mm/min for F%3.0f\n",
+			hottest.Energy, hottest.Line, hottest.Speed, hottest.Feed)
+	}
+	if locked > 0 {
+		log.Printf("Torch height locked over %3.1f mm\n", locked)
+	}
+}
+
+func writeEnergy(s *Sim) {
+	if s.Beam == nil {
+		return
+	}
+	f, err := os.Create(s.outFile("energy.csv"))
+	if err != nil {
+		log.Printf("Could not write energy.csv : %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err = fmt.Fprintln(f, "line,x0,y0,x1,y1,speed,power,energy,locked"); err != nil {
+		log.Printf("Could not write energy.csv : %v", err)
+		return
+	}
+	for _, c := range s.Beam.Cuts {
+		_, err = fmt.Fprintf(f, "%v,%.4f,%.4f,%.4f,%.4f,%.1f,%.2f,%.4f,%v\n",
+			c.Line, c.From.X, c.From.Y, c.To.X, c.To.Y, c.Speed, c.Power, c.Energy, c.Locked)
+		if err != nil {
+			log.Printf("Could not write energy.csv : %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"testing"
+)
+
+func squareCorner(dynamic bool) *beamRun {
+	return &beamRun{
+		pts: []*tooling.Point{
+			{X: 0, Y: 0},
+			{X: 20, Y: 0},
+			{X: 20, Y: 20},
+		},
+		feed:    25,
+		power:   30,
+		dynamic: dynamic,
+		pierce:  true,
+	}
+}
+
+func TestBeamCorner(t *testing.T) {
+	b := tooling.BuildLaser(tooling.MakeWood(15), 100, 100, 3)
+
+	// the middle of the first side, and the corner
+	cuts := beamChain(b, []*beamRun{squareCorner(false)})
+	mid, corner := cuts[20], cuts[39]
+	if mid.Speed != 25*60 || mid.Energy != 30./25 {
+		t.Errorf("Straight cut → Expected: 1500 mm/min and 1.2 J/mm, Got: %v and %v", mid.Speed, mid.Energy)
+	}
+	if corner.Speed >= mid.Speed || corner.Energy <= mid.Energy {
+		t.Errorf("Corner under M3 → Expected slower and hotter, Got: %v and %v", corner.Speed, corner.Energy)
+	}
+
+	cuts = beamChain(b, []*beamRun{squareCorner(true)})
+	mid, corner = cuts[20], cuts[39]
+	if corner.Speed >= mid.Speed || corner.Energy-mid.Energy > 1e-9 {
+		t.Errorf("Corner under M4 → Expected slower at the same energy, Got: %v and %v", corner.Speed, corner.Energy)
+	}
+}
@@ -0,0 +1,87 @@
+package sim
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"log"
+)
+
+// Fixture
+// A vise, clamp or other part of the setup the tool must never touch.
+type Fixture struct {
+	Name string
+	grid *tooling.Stock
+}
+
+// Collision
+// A part of the tool running into the stock or a fixture.  Point is
+// the first contact along the move and Depth the deepest the part goes
+// into the material.
+type Collision struct {
+	Move    *Move
+	Line    int
+	Part    string
+	Against string
+	Point   *tooling.Point
+	Depth   float64
+}
+
+func (c *Collision) String() string {
+	return fmt.Sprintf("line %v: %v hits %v at %v, %3.3f deep", c.Line, c.Part, c.Against, c.Point, c.Depth)
+}
+
+// AddFixture
+// Add a closed mesh, in machine coordinates, as a fixture.
+func (s *Sim) AddFixture(name string, m *tooling.Mesh) {
+	lo, hi := m.Bounds()
+	grid := tooling.MakeEmptyStock(lo, hi, s.Resolution)
+	grid.Fill(m)
+	s.Fixtures = append(s.Fixtures, &Fixture{Name: name, grid: grid})
+}
+
+// checkStock
+// Check the part of the tool against the stock.  Rapids check the whole
+// tool before the stock is cut, feeds check what is above the flutes
+// after the flutes have cut their way.
+func (s *Sim) checkStock(m *Move, part int) {
+	if s.Stock == nil {
+		return
+	}
+	s.collide(m, part, s.Stock, "stock")
+}
+
+// checkFixtures
+// Nothing on the tool may touch a fixture.
+func (s *Sim) checkFixtures(m *Move) {
+	for _, f := range s.Fixtures {
+		s.collide(m, tooling.PART_ALL, f.grid, f.Name)
+	}
+}
+
+func (s *Sim) collide(m *Move, part int, grid *tooling.Stock, against string) {
+	a := tooling.MakeAssembly(s.ToolHead.Cutter(), part, s.Tool.SpindleNose())
+	sw := m.sweepBody(a)
+	contact := grid.Contact(sw)
+	if contact == nil {
+		return
+	}
+
+	at := sw.At(contact.T)
+	rel := &tooling.Point{X: contact.Point.X - at.X, Y: contact.Point.Y - at.Y, Z: contact.Point.Z - at.Z}
+	if ob, ok := sw.Body().(*tooling.OrientedBody); ok {
+		rel = ob.Local(rel)
+	}
+	c := &Collision{
+		Move:    m,
+		Line:    m.Node.Cmd.Line(),
+		Part:    a.Part(rel),
+		Against: against,
+		Point:   contact.Point,
+		Depth:   contact.Depth,
+	}
+	if m.Kind == MOVE_RAPID && c.Part == "cutter" {
+		c.Part = "rapid"
+	}
+	log.Printf("COLLISION %v", c)
+	s.Collisions = append(s.Collisions, c)
+}
@@ -0,0 +1,55 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+// collisionsWith
+// The collisions against one thing.
+func collisionsWith(s *Sim, against string) []*Collision {
+	var ret []*Collision
+	for _, c := range s.Collisions {
+		if c.Against == against {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+func TestCollide(t *testing.T) {
+	// the first rapid leaves from the middle of the block, then the
+	// 6 mm tool rapids down 10 mm into the top of it at X-8 Y-8, the
+	// deepest 1 mm cell is half a diagonal off the axis
+	s := runSrc(t, "G0 Z30\nG0 X-8 Y-8\nG0 Z5\n")
+	hits := collisionsWith(s, "stock")
+	if len(hits) != 2 {
+		t.Fatalf("Rapids into the stock → Expected: 2, out of the middle and the plunge, Got: %v", hits)
+	}
+	c := hits[1]
+	if c.Line != 3 || c.Part != "rapid" || math.Abs(c.Point.Z-14.5) > 1e-9 {
+		t.Errorf("Plunge → Expected: line 3, rapid, at the top layer Z14.5, Got: %v", c)
+	}
+	if want := 3 - math.Sqrt(0.5); math.Abs(c.Depth-want) > 1e-3 {
+		t.Errorf("Plunge depth → Expected: %3.3f, Got: %3.3f", want, c.Depth)
+	}
+
+	// above the block the 20 mm holder sweeps into a clamp the
+	// cutter and shank pass beside
+	s = runWith(t, "G0 Z16\nG0 X10\n", func(s *Sim) {
+		s.AddFixture("clamp", tooling.MakeStock(&tooling.Point{X: 14, Y: -3, Z: 15}, &tooling.Point{X: 20, Y: 3, Z: 60}, 1).Mesh())
+	})
+	hits = collisionsWith(s, "clamp")
+	if len(hits) != 1 {
+		t.Fatalf("Holder into the clamp → Expected: 1 collision, Got: %v", hits)
+	}
+	c = hits[0]
+	if c.Line != 2 || c.Part != "holder" || math.Abs(c.Point.X-14.5) > 1e-9 {
+		t.Errorf("Holder → Expected: line 2, holder, at the near face X14.5, Got: %v", c)
+	}
+	if want := 10 - math.Hypot(4.5, 0.5); math.Abs(c.Depth-want) > 1e-3 {
+		t.Errorf("Holder depth → Expected: %3.3f, Got: %3.3f", want, c.Depth)
+	}
+}
@@ -0,0 +1,266 @@
+package sim
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"log"
+	"math"
+	"sort"
+)
+
+// CutSample
+// The cutting conditions over a piece of a feed move.
+// Time and Duration are seconds, Feed is mm/min.
+// Ae is the radial width and Ap the axial depth of the cut in mm.
+// ChipLoad is the feed per tooth, Chip the thickness after radial
+// chip thinning, both mm.
+// Mrr is mm3/s, Power W, Force the tangential cutting force in N
+// and Torque N·m at the spindle.
+type CutSample struct {
+	Time     float64
+	Duration float64
+	Line     int
+	Rpm      float64
+	Feed     float64
+	Ae       float64
+	Ap       float64
+	ChipLoad float64
+	Chip     float64
+	Mrr      float64
+	Power    float64
+	Force    float64
+	Torque   float64
+}
+
+// Limits
+// What the machine and tooling can take.  SpindlePower is W and
+// SpindleTorque N·m.  ChipFactor is how far over the largest chip
+// load recommended for the material a cut may go.
+type Limits struct {
+	SpindlePower  float64
+	SpindleTorque float64
+	ChipFactor    float64
+}
+
+// DefaultLimits
+// A 2.2kW router spindle.
+func DefaultLimits() *Limits {
+	return &Limits{
+		SpindlePower:  2200,
+		SpindleTorque: 12,
+		ChipFactor:    1.5,
+	}
+}
+
+// Overload
+// A cut over one of the limits.
+type Overload struct {
+	Line  int
+	Time  float64
+	What  string
+	Value float64
+	Limit float64
+}
+
+func (o *Overload) String() string {
+	return fmt.Sprintf("line %v: %v %3.3f over the limit %3.3f at %3.3fs", o.Line, o.What, o.Value, o.Limit, o.Time)
+}
+
+// Analysis
+// The samples of every feed move in program order, and the cuts
+// which went over the limits.
+type Analysis struct {
+	Samples   []*CutSample
+	Overloads []*Overload
+	blocks    map[int]*CutSample
+}
+
+func MakeAnalysis() *Analysis {
+	return &Analysis{
+		blocks: make(map[int]*CutSample),
+	}
+}
+
+func (a *Analysis) add(cs *CutSample) {
+	a.Samples = append(a.Samples, cs)
+
+	b, ok := a.blocks[cs.Line]
+	if !ok {
+		cp := *cs
+		a.blocks[cs.Line] = &cp
+		return
+	}
+	b.Duration += cs.Duration
+	b.Rpm = math.Max(b.Rpm, cs.Rpm)
+	b.Feed = math.Max(b.Feed, cs.Feed)
+	b.Ae = math.Max(b.Ae, cs.Ae)
+	b.Ap = math.Max(b.Ap, cs.Ap)
+	b.ChipLoad = math.Max(b.ChipLoad, cs.ChipLoad)
+	b.Chip = math.Max(b.Chip, cs.Chip)
+	b.Mrr = math.Max(b.Mrr, cs.Mrr)
+	b.Power = math.Max(b.Power, cs.Power)
+	b.Force = math.Max(b.Force, cs.Force)
+	b.Torque = math.Max(b.Torque, cs.Torque)
+}
+
+// Blocks
+// The largest of each value over the samples of a source line,
+// in line order.  Time is when the line started cutting and Duration
+// the total time spent cutting.
+func (a *Analysis) Blocks() []*CutSample {
+	ret := make([]*CutSample, 0, len(a.blocks))
+	for _, b := range a.blocks {
+		ret = append(ret, b)
+	}
+	sort.Slice(ret, func(i int, j int) bool {
+		return ret[i].Line < ret[j].Line
+	})
+	return ret
+}
+
+// Peak
+// The sample with the most power, nil without any cutting.
+func (a *Analysis) Peak() *CutSample {
+	var ret *CutSample
+	for _, cs := range a.Samples {
+		if ret == nil || cs.Power > ret.Power {
+			ret = cs
+		}
+	}
+	return ret
+}
+
+// cutMove
+// Remove the swept volume of the move from the stock.  Feed moves
+// are cut a piece at a time so the analysis follows the engagement
+// along the move.
+func (s *Sim) cutMove(m *Move) {
+	if s.Turned != nil {
+		s.turnMove(m)
+		return
+	}
+	if s.Sheet != nil {
+		s.beamMove(m)
+		return
+	}
+	if s.Stock == nil {
+		return
+	}
+	c := s.ToolHead.Cutter()
+	sw := m.Swept(c)
+	if m.Kind == MOVE_RAPID {
+		m.Removed = s.Stock.Cut(sw)
+		return
+	}
+
+	step := s.AnalysisStep
+	if step <= 0 {
+		step = c.Diameter / 2
+	}
+	length := sw.Length()
+	pieces := int(math.Max(1, math.Ceil(length/step)))
+
+	m.Removed = &tooling.Removal{}
+	for i := 0; i < pieces; i++ {
+		t0 := float64(i) / float64(pieces)
+		t1 := float64(i+1) / float64(pieces)
+		piece := sw
+		if pieces > 1 {
+			piece = m.piece(c, sw.At(t0), sw.At(t1))
+		}
+		rm := s.Stock.Cut(piece)
+		m.Removed.Add(rm)
+		s.analyze(m, rm, length*(t1-t0), m.Duration*(t1-t0), m.Start+m.Duration*t0)
+	}
+}
+
+// piece
+// The cutting sweep of the move between two points along it.
+func (m *Move) piece(c *tooling.Cutter, fr *tooling.Point, to *tooling.Point) *tooling.Swept {
+	return m.sweepBetween(cutterBody(c, true), fr, to)
+}
+
+// analyze
+// Work out the cutting conditions from what a piece of a move removed,
+// and check them against the limits.
+func (s *Sim) analyze(m *Move, rm *tooling.Removal, length float64, duration float64, start float64) {
+	if rm == nil || rm.Cells == 0 || length <= 0 || duration <= 0 {
+		return
+	}
+	c := s.ToolHead.Cutter()
+	mat := s.Tool.Material()
+
+	cs := &CutSample{
+		Time:     start,
+		Duration: duration,
+		Line:     m.Node.Cmd.Line(),
+		Rpm:      m.Rpm,
+		Feed:     length / duration * 60,
+	}
+	// the removed cells are cell centers, so add back a cell of depth
+	cs.Ap = rm.Max.Z - rm.Min.Z + s.Stock.Cell()
+	cs.Ae = math.Min(c.Diameter, rm.Volume/(cs.Ap*length))
+	cs.Mrr = rm.Volume / duration
+	cs.Power = cs.Mrr * mat.CuttingEnergy()
+
+	if cs.Rpm > 0 {
+		cs.ChipLoad = cs.Feed / (cs.Rpm * float64(c.Flutes))
+		cs.Chip = cs.ChipLoad
+		// radial chip thinning below half the diameter
+		if ratio := cs.Ae / c.Diameter; ratio < 0.5 {
+			cs.Chip = cs.ChipLoad * 2 * math.Sqrt(ratio-ratio*ratio)
+		}
+		surface := math.Pi * c.Diameter / 1000 * cs.Rpm / 60
+		cs.Force = cs.Power / surface
+		cs.Torque = cs.Power / (2 * math.Pi * cs.Rpm / 60)
+	}
+
+	s.Analysis.add(cs)
+	s.checkLimits(cs, c, mat)
+}
+
+func (s *Sim) checkLimits(cs *CutSample, c *tooling.Cutter, mat tooling.Material) {
+	l := s.Limits
+	if l == nil {
+		return
+	}
+	if l.SpindlePower > 0 && cs.Power > l.SpindlePower {
+		s.overload(cs, "spindle power", cs.Power, l.SpindlePower)
+	}
+	if l.SpindleTorque > 0 && cs.Torque > l.SpindleTorque {
+		s.overload(cs, "spindle torque", cs.Torque, l.SpindleTorque)
+	}
+	if limit := c.ForceLimit(); cs.Force > limit {
+		s.overload(cs, "tool force", cs.Force, limit)
+	}
+	if _, hi := mat.ChipLoad(c.Diameter); l.ChipFactor > 0 && hi > 0 && cs.Chip > hi*l.ChipFactor {
+		s.overload(cs, "chip load", cs.Chip, hi*l.ChipFactor)
+	}
+}
+
+// overload
+// Record the cut over a limit, once per line and limit.
+func (s *Sim) overload(cs *CutSample, what string, value float64, limit float64) {
+	for _, o := range s.Analysis.Overloads {
+		if o.Line == cs.Line && o.What == what {
+			o.Value = math.Max(o.Value, value)
+			return
+		}
+	}
+	o := &Overload{Line: cs.Line, Time: cs.Time, What: what, Value: value, Limit: limit}
+	log.Printf("OVERLOAD %v", o)
+	s.Analysis.Overloads = append(s.Analysis.Overloads, o)
+}
+
+func logAnalysis(s *Sim) {
+	if s.Analysis == nil {
+		return
+	}
+	log.Printf("Run time %3.3fs\n", s.Clock)
+	if peak := s.Analysis.Peak(); peak != nil {
+		log.Printf("Peak %3.1f W, %3.3f mm3/s, %3.1f N at line %v\n", peak.Power, peak.Mrr, peak.Force, peak.Line)
+	}
+	if len(s.Analysis.Overloads) > 0 {
+		log.Printf("%v overloads, first %v\n", len(s.Analysis.Overloads), s.Analysis.Overloads[0])
+	}
+}
@@ -0,0 +1,42 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	// a 6 mm slot 2 mm deep across the top of the block at 600 mm/min,
+	// 1 mm cells so the slot is 6 cells wide and 2 deep
+	s := runWith(t, "G0 Z20\nG0 X-20 Y0\nS10000 M3\nG1 Z13 F600\nG1 X20\n", func(s *Sim) {
+		s.Limits.SpindlePower = 5
+	})
+	blocks := s.Analysis.Blocks()
+	if len(blocks) != 1 || blocks[0].Line != 5 {
+		t.Fatalf("Blocks → Expected: only the slot at line 5, Got: %v", blocks)
+	}
+	b := blocks[0]
+	if b.Ae != 6 || b.Ap != 2 {
+		t.Errorf("Slot → Expected: Ae 6 and Ap 2, Got: %v and %v", b.Ae, b.Ap)
+	}
+	// 6 x 2 mm at 10 mm/s, the cells of a piece over by a cell at most
+	if b.Mrr < 120 || b.Mrr > 120*1.1 {
+		t.Errorf("Mrr → Expected: about 120 mm3/s, Got: %v", b.Mrr)
+	}
+	if want := b.Mrr * s.Tool.Material().CuttingEnergy(); math.Abs(b.Power-want) > 1e-9 {
+		t.Errorf("Power → Expected: %v from the cutting energy, Got: %v", want, b.Power)
+	}
+	if want := b.Feed / (b.Rpm * 2); math.Abs(b.ChipLoad-want) > 1e-9 {
+		t.Errorf("Chip load → Expected: %v a flute, Got: %v", want, b.ChipLoad)
+	}
+
+	var power *Overload
+	for _, o := range s.Analysis.Overloads {
+		if o.What == "spindle power" {
+			power = o
+		}
+	}
+	if power == nil || power.Line != 5 || power.Limit != 5 || power.Value != b.Power {
+		t.Errorf("5 W spindle → Expected: overloaded at line 5 by %v W, Got: %v", b.Power, power)
+	}
+}
@@ -0,0 +1,545 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"math"
+	"sort"
+)
+
+// maxCyclePasses bounds the passes and pecks of a cycle with bad words.
+const maxCyclePasses = 1000
+
+// turnCycle
+// The words of the first block of the Fanuc lathe cycles, kept until
+// they are given again.  The second block runs the cycle.
+type turnCycle struct {
+	depth     float64 // G71 U and G72 W, the depth of each roughing cut
+	retract   float64 // G71 and G72 R, the pull back after each cut
+	reliefX   float64 // G73 U, the X stock the G73 passes remove
+	reliefZ   float64 // G73 W
+	divisions int     // G73 R, the number of G73 passes
+	peck      float64 // G74 and G75 R, the pull back after each peck
+	finishing int     // G76 P, the passes at the full depth
+	chamfer   float64 // G76 P, the pull out in tenths of the lead
+	angle     float64 // G76 P, the included angle of the thread
+	minDepth  float64 // G76 Q, the least depth of a pass
+	allowance float64 // G76 R, the depth of the finishing pass
+}
+
+func defaultTurnCycle() turnCycle {
+	return turnCycle{
+		depth:     1,
+		retract:   0.5,
+		divisions: 1,
+		peck:      0.5,
+		finishing: 1,
+		angle:     60,
+		minDepth:  0.05,
+	}
+}
+
+// cycleMove
+// One straight move of an expanded cycle.
+type cycleMove struct {
+	kind int
+	to   *tooling.Point
+}
+
+// increment
+// A cycle word Fanuc writes without a decimal point, in µm.
+func increment(c *gcode.Coords, w byte, v float64) float64 {
+	if c.Decimal(w) {
+		return v
+	}
+	return v / 1000
+}
+
+func sign(v float64) float64 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// cmdTurnCycle
+// G70 to G76 on a lathe, nothing on a mill.
+func cmdTurnCycle(s *Sim, cn *gcode.CmdNode) {
+	l := s.lathe()
+	if l == nil {
+		return
+	}
+	c := cn.Cmd.Coords()
+	start := s.ToolHead.Pos()
+	moves := c.Has('X') || c.Has('Z') || c.Has('U') || c.Has('W')
+	runs := c.Has('P') && c.Has('Q')
+
+	switch cn.Cmd.CmdType() {
+	case gcode.CMD_FINISH_CYCLE:
+		blocks := s.cycleBlocks(cn)
+		for _, b := range blocks {
+			if b.Cmd.CmdType() != gcode.CMD_FINISH_CYCLE {
+				cmdVisitor(s, b)
+			}
+		}
+		if blocks != nil {
+			s.turnTo(cn, l, MOVE_RAPID, start)
+		}
+
+	case gcode.CMD_TURN_CYCLE, gcode.CMD_FACE_CYCLE:
+		alongZ := cn.Cmd.CmdType() == gcode.CMD_TURN_CYCLE
+		if !runs {
+			if alongZ && c.Has('U') {
+				s.cycle.depth = c.U
+			}
+			if !alongZ && c.Has('W') {
+				s.cycle.depth = c.W
+			}
+			if c.Has('R') {
+				s.cycle.retract = c.R
+			}
+			return
+		}
+		blocks := s.cycleBlocks(cn)
+		if blocks == nil {
+			return
+		}
+		profile, pockets := cycleProfile(l, blocks, start)
+		profile = shiftMoves(profile, l.ProgramX(c.U), c.W)
+		s.runCycle(cn, l, roughCycle(profile, start, s.cycle.depth, s.cycle.retract, alongZ, pockets))
+		s.skipProfile(cn, blocks)
+
+	case gcode.CMD_PATTERN_CYCLE:
+		if !runs {
+			if c.Has('U') {
+				s.cycle.reliefX = c.U
+			}
+			if c.Has('W') {
+				s.cycle.reliefZ = c.W
+			}
+			if c.Has('R') {
+				s.cycle.divisions = int(math.Max(1, math.Round(c.R)))
+			}
+			return
+		}
+		blocks := s.cycleBlocks(cn)
+		if blocks == nil {
+			return
+		}
+		profile, _ := cycleProfile(l, blocks, start)
+		profile = shiftMoves(profile, l.ProgramX(c.U), c.W)
+		s.runCycle(cn, l, patternCycle(profile, start, s.cycle.reliefX, s.cycle.reliefZ, s.cycle.divisions))
+		s.skipProfile(cn, blocks)
+
+	case gcode.CMD_FACE_PECK_CYCLE, gcode.CMD_GROOVE_CYCLE:
+		if !moves {
+			if c.Has('R') {
+				s.cycle.peck = c.R
+			}
+			return
+		}
+		target := turnPoint(l, c, start)
+		// P is the step in X and Q in Z, for either cycle
+		stepX := increment(c, 'P', c.P)
+		stepZ := increment(c, 'Q', c.Q)
+		relief := 0.0
+		if c.Has('R') {
+			relief = c.R
+		}
+		if cn.Cmd.CmdType() == gcode.CMD_FACE_PECK_CYCLE {
+			s.runCycle(cn, l, peckCycle(start, target, stepZ, stepX, s.cycle.peck, relief, true))
+		} else {
+			s.runCycle(cn, l, peckCycle(start, target, stepX, stepZ, s.cycle.peck, relief, false))
+		}
+
+	case gcode.CMD_THREAD_CYCLE:
+		if !moves {
+			if c.Has('P') {
+				p := int(c.P)
+				s.cycle.finishing = p / 10000
+				s.cycle.chamfer = float64(p / 100 % 100)
+				s.cycle.angle = float64(p % 100)
+			}
+			if c.Has('Q') {
+				s.cycle.minDepth = increment(c, 'Q', c.Q)
+			}
+			if c.Has('R') {
+				s.cycle.allowance = c.R
+			}
+			return
+		}
+		if l.CssOn() {
+			s.warn(cn, s.Clock, "threading under constant surface speed, the lead follows the spindle")
+		}
+		end := turnPoint(l, c, start)
+		height := increment(c, 'P', c.P)
+		first := increment(c, 'Q', c.Q)
+		taper := 0.0
+		if c.Has('R') {
+			taper = c.R
+		}
+		s.runCycle(cn, l, threadCycle(&s.cycle, start, end, height, first, taper, c.F))
+	}
+}
+
+// cycleBlocks
+// The profile blocks N P through N Q of a cycle.
+func (s *Sim) cycleBlocks(cn *gcode.CmdNode) []*gcode.CmdNode {
+	c := cn.Cmd.Coords()
+	var blocks []*gcode.CmdNode
+	if s.tree != nil {
+		blocks = s.tree.Blocks(int(c.P), int(c.Q))
+	}
+	if blocks == nil {
+		s.warn(cn, s.Clock, "there are no blocks N%v to N%v for the cycle", int(c.P), int(c.Q))
+	}
+	return blocks
+}
+
+// skipProfile
+// After G71 to G73 the program goes on after the profile.
+func (s *Sim) skipProfile(cn *gcode.CmdNode, blocks []*gcode.CmdNode) {
+	if blocks[0].Cmd.Line() > cn.Cmd.Line() {
+		s.skipThrough = blocks[len(blocks)-1]
+	}
+}
+
+func (s *Sim) runCycle(cn *gcode.CmdNode, l *tooling.Lathe, moves []*cycleMove) {
+	for _, m := range moves {
+		s.turnTo(cn, l, m.kind, m.to)
+	}
+}
+
+// cycleProfile
+// The moves of the profile blocks from the start point, arcs in chords.
+// True for a type II profile, when the first block moves in both X and Z.
+func cycleProfile(l *tooling.Lathe, blocks []*gcode.CmdNode, start *tooling.Point) ([]*cycleMove, bool) {
+	var ret []*cycleMove
+	pockets := false
+	cur := start
+	for _, cn := range blocks {
+		c := cn.Cmd.Coords()
+		to := turnPoint(l, c, cur)
+		switch cn.Cmd.CmdType() {
+		case gcode.CMD_FAST, gcode.CMD_LINEAR:
+			if ret == nil {
+				pockets = (c.Has('X') || c.Has('U')) && (c.Has('Z') || c.Has('W'))
+			}
+			kind := MOVE_FEED
+			if cn.Cmd.CmdType() == gcode.CMD_FAST {
+				kind = MOVE_RAPID
+			}
+			ret = append(ret, &cycleMove{kind: kind, to: to})
+		case gcode.CMD_CW_ARC, gcode.CMD_CCW_ARC:
+			ccw := cn.Cmd.CmdType() == gcode.CMD_CCW_ARC
+			center := turnCenter(c, cur, to, ccw)
+			n := turnChords(cur, to, center, ccw)
+			for i := 1; i <= n; i++ {
+				at := tooling.ArcAt(cur, to, center, tooling.PLANE_XZ, ccw, float64(i)/float64(n))
+				ret = append(ret, &cycleMove{kind: MOVE_FEED, to: at})
+			}
+		default:
+			continue
+		}
+		cur = to
+	}
+	return ret, pockets
+}
+
+// shiftMoves
+// The moves moved by the finishing allowance, or a G73 relief.
+func shiftMoves(moves []*cycleMove, dx float64, dz float64) []*cycleMove {
+	ret := make([]*cycleMove, len(moves))
+	for i, m := range moves {
+		ret[i] = &cycleMove{kind: m.kind, to: &tooling.Point{X: m.to.X + dx, Z: m.to.Z + dz}}
+	}
+	return ret
+}
+
+// uvAxes
+// Cycles cut along u and step in v, along Z and in X when alongZ.
+func uvAxes(alongZ bool) (func(p *tooling.Point) (float64, float64), func(u float64, v float64) *tooling.Point) {
+	if alongZ {
+		return func(p *tooling.Point) (float64, float64) { return p.Z, p.X },
+			func(u float64, v float64) *tooling.Point { return &tooling.Point{X: v, Z: u} }
+	}
+	return func(p *tooling.Point) (float64, float64) { return p.X, p.Z },
+		func(u float64, v float64) *tooling.Point { return &tooling.Point{X: u, Z: v} }
+}
+
+// roughCycle
+// G71 and G72 stock removal.  Each cut runs along u from the start
+// until it meets the profile, at levels depth apart stepping in v from
+// the start toward the profile.  A type I cycle only cuts from the
+// start, type II also cuts the pockets.  A last pass follows the
+// profile and the tool goes back to the start.
+func roughCycle(profile []*cycleMove, start *tooling.Point, depth float64, retract float64, alongZ bool, pockets bool) []*cycleMove {
+	if len(profile) == 0 || depth <= 0 {
+		return nil
+	}
+	uv, at := uvAxes(alongZ)
+	n := len(profile)
+	us := make([]float64, n)
+	vs := make([]float64, n)
+	for i, m := range profile {
+		us[i], vs[i] = uv(m.to)
+	}
+	ua, va := uv(start)
+	cu := sign(us[n-1] - ua)
+	sv := sign(vs[0] - va)
+	if cu == 0 || sv == 0 {
+		return nil
+	}
+	far := va
+	for _, v := range vs {
+		if sv*(v-far) > 0 {
+			far = v
+		}
+	}
+
+	var ret []*cycleMove
+	add := func(kind int, u float64, v float64) {
+		ret = append(ret, &cycleMove{kind: kind, to: at(u, v)})
+	}
+	for k := 1; k <= maxCyclePasses; k++ {
+		level := va + sv*float64(k)*depth
+		if sv*(level-far) > 0 {
+			level = far
+		}
+		back := level - sv*retract
+		above := math.Max(sv*va, sv*(level-sv*depth)) * sv
+		lastU := ua
+		for _, iv := range roughIntervals(us, vs, ua, cu, sv, level) {
+			u1, u2 := iv[0], iv[1]
+			if u1 == ua {
+				add(MOVE_RAPID, ua, level)
+			} else {
+				if !pockets {
+					break
+				}
+				// over the part, down to the last level cut in the pocket
+				add(MOVE_RAPID, lastU, va)
+				add(MOVE_RAPID, u1, va)
+				add(MOVE_RAPID, u1, above)
+				add(MOVE_FEED, u1, level)
+			}
+			add(MOVE_FEED, u2, level)
+			add(MOVE_RAPID, u2-cu*retract, back)
+			if u1 == ua {
+				add(MOVE_RAPID, ua, back)
+				lastU = ua
+			} else {
+				add(MOVE_RAPID, u2-cu*retract, va)
+				lastU = u2 - cu*retract
+			}
+		}
+		if lastU != ua {
+			add(MOVE_RAPID, ua, va)
+		}
+		if level == far {
+			break
+		}
+	}
+
+	add(MOVE_RAPID, ua, va)
+	ret = append(ret, profile...)
+	add(MOVE_RAPID, us[n-1], va)
+	add(MOVE_RAPID, ua, va)
+	return ret
+}
+
+// roughIntervals
+// Where a cut at the level is in stock, from ua in the direction cu
+// to the end of the profile.  There is stock where the profile is
+// farther than the level in the direction sv, the profile is flat
+// before its first point.
+func roughIntervals(us []float64, vs []float64, ua float64, cu float64, sv float64, level float64) [][2]float64 {
+	n := len(us)
+	end := us[n-1]
+	vAt := func(u float64) float64 {
+		if cu*(u-us[0]) <= 0 {
+			return vs[0]
+		}
+		for i := 1; i < n; i++ {
+			if cu*(u-us[i]) <= 0 {
+				t := (u - us[i-1]) / (us[i] - us[i-1])
+				return vs[i-1] + t*(vs[i]-vs[i-1])
+			}
+		}
+		return vs[n-1]
+	}
+
+	cuts := []float64{ua, end}
+	cuts = append(cuts, us...)
+	for i := 1; i < n; i++ {
+		a, b := vs[i-1]-level, vs[i]-level
+		if a*b < 0 {
+			cuts = append(cuts, us[i-1]+a/(a-b)*(us[i]-us[i-1]))
+		}
+	}
+	inside := cuts[:0]
+	for _, u := range cuts {
+		if cu*(u-ua) >= 0 && cu*(end-u) >= 0 {
+			inside = append(inside, u)
+		}
+	}
+	sort.Slice(inside, func(i int, j int) bool { return cu*inside[i] < cu*inside[j] })
+
+	var ret [][2]float64
+	for i := 1; i < len(inside); i++ {
+		a, b := inside[i-1], inside[i]
+		if cu*(b-a) < 1e-9 {
+			continue
+		}
+		if sv*(vAt((a+b)/2)-level) < -1e-9 {
+			continue
+		}
+		if len(ret) > 0 && ret[len(ret)-1][1] == a {
+			ret[len(ret)-1][1] = b
+		} else {
+			ret = append(ret, [2]float64{a, b})
+		}
+	}
+	return ret
+}
+
+// patternCycle
+// G73, the profile again and again, each time closer by the relief
+// over the divisions, the last on the finishing allowance.
+func patternCycle(profile []*cycleMove, start *tooling.Point, reliefX float64, reliefZ float64, divisions int) []*cycleMove {
+	if len(profile) == 0 {
+		return nil
+	}
+	var ret []*cycleMove
+	for k := divisions - 1; k >= 0; k-- {
+		f := 0.0
+		if divisions > 1 {
+			f = float64(k) / float64(divisions-1)
+		}
+		ret = append(ret, shiftMoves(profile, f*reliefX, f*reliefZ)...)
+		last := ret[len(ret)-1].to
+		ret = append(ret,
+			&cycleMove{kind: MOVE_RAPID, to: &tooling.Point{X: start.X, Z: last.Z}},
+			&cycleMove{kind: MOVE_RAPID, to: start})
+	}
+	return ret
+}
+
+// peckCycle
+// G74 and G75, pecking along u to the target peck at a time, pulling
+// back retract after each, then over in v by step until the target.
+// G74 pecks along Z and G75 along X.  Relief moves off the bottom
+// before coming back out.
+func peckCycle(start *tooling.Point, target *tooling.Point, peck float64, step float64, retract float64, relief float64, alongZ bool) []*cycleMove {
+	uv, at := uvAxes(alongZ)
+	ua, va := uv(start)
+	ut, vt := uv(target)
+	cu := sign(ut - ua)
+	cv := sign(vt - va)
+
+	var ret []*cycleMove
+	add := func(kind int, u float64, v float64) {
+		ret = append(ret, &cycleMove{kind: kind, to: at(u, v)})
+	}
+	v := va
+	for pass := 0; pass < maxCyclePasses; pass++ {
+		add(MOVE_RAPID, ua, v)
+		u := ua
+		for i := 0; i < maxCyclePasses && cu*(ut-u) > 0; i++ {
+			next := ut
+			if peck > 0 && cu*(ut-u) > peck {
+				next = u + cu*peck
+			}
+			add(MOVE_FEED, next, v)
+			u = next
+			if cu*(ut-u) > 0 && retract > 0 {
+				add(MOVE_RAPID, u-cu*retract, v)
+			}
+		}
+		off := v
+		if relief > 0 && cv != 0 {
+			off = v - cv*relief
+			add(MOVE_RAPID, u, off)
+		}
+		add(MOVE_RAPID, ua, off)
+		if cv == 0 || step <= 0 || cv*(vt-v) <= 1e-9 {
+			break
+		}
+		v += cv * step
+		if cv*(v-vt) > 0 {
+			v = vt
+		}
+	}
+	add(MOVE_RAPID, ua, va)
+	return ret
+}
+
+// threadDepths
+// The depth of each threading pass from the crest, the first depth
+// deeper by the square root of the pass, so each removes about as
+// much, and at least the least depth deeper.  Then the finishing
+// allowance and the passes at the full height.
+func threadDepths(height float64, first float64, minDepth float64, allowance float64, finishing int) []float64 {
+	if first <= 0 {
+		first = height
+	}
+	rough := height - allowance
+	var ret []float64
+	prev := 0.0
+	for n := 1; n < maxCyclePasses; n++ {
+		d := math.Max(first*math.Sqrt(float64(n)), prev+minDepth)
+		if d >= rough {
+			break
+		}
+		ret = append(ret, d)
+		prev = d
+	}
+	if allowance > 0 && rough > prev {
+		ret = append(ret, rough)
+	}
+	for i := 0; i < finishing || i == 0; i++ {
+		ret = append(ret, height)
+	}
+	return ret
+}
+
+// threadCycle
+// G76, passes from the start to end, end at the root of the thread
+// and the crest height further out.  Each pass starts in along the
+// flank of the thread, taper is how much further out the thread starts
+// than it ends, and the chamfer pulls out at the end over tenths of
+// the lead.
+func threadCycle(tc *turnCycle, start *tooling.Point, end *tooling.Point, height float64, first float64, taper float64, lead float64) []*cycleMove {
+	sx := sign(start.X - end.X)
+	cz := sign(end.Z - start.Z)
+	if sx == 0 || cz == 0 || height <= 0 {
+		return nil
+	}
+	flank := math.Tan(tc.angle * math.Pi / 360)
+	pull := tc.chamfer / 10 * lead
+
+	var ret []*cycleMove
+	add := func(kind int, x float64, z float64) {
+		ret = append(ret, &cycleMove{kind: kind, to: &tooling.Point{X: x, Z: z}})
+	}
+	for _, d := range threadDepths(height, first, tc.minDepth, tc.allowance, tc.finishing) {
+		xe := end.X + sx*(height-d)
+		xs := xe + taper
+		zs := start.Z - cz*d*flank
+		add(MOVE_RAPID, xs, zs)
+		if pull > 0 && pull < math.Abs(end.Z-zs) {
+			zc := end.Z - cz*pull
+			xc := xs + (xe-xs)*(zc-zs)/(end.Z-zs)
+			add(MOVE_FEED, xc, zc)
+			add(MOVE_FEED, xc+sx*pull, end.Z)
+		} else {
+			add(MOVE_FEED, xe, end.Z)
+		}
+		add(MOVE_RAPID, start.X, end.Z)
+		add(MOVE_RAPID, start.X, start.Z)
+	}
+	return ret
+}
@@ -0,0 +1,98 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"math"
+	"testing"
+)
+
+func TestRoughCycleTypeI(t *testing.T) {
+	// a shaft of radius 10 for 20 long, then a shoulder out to radius 20
+	profile := []*cycleMove{
+		{kind: MOVE_RAPID, to: &tooling.Point{X: 10, Z: 2}},
+		{kind: MOVE_FEED, to: &tooling.Point{X: 10, Z: -20}},
+		{kind: MOVE_FEED, to: &tooling.Point{X: 20, Z: -20}},
+	}
+	start := &tooling.Point{X: 22, Z: 2}
+	moves := roughCycle(profile, start, 2, 0.5, true, false)
+
+	cuts := 0
+	for _, m := range moves[:len(moves)-len(profile)-3] {
+		if m.kind != MOVE_FEED {
+			continue
+		}
+		cuts++
+		if m.to.X < 10 || math.Abs(m.to.Z+20) > 1e-9 {
+			t.Errorf("Roughing cut → Expected to end on the shoulder at Z-20, Got: %v", m.to)
+		}
+	}
+	if cuts != 6 {
+		t.Errorf("Roughing cuts → Expected: 6, from radius 20 to 10, Got: %v", cuts)
+	}
+	if last := moves[len(moves)-1].to; last.Dist(start) != 0 {
+		t.Errorf("End of the cycle → Expected: %v, Got: %v", start, last)
+	}
+}
+
+func TestRoughCycleTypeII(t *testing.T) {
+	// a pocket down to radius 6 between two lands at radius 10
+	profile := []*cycleMove{
+		{kind: MOVE_RAPID, to: &tooling.Point{X: 10, Z: 1}},
+		{kind: MOVE_FEED, to: &tooling.Point{X: 10, Z: -10}},
+		{kind: MOVE_FEED, to: &tooling.Point{X: 6, Z: -14}},
+		{kind: MOVE_FEED, to: &tooling.Point{X: 10, Z: -18}},
+		{kind: MOVE_FEED, to: &tooling.Point{X: 10, Z: -25}},
+	}
+	start := &tooling.Point{X: 12, Z: 2}
+	moves := roughCycle(profile, start, 1, 0.5, true, true)
+
+	deepest := start.X
+	for _, m := range moves {
+		if m.kind == MOVE_FEED {
+			deepest = math.Min(deepest, m.to.X)
+		}
+		// a rapid may not go below the lands outside the pocket
+		if m.kind == MOVE_RAPID && m.to.X < 10 && (m.to.Z > -10 || m.to.Z < -18) && m.to.Z < start.Z {
+			t.Errorf("Rapid → Expected clear of the lands, Got: %v", m.to)
+		}
+	}
+	if deepest != 6 {
+		t.Errorf("Pocket → Expected cut down to radius 6, Got: %v", deepest)
+	}
+	if type1 := roughCycle(profile, start, 1, 0.5, true, false); len(type1) >= len(moves) {
+		t.Errorf("Type I → Expected fewer moves without the pocket, Got: %v and %v", len(type1), len(moves))
+	}
+}
+
+func TestThreadDepths(t *testing.T) {
+	depths := threadDepths(1.3, 0.3, 0.05, 0.02, 2)
+	prev := 0.0
+	for i, d := range depths[:len(depths)-1] {
+		if d < prev || (i < len(depths)-3 && d-prev < 0.05-1e-9) {
+			t.Errorf("Pass %v → Expected at least 0.05 deeper than %v, Got: %v", i, prev, d)
+		}
+		prev = d
+	}
+	n := len(depths)
+	if depths[n-1] != 1.3 || depths[n-2] != 1.3 || depths[n-3] != 1.28 {
+		t.Errorf("Last passes → Expected 1.28 then 1.3 twice, Got: %v", depths[n-3:])
+	}
+}
+
+func TestPeckCycle(t *testing.T) {
+	start := &tooling.Point{X: 0, Z: 2}
+	target := &tooling.Point{X: 0, Z: -10}
+	moves := peckCycle(start, target, 3, 0, 0.5, 0, true)
+	pecks := 0
+	for _, m := range moves {
+		if m.kind == MOVE_FEED {
+			pecks++
+		}
+	}
+	if pecks != 4 {
+		t.Errorf("Pecks → Expected: 4 of 3mm for 12mm, Got: %v", pecks)
+	}
+	if last := moves[len(moves)-1].to; last.Dist(start) != 0 {
+		t.Errorf("End of the cycle → Expected: %v, Got: %v", start, last)
+	}
+}
@@ -0,0 +1,187 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"math"
+)
+
+// rotaryStep is the largest rotary change in degrees for one piece
+// of a multi-axis move, the tip does not move in a line between.
+const rotaryStep = 1.0
+
+// toolLength
+// The gauge length of the mounted tool, spindle nose to tip.
+func (s *Sim) toolLength() float64 {
+	c := s.ToolHead.Cutter()
+	return c.Length + c.HolderLength
+}
+
+// Pose
+// Where the tool tip is and which way the tool points in the workpiece.
+func (s *Sim) Pose() *tooling.Pose {
+	return &tooling.Pose{Tip: s.ToolHead.Pos(), Axis: s.toolAxis()}
+}
+
+func (s *Sim) toolAxis() *tooling.Point {
+	return s.Tool.Kinematics().Forward(&s.Joints, s.toolLength()).Axis
+}
+
+func cmdTcp(s *Sim, on bool) {
+	s.Tcp = on
+}
+
+// cmdMultiAxis
+// A linear move on a machine with rotaries.  With tool center point
+// control the tip moves in a line in the workpiece while the rotaries
+// turn, without it the joints move in a line and the tip follows the
+// kinematics.  Either way the move is simulated in pieces short enough
+// for the tool to be taken as fixed in each.
+func cmdMultiAxis(s *Sim, cn *gcode.CmdNode) {
+	kind := MOVE_FEED
+	if cn.Cmd.CmdType() == gcode.CMD_FAST {
+		kind = MOVE_RAPID
+	}
+	multiAxisMove(s, cn, kind, false, false)
+}
+
+// cmdMultiAxisArc
+// G2 and G3 on a machine with rotaries, in chords through the
+// kinematics as a line is.  The arc is the tip's in the workpiece with
+// tool center point control and the linear joints' without, rotaries
+// given turn along it.
+func cmdMultiAxisArc(s *Sim, cn *gcode.CmdNode, ccw bool) {
+	multiAxisMove(s, cn, MOVE_FEED, true, ccw)
+}
+
+// multiAxisMove
+// The move to the command's words, a line or an arc of the plane in
+// the programmed coordinates, in pieces no longer than rotaryStep and
+// arcChord.
+func multiAxisMove(s *Sim, cn *gcode.CmdNode, kind int, arc bool, ccw bool) {
+	k := s.Tool.Kinematics()
+	c := cn.Cmd.Coords()
+	length := s.toolLength()
+
+	tipFr := s.ToolHead.Pos()
+	fr := k.Inverse(tipFr, &s.Joints, length)
+	to := *fr
+	turn := 0.0
+	for _, r := range k.Rotaries {
+		cmd := c.C
+		switch r.Name {
+		case "A":
+			cmd = c.A
+		case "B":
+			cmd = c.B
+		}
+		a := r.Next(fr.Angle(r.Name), cmd)
+		if !r.InLimits(a) {
+			s.warn(cn, s.Clock, "%v%v is past the %v to %v travel", r.Name, a, r.Min, r.Max)
+		}
+		to.SetAngle(r.Name, a)
+		turn = math.Max(turn, math.Abs(a-fr.Angle(r.Name)))
+	}
+
+	// the programmed path, the tip's with TCP and the joints' without
+	pFr := &tooling.Point{X: fr.X, Y: fr.Y, Z: fr.Z}
+	if s.Tcp {
+		pFr = tipFr
+	}
+	pTo := CmdToXYZ(c, pFr)
+	if s.Tcp {
+		to = *k.Inverse(pTo, &to, length)
+	} else {
+		to.X, to.Y, to.Z = pTo.X, pTo.Y, pTo.Z
+	}
+
+	linear := math.Sqrt((to.X-fr.X)*(to.X-fr.X) + (to.Y-fr.Y)*(to.Y-fr.Y) + (to.Z-fr.Z)*(to.Z-fr.Z))
+	pieces := int(math.Max(1, math.Ceil(turn/rotaryStep)))
+	along := func(t float64) *tooling.Point {
+		return &tooling.Point{X: pFr.X + t*(pTo.X-pFr.X), Y: pFr.Y + t*(pTo.Y-pFr.Y), Z: pFr.Z + t*(pTo.Z-pFr.Z)}
+	}
+	if arc {
+		plane := s.Tool.Plane()
+		center := arcCenter(c, pFr, pTo, plane, ccw)
+		_, _, sweep := tooling.ArcAngles(pFr, pTo, center, plane, ccw)
+		linear = tooling.ArcLength(pFr, pTo, center, plane, ccw)
+		pieces = int(math.Max(float64(pieces), math.Ceil(math.Abs(sweep)/arcChord)))
+		along = func(t float64) *tooling.Point {
+			return tooling.ArcAt(pFr, pTo, center, plane, ccw, t)
+		}
+	}
+	if linear == 0 && turn == 0 {
+		return
+	}
+
+	duration := multiAxisDuration(s, kind, linear, turn)
+	for i := 1; i <= pieces; i++ {
+		t := float64(i) / float64(pieces)
+		j := lerpJoints(fr, &to, t)
+		mid := lerpJoints(fr, &to, (float64(i)-0.5)/float64(pieces))
+		p := along(t)
+		var tip *tooling.Point
+		if s.Tcp {
+			tip = p
+			j = k.Inverse(tip, j, length)
+		} else {
+			j.X, j.Y, j.Z = p.X, p.Y, p.Z
+			tip = k.Forward(j, length).Tip
+		}
+
+		m := s.beginMove(kind, cn)
+		m.Axis = k.Forward(mid, length).Axis
+		m.Duration = duration / float64(pieces)
+		s.ToolHead.MoveTo(tip)
+		s.Joints = *j
+		s.endMove(m, tip)
+	}
+}
+
+// arcCenter
+// The center of a G2 or G3 in the plane, from R or from I, J and K
+// from the start.
+func arcCenter(c *gcode.Coords, fr *tooling.Point, to *tooling.Point, plane int, ccw bool) *tooling.Point {
+	if c.Has('R') {
+		return tooling.RadiusCenter(fr, to, c.R, plane, ccw)
+	}
+	switch plane {
+	case tooling.PLANE_XZ:
+		return &tooling.Point{X: fr.X + c.I, Y: fr.Y, Z: fr.Z + c.K}
+	case tooling.PLANE_YZ:
+		return &tooling.Point{X: fr.X, Y: fr.Y + c.J, Z: fr.Z + c.K}
+	}
+	return &tooling.Point{X: fr.X + c.I, Y: fr.Y + c.J, Z: fr.Z}
+}
+
+// multiAxisDuration
+// Linear axes at the feed in mm/min, a move of only rotaries at the
+// feed in degrees/min, or the whole move in 60/F with inverse time.
+// Rapids of the linear axes speed up at the Accel.
+func multiAxisDuration(s *Sim, kind int, linear float64, turn float64) float64 {
+	f := s.Tool.FeedRate()
+	if f <= 0 {
+		return 0
+	}
+	if kind != MOVE_RAPID && s.Tool.CurrentFeedMode() == tooling.FEED_INVERSE_TIME {
+		return 60 / f
+	}
+	if linear > 0 {
+		if kind == MOVE_RAPID {
+			return tooling.MoveTime(linear, f, s.Accel, 0)
+		}
+		return linear / (f / 60)
+	}
+	return turn / (f / 60)
+}
+
+func lerpJoints(fr *tooling.Joints, to *tooling.Joints, t float64) *tooling.Joints {
+	return &tooling.Joints{
+		X: fr.X + t*(to.X-fr.X),
+		Y: fr.Y + t*(to.Y-fr.Y),
+		Z: fr.Z + t*(to.Z-fr.Z),
+		A: fr.A + t*(to.A-fr.A),
+		B: fr.B + t*(to.B-fr.B),
+		C: fr.C + t*(to.C-fr.C),
+	}
+}
@@ -0,0 +1,41 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"math"
+	"testing"
+)
+
+func TestArcOnRotary(t *testing.T) {
+	indexer := func(s *Sim) {
+		s.Tool.SetKinematics(tooling.MakeIndexer("A", &tooling.Point{}))
+	}
+
+	// a half circle over the block with A still, the tip on the arc
+	s := runWith(t, "G0 Z30\nG0 X10 Y0\nG3 X-10 Y0 I-10 J0 F500\n", indexer)
+	arc := 0
+	top := 0.0
+	for _, m := range s.Moves {
+		if m.Node == nil || m.Node.Cmd.CmdType() != gcode.CMD_CCW_ARC {
+			continue
+		}
+		arc++
+		if r := math.Hypot(m.To.X, m.To.Y); math.Abs(r-10) > 1e-6 {
+			t.Errorf("Arc radius → Expected: 10, Got: %v at %v", r, m.To)
+		}
+		top = math.Max(top, m.To.Y)
+	}
+	if arc < 2 || math.Abs(top-10) > 0.1 {
+		t.Errorf("Arc → Expected: chords over Y10, Got: %v pieces to Y%v", arc, top)
+	}
+	if math.Abs(s.Joints.X+10) > 1e-6 || math.Abs(s.Joints.Y) > 1e-6 || math.Abs(s.Joints.Z-30) > 1e-6 {
+		t.Errorf("Joints → Expected: X-10 Y0 Z30, Got: %v", s.Joints)
+	}
+
+	// R and A together, the joints ending where the words put them
+	s = runWith(t, "G0 Z30\nG0 X10 Y0\nG2 X-10 Y0 R10 A90 F500\n", indexer)
+	if math.Abs(s.Joints.X+10) > 1e-6 || math.Abs(s.Joints.Y) > 1e-6 || math.Abs(s.Joints.A-90) > 1e-6 {
+		t.Errorf("Joints → Expected: X-10 Y0 A90, Got: %v", s.Joints)
+	}
+}
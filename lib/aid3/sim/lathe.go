@@ -0,0 +1,188 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"log"
+	"math"
+	"os"
+)
+
+// arcChord is the largest turn in radians of one chord of a turned arc.
+const arcChord = math.Pi / 36
+
+// lathe
+// The machine as a lathe, nil when it is not one.
+func (s *Sim) lathe() *tooling.Lathe {
+	l, _ := s.Tool.(*tooling.Lathe)
+	return l
+}
+
+// turnPoint
+// The head position for the X and Z words, X in diameter or radius.
+// U and W move X and Z by that much.
+func turnPoint(l *tooling.Lathe, c *gcode.Coords, cur *tooling.Point) *tooling.Point {
+	ret := &tooling.Point{X: cur.X, Z: cur.Z}
+	if c.Has('X') {
+		ret.X = l.ProgramX(c.X)
+	}
+	if c.Has('Z') {
+		ret.Z = c.Z
+	}
+	if c.Has('U') {
+		ret.X += l.ProgramX(c.U)
+	}
+	if c.Has('W') {
+		ret.Z += c.W
+	}
+	return ret
+}
+
+// cmdTurn
+// G0 and G1 on a lathe.
+func cmdTurn(s *Sim, cn *gcode.CmdNode, l *tooling.Lathe) {
+	kind := MOVE_FEED
+	if cn.Cmd.CmdType() == gcode.CMD_FAST {
+		kind = MOVE_RAPID
+	}
+	s.turnTo(cn, l, kind, turnPoint(l, cn.Cmd.Coords(), s.ToolHead.Pos()))
+}
+
+// turnTo
+// One straight move of a lathe, rapid or at the feed.
+func (s *Sim) turnTo(cn *gcode.CmdNode, l *tooling.Lathe, kind int, to *tooling.Point) {
+	if s.ToolHead.Pos().Dist(to) == 0 {
+		return
+	}
+	s.updateCss(l)
+	m := s.beginMove(kind, cn)
+	if kind == MOVE_RAPID {
+		m.Feed = s.Tool.FastFeedRate()
+	}
+	s.ToolHead.MoveTo(to)
+	s.endMove(m, to)
+}
+
+// cmdTurnArc
+// G2 and G3 on a lathe, in the XZ plane.  I and K are from the start
+// to the center, I is a radius even in diameter mode.
+func cmdTurnArc(s *Sim, cn *gcode.CmdNode, l *tooling.Lathe, ccw bool) {
+	c := cn.Cmd.Coords()
+	fr := s.ToolHead.Pos()
+	to := turnPoint(l, c, fr)
+
+	center := turnCenter(c, fr, to, ccw)
+
+	s.updateCss(l)
+	m := s.beginMove(MOVE_ARC, cn)
+	m.Center = center
+	m.Ccw = ccw
+	m.Plane = tooling.PLANE_XZ
+	n := turnChords(fr, to, center, ccw)
+	for i := 1; i <= n; i++ {
+		s.ToolHead.MoveTo(tooling.ArcAt(fr, to, center, tooling.PLANE_XZ, ccw, float64(i)/float64(n)))
+	}
+	s.endMove(m, to)
+}
+
+// turnCenter
+// The center of a turned arc, from R or from I and K.
+func turnCenter(c *gcode.Coords, fr *tooling.Point, to *tooling.Point, ccw bool) *tooling.Point {
+	if c.Has('R') {
+		return radiusCenter(fr, to, c.R, ccw)
+	}
+	return &tooling.Point{X: fr.X + c.I, Z: fr.Z + c.K}
+}
+
+// radiusCenter
+// The center of an arc in the XZ plane given by its radius, negative
+// for the arc over half a circle.
+func radiusCenter(fr *tooling.Point, to *tooling.Point, r float64, ccw bool) *tooling.Point {
+	return tooling.RadiusCenter(fr, to, r, tooling.PLANE_XZ, ccw)
+}
+
+func turnChords(fr *tooling.Point, to *tooling.Point, center *tooling.Point, ccw bool) int {
+	_, _, sweep := tooling.ArcAngles(fr, to, center, tooling.PLANE_XZ, ccw)
+	return int(math.Max(1, math.Ceil(math.Abs(sweep)/arcChord)))
+}
+
+// turnMove
+// Cut the turned stock with the insert along the move, arcs a chord
+// at a time.
+func (s *Sim) turnMove(m *Move) {
+	in := s.lathe().Insert()
+	if m.Kind != MOVE_ARC {
+		m.Removed = s.Turned.Cut(tooling.SweepInsert(in, m.From, m.To))
+		return
+	}
+	m.Removed = &tooling.Removal{}
+	n := turnChords(m.From, m.To, m.Center, m.Ccw)
+	prev := m.From
+	for i := 1; i <= n; i++ {
+		at := tooling.ArcAt(m.From, m.To, m.Center, m.Plane, m.Ccw, float64(i)/float64(n))
+		m.Removed.Add(s.Turned.Cut(tooling.SweepInsert(in, prev, at)))
+		prev = at
+	}
+}
+
+// updateCss
+// Under constant surface speed the spindle follows the radius.
+func (s *Sim) updateCss(l *tooling.Lathe) {
+	if !l.CssOn() {
+		return
+	}
+	s.Tool.Spindle().Speed(l.CssRpm(s.ToolHead.Pos().X), s.Clock)
+}
+
+// cmdLatheSpeed
+// S after G50 on the same line clamps the RPM, under G96 it is the
+// surface speed in m/min.  False when S is a plain RPM.
+func cmdLatheSpeed(s *Sim, cn *gcode.CmdNode, l *tooling.Lathe, speed float64) bool {
+	if s.clampLine != 0 && s.clampLine == cn.Cmd.Line() {
+		l.ClampRpm(speed)
+		s.clampLine = 0
+		return true
+	}
+	if l.CssOn() {
+		l.SurfaceSpeed(speed)
+		s.updateCss(l)
+		return true
+	}
+	return false
+}
+
+// cmdLatheMode
+// G7, G8, G96, G97 and G50, nothing on a mill.
+func cmdLatheMode(s *Sim, cn *gcode.CmdNode) {
+	l := s.lathe()
+	if l == nil {
+		return
+	}
+	switch cn.Cmd.CmdType() {
+	case gcode.CMD_DIAMETER_MODE:
+		l.DiameterMode(true)
+	case gcode.CMD_RADIUS_MODE:
+		l.DiameterMode(false)
+	case gcode.CMD_CSS:
+		l.Css(true)
+	case gcode.CMD_CSS_OFF:
+		// G97 holds the speed CSS was turning at
+		l.Css(false)
+	case gcode.CMD_MAX_SPINDLE:
+		s.clampLine = cn.Cmd.Line()
+	}
+}
+
+func writeProfile(s *Sim) {
+	if s.Turned == nil {
+		return
+	}
+	if f, err := os.Create(s.outFile("profile.csv")); err == nil {
+		defer f.Close()
+		if err = s.Turned.WriteProfile(f); err != nil {
+			log.Printf("Could not write profile.csv : %v", err)
+		}
+	} else {
+		log.Printf("Could not write profile.csv : %v", err)
+	}
+}
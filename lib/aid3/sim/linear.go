@@ -3,15 +3,35 @@ package sim
 import (
 	"github.com/timleecasey/stllib/lib/aid3/gcode"
 	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
-	"math"
 )
 
 func cmdLinear(s *Sim, cn *gcode.CmdNode) {
+	if l := s.lathe(); l != nil {
+		cmdTurn(s, cn, l)
+		return
+	}
+	if len(s.Tool.Kinematics().Rotaries) > 0 {
+		cmdMultiAxis(s, cn)
+		return
+	}
+	kind := MOVE_FEED
+	if cn.Cmd.CmdType() == gcode.CMD_FAST {
+		kind = MOVE_RAPID
+	}
+	m := s.beginMove(kind, cn)
+	toPt := CmdToXYZ(cn.Cmd.Coords(), s.ToolHead.Pos())
+	if p := s.printer(); p != nil {
+		toPt = s.level(p, cn.Cmd.Coords(), toPt)
+	}
+	linearTo(s, m, toPt)
+}
+
+// linearTo
+// Move in a line from the head to toPt in time slices.
+func linearTo(s *Sim, m *Move, toPt *tooling.Point) {
 	curPt := s.ToolHead.Pos()
 	curFeedRate := s.Tool.FeedRate() // mm/s?
-	coords := cn.Cmd.Coords()
-	toPt := CmdToXYZ(coords, curPt)
-	slice := s.TimeSlice // s
+	slice := s.TimeSlice             // s
 	distPerSlice := curFeedRate * slice
 	//
 	// The x,y,z diff over the time slice
@@ -21,7 +41,7 @@ func cmdLinear(s *Sim, cn *gcode.CmdNode) {
 		Y: toPt.Y - curPt.Y,
 		Z: toPt.Z - curPt.Z,
 	}
-	dist := math.Sqrt(math.Pow(toPt.X, 2) + math.Pow(toPt.Y, 2) + math.Pow(toPt.Z, 2))
+	dist := curPt.Dist(toPt)
 	numIntersMoving := (dist / s.Tool.FeedRate()) / s.TimeSlice // (mm / (mm/s) -> s) / s -> count
 
 	if dist != 0 {
@@ -49,6 +69,52 @@ func cmdLinear(s *Sim, cn *gcode.CmdNode) {
 			//
 			runLinearAffine(s, affine, toPt, diffPt)
 		}
+		s.endMove(m, s.ToolHead.Pos())
+	} else if s.printer() != nil {
+		// a retraction only moves the filament
+		s.endMove(m, curPt)
 	}
 
 }
+
+// rapidTo
+// A rapid from the head to toPt for a command which is not itself a
+// move.
+func rapidTo(s *Sim, cn *gcode.CmdNode, toPt *tooling.Point) {
+	feed := s.Tool.FeedRate()
+	s.Tool.AssignFeedRate(s.Tool.FastFeedRate())
+	m := s.beginMove(MOVE_RAPID, cn)
+	linearTo(s, m, toPt)
+	s.Tool.AssignFeedRate(feed)
+}
+
+// cmdHome
+// G28 homes the axes given, or all of them, to the origin.  A printer
+// goes straight there and stops following the bed mesh, as Marlin
+// does.  A mill first goes through the X Y Z given.
+func cmdHome(s *Sim, cn *gcode.CmdNode) {
+	if s.lathe() != nil || len(s.Tool.Kinematics().Rotaries) > 0 {
+		s.warn(cn, s.Clock, "G28 is not simulated on this machine")
+		return
+	}
+	c := cn.Cmd.Coords()
+	all := !c.Has('X') && !c.Has('Y') && !c.Has('Z')
+	if p := s.printer(); p != nil {
+		p.Leveling = false
+		s.levelZ = 0
+	} else if !all {
+		rapidTo(s, cn, CmdToXYZ(c, s.ToolHead.Pos()))
+	}
+	cur := s.ToolHead.Pos()
+	home := &tooling.Point{X: cur.X, Y: cur.Y, Z: cur.Z}
+	if all || c.Has('Z') {
+		home.Z = 0
+	}
+	if all || c.Has('X') {
+		home.X = 0
+	}
+	if all || c.Has('Y') {
+		home.Y = 0
+	}
+	rapidTo(s, cn, home)
+}
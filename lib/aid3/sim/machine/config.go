@@ -0,0 +1,489 @@
+package machine
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/sim"
+	"github.com/timleecasey/stllib/lib/aid3/sim/material"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"github.com/timleecasey/stllib/lib/stl"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config
+// A machine as one checked in file, JSON or YAML.  Type is one of the
+// builtin machines and Dialect the control, as sim.ParseDialect reads
+// it.  Axes limit the travel, X, Y and Z in mm about the machine zero
+// and the rotaries of the kinematics in degrees.  Rapid is the G0 feed in mm/min, 0 for the
+// machine's own, and Accel how fast rapids speed up in mm/s2.
+// WorkOffsets are G54 to G59 by name, the program zero on the machine,
+// all 0 when not given.
+// TimeSlice, Tolerance and Resolution are for the sim, 0 for its
+// defaults, a mill's resolution then coarser for a larger block.  File
+// names in the config are from its directory.
+type Config struct {
+	Name       string          `json:"name" yaml:"name"`
+	Type       string          `json:"type" yaml:"type"`
+	Dialect    string          `json:"dialect" yaml:"dialect"`
+	Axes       []*AxisSpec     `json:"axes" yaml:"axes"`
+	Kinematics *KinematicsSpec `json:"kinematics" yaml:"kinematics"`
+	Rapid      float64         `json:"rapid" yaml:"rapid"`
+	Accel      float64         `json:"accel" yaml:"accel"`
+	Spindle    *SpindleSpec    `json:"spindle" yaml:"spindle"`
+	Changer    *ChangerSpec    `json:"changer" yaml:"changer"`
+	Stock      *StockSpec      `json:"stock" yaml:"stock"`
+	TimeSlice  float64         `json:"timeSlice" yaml:"timeSlice"`
+	Tolerance  float64         `json:"tolerance" yaml:"tolerance"`
+	Resolution float64         `json:"resolution" yaml:"resolution"`
+
+	WorkOffsets map[string]*tooling.Point `json:"workOffsets" yaml:"workOffsets"`
+
+	dir string
+}
+
+// AxisSpec
+// The travel of an axis by name.  Wrap is for a rotary, none for one
+// with limits, shortest or signed for one turning without end.
+type AxisSpec struct {
+	Name string  `json:"name" yaml:"name"`
+	Min  float64 `json:"min" yaml:"min"`
+	Max  float64 `json:"max" yaml:"max"`
+	Wrap string  `json:"wrap" yaml:"wrap"`
+}
+
+// KinematicsSpec
+// Kind is three-axis, indexer, table-table, head-table or head-head.
+// Rotary is the indexer axis or the tilt, A or B.  Pivot is where the
+// table rotaries cross, PivotLength the head pivot above the gauge line.
+type KinematicsSpec struct {
+	Kind        string         `json:"kind" yaml:"kind"`
+	Rotary      string         `json:"rotary" yaml:"rotary"`
+	Pivot       *tooling.Point `json:"pivot" yaml:"pivot"`
+	PivotLength float64        `json:"pivotLength" yaml:"pivotLength"`
+}
+
+// SpindleSpec
+// The gears in RPM, Accel in RPM/s and OrientTime in seconds as in the
+// tooling.Spindle.  Power in W and Torque in Nm limit the cuts.
+type SpindleSpec struct {
+	Gears      []tooling.GearRange `json:"gears" yaml:"gears"`
+	Accel      float64             `json:"accel" yaml:"accel"`
+	OrientTime float64             `json:"orientTime" yaml:"orientTime"`
+	Power      float64             `json:"power" yaml:"power"`
+	Torque     float64             `json:"torque" yaml:"torque"`
+}
+
+// ChangerSpec
+// A changer of Slots tools, 0 for any, taking Time seconds a change.
+// The tools are listed in it or in the Table file.
+type ChangerSpec struct {
+	Slots int         `json:"slots" yaml:"slots"`
+	Time  float64     `json:"time" yaml:"time"`
+	Tools []*ToolSpec `json:"tools" yaml:"tools"`
+	Table string      `json:"table" yaml:"table"`
+}
+
+// StockSpec
+// The stock a program starts from, of a builtin material.  Size is as
+// ParseStock reads it, or Lo and Hi are the corners, or Stl is the
+// shape of it.
+type StockSpec struct {
+	Material string         `json:"material" yaml:"material"`
+	Size     string         `json:"size" yaml:"size"`
+	Lo       *tooling.Point `json:"lo" yaml:"lo"`
+	Hi       *tooling.Point `json:"hi" yaml:"hi"`
+	Stl      string         `json:"stl" yaml:"stl"`
+}
+
+var kinematics = map[string]func(k *KinematicsSpec) *tooling.Kinematics{
+	"three-axis": func(k *KinematicsSpec) *tooling.Kinematics {
+		return tooling.MakeThreeAxis()
+	},
+	"indexer": func(k *KinematicsSpec) *tooling.Kinematics {
+		return tooling.MakeIndexer(k.Rotary, k.Pivot)
+	},
+	"table-table": func(k *KinematicsSpec) *tooling.Kinematics {
+		return tooling.MakeTableTable(k.Rotary, k.Pivot)
+	},
+	"head-table": func(k *KinematicsSpec) *tooling.Kinematics {
+		return tooling.MakeHeadTable(k.Rotary, k.PivotLength, k.Pivot)
+	},
+	"head-head": func(k *KinematicsSpec) *tooling.Kinematics {
+		return tooling.MakeHeadHead(k.Rotary, k.PivotLength)
+	},
+}
+
+var wraps = map[string]int{
+	"":         tooling.WRAP_NONE,
+	"none":     tooling.WRAP_NONE,
+	"shortest": tooling.WRAP_SHORTEST,
+	"signed":   tooling.WRAP_SIGNED,
+}
+
+// Default
+// A mill of the builtin kind, everything else its defaults.
+func Default() *Config {
+	return &Config{Type: "mill"}
+}
+
+// Load
+// A machine config in JSON, or YAML when named .yaml or .yml.
+func Load(fileNm string) (*Config, error) {
+	c := &Config{}
+	if err := decode(fileNm, "machine", c); err != nil {
+		return nil, err
+	}
+	c.dir = filepath.Dir(fileNm)
+	return c, nil
+}
+
+// path
+// A file named in the config, from the directory of the config.
+func (c *Config) path(fileNm string) string {
+	if fileNm == "" || filepath.IsAbs(fileNm) || c.dir == "" {
+		return fileNm
+	}
+	return filepath.Join(c.dir, fileNm)
+}
+
+// ParseStock
+// The corners of stock as WxDxH, DxL or x0,y0,z0:x1,y1,z1 in mm.  The
+// top of the stock is Z 0, WxDxH runs from the origin along X and Y
+// down H, DxL is a bar down Z around the axis.
+func ParseStock(spec string) (*tooling.Point, *tooling.Point, error) {
+	if corners := strings.Split(spec, ":"); len(corners) == 2 {
+		lo, err := parsePoint(corners[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		hi, err := parsePoint(corners[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		if hi.X <= lo.X || hi.Y <= lo.Y || hi.Z <= lo.Z {
+			return nil, nil, fmt.Errorf("stock %q has its corners swapped", spec)
+		}
+		return lo, hi, nil
+	}
+	dims, err := parseFloats(strings.Split(strings.ToLower(spec), "x"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("stock %q: %w", spec, err)
+	}
+	for _, d := range dims {
+		if d <= 0 {
+			return nil, nil, fmt.Errorf("stock %q must be positive", spec)
+		}
+	}
+	switch len(dims) {
+	case 2:
+		r := dims[0] / 2
+		return &tooling.Point{X: -r, Y: -r, Z: -dims[1]}, &tooling.Point{X: r, Y: r, Z: 0}, nil
+	case 3:
+		return &tooling.Point{X: 0, Y: 0, Z: -dims[2]}, &tooling.Point{X: dims[0], Y: dims[1], Z: 0}, nil
+	}
+	return nil, nil, fmt.Errorf("stock %q is neither WxDxH, DxL nor x0,y0,z0:x1,y1,z1", spec)
+}
+
+func parsePoint(s string) (*tooling.Point, error) {
+	v, err := parseFloats(strings.Split(s, ","))
+	if err != nil || len(v) != 3 {
+		return nil, fmt.Errorf("corner %q is not x,y,z", s)
+	}
+	return &tooling.Point{X: v[0], Y: v[1], Z: v[2]}, nil
+}
+
+func parseFloats(fields []string) ([]float64, error) {
+	ret := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", f)
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+// stockCells
+// The most cells a block is cut into when the config leaves the
+// resolution to the size of the stock.
+const stockCells = 1 << 18
+
+// stock
+// The material and corners of the stock, with the mesh of it when it
+// is shaped.  Without a stock it is a 30 mm cube of oak centered on
+// X and Y with its top at Z 0, as ParseStock places stock.
+func (c *Config) stock() (*material.Spec, *tooling.Point, *tooling.Point, *tooling.Mesh, error) {
+	st := c.Stock
+	if st == nil {
+		st = &StockSpec{}
+	}
+	name := st.Material
+	if name == "" {
+		name = "Oak"
+	}
+	spec := material.Builtin().Lookup(name)
+	if spec == nil {
+		return nil, nil, nil, nil, fmt.Errorf("unknown material %q", name)
+	}
+	lo, hi := &tooling.Point{X: -15, Y: -15, Z: -30}, &tooling.Point{X: 15, Y: 15, Z: 0}
+	var mesh *tooling.Mesh
+	switch {
+	case st.Size != "":
+		var err error
+		if lo, hi, err = ParseStock(st.Size); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	case st.Lo != nil && st.Hi != nil:
+		lo, hi = st.Lo, st.Hi
+	case st.Lo != nil || st.Hi != nil:
+		return nil, nil, nil, nil, fmt.Errorf("stock needs both corners")
+	}
+	if st.Stl != "" {
+		model, err := stl.LoadModel(c.path(st.Stl))
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		mesh = model.Mesh()
+		if st.Size == "" && st.Lo == nil {
+			lo, hi = mesh.Bounds()
+		}
+	}
+	return spec, lo, hi, mesh, nil
+}
+
+// Build
+// The machine of the config with its stock and tools.
+func (c *Config) Build() (tooling.Cnc, error) {
+	m, _, err := c.build()
+	return m, err
+}
+
+// build
+// The machine, and the shape of its stock when it is not a block.
+func (c *Config) build() (tooling.Cnc, *tooling.Mesh, error) {
+	spec, lo, hi, mesh, err := c.stock()
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := Builtin(c.Type, material.MakeBlock(spec, lo, hi), lo, hi)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Rapid < 0 || c.Accel < 0 {
+		return nil, nil, fmt.Errorf("rapid and accel must not be negative")
+	}
+	if c.Rapid > 0 {
+		switch t := m.(type) {
+		case *tooling.Simple3d:
+			t.Rapid = c.Rapid
+		case *tooling.Lathe:
+			t.Rapid = c.Rapid
+		case *tooling.BeamCutter:
+			t.Rapid = c.Rapid
+		case *tooling.Printer:
+			t.Rapid = c.Rapid
+		}
+	}
+	if p, ok := m.(*tooling.Printer); ok && c.Accel > 0 {
+		p.TravelAccel = c.Accel
+	}
+	if err = c.kinematics(m); err != nil {
+		return nil, nil, err
+	}
+	if err = c.spindle(m.Spindle()); err != nil {
+		return nil, nil, err
+	}
+	if ch := c.Changer; ch != nil {
+		tools := ch.Tools
+		if ch.Table != "" {
+			table, err := LoadTools(c.path(ch.Table))
+			if err != nil {
+				return nil, nil, err
+			}
+			tools = append(tools, table...)
+		}
+		if err = Mount(m, tools); err != nil {
+			return nil, nil, err
+		}
+	}
+	return m, mesh, nil
+}
+
+// kinematics
+// The rotaries of a mill, limited by the axes named for them.
+func (c *Config) kinematics(m tooling.Cnc) error {
+	k := tooling.MakeThreeAxis()
+	if c.Kinematics != nil {
+		kind := strings.ToLower(c.Kinematics.Kind)
+		build := kinematics[kind]
+		if build == nil {
+			return fmt.Errorf("unknown kinematics %q", c.Kinematics.Kind)
+		}
+		ks := *c.Kinematics
+		ks.Rotary = strings.ToUpper(ks.Rotary)
+		if ks.Pivot == nil {
+			ks.Pivot = &tooling.Point{}
+		}
+		if kind != "three-axis" {
+			if ks.Rotary != "A" && ks.Rotary != "B" {
+				return fmt.Errorf("%v rotary %q is not A or B", kind, c.Kinematics.Rotary)
+			}
+			if m.Type() != tooling.CncMilling {
+				return fmt.Errorf("a %v has no rotaries", c.Type)
+			}
+		}
+		k = build(&ks)
+	}
+	for _, ax := range c.Axes {
+		name := strings.ToUpper(ax.Name)
+		switch name {
+		case "X", "Y", "Z":
+			if ax.Max < ax.Min {
+				return fmt.Errorf("axis %v has min %v over max %v", name, ax.Min, ax.Max)
+			}
+			continue
+		}
+		r := k.Rotary(name)
+		if r == nil {
+			return fmt.Errorf("axis %q is not on the machine", ax.Name)
+		}
+		wrap, ok := wraps[strings.ToLower(ax.Wrap)]
+		if !ok {
+			return fmt.Errorf("axis %v: unknown wrap %q", name, ax.Wrap)
+		}
+		r.Min, r.Max, r.Wrap = ax.Min, ax.Max, wrap
+	}
+	if m.Type() == tooling.CncMilling {
+		m.SetKinematics(k)
+	}
+	return nil
+}
+
+// spindle
+// The speeds and how fast the spindle gets to them.
+func (c *Config) spindle(sp *tooling.Spindle) error {
+	cs := c.Spindle
+	if cs == nil {
+		return nil
+	}
+	for _, g := range cs.Gears {
+		if g.Max <= g.Min || g.Min < 0 {
+			return fmt.Errorf("spindle gear %v to %v", g.Min, g.Max)
+		}
+	}
+	if len(cs.Gears) > 0 {
+		sp.Gears = cs.Gears
+	}
+	if cs.Accel > 0 {
+		sp.Accel = cs.Accel
+	}
+	if cs.OrientTime > 0 {
+		sp.OrientTime = cs.OrientTime
+	}
+	return nil
+}
+
+// blockResolution
+// The cell size for a block lo to hi, a quarter mm doubled until the
+// block is at most stockCells cells.
+func blockResolution(lo *tooling.Point, hi *tooling.Point) float64 {
+	res := 0.25
+	for (hi.X-lo.X)*(hi.Y-lo.Y)*(hi.Z-lo.Z)/(res*res*res) > stockCells {
+		res *= 2
+	}
+	return res
+}
+
+// travel
+// The box the linear axes can reach, without limits on an axis not
+// named.
+func (c *Config) travel() tooling.Volume {
+	lo := &tooling.Point{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)}
+	hi := &tooling.Point{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)}
+	limited := false
+	for _, ax := range c.Axes {
+		switch strings.ToUpper(ax.Name) {
+		case "X":
+			lo.X, hi.X = ax.Min, ax.Max
+		case "Y":
+			lo.Y, hi.Y = ax.Min, ax.Max
+		case "Z":
+			lo.Z, hi.Z = ax.Min, ax.Max
+		default:
+			continue
+		}
+		limited = true
+	}
+	if !limited {
+		return nil
+	}
+	return tooling.MakeVolume(lo, hi)
+}
+
+// workOffsets
+// G54 to G59 in order, nil for those not given.
+func (c *Config) workOffsets() ([]*tooling.Point, error) {
+	if len(c.WorkOffsets) == 0 {
+		return nil, nil
+	}
+	ret := make([]*tooling.Point, 6)
+	for nm, p := range c.WorkOffsets {
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(nm), "G"))
+		if err != nil || n < 54 || n > 59 || p == nil {
+			return nil, fmt.Errorf("work offset %q is not one of G54 to G59", nm)
+		}
+		ret[n-54] = p
+	}
+	return ret, nil
+}
+
+// Sim
+// A sim started on the machine of the config, ready to run.
+func (c *Config) Sim() (*sim.Sim, error) {
+	dialect, err := sim.ParseDialect(c.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	m, mesh, err := c.build()
+	if err != nil {
+		return nil, err
+	}
+	res := c.Resolution
+	if res == 0 && m.Type() == tooling.CncMilling {
+		res = blockResolution(m.Material().Volume().Bounds())
+	}
+	s := &sim.Sim{
+		TimeSlice:  c.TimeSlice,
+		Tolerance:  c.Tolerance,
+		Resolution: res,
+		Vol:        c.travel(),
+		Accel:      c.Accel,
+		Dialect:    dialect,
+	}
+	if ch := c.Changer; ch != nil {
+		s.ToolChangeTime = ch.Time
+		s.ToolSlots = ch.Slots
+	}
+	if s.WorkOffsets, err = c.workOffsets(); err != nil {
+		return nil, err
+	}
+	s.StartWith(m)
+	if cs := c.Spindle; cs != nil {
+		if cs.Power > 0 {
+			s.Limits.SpindlePower = cs.Power
+		}
+		if cs.Torque > 0 {
+			s.Limits.SpindleTorque = cs.Torque
+		}
+	}
+	if mesh != nil {
+		if s.Stock == nil {
+			return nil, fmt.Errorf("a %v has no block to shape as %v", c.Type, c.Stock.Stl)
+		}
+		s.StockFrom(mesh)
+	}
+	return s, nil
+}
@@ -0,0 +1,133 @@
+package machine
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+func TestMachineFiles(t *testing.T) {
+	files, err := filepath.Glob("../../../../machines/*.*")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Machines → Expected the checked in files, Got: %v %v", files, err)
+	}
+	for _, fileNm := range files {
+		c, err := Load(fileNm)
+		if err != nil {
+			t.Errorf("%v → %v", fileNm, err)
+			continue
+		}
+		s, err := c.Sim()
+		if err != nil {
+			t.Errorf("%v → %v", fileNm, err)
+			continue
+		}
+		if s.Tool.FastFeedRate() != c.Rapid {
+			t.Errorf("%v rapid → Expected: %v, Got: %v", fileNm, c.Rapid, s.Tool.FastFeedRate())
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfgNm := filepath.Join(dir, "mill.yaml")
+	src := `
+type: mill
+dialect: fanuc
+axes:
+  - {name: X, min: -40, max: 40}
+  - {name: A, min: -30, max: 30}
+kinematics: {kind: indexer, rotary: A}
+rapid: 6000
+accel: 1000
+spindle: {gears: [{min: 100, max: 8000}], power: 5000}
+changer: {slots: 2, time: 5}
+stock: {lo: {x: -5, y: -5, z: -20}, hi: {x: 5, y: 5, z: -10}}
+resolution: 1
+workOffsets: {g55: {x: 1, y: 2, z: -3}}
+`
+	if err := os.WriteFile(cfgNm, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ncNm := filepath.Join(dir, "p.nc")
+	if err := os.WriteFile(ncNm, []byte("T3 M6\nG4 P500\nG0 X50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(cfgNm)
+	if err != nil {
+		t.Fatalf("Load → %v", err)
+	}
+	s, err := c.Sim()
+	if err != nil {
+		t.Fatalf("Sim → %v", err)
+	}
+	if r := s.Tool.Kinematics().Rotary("A"); r == nil || r.Min != -30 || r.Wrap != tooling.WRAP_NONE {
+		t.Errorf("A → Expected a -30 to 30 indexer, Got: %+v", r)
+	}
+	if sp := s.Tool.Spindle(); sp.Gears[0].Max != 8000 || s.Limits.SpindlePower != 5000 {
+		t.Errorf("Spindle → Expected 8000 RPM and 5 kW, Got: %v and %v", sp.Gears, s.Limits.SpindlePower)
+	}
+	if o := s.WorkOffsets; len(o) != 6 || o[0] != nil || *o[1] != (tooling.Point{X: 1, Y: 2, Z: -3}) {
+		t.Errorf("Work offsets → Expected: G55 at X1 Y2 Z-3, Got: %v", o)
+	}
+
+	tree, err := gcode.Parse(ncNm)
+	if err != nil {
+		t.Fatalf("Parse → %v", err)
+	}
+	s.OutDir = dir
+	s.Run(tree)
+	// 5s changing, P500 is ms on Fanuc, 50mm at 100mm/s ramping 0.1s each end
+	if math.Abs(s.Clock-6.1) > 1e-9 {
+		t.Errorf("Clock → Expected: 6.1, Got: %v", s.Clock)
+	}
+	var got []string
+	for _, w := range s.Warnings {
+		got = append(got, w.Message)
+	}
+	all := strings.Join(got, "; ")
+	if !strings.Contains(all, "T3 is not in the 2 slot changer") || !strings.Contains(all, "X50 is past the -40 to 40 travel") {
+		t.Errorf("Warnings → Expected the slot and the travel, Got: %v", all)
+	}
+}
+
+func TestDefaultStock(t *testing.T) {
+	s, err := Default().Sim()
+	if err != nil {
+		t.Fatalf("Sim → %v", err)
+	}
+	lo, hi := s.Stock.Bounds()
+	if lo.Z != -30 || hi.Z != 0 || lo.X != -15 || hi.X != 15 {
+		t.Errorf("Default stock → Expected: 30 mm with its top at Z0, Got: %v %v", lo, hi)
+	}
+	if s.Stock.Cell() != 0.5 {
+		t.Errorf("Default resolution → Expected: 0.5 for 216000 cells, Got: %v", s.Stock.Cell())
+	}
+
+	// a small block keeps the finest cells
+	c := &Config{Type: "mill", Stock: &StockSpec{Size: "10x10x5"}}
+	if s, err = c.Sim(); err != nil || s.Stock.Cell() != 0.25 {
+		t.Errorf("10x10x5 resolution → Expected: 0.25, Got: %v %v", s, err)
+	}
+}
+
+func TestBadConfig(t *testing.T) {
+	for _, c := range []*Config{
+		{Type: "mill", Kinematics: &KinematicsSpec{Kind: "table-table", Rotary: "C"}},
+		{Type: "lathe", Kinematics: &KinematicsSpec{Kind: "indexer", Rotary: "A"}},
+		{Type: "mill", Axes: []*AxisSpec{{Name: "B", Min: -10, Max: 10}}},
+		{Type: "mill", Dialect: "heidenhain"},
+		{Type: "mill", Stock: &StockSpec{Material: "Cheese"}},
+		{Type: "mill", Spindle: &SpindleSpec{Gears: []tooling.GearRange{{Min: 100, Max: 10}}}},
+		{Type: "mill", WorkOffsets: map[string]*tooling.Point{"G60": {X: 1}}},
+	} {
+		if _, err := c.Sim(); err == nil {
+			t.Errorf("%+v → Expected an error", c)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package machine
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"sort"
+	"strings"
+)
+
+// builders
+// The builtin machines by name, over the stock or bed from lo to hi.
+var builders = map[string]func(m tooling.Material, lo *tooling.Point, hi *tooling.Point) tooling.Cnc{
+	"mill": func(m tooling.Material, lo *tooling.Point, hi *tooling.Point) tooling.Cnc {
+		return tooling.BuildCnc(m)
+	},
+	"lathe": func(m tooling.Material, lo *tooling.Point, hi *tooling.Point) tooling.Cnc {
+		return tooling.BuildLathe(m, (hi.X-lo.X)/2, hi.Z-lo.Z)
+	},
+	"laser": func(m tooling.Material, lo *tooling.Point, hi *tooling.Point) tooling.Cnc {
+		return tooling.BuildLaser(m, hi.X-lo.X, hi.Y-lo.Y, hi.Z-lo.Z)
+	},
+	"plasma": func(m tooling.Material, lo *tooling.Point, hi *tooling.Point) tooling.Cnc {
+		return tooling.BuildPlasma(m, hi.X-lo.X, hi.Y-lo.Y, hi.Z-lo.Z)
+	},
+	"printer": func(m tooling.Material, lo *tooling.Point, hi *tooling.Point) tooling.Cnc {
+		return tooling.BuildPrinter(m, hi.X-lo.X, hi.Y-lo.Y, hi.Z-lo.Z)
+	},
+}
+
+// Builtin
+// The named machine with the material, the stock of a mill, the bar
+// of a lathe, the sheet of a cutter or the bed of a printer from lo to
+// hi.
+func Builtin(name string, m tooling.Material, lo *tooling.Point, hi *tooling.Point) (tooling.Cnc, error) {
+	b := builders[strings.ToLower(name)]
+	if b == nil {
+		return nil, fmt.Errorf("unknown machine %q, one of %v", name, strings.Join(Names(), ", "))
+	}
+	return b(m, lo, hi), nil
+}
+
+// Names
+// The sorted names of the builtin machines.
+func Names() []string {
+	ret := make([]string, 0, len(builders))
+	for nm := range builders {
+		ret = append(ret, nm)
+	}
+	sort.Strings(ret)
+	return ret
+}
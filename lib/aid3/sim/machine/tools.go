@@ -0,0 +1,144 @@
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ToolSpec
+// A tool in a tool table file, by Number in the changer or turret.
+// Kind is a milling cutter, flat, ball, bullnose, vbit, drill,
+// tapered, lollipop or threadmill, or a lathe insert, turning, boring
+// or grooving.  Lengths are mm and angles degrees, as in the Cutter
+// and the Insert, what a kind does not use is left out.
+type ToolSpec struct {
+	Number       int64   `json:"number" yaml:"number"`
+	Kind         string  `json:"kind" yaml:"kind"`
+	Diameter     float64 `json:"diameter" yaml:"diameter"`
+	CornerRadius float64 `json:"cornerRadius" yaml:"cornerRadius"`
+	Angle        float64 `json:"angle" yaml:"angle"`
+	TaperAngle   float64 `json:"taperAngle" yaml:"taperAngle"`
+	TipDiameter  float64 `json:"tipDiameter" yaml:"tipDiameter"`
+	NeckDiameter float64 `json:"neckDiameter" yaml:"neckDiameter"`
+	Pitch        float64 `json:"pitch" yaml:"pitch"`
+	Flutes       int     `json:"flutes" yaml:"flutes"`
+	FluteLength  float64 `json:"fluteLength" yaml:"fluteLength"`
+	Length       float64 `json:"length" yaml:"length"`
+	LeftHand     bool    `json:"leftHand" yaml:"leftHand"`
+
+	Lead       float64 `json:"lead" yaml:"lead"`
+	NoseRadius float64 `json:"noseRadius" yaml:"noseRadius"`
+	Size       float64 `json:"size" yaml:"size"`
+	Width      float64 `json:"width" yaml:"width"`
+	Depth      float64 `json:"depth" yaml:"depth"`
+}
+
+// Cutter
+// The milling cutter of the spec, nil for an insert.
+func (t *ToolSpec) Cutter() (*tooling.Cutter, error) {
+	var c *tooling.Cutter
+	switch strings.ToLower(t.Kind) {
+	case "flat", "":
+		c = tooling.MakeFlatEndMill(t.Diameter, t.FluteLength)
+	case "ball":
+		c = tooling.MakeBallEndMill(t.Diameter, t.FluteLength)
+	case "bullnose":
+		c = tooling.MakeBullNose(t.Diameter, t.CornerRadius, t.FluteLength)
+	case "vbit":
+		c = tooling.MakeVBit(t.Diameter, t.Angle, t.TipDiameter)
+	case "drill":
+		c = tooling.MakeDrill(t.Diameter, t.Angle, t.FluteLength)
+	case "tapered":
+		c = tooling.MakeTaperedBall(t.TipDiameter, t.TaperAngle, t.Diameter, t.FluteLength)
+	case "lollipop":
+		c = tooling.MakeLollipop(t.Diameter, t.NeckDiameter, t.FluteLength)
+	case "threadmill":
+		c = tooling.MakeThreadMill(t.Diameter, t.Pitch, t.NeckDiameter, t.FluteLength)
+	case "turning", "boring", "grooving":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("tool %v: unknown kind %q", t.Number, t.Kind)
+	}
+	if t.Diameter <= 0 {
+		return nil, fmt.Errorf("tool %v: no diameter", t.Number)
+	}
+	if t.Flutes > 0 {
+		c.Flutes = t.Flutes
+	}
+	if t.Length > 0 {
+		c.Length = t.Length
+	}
+	c.LeftHand = t.LeftHand
+	return c, nil
+}
+
+// Insert
+// The lathe insert of the spec, nil for a milling cutter.
+func (t *ToolSpec) Insert() *tooling.Insert {
+	switch strings.ToLower(t.Kind) {
+	case "turning":
+		return tooling.MakeTurningInsert(t.Angle, t.Lead, t.NoseRadius, t.Size)
+	case "boring":
+		return tooling.MakeBoringInsert(t.Angle, t.Lead, t.NoseRadius, t.Size)
+	case "grooving":
+		return tooling.MakeGroovingInsert(t.Width, t.NoseRadius, t.Depth)
+	}
+	return nil
+}
+
+// LoadTools
+// A list of tool specs in JSON, or YAML when named .yaml or .yml.
+func LoadTools(fileNm string) ([]*ToolSpec, error) {
+	var specs []*ToolSpec
+	if err := decode(fileNm, "tools", &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// decode
+// The file into v, YAML when named .yaml or .yml and JSON otherwise.
+// What is the kind of file for the errors.
+func decode(fileNm string, what string, v interface{}) error {
+	data, err := os.ReadFile(fileNm)
+	if err != nil {
+		return fmt.Errorf("read %v %q: %w", what, fileNm, err)
+	}
+	switch strings.ToLower(filepath.Ext(fileNm)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, v)
+	default:
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return fmt.Errorf("decode %v %q: %w", what, fileNm, err)
+	}
+	return nil
+}
+
+// Mount
+// Put the tools in the machine, cutters in its tool table and inserts
+// in the turret of a lathe.
+func Mount(m tooling.Cnc, specs []*ToolSpec) error {
+	for _, t := range specs {
+		if in := t.Insert(); in != nil {
+			l, ok := m.(*tooling.Lathe)
+			if !ok {
+				return fmt.Errorf("tool %v: a %v insert needs a lathe", t.Number, t.Kind)
+			}
+			l.AddInsert(t.Number, in)
+			continue
+		}
+		c, err := t.Cutter()
+		if err != nil {
+			return err
+		}
+		m.Tools().Add(t.Number, c)
+	}
+	return nil
+}
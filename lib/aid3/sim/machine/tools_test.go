@@ -0,0 +1,45 @@
+package machine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+func TestLoadTools(t *testing.T) {
+	fileNm := filepath.Join(t.TempDir(), "tools.yaml")
+	src := `
+- {number: 1, kind: flat, diameter: 6, fluteLength: 20}
+- {number: 2, kind: ball, diameter: 3, fluteLength: 10, flutes: 4}
+- {number: 3, kind: turning, angle: 80, lead: 95, noseRadius: 0.4, size: 12}
+`
+	if err := os.WriteFile(fileNm, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	specs, err := LoadTools(fileNm)
+	if err != nil {
+		t.Fatalf("Load → %v", err)
+	}
+	mill := tooling.BuildCnc(tooling.MakeWood(15))
+	if err = Mount(mill, specs); err == nil {
+		t.Errorf("Insert on a mill → Expected an error")
+	}
+	if err = Mount(mill, specs[:2]); err != nil {
+		t.Fatalf("Mount → %v", err)
+	}
+	if c := mill.Tools().Lookup(2); c == nil || c.Kind != tooling.CUTTER_BALL || c.Flutes != 4 {
+		t.Errorf("T2 → Expected a 4 flute ball, Got: %v", c)
+	}
+	lathe := tooling.BuildLathe(tooling.MakeWood(15), 20, 100)
+	if err = Mount(lathe, specs[2:]); err != nil {
+		t.Errorf("Insert on a lathe → %v", err)
+	}
+}
+
+func TestUnknownMachine(t *testing.T) {
+	if _, err := Builtin("router5", tooling.MakeWood(15), &tooling.Point{}, &tooling.Point{X: 1, Y: 1, Z: 1}); err == nil {
+		t.Errorf("Unknown machine → Expected an error")
+	}
+}
@@ -0,0 +1,143 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"log"
+)
+
+// printDuration
+// A printer speeds up and slows down on every move, at the travel
+// acceleration when it lays nothing.  The feed is under M220.
+func printDuration(p *tooling.Printer, m *Move) float64 {
+	accel := p.Accel
+	if !m.Node.Cmd.Coords().Has('E') {
+		accel = p.TravelAccel
+	}
+	return p.MoveTime(m.Length(), m.Feed*p.FeedOverride, accel)
+}
+
+// cmdBedLevel
+// G29 probes each point of the mesh and turns leveling on.  The bed
+// probed is the mesh as it is set, M421 can shape it.
+func cmdBedLevel(s *Sim, cn *gcode.CmdNode) {
+	p := s.printer()
+	if p == nil {
+		s.warn(cn, s.Clock, "G29 is only simulated on a printer")
+		return
+	}
+	s.Clock += float64(p.Mesh.Points()) * p.ProbeTime
+	p.Leveling = true
+}
+
+// cmdLeveling
+// M420 S1 follows the mesh and S0 stops, Z is the fade height.
+func cmdLeveling(s *Sim, cn *gcode.CmdNode) {
+	p := s.printer()
+	if p == nil {
+		return
+	}
+	c := cn.Cmd.Coords()
+	if c.Has('S') {
+		p.Leveling = c.S != 0
+	}
+	if c.Has('Z') {
+		p.Mesh.Fade = c.Z
+	}
+}
+
+// cmdMeshPoint
+// M421 I J Z sets a point of the mesh.
+func cmdMeshPoint(s *Sim, cn *gcode.CmdNode) {
+	p := s.printer()
+	if p == nil {
+		return
+	}
+	c := cn.Cmd.Coords()
+	if !c.Has('I') || !c.Has('J') || !c.Has('Z') {
+		s.warn(cn, s.Clock, "M421 needs I J and Z")
+		return
+	}
+	if !p.Mesh.Set(int(c.I), int(c.J), c.Z) {
+		s.warn(cn, s.Clock, "M421 I%v J%v is not on the mesh", c.I, c.J)
+	}
+}
+
+// cmdFirmwareRetract
+// G10 pulls the filament back and G11 feeds it again, as set on the
+// printer rather than by E words.  G10 on a mill sets offsets, the
+// frame has them, without an L it sets nothing.
+func cmdFirmwareRetract(s *Sim, cn *gcode.CmdNode, retract bool) {
+	p := s.printer()
+	if p == nil {
+		if retract && !cn.Cmd.Coords().Has('L') {
+			s.warn(cn, s.Clock, "G10 without L sets no offset")
+		}
+		return
+	}
+	fed := p.Retract(retract)
+	if fed == 0 {
+		return
+	}
+	s.Clock += p.MoveTime(p.RetractLength, p.RetractFeed, p.RetractAccel)
+	s.feedFilament(fed)
+}
+
+// cmdAccel
+// M204 P sets printing, T travel and R retract acceleration, S sets
+// printing and travel.
+func cmdAccel(s *Sim, cn *gcode.CmdNode) {
+	p := s.printer()
+	if p == nil {
+		return
+	}
+	c := cn.Cmd.Coords()
+	if c.Has('S') {
+		p.Accel = c.S
+		p.TravelAccel = c.S
+	}
+	if c.Has('P') {
+		p.Accel = c.P
+	}
+	if c.Has('T') {
+		p.TravelAccel = c.T
+	}
+	if c.Has('R') {
+		p.RetractAccel = c.R
+	}
+}
+
+// cmdJerk
+// M205 X or Y, the speed a move may start and stop at.
+func cmdJerk(s *Sim, cn *gcode.CmdNode) {
+	p := s.printer()
+	if p == nil {
+		return
+	}
+	c := cn.Cmd.Coords()
+	if c.Has('X') {
+		p.Jerk = c.X
+	}
+	if c.Has('Y') {
+		p.Jerk = c.Y
+	}
+}
+
+// cmdOverride
+// M220 S and M221 S as a percentage of the feed and the flow.
+func cmdOverride(s *Sim, cn *gcode.CmdNode, flow bool) {
+	p := s.printer()
+	c := cn.Cmd.Coords()
+	if p == nil || !c.Has('S') {
+		return
+	}
+	if flow {
+		p.FlowOverride = c.S / 100
+	} else {
+		p.FeedOverride = c.S / 100
+	}
+}
+
+func cmdMessage(s *Sim, cn *gcode.CmdNode) {
+	log.Printf("Message at %3.1f s : %v\n", s.Clock, cn.Cmd.Text())
+}
@@ -0,0 +1,208 @@
+package material
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	FAMILY_METAL   = "metal"
+	FAMILY_PLASTIC = "plastic"
+	FAMILY_WOOD    = "wood"
+)
+
+// Range
+// A low and high value, for recommendations.
+type Range struct {
+	Min float64 `json:"min" yaml:"min"`
+	Max float64 `json:"max" yaml:"max"`
+}
+
+// ChipLoad
+// The feed per tooth in mm recommended for a cutter diameter in mm.
+type ChipLoad struct {
+	Diameter float64 `json:"diameter" yaml:"diameter"`
+	Min      float64 `json:"min" yaml:"min"`
+	Max      float64 `json:"max" yaml:"max"`
+}
+
+// Spec
+// The properties of a stock material.
+// Density is g/cm3.
+// Hardness is Brinell, or the Brinell equivalent for plastics and woods.
+// Stiffness is the elastic modulus in GPa.
+// CuttingEnergy is the specific cutting energy in J/mm3, or W per mm3/s.
+// SurfaceSpeed is m/min for carbide tooling.
+// ChipLoads are by cutter diameter, in increasing diameter.
+type Spec struct {
+	Name          string     `json:"name" yaml:"name"`
+	Family        string     `json:"family" yaml:"family"`
+	Density       float64    `json:"density" yaml:"density"`
+	Hardness      float64    `json:"hardness" yaml:"hardness"`
+	Stiffness     float64    `json:"stiffness" yaml:"stiffness"`
+	CuttingEnergy float64    `json:"cuttingEnergy" yaml:"cuttingEnergy"`
+	SurfaceSpeed  Range      `json:"surfaceSpeed" yaml:"surfaceSpeed"`
+	ChipLoads     []ChipLoad `json:"chipLoads" yaml:"chipLoads"`
+}
+
+// ChipLoad
+// The recommended feed per tooth for a cutter diameter, interpolated
+// between the table entries and held at the ends.
+func (s *Spec) ChipLoad(diameter float64) (float64, float64) {
+	cl := s.ChipLoads
+	if len(cl) == 0 {
+		return 0, 0
+	}
+	if diameter <= cl[0].Diameter {
+		return cl[0].Min, cl[0].Max
+	}
+	for i := 1; i < len(cl); i++ {
+		if diameter <= cl[i].Diameter {
+			t := (diameter - cl[i-1].Diameter) / (cl[i].Diameter - cl[i-1].Diameter)
+			return cl[i-1].Min + t*(cl[i].Min-cl[i-1].Min), cl[i-1].Max + t*(cl[i].Max-cl[i-1].Max)
+		}
+	}
+	last := cl[len(cl)-1]
+	return last.Min, last.Max
+}
+
+// Database
+// Material specs by name, names are not case sensitive.
+type Database struct {
+	specs map[string]*Spec
+}
+
+// Builtin
+// A database of the materials known without any file.
+func Builtin() *Database {
+	db := &Database{specs: make(map[string]*Spec)}
+	for _, list := range [][]*Spec{metals(), plastics(), woods()} {
+		for _, s := range list {
+			db.Add(s)
+		}
+	}
+	return db
+}
+
+// LoadDatabase
+// The builtin materials, with those in the file added or replacing
+// builtins of the same name.  The file is a list of specs in JSON,
+// or YAML when named .yaml or .yml.
+func LoadDatabase(fileNm string) (*Database, error) {
+	db := Builtin()
+	if err := db.Load(fileNm); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *Database) Load(fileNm string) error {
+	data, err := os.ReadFile(fileNm)
+	if err != nil {
+		return fmt.Errorf("read materials %q: %w", fileNm, err)
+	}
+
+	var specs []*Spec
+	switch strings.ToLower(filepath.Ext(fileNm)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &specs)
+	default:
+		err = json.Unmarshal(data, &specs)
+	}
+	if err != nil {
+		return fmt.Errorf("decode materials %q: %w", fileNm, err)
+	}
+
+	for _, s := range specs {
+		if s.Name == "" {
+			return fmt.Errorf("decode materials %q: material without a name", fileNm)
+		}
+		db.Add(s)
+	}
+	return nil
+}
+
+// Add
+// The spec by its name, its chip loads a sorted copy of those given.
+func (db *Database) Add(s *Spec) {
+	s.ChipLoads = append([]ChipLoad(nil), s.ChipLoads...)
+	sort.Slice(s.ChipLoads, func(i int, j int) bool {
+		return s.ChipLoads[i].Diameter < s.ChipLoads[j].Diameter
+	})
+	db.specs[strings.ToLower(s.Name)] = s
+}
+
+// Lookup
+// The spec by name, nil when not known.
+func (db *Database) Lookup(name string) *Spec {
+	return db.specs[strings.ToLower(name)]
+}
+
+// Names
+// The sorted names of all the materials.
+func (db *Database) Names() []string {
+	ret := make([]string, 0, len(db.specs))
+	for _, s := range db.specs {
+		ret = append(ret, s.Name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Block
+// A block of stock made from a material, as a tooling.Material.
+type Block struct {
+	spec   *Spec
+	volume tooling.Volume
+}
+
+// MakeBlock
+// A block of the material from fr to to.
+func MakeBlock(s *Spec, fr *tooling.Point, to *tooling.Point) tooling.Material {
+	return &Block{
+		spec:   s,
+		volume: tooling.MakeVolume(fr, to),
+	}
+}
+
+func (b *Block) Spec() *Spec {
+	return b.spec
+}
+
+func (b *Block) Name() string {
+	return b.spec.Name
+}
+
+func (b *Block) Hardness() float64 {
+	return b.spec.Hardness
+}
+
+func (b *Block) Stiffness() float64 {
+	return b.spec.Stiffness
+}
+
+func (b *Block) Density() float64 {
+	return b.spec.Density
+}
+
+func (b *Block) CuttingEnergy() float64 {
+	return b.spec.CuttingEnergy
+}
+
+func (b *Block) SurfaceSpeed() (float64, float64) {
+	return b.spec.SurfaceSpeed.Min, b.spec.SurfaceSpeed.Max
+}
+
+func (b *Block) ChipLoad(diameter float64) (float64, float64) {
+	return b.spec.ChipLoad(diameter)
+}
+
+func (b *Block) Volume() tooling.Volume {
+	return b.volume
+}
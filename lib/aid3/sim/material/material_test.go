@@ -0,0 +1,85 @@
+package material
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+func TestBuiltinFamilies(t *testing.T) {
+	db := Builtin()
+	for _, nm := range []string{"6061-T6", "4140", "316", "ti-6al-4v", "C360-Brass", "Nylon-6/6", "Acetal", "HDPE", "Oak", "Pine", "MDF"} {
+		if db.Lookup(nm) == nil {
+			t.Errorf("Expected builtin material %v", nm)
+		}
+	}
+}
+
+func TestChipLoadInterpolation(t *testing.T) {
+	s := Builtin().Lookup("6061-T6")
+	lo, hi := s.ChipLoad(8)
+	if math.Abs(lo-0.05) > 1e-9 || math.Abs(hi-0.10) > 1e-9 {
+		t.Errorf("8mm chip load → Expected: 0.05..0.10, Got: %v..%v", lo, hi)
+	}
+	lo, _ = s.ChipLoad(1)
+	if lo != 0.02 {
+		t.Errorf("1mm chip load → Expected the smallest entry 0.02, Got: %v", lo)
+	}
+}
+
+func TestChipLoadsOwned(t *testing.T) {
+	db := Builtin()
+	db.Lookup("6061-T6").ChipLoads[0].Min = 1
+	if lo, _ := db.Lookup("7075-T6").ChipLoad(3); lo != 0.02 {
+		t.Errorf("7075-T6 after changing 6061-T6 → Expected: 0.02, Got: %v", lo)
+	}
+	if lo, _ := Builtin().Lookup("6061-T6").ChipLoad(3); lo != 0.02 {
+		t.Errorf("A second builtin 6061-T6 → Expected: 0.02, Got: %v", lo)
+	}
+
+	given := []ChipLoad{{Diameter: 10, Min: 0.2, Max: 0.3}, {Diameter: 3, Min: 0.1, Max: 0.2}}
+	db.Add(&Spec{Name: "Shop-Acrylic", ChipLoads: given})
+	if given[0].Diameter != 10 {
+		t.Errorf("Chip loads given to Add → Expected: left in their order, Got: %v", given)
+	}
+	if cl := db.Lookup("Shop-Acrylic").ChipLoads; cl[0].Diameter != 3 {
+		t.Errorf("Chip loads added → Expected: sorted by diameter, Got: %v", cl)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	fileNm := filepath.Join(t.TempDir(), "shop.yaml")
+	src := `
+- name: Shop-Delrin
+  family: plastic
+  density: 1.41
+  cuttingEnergy: 0.12
+  surfaceSpeed: {min: 100, max: 300}
+  chipLoads:
+    - {diameter: 6, min: 0.1, max: 0.2}
+- name: oak
+  family: wood
+  density: 0.9
+`
+	if err := os.WriteFile(fileNm, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := LoadDatabase(fileNm)
+	if err != nil {
+		t.Fatalf("Load → %v", err)
+	}
+	if s := db.Lookup("shop-delrin"); s == nil || s.CuttingEnergy != 0.12 {
+		t.Errorf("Expected Shop-Delrin from the file, Got: %v", s)
+	}
+	if s := db.Lookup("Oak"); s.Density != 0.9 {
+		t.Errorf("Oak density → Expected the file to replace the builtin 0.9, Got: %v", s.Density)
+	}
+
+	var m tooling.Material = MakeBlock(db.Lookup("Shop-Delrin"), &tooling.Point{X: -5, Y: -5, Z: -5}, &tooling.Point{X: 5, Y: 5, Z: 0})
+	if lo, _ := m.SurfaceSpeed(); lo != 100 {
+		t.Errorf("Surface speed through tooling.Material → Expected: 100, Got: %v", lo)
+	}
+}
@@ -1 +1,64 @@
 package material
+
+// aluminiumChipLoads and steelChipLoads are the feed per tooth
+// for carbide end mills, the other metals are scaled from these.
+var aluminiumChipLoads = []ChipLoad{
+	{Diameter: 3, Min: 0.02, Max: 0.04},
+	{Diameter: 6, Min: 0.04, Max: 0.08},
+	{Diameter: 10, Min: 0.06, Max: 0.12},
+	{Diameter: 12, Min: 0.08, Max: 0.15},
+	{Diameter: 20, Min: 0.10, Max: 0.20},
+}
+
+var steelChipLoads = []ChipLoad{
+	{Diameter: 3, Min: 0.010, Max: 0.025},
+	{Diameter: 6, Min: 0.025, Max: 0.050},
+	{Diameter: 10, Min: 0.040, Max: 0.080},
+	{Diameter: 12, Min: 0.050, Max: 0.100},
+	{Diameter: 20, Min: 0.080, Max: 0.140},
+}
+
+// chipLoads
+// A copy of the table for a spec of its own.
+func chipLoads(cl []ChipLoad) []ChipLoad {
+	return scaleChipLoads(cl, 1)
+}
+
+func scaleChipLoads(cl []ChipLoad, f float64) []ChipLoad {
+	ret := make([]ChipLoad, len(cl))
+	for i, c := range cl {
+		ret[i] = ChipLoad{Diameter: c.Diameter, Min: c.Min * f, Max: c.Max * f}
+	}
+	return ret
+}
+
+func metals() []*Spec {
+	return []*Spec{
+		{Name: "6061-T6", Family: FAMILY_METAL, Density: 2.70, Hardness: 95, Stiffness: 69, CuttingEnergy: 0.7,
+			SurfaceSpeed: Range{250, 600}, ChipLoads: chipLoads(aluminiumChipLoads)},
+		{Name: "7075-T6", Family: FAMILY_METAL, Density: 2.81, Hardness: 150, Stiffness: 72, CuttingEnergy: 0.8,
+			SurfaceSpeed: Range{200, 500}, ChipLoads: chipLoads(aluminiumChipLoads)},
+		{Name: "2024-T3", Family: FAMILY_METAL, Density: 2.78, Hardness: 120, Stiffness: 73, CuttingEnergy: 0.8,
+			SurfaceSpeed: Range{200, 500}, ChipLoads: chipLoads(aluminiumChipLoads)},
+		{Name: "5083-H111", Family: FAMILY_METAL, Density: 2.66, Hardness: 75, Stiffness: 71, CuttingEnergy: 0.7,
+			SurfaceSpeed: Range{250, 600}, ChipLoads: scaleChipLoads(aluminiumChipLoads, 0.9)},
+		{Name: "1018", Family: FAMILY_METAL, Density: 7.87, Hardness: 126, Stiffness: 205, CuttingEnergy: 2.0,
+			SurfaceSpeed: Range{90, 200}, ChipLoads: chipLoads(steelChipLoads)},
+		{Name: "1045", Family: FAMILY_METAL, Density: 7.87, Hardness: 170, Stiffness: 205, CuttingEnergy: 2.2,
+			SurfaceSpeed: Range{80, 180}, ChipLoads: chipLoads(steelChipLoads)},
+		{Name: "4140", Family: FAMILY_METAL, Density: 7.85, Hardness: 197, Stiffness: 205, CuttingEnergy: 2.4,
+			SurfaceSpeed: Range{70, 150}, ChipLoads: scaleChipLoads(steelChipLoads, 0.9)},
+		{Name: "304", Family: FAMILY_METAL, Density: 8.00, Hardness: 123, Stiffness: 193, CuttingEnergy: 2.8,
+			SurfaceSpeed: Range{60, 120}, ChipLoads: scaleChipLoads(steelChipLoads, 0.8)},
+		{Name: "316", Family: FAMILY_METAL, Density: 8.00, Hardness: 149, Stiffness: 193, CuttingEnergy: 2.9,
+			SurfaceSpeed: Range{50, 110}, ChipLoads: scaleChipLoads(steelChipLoads, 0.8)},
+		{Name: "17-4PH", Family: FAMILY_METAL, Density: 7.80, Hardness: 352, Stiffness: 197, CuttingEnergy: 3.2,
+			SurfaceSpeed: Range{40, 90}, ChipLoads: scaleChipLoads(steelChipLoads, 0.7)},
+		{Name: "Ti-6Al-4V", Family: FAMILY_METAL, Density: 4.43, Hardness: 334, Stiffness: 114, CuttingEnergy: 3.5,
+			SurfaceSpeed: Range{30, 70}, ChipLoads: scaleChipLoads(steelChipLoads, 0.7)},
+		{Name: "Ti-Grade-2", Family: FAMILY_METAL, Density: 4.51, Hardness: 145, Stiffness: 105, CuttingEnergy: 2.8,
+			SurfaceSpeed: Range{40, 90}, ChipLoads: scaleChipLoads(steelChipLoads, 0.8)},
+		{Name: "C360-Brass", Family: FAMILY_METAL, Density: 8.50, Hardness: 78, Stiffness: 97, CuttingEnergy: 0.9,
+			SurfaceSpeed: Range{150, 400}, ChipLoads: scaleChipLoads(aluminiumChipLoads, 0.8)},
+	}
+}
@@ -1 +1,19 @@
 package material
+
+var plasticChipLoads = []ChipLoad{
+	{Diameter: 3, Min: 0.05, Max: 0.10},
+	{Diameter: 6, Min: 0.10, Max: 0.20},
+	{Diameter: 10, Min: 0.15, Max: 0.30},
+	{Diameter: 12, Min: 0.20, Max: 0.35},
+}
+
+func plastics() []*Spec {
+	return []*Spec{
+		{Name: "Nylon-6/6", Family: FAMILY_PLASTIC, Density: 1.14, Hardness: 12, Stiffness: 2.8, CuttingEnergy: 0.10,
+			SurfaceSpeed: Range{150, 500}, ChipLoads: chipLoads(plasticChipLoads)},
+		{Name: "Acetal", Family: FAMILY_PLASTIC, Density: 1.41, Hardness: 14, Stiffness: 2.9, CuttingEnergy: 0.10,
+			SurfaceSpeed: Range{200, 600}, ChipLoads: chipLoads(plasticChipLoads)},
+		{Name: "HDPE", Family: FAMILY_PLASTIC, Density: 0.95, Hardness: 4, Stiffness: 0.9, CuttingEnergy: 0.05,
+			SurfaceSpeed: Range{200, 600}, ChipLoads: scaleChipLoads(plasticChipLoads, 1.2)},
+	}
+}
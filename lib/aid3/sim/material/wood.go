@@ -1 +1,29 @@
 package material
+
+var woodChipLoads = []ChipLoad{
+	{Diameter: 3, Min: 0.05, Max: 0.10},
+	{Diameter: 6, Min: 0.10, Max: 0.25},
+	{Diameter: 10, Min: 0.20, Max: 0.40},
+	{Diameter: 12, Min: 0.25, Max: 0.50},
+}
+
+func woods() []*Spec {
+	return []*Spec{
+		{Name: "Oak", Family: FAMILY_WOOD, Density: 0.70, Hardness: 3.5, Stiffness: 12.5, CuttingEnergy: 0.07,
+			SurfaceSpeed: Range{300, 1000}, ChipLoads: chipLoads(woodChipLoads)},
+		{Name: "Hard-Maple", Family: FAMILY_WOOD, Density: 0.71, Hardness: 4.0, Stiffness: 12.6, CuttingEnergy: 0.08,
+			SurfaceSpeed: Range{300, 1000}, ChipLoads: chipLoads(woodChipLoads)},
+		{Name: "Walnut", Family: FAMILY_WOOD, Density: 0.61, Hardness: 3.0, Stiffness: 11.6, CuttingEnergy: 0.06,
+			SurfaceSpeed: Range{300, 1000}, ChipLoads: chipLoads(woodChipLoads)},
+		{Name: "Cherry", Family: FAMILY_WOOD, Density: 0.58, Hardness: 2.9, Stiffness: 10.3, CuttingEnergy: 0.06,
+			SurfaceSpeed: Range{300, 1000}, ChipLoads: chipLoads(woodChipLoads)},
+		{Name: "Pine", Family: FAMILY_WOOD, Density: 0.40, Hardness: 1.5, Stiffness: 9.0, CuttingEnergy: 0.04,
+			SurfaceSpeed: Range{400, 1200}, ChipLoads: scaleChipLoads(woodChipLoads, 1.2)},
+		{Name: "Douglas-Fir", Family: FAMILY_WOOD, Density: 0.53, Hardness: 2.3, Stiffness: 13.4, CuttingEnergy: 0.05,
+			SurfaceSpeed: Range{400, 1200}, ChipLoads: scaleChipLoads(woodChipLoads, 1.1)},
+		{Name: "Cedar", Family: FAMILY_WOOD, Density: 0.37, Hardness: 1.2, Stiffness: 7.7, CuttingEnergy: 0.04,
+			SurfaceSpeed: Range{400, 1200}, ChipLoads: scaleChipLoads(woodChipLoads, 1.2)},
+		{Name: "MDF", Family: FAMILY_WOOD, Density: 0.75, Hardness: 2.0, Stiffness: 3.5, CuttingEnergy: 0.06,
+			SurfaceSpeed: Range{300, 900}, ChipLoads: chipLoads(woodChipLoads)},
+	}
+}
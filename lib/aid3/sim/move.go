@@ -0,0 +1,185 @@
+package sim
+
+import (
+	"math"
+
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+const (
+	MOVE_RAPID = iota
+	MOVE_FEED
+	MOVE_ARC
+	MOVE_PROBE
+)
+
+// Move
+// One motion command as simulated, from and to are tool tip positions.
+// Center, Ccw and Plane are only used by arcs.  First and Last are
+// the range of points in the head path posted for this move.  Start
+// and Duration are seconds from the start of the program, Rpm is how
+// fast the spindle was actually turning at the start.  Dry is true
+// without any coolant on.  Axis is the tool axis in the workpiece,
+// nil or +Z for a 3-axis move.
+type Move struct {
+	Kind     int
+	From     *tooling.Point
+	To       *tooling.Point
+	Center   *tooling.Point
+	Axis     *tooling.Point
+	Ccw      bool
+	Plane    int
+	Feed     float64
+	FeedMode int
+	Rpm      float64
+	Tool     int64
+	Node     *gcode.CmdNode
+	First    int
+	Last     int
+	Start    float64
+	Duration float64
+	Dry      bool
+	Removed  *tooling.Removal
+}
+
+// Swept
+// The volume swept by the cutting part of the cutter during the move.
+func (m *Move) Swept(c *tooling.Cutter) *tooling.Swept {
+	return m.sweep(c, true)
+}
+
+func (m *Move) sweep(c *tooling.Cutter, cuttingOnly bool) *tooling.Swept {
+	return m.sweepBetween(cutterBody(c, cuttingOnly), m.From, m.To)
+}
+
+func (m *Move) sweepBody(b tooling.Body) *tooling.Swept {
+	return m.sweepBetween(b, m.From, m.To)
+}
+
+// sweepBetween
+// The body swept along the move between two points on it, turned
+// to the tool axis of the move.
+func (m *Move) sweepBetween(b tooling.Body, fr *tooling.Point, to *tooling.Point) *tooling.Swept {
+	if !tooling.Vertical(m.Axis) {
+		b = tooling.OrientBody(b, m.Axis)
+	}
+	if m.Kind == MOVE_ARC {
+		return tooling.SweepBodyArc(b, fr, to, m.Center, m.Plane, m.Ccw)
+	}
+	return tooling.SweepBody(b, fr, to)
+}
+
+func cutterBody(c *tooling.Cutter, cuttingOnly bool) tooling.Body {
+	if cuttingOnly {
+		return tooling.MakeCutterBody(c, tooling.PART_CUTTING)
+	}
+	return tooling.MakeCutterBody(c, tooling.PART_ALL)
+}
+
+func (m *Move) Length() float64 {
+	if m.Kind == MOVE_ARC {
+		return tooling.ArcLength(m.From, m.To, m.Center, m.Plane, m.Ccw)
+	}
+	return m.From.Dist(m.To)
+}
+
+// beginMove
+// Start recording a move from the current head position.
+func (s *Sim) beginMove(kind int, cn *gcode.CmdNode) *Move {
+	if b := s.beam(); b != nil {
+		s.pierce(b, kind, cn)
+	}
+	return &Move{
+		Kind:     kind,
+		From:     s.ToolHead.Pos(),
+		Plane:    s.Tool.Plane(),
+		Feed:     s.Tool.FeedRate(),
+		FeedMode: s.Tool.CurrentFeedMode(),
+		Rpm:      math.Abs(s.Tool.Spindle().Rpm(s.Clock)),
+		Tool:     s.Tool.CurrentTool(),
+		Dry:      !s.Tool.Outputs().Coolant(),
+		Axis:     s.toolAxis(),
+		Node:     cn,
+		First:    s.ToolHead.PointCount(),
+	}
+}
+
+// endMove
+// Finish the move at to, check it for collisions and remove what
+// the cutter swept from the stock.
+func (s *Sim) endMove(m *Move, to *tooling.Point) {
+	m.To = to
+	m.Last = s.ToolHead.PointCount()
+	m.Start = s.Clock
+	if m.Duration == 0 {
+		m.Duration = moveDuration(m)
+		if p := s.printer(); p != nil {
+			m.Duration = printDuration(p, m)
+		} else if m.Kind == MOVE_RAPID && s.Accel > 0 {
+			m.Duration = s.rapidDuration(m)
+		}
+	}
+	s.Clock += m.Duration
+	if s.printer() != nil {
+		// a retraction feeds filament without moving
+		s.printMove(m)
+	}
+	if m.Kind == MOVE_PROBE {
+		// the probe stops on touching, it neither cuts nor collides
+		s.checkTravel(m)
+	} else if m.From.Dist(m.To) > 0 || m.Kind == MOVE_ARC {
+		if m.Kind == MOVE_RAPID {
+			s.checkStock(m, tooling.PART_ALL)
+		}
+		s.cutMove(m)
+		s.checkSpindle(m)
+		if m.Kind != MOVE_RAPID {
+			s.checkStock(m, tooling.PART_NON_CUTTING)
+		}
+		s.checkFixtures(m)
+		s.checkTravel(m)
+	}
+	s.Moves = append(s.Moves, m)
+	for _, f := range s.moveObservers {
+		f(m)
+	}
+}
+
+// moveDuration
+// The time in seconds for the move at its feed, F is mm/min, mm/rev
+// or 1/min depending on the feed mode.  Rapids are at the fast feed
+// rate in mm/min.
+func moveDuration(m *Move) float64 {
+	length := m.Length()
+	if m.Feed <= 0 {
+		return 0
+	}
+	if m.Kind != MOVE_RAPID {
+		switch m.FeedMode {
+		case tooling.FEED_INVERSE_TIME:
+			return 60 / m.Feed
+		case tooling.FEED_PER_REVOLUTION:
+			if m.Rpm <= 0 {
+				return 0
+			}
+			return length / (m.Feed * m.Rpm / 60)
+		}
+	}
+	return length / (m.Feed / 60)
+}
+
+// movePoints
+// The move as a line, arcs as chords.
+func movePoints(m *Move) []*tooling.Point {
+	if m.Kind != MOVE_ARC {
+		return []*tooling.Point{m.From, m.To}
+	}
+	_, _, sweep := tooling.ArcAngles(m.From, m.To, m.Center, m.Plane, m.Ccw)
+	n := int(math.Max(1, math.Ceil(math.Abs(sweep)/arcChord)))
+	ret := []*tooling.Point{m.From}
+	for i := 1; i <= n; i++ {
+		ret = append(ret, tooling.ArcAt(m.From, m.To, m.Center, m.Plane, m.Ccw, float64(i)/float64(n)))
+	}
+	return ret
+}
@@ -0,0 +1,66 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"log"
+)
+
+// cmdOutput
+// Coolant, air blast and vacuum on and off.
+func cmdOutput(s *Sim, kind int, on bool) {
+	s.Tool.Outputs().Set(kind, on, s.Clock)
+}
+
+func cmdCoolantOff(s *Sim) {
+	s.Tool.Outputs().CoolantOff(s.Clock)
+}
+
+// cmdDigitalOut
+// M62 and M64 turn pin P on, M63 and M65 turn it off.  The synced
+// forms wait for the next motion, which starts at the same time here.
+func cmdDigitalOut(s *Sim, cn *gcode.CmdNode) {
+	pin := int(cn.Cmd.Coords().P)
+	switch cn.Cmd.Src() {
+	case "M62", "M64":
+		s.Tool.Outputs().Digital(pin, true, s.Clock)
+	default:
+		s.Tool.Outputs().Digital(pin, false, s.Clock)
+	}
+}
+
+// cmdAnalogOut
+// M67 and M68 set pin E to Q.
+func cmdAnalogOut(s *Sim, cn *gcode.CmdNode) {
+	c := cn.Cmd.Coords()
+	s.Tool.Outputs().Analog(int(c.E), c.Q, s.Clock)
+}
+
+// ObserveMoves
+// Call f with every move once it has been simulated.
+func (s *Sim) ObserveMoves(f func(m *Move)) {
+	s.moveObservers = append(s.moveObservers, f)
+}
+
+func logDryCuts(s *Sim) {
+	removed := 0.0
+	duration := 0.0
+	first := 0
+	for _, m := range s.Moves {
+		if !m.Dry || m.Removed == nil || m.Removed.Cells == 0 {
+			continue
+		}
+		if first == 0 {
+			first = m.Node.Cmd.Line()
+		}
+		removed += m.Removed.Volume
+		duration += m.Duration
+	}
+	if removed > 0 {
+		log.Printf("Cut %3.3f mm3 dry over %3.3fs, first at line %v\n", removed, duration, first)
+	}
+	if debugOutputs {
+		for _, e := range s.Tool.Outputs().Events {
+			log.Printf("Output %v\n", e)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package sim
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+// PlotConfig
+// What WriteSVG draws.  Views are xy, xz or yz, drawn side by side,
+// each Size px along its longer side.  ColorBy is tool or feed, the
+// rapids are left out without Rapids.
+type PlotConfig struct {
+	Views   []string
+	ColorBy string
+	Size    float64
+	Rapids  bool
+}
+
+func MakePlotConfig() *PlotConfig {
+	return &PlotConfig{Views: []string{"xy"}, ColorBy: "tool", Size: 600, Rapids: true}
+}
+
+// a projection, the axes across and up the view
+type view struct {
+	name   string
+	across func(p *tooling.Point) float64
+	up     func(p *tooling.Point) float64
+}
+
+var views = map[string]*view{
+	"xy": {"XY", func(p *tooling.Point) float64 { return p.X }, func(p *tooling.Point) float64 { return p.Y }},
+	"xz": {"XZ", func(p *tooling.Point) float64 { return p.X }, func(p *tooling.Point) float64 { return p.Z }},
+	"yz": {"YZ", func(p *tooling.Point) float64 { return p.Y }, func(p *tooling.Point) float64 { return p.Z }},
+}
+
+var toolColors = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b", "#e377c2", "#17becf", "#bcbd22", "#7f7f7f"}
+
+const (
+	plotMargin = 24.0
+	plotLegend = 28.0
+)
+
+// WriteSVG
+// The head path of the moves projected onto the views.  Rapids are
+// dashed, arcs heavier than feeds and probe moves dotted.  Each move
+// is a polyline of the points the head was posted, with its line and
+// command as a tooltip.
+func (s *Sim) WriteSVG(w io.Writer, cfg *PlotConfig) error {
+	var vs []*view
+	for _, nm := range cfg.Views {
+		v, ok := views[strings.ToLower(nm)]
+		if !ok {
+			return fmt.Errorf("unknown view %q, not xy, xz or yz", nm)
+		}
+		vs = append(vs, v)
+	}
+	if len(vs) == 0 {
+		return fmt.Errorf("no views to draw")
+	}
+	if cfg.ColorBy != "tool" && cfg.ColorBy != "feed" {
+		return fmt.Errorf("unknown color %q, not tool or feed", cfg.ColorBy)
+	}
+	if cfg.Size <= 0 {
+		return fmt.Errorf("size %v is not above 0", cfg.Size)
+	}
+
+	var path []*tooling.Point
+	if s.ToolHead != nil {
+		s.ToolHead.Path(func(p *tooling.Point) {
+			path = append(path, p)
+		})
+	}
+	var moves []*Move
+	var pts [][]*tooling.Point
+	var bounds *Bounds
+	for _, m := range s.Moves {
+		if m.To == nil || (m.Kind == MOVE_RAPID && !cfg.Rapids) {
+			continue
+		}
+		mp := []*tooling.Point{m.From}
+		if m.First < m.Last && m.Last <= len(path) {
+			mp = append(mp, path[m.First:m.Last]...)
+		}
+		if last := mp[len(mp)-1]; last.Dist(m.To) > 1e-9 {
+			mp = append(mp, m.To)
+		}
+		for _, p := range mp {
+			bounds = bounds.add(p)
+		}
+		moves, pts = append(moves, m), append(pts, mp)
+	}
+	if bounds == nil {
+		bounds = bounds.add(&tooling.Point{})
+	}
+	color := s.plotColors(moves, cfg.ColorBy)
+
+	// every view the same scale, the longest span of any across Size
+	span := 1.0
+	for _, v := range vs {
+		span = math.Max(span, math.Max(extent(v.across, bounds), extent(v.up, bounds)))
+	}
+	scale := cfg.Size / span
+	width, height := plotMargin, 0.0
+	for _, v := range vs {
+		width += extent(v.across, bounds)*scale + plotMargin
+		height = math.Max(height, extent(v.up, bounds)*scale)
+	}
+	height += 2*plotMargin + plotLegend
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif" font-size="11">`+"\n",
+		width, height, width, height)
+	b.WriteString(`<style>
+polyline { fill: none; stroke-width: 1; stroke-linejoin: round; stroke-linecap: round }
+polyline:hover { stroke-width: 3 }
+.rapid { stroke-dasharray: 4 3; opacity: 0.5 }
+.arc { stroke-width: 1.6 }
+.probe { stroke-dasharray: 1 2 }
+</style>
+<rect width="100%" height="100%" fill="white"/>
+`)
+	kinds := []string{"rapid", "feed", "arc", "probe"}
+	left := plotMargin
+	for _, v := range vs {
+		w := extent(v.across, bounds) * scale
+		h := extent(v.up, bounds) * scale
+		top := plotMargin
+		fmt.Fprintf(&b, `<g class="view">`+"\n")
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f">%v</text>`+"\n", left, top-8, v.name)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="none" stroke="#ddd"/>`+"\n", left, top, w, h)
+		for i, m := range moves {
+			var xy []string
+			for _, p := range pts[i] {
+				// up is up, the SVG y runs down
+				xy = append(xy, fmt.Sprintf("%.2f,%.2f", left+(v.across(p)-v.across(bounds.Lo))*scale, top+h-(v.up(p)-v.up(bounds.Lo))*scale))
+			}
+			fmt.Fprintf(&b, `<polyline class="%v" stroke="%v" points="%v"><title>%v</title></polyline>`+"\n",
+				kinds[m.Kind], color(m), strings.Join(xy, " "), html.EscapeString(moveTip(m)))
+		}
+		b.WriteString("</g>\n")
+		left += w + plotMargin
+	}
+	s.plotLegend(&b, moves, cfg.ColorBy, color, height-plotLegend/2)
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// extent
+// The span of the bounds along an axis of a view, a mm at the least so
+// a flat view still shows.
+func extent(axis func(p *tooling.Point) float64, b *Bounds) float64 {
+	return math.Max(axis(b.Hi)-axis(b.Lo), 1)
+}
+
+// plotColors
+// The color of a move, by its tool in the order the tools are first
+// used, or along blue to red from the slowest feed to the fastest.
+// Rapids have no feed and are grey by feed.
+func (s *Sim) plotColors(moves []*Move, by string) func(m *Move) string {
+	if by == "tool" {
+		order := map[int64]int{}
+		for _, m := range moves {
+			if _, ok := order[m.Tool]; !ok {
+				order[m.Tool] = len(order)
+			}
+		}
+		return func(m *Move) string {
+			return toolColors[order[m.Tool]%len(toolColors)]
+		}
+	}
+	lo, hi := feedRange(moves)
+	return func(m *Move) string {
+		if m.Kind == MOVE_RAPID {
+			return "#999"
+		}
+		return feedColor(programmedFeed(m), lo, hi)
+	}
+}
+
+func feedRange(moves []*Move) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, m := range moves {
+		if m.Kind != MOVE_RAPID {
+			f := programmedFeed(m)
+			lo, hi = math.Min(lo, f), math.Max(hi, f)
+		}
+	}
+	return lo, hi
+}
+
+func feedColor(f float64, lo float64, hi float64) string {
+	t := 0.0
+	if hi > lo {
+		t = (f - lo) / (hi - lo)
+	}
+	return fmt.Sprintf("hsl(%.0f,80%%,45%%)", 240*(1-t))
+}
+
+// plotLegend
+// The tools or the feed range along the bottom.
+func (s *Sim) plotLegend(b *strings.Builder, moves []*Move, by string, color func(m *Move) string, y float64) {
+	x := plotMargin
+	swatch := func(c string, label string) {
+		fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="12" height="8" fill="%v"/>`, x, y-8, c)
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f">%v</text>`+"\n", x+16, y, html.EscapeString(label))
+		x += 24 + 7*float64(len(label))
+	}
+	b.WriteString(`<g class="legend">` + "\n")
+	if by == "tool" {
+		seen := map[int64]bool{}
+		for _, m := range moves {
+			if !seen[m.Tool] {
+				seen[m.Tool] = true
+				swatch(color(m), fmt.Sprintf("T%v", m.Tool))
+			}
+		}
+	} else if lo, hi := feedRange(moves); lo <= hi {
+		swatch(feedColor(lo, lo, hi), fmt.Sprintf("F%.0f", lo))
+		swatch(feedColor(hi, lo, hi), fmt.Sprintf("F%.0f", hi))
+	}
+	b.WriteString("</g>\n")
+}
+
+// moveTip
+// The line, the command as written and the tool and feed it ran at.
+func moveTip(m *Move) string {
+	tip := ""
+	if m.Node != nil {
+		tip = fmt.Sprintf("line %v: %v", m.Node.Cmd.Line(), m.Node.Cmd.Block())
+	}
+	if m.Kind == MOVE_RAPID {
+		return fmt.Sprintf("%v (T%v rapid)", tip, m.Tool)
+	}
+	return fmt.Sprintf("%v (T%v F%.0f)", tip, m.Tool, programmedFeed(m))
+}
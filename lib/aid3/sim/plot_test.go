@@ -0,0 +1,48 @@
+package sim
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteSVG(t *testing.T) {
+	s := runSrc(t, "G21 G90 G17\nT1 M6\nG0 X-20 Y-20 Z20\nG1 Z10 F200\nG1 X0 F100\nT2 M6\nG0 Z20\nG2 X20 Y-20 I10 J-20 F400\n")
+	cfg := MakePlotConfig()
+	cfg.Views = []string{"xy", "xz", "yz"}
+	var b strings.Builder
+	if err := s.WriteSVG(&b, cfg); err != nil {
+		t.Fatalf("WriteSVG → %v", err)
+	}
+	svg := b.String()
+	d := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := d.Token(); err != nil {
+			if err.Error() != "EOF" {
+				t.Fatalf("SVG → Expected: well formed, Got: %v", err)
+			}
+			break
+		}
+	}
+	if n := strings.Count(svg, "<polyline"); n != 3*len(s.Moves) {
+		t.Errorf("Polylines → Expected: %v, Got: %v", 3*len(s.Moves), n)
+	}
+	for _, want := range []string{`class="rapid"`, `class="arc"`, "<title>line 8: G2 X20 Y-20 I10 J-20 F400 (T2 F400)</title>", ">T2</text>"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG → Expected: %v, Got: none", want)
+		}
+	}
+
+	cfg.Views, cfg.ColorBy, cfg.Rapids = []string{"xy"}, "feed", false
+	b.Reset()
+	if err := s.WriteSVG(&b, cfg); err != nil {
+		t.Fatalf("WriteSVG → %v", err)
+	}
+	if svg = b.String(); strings.Contains(svg, `class="rapid"`) || !strings.Contains(svg, ">F100</text>") || !strings.Contains(svg, ">F400</text>") {
+		t.Errorf("By feed without rapids → Expected: the feeds from F100 to F400, Got: %v", svg)
+	}
+	cfg.Views = []string{"xw"}
+	if err := s.WriteSVG(&b, cfg); err == nil {
+		t.Errorf("View xw → Expected: an error")
+	}
+}
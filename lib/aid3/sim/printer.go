@@ -0,0 +1,301 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"log"
+	"math"
+)
+
+// printCells is the most cells in the as-printed grid, larger prints
+// get coarser cells.
+const printCells = 64e6
+
+// PrintedBead
+// The plastic laid by one piece of a move.  Filament is the mm of
+// filament fed and Volume the mm3 laid, Width and Height the bead.
+// Temp is the hotend in °C and Fan 0 to 1 as it was laid.
+type PrintedBead struct {
+	Line     int
+	From     *tooling.Point
+	To       *tooling.Point
+	Filament float64
+	Volume   float64
+	Width    float64
+	Height   float64
+	Temp     float64
+	Fan      float64
+}
+
+// PrintReport
+// What a printer program laid down.  Filament is the mm fed, Volume
+// the mm3 in beads, Retractions how many times and Retracted how much
+// filament was pulled back.  Printed is the beads as cells.
+type PrintReport struct {
+	Filament    float64
+	Volume      float64
+	Retractions int
+	Retracted   float64
+	Beads       []*PrintedBead
+	Printed     *tooling.Stock
+}
+
+// PrintComparison
+// The print against the model it was sliced from, volumes in mm3.
+// Missing is in the model but not printed, Extra printed outside it.
+type PrintComparison struct {
+	Model   float64
+	Printed float64
+	Missing float64
+	Extra   float64
+}
+
+func (s *Sim) printer() *tooling.Printer {
+	p, _ := s.Tool.(*tooling.Printer)
+	return p
+}
+
+func cmdExtrudeMode(s *Sim, relative bool) {
+	if p := s.printer(); p != nil {
+		p.ExtrudeRelative(relative)
+	}
+}
+
+// cmdSetPosition
+// G92 sets where the filament is, the axes are not offset.
+func cmdSetPosition(s *Sim, cn *gcode.CmdNode) {
+	c := cn.Cmd.Coords()
+	if p := s.printer(); p != nil && c.Has('E') {
+		p.SetE(c.E)
+	}
+	if c.Has('X') || c.Has('Y') || c.Has('Z') {
+		s.warn(cn, s.Clock, "G92 on the axes is not simulated")
+	}
+}
+
+// cmdHeater
+// M104 and M140 set the hotend and bed, M109 and M190 wait too.  With
+// S a wait is only for heating up, with R it is for cooling as well.
+func cmdHeater(s *Sim, cn *gcode.CmdNode, bed bool, wait bool) {
+	p := s.printer()
+	if p == nil {
+		return
+	}
+	h := p.Hotend
+	if bed {
+		h = p.Bed
+	}
+	c := cn.Cmd.Coords()
+	heating := true
+	switch {
+	case c.Has('S'):
+		h.Set(c.S, s.Clock)
+	case c.Has('R'):
+		heating = false
+		h.Set(c.R, s.Clock)
+	}
+	if !wait {
+		return
+	}
+	if heating && h.Temp(s.Clock) >= h.Target {
+		return
+	}
+	s.Clock = math.Max(s.Clock, h.ReadyAt())
+}
+
+// cmdFan
+// M106 S of 255, full without S.  M107 is off.
+func cmdFan(s *Sim, cn *gcode.CmdNode, on bool) {
+	p := s.printer()
+	if p == nil {
+		return
+	}
+	p.Fan = 0
+	if on {
+		p.Fan = 1
+		if c := cn.Cmd.Coords(); c.Has('S') {
+			p.Fan = math.Max(0, math.Min(1, c.S/255))
+		}
+	}
+}
+
+// printMove
+// Feed the filament of the E word.  Pulling it back is a retraction,
+// which the next feed pays back before any plastic is laid.
+func (s *Sim) printMove(m *Move) {
+	p := s.printer()
+	m.Dry = false
+	c := m.Node.Cmd.Coords()
+	if !c.Has('E') {
+		return
+	}
+	fed := p.Extrude(c.E)
+	if m.From.Dist(m.To) == 0 && m.Feed > 0 {
+		// the filament alone moves at the feed
+		d := p.MoveTime(math.Abs(fed), m.Feed*p.FeedOverride, p.RetractAccel)
+		m.Duration += d
+		s.Clock += d
+	}
+	laid := s.feedFilament(fed) * p.FlowOverride
+	if laid <= 0 {
+		return
+	}
+	if temp := p.Hotend.Temp(m.Start); temp < p.MinExtrudeTemp {
+		s.warn(m.Node, m.Start, "cold extrusion at %3.0f°C", temp)
+		return
+	}
+	pts := movePoints(m)
+	length := 0.0
+	for i := 1; i < len(pts); i++ {
+		length += pts[i-1].Dist(pts[i])
+	}
+	if length == 0 {
+		s.warn(m.Node, m.Start, "extruding %3.3f mm without moving", laid)
+		return
+	}
+	height := p.LayerHeight(m.To.Z - s.levelZ)
+	for i := 1; i < len(pts); i++ {
+		l := pts[i-1].Dist(pts[i])
+		if l == 0 {
+			continue
+		}
+		fil := laid * l / length
+		b := tooling.MakeBead(pts[i-1], pts[i], fil*p.FilamentArea(), height)
+		s.beads = append(s.beads, &PrintedBead{
+			Line:     m.Node.Cmd.Line(),
+			From:     pts[i-1],
+			To:       pts[i],
+			Filament: fil,
+			Volume:   fil * p.FilamentArea(),
+			Width:    b.Width,
+			Height:   b.Height,
+			Temp:     p.Hotend.Temp(m.Start),
+			Fan:      p.Fan,
+		})
+	}
+}
+
+// feedFilament
+// Filament fed, negative when it is pulled back, and returns what
+// comes out of the nozzle.  A retraction is owed, and the next feed
+// pays it back before any plastic is laid.
+func (s *Sim) feedFilament(fed float64) float64 {
+	if fed < 0 {
+		s.retractions++
+		s.retracted -= fed
+		s.owed -= fed
+		return 0
+	}
+	laid := fed - math.Min(fed, s.owed)
+	s.owed -= fed - laid
+	return laid
+}
+
+// level
+// Follow the bed mesh while leveling is on, Z moves by the
+// compensation at the end of the move.  A Z carried from the last
+// move has its compensation taken off first.  Marlin also splits
+// long moves where they cross the mesh, here only the end is moved.
+func (s *Sim) level(p *tooling.Printer, c *gcode.Coords, to *tooling.Point) *tooling.Point {
+	if !c.Has('Z') {
+		to.Z -= s.levelZ
+	}
+	s.levelZ = 0
+	if p.Leveling {
+		s.levelZ = p.Mesh.Compensation(to)
+	}
+	return &tooling.Point{X: to.X, Y: to.Y, Z: to.Z + s.levelZ}
+}
+
+// printReport
+// The beads, and the cells they fill over their bounds.
+func printReport(s *Sim) *PrintReport {
+	p := s.printer()
+	if p == nil {
+		return nil
+	}
+	ret := &PrintReport{
+		Retractions: s.retractions,
+		Retracted:   s.retracted,
+		Beads:       s.beads,
+	}
+	for _, b := range s.beads {
+		ret.Filament += b.Filament
+		ret.Volume += b.Volume
+	}
+	if lo, hi := beadBounds(s.beads); lo != nil {
+		ret.Printed = s.printStock(lo, hi)
+	}
+	return ret
+}
+
+func beadBounds(beads []*PrintedBead) (*tooling.Point, *tooling.Point) {
+	var lo, hi *tooling.Point
+	for _, pb := range beads {
+		b := tooling.MakeBead(pb.From, pb.To, pb.Volume, pb.Height)
+		blo, bhi := b.Bounds()
+		if lo == nil {
+			lo, hi = blo, bhi
+			continue
+		}
+		lo = &tooling.Point{X: math.Min(lo.X, blo.X), Y: math.Min(lo.Y, blo.Y), Z: math.Min(lo.Z, blo.Z)}
+		hi = &tooling.Point{X: math.Max(hi.X, bhi.X), Y: math.Max(hi.Y, bhi.Y), Z: math.Max(hi.Z, bhi.Z)}
+	}
+	return lo, hi
+}
+
+// printStock
+// The beads laid in a grid over the bounds.  The cells are a quarter
+// of the nozzle or the resolution, whichever is finer, unless that
+// would be more than printCells.
+func (s *Sim) printStock(lo *tooling.Point, hi *tooling.Point) *tooling.Stock {
+	cell := math.Min(s.Resolution, s.printer().Nozzle/4)
+	vol := (hi.X - lo.X) * (hi.Y - lo.Y) * (hi.Z - lo.Z)
+	cell = math.Max(cell, math.Cbrt(vol/printCells))
+	ret := tooling.MakeEmptyStock(lo, hi, cell)
+	for _, pb := range s.beads {
+		ret.Deposit(tooling.MakeBead(pb.From, pb.To, pb.Volume, pb.Height))
+	}
+	return ret
+}
+
+// ComparePrint
+// Compare what was printed with the mesh it was sliced from.  The
+// mesh is moved to sit on the bed, centered on the print.
+func (s *Sim) ComparePrint(model *tooling.Mesh) *PrintComparison {
+	if s.Print == nil || s.Print.Printed == nil {
+		return nil
+	}
+	mlo, mhi := model.Bounds()
+	plo, phi := s.Print.Printed.Bounds()
+	model = model.TranslateTo(&tooling.Point{
+		X: (plo.X+phi.X)/2 - (mlo.X+mhi.X)/2,
+		Y: (plo.Y+phi.Y)/2 - (mlo.Y+mhi.Y)/2,
+		Z: -mlo.Z,
+	})
+	mlo, mhi = model.Bounds()
+	lo := &tooling.Point{X: math.Min(mlo.X, plo.X), Y: math.Min(mlo.Y, plo.Y), Z: math.Min(mlo.Z, plo.Z)}
+	hi := &tooling.Point{X: math.Max(mhi.X, phi.X), Y: math.Max(mhi.Y, phi.Y), Z: math.Max(mhi.Z, phi.Z)}
+
+	printed := s.printStock(lo, hi)
+	target := tooling.MakeEmptyStock(lo, hi, printed.Cell())
+	target.Fill(model)
+
+	ret := &PrintComparison{Model: target.Volume(), Printed: printed.Volume()}
+	both := printed.Clone()
+	both.Intersect(target)
+	ret.Missing = ret.Model - both.Volume()
+	ret.Extra = ret.Printed - both.Volume()
+	return ret
+}
+
+func logPrint(s *Sim) {
+	if s.Print == nil {
+		return
+	}
+	log.Printf("Extruded %3.1f mm of filament, %3.1f mm3 in %v beads, %v retractions of %3.1f mm\n",
+		s.Print.Filament, s.Print.Volume, len(s.Print.Beads), s.Print.Retractions, s.Print.Retracted)
+	if s.Print.Printed != nil {
+		log.Printf("Printed %3.1f mm3 at %3.2f mm cells\n", s.Print.Printed.Volume(), s.Print.Printed.Cell())
+	}
+}
@@ -0,0 +1,163 @@
+package sim
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+// The parameters a probe sets, the trip point and 1 when it tripped.
+const (
+	PARAM_PROBE_X  = gcode.PARAM_PROBE_X
+	PARAM_PROBE_Y  = gcode.PARAM_PROBE_Y
+	PARAM_PROBE_Z  = gcode.PARAM_PROBE_Z
+	PARAM_PROBE_OK = gcode.PARAM_PROBE_OK
+)
+
+// Probe
+// A G38 straight probe.  G38.2 and G38.3 move toward the work and stop
+// on touching it, G38.4 and G38.5 move away and stop on letting go,
+// the stock or a fixture to the resolution of its cells.  At is where
+// it tripped, the end of the move when it did not, Against is what it
+// touched.
+type Probe struct {
+	Line    int
+	Code    string
+	From    *tooling.Point
+	To      *tooling.Point
+	At      *tooling.Point
+	Tripped bool
+	Against string
+}
+
+func (p *Probe) String() string {
+	if !p.Tripped {
+		return fmt.Sprintf("line %v: %v to %v did not trip", p.Line, p.Code, p.To)
+	}
+	return fmt.Sprintf("line %v: %v tripped on %v at %v", p.Line, p.Code, p.Against, p.At)
+}
+
+// cmdProbe
+// Move toward the target until the probe trips and store where in
+// #5061 to #5063.  A G38.2 or G38.4 which does not trip stops the
+// program, as the control alarms.  Other machines feed to the target
+// without probing, so the moves after start from there.
+func cmdProbe(s *Sim, cn *gcode.CmdNode) error {
+	code := cn.Cmd.Src()
+	if s.lathe() != nil || s.Sheet != nil || s.printer() != nil {
+		s.warn(cn, s.Clock, "%v is only simulated on a mill, fed to the target", code)
+		cmdLinear(s, cn)
+		return nil
+	}
+	m := s.beginMove(MOVE_PROBE, cn)
+	p := &Probe{Line: cn.Cmd.Line(), Code: code, From: m.From, To: CmdToXYZ(cn.Cmd.Coords(), m.From)}
+	body := cutterBody(s.ToolHead.Cutter(), false)
+	if code == "G38.2" || code == "G38.3" {
+		s.probeToward(m, body, p)
+	} else {
+		s.probeAway(m, body, p)
+	}
+	if !p.Tripped {
+		p.At = p.To
+	}
+	linearTo(s, m, p.At)
+	if m.To == nil {
+		s.endMove(m, p.At)
+	}
+	s.Probes = append(s.Probes, p)
+	if debugProbe {
+		log.Printf("PROBE %v", p)
+	}
+
+	at := p.At
+	if s.frame != nil {
+		at = s.frame.Program(at)
+	}
+	s.Params[PARAM_PROBE_X], s.Params[PARAM_PROBE_Y], s.Params[PARAM_PROBE_Z] = at.X, at.Y, at.Z
+	s.Params[PARAM_PROBE_OK] = 0
+	if p.Tripped {
+		s.Params[PARAM_PROBE_OK] = 1
+	}
+	if !p.Tripped && (code == "G38.2" || code == "G38.4") {
+		return fmt.Errorf("%v to %v did not trip", code, p.To)
+	}
+	return nil
+}
+
+// probeGrids
+// What a probe can touch, by name.
+func (s *Sim) probeGrids() ([]*tooling.Stock, []string) {
+	var grids []*tooling.Stock
+	var names []string
+	if s.Stock != nil {
+		grids, names = append(grids, s.Stock), append(names, "stock")
+	}
+	for _, f := range s.Fixtures {
+		grids, names = append(grids, f.grid), append(names, f.Name)
+	}
+	return grids, names
+}
+
+// probeToward
+// The first contact along the move.
+func (s *Sim) probeToward(m *Move, body tooling.Body, p *Probe) {
+	sw := m.sweepBetween(body, p.From, p.To)
+	grids, names := s.probeGrids()
+	first := math.Inf(1)
+	for i, g := range grids {
+		if c := g.Contact(sw); c != nil && c.T < first {
+			first = c.T
+			p.Against = names[i]
+		}
+	}
+	if p.Against != "" {
+		// stopped a µm short, touching rather than in the cell
+		p.Tripped = true
+		p.At = sw.At(math.Max(0, first-1e-3/sw.Length()))
+	}
+}
+
+// probeAway
+// The first place along the move, in half cells, where the probe no
+// longer touches what it touched at the start.
+func (s *Sim) probeAway(m *Move, body tooling.Body, p *Probe) {
+	grids, names := s.probeGrids()
+	length := p.From.Dist(p.To)
+	if length == 0 {
+		return
+	}
+	// within 2 µm back along the move, as a probe stops short of what it touches
+	back := &tooling.Point{X: (p.From.X - p.To.X) / length * 2e-3, Y: (p.From.Y - p.To.Y) / length * 2e-3, Z: (p.From.Z - p.To.Z) / length * 2e-3}
+	touching := func(at *tooling.Point) string {
+		sw := m.sweepBetween(body, at, &tooling.Point{X: at.X + back.X, Y: at.Y + back.Y, Z: at.Z + back.Z})
+		for i, g := range grids {
+			if g.Contact(sw) != nil {
+				return names[i]
+			}
+		}
+		return ""
+	}
+	p.Against = touching(p.From)
+	if p.Against == "" || len(grids) == 0 {
+		// nothing to let go of, the probe cannot trip
+		p.Against = ""
+		return
+	}
+	step := grids[0].Cell() / 2
+	for _, g := range grids {
+		step = math.Min(step, g.Cell()/2)
+	}
+	sw := tooling.SweepBody(body, p.From, p.To)
+	n := int(math.Ceil(length / step))
+	for k := 1; k <= n; k++ {
+		at := sw.At(float64(k) / float64(n))
+		if touching(at) == "" {
+			p.Tripped = true
+			p.At = at
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func runSrc(t *testing.T, src string) *Sim {
+	return runWith(t, src, nil)
+}
+
+// runWith
+// Run the program on the 30 mm wood block, setup given the sim first.
+func runWith(t *testing.T, src string, setup func(s *Sim)) *Sim {
+	dir := t.TempDir()
+	ncNm := filepath.Join(dir, "p.nc")
+	if err := os.WriteFile(ncNm, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := gcode.Parse(ncNm)
+	if err != nil {
+		t.Fatalf("Parse → %v", err)
+	}
+	s := &Sim{Resolution: 1}
+	s.StartWith(tooling.BuildCnc(tooling.MakeWood(15)))
+	s.OutDir = dir
+	if setup != nil {
+		setup(s)
+	}
+	s.Run(tree)
+	return s
+}
+
+func TestProbe(t *testing.T) {
+	// a 6 mm tool touching off the top and the +X side of the 30 mm block,
+	// out of the hole its first rapid leaves from the middle of it
+	s := runSrc(t, "G0 Z30\nG0 X-8 Y-8\nG38.2 Z0 F100\nG38.4 Z30\nG0 X30 Z5\nG38.2 X0\nG0 X40\nG38.3 X50\nG0 Z30\nG38.2 Z20\nG0 X0\n")
+	if len(s.Probes) != 5 {
+		t.Fatalf("Probes → Expected: 5, Got: %v", len(s.Probes))
+	}
+	top, off, side, air := s.Probes[0], s.Probes[1], s.Probes[2], s.Probes[3]
+	if !top.Tripped || top.Against != "stock" || math.Abs(top.At.Z-15) > 1 {
+		t.Errorf("Top → Expected: tripped at Z15, Got: %v", top)
+	}
+	if !off.Tripped || off.At.Z < top.At.Z || off.At.Z-top.At.Z > 1 {
+		t.Errorf("Off the top → Expected: let go just above %v, Got: %v", top.At, off)
+	}
+	if !side.Tripped || math.Abs(side.At.X-18) > 1 {
+		t.Errorf("Side → Expected: tripped at X18, Got: %v", side)
+	}
+	if air.Tripped || air.At.X != 50 || s.Params[PARAM_PROBE_OK] != 0 {
+		t.Errorf("G38.3 in the air → Expected: no trip and no alarm, Got: %v", air)
+	}
+	if s.Params[PARAM_PROBE_X] != 50 || s.Params[PARAM_PROBE_Z] != 20 {
+		t.Errorf("Params → Expected: the end of the last probe, Got: %v", s.Params)
+	}
+	if s.Alarm == nil || s.Alarm.Line != 10 {
+		t.Errorf("G38.2 in the air → Expected: an alarm at line 10, Got: %v", s.Alarm)
+	}
+	if last := s.Moves[len(s.Moves)-1]; last.Node.Cmd.Line() != 10 {
+		t.Errorf("Alarm → Expected: the program stopped at line 10, Got: a move at %v", last.Node.Cmd.Line())
+	}
+	for _, m := range s.Moves {
+		if m.Kind == MOVE_PROBE && m.Removed != nil {
+			t.Errorf("Stock → Expected: uncut by probing, Got: %v cut at line %v", m.Removed.Cells, m.Node.Cmd.Line())
+		}
+	}
+}
+
+func TestProbeOffMill(t *testing.T) {
+	// a lathe feeds to the target, the next move starting there
+	s := runWith(t, "G0 X40 Z5\nG38.2 X34 Z-10 F100\nG0 X50\n", func(s *Sim) {
+		s.StartWith(tooling.BuildLathe(tooling.MakeWood(15), 15, 60))
+	})
+	if len(s.Probes) != 0 || len(s.Warnings) == 0 || s.Warnings[0].Line != 2 {
+		t.Errorf("Lathe → Expected: a warning at line 2 and no probe, Got: %v probes, warnings %v", len(s.Probes), s.Warnings)
+	}
+	want := &tooling.Point{X: s.lathe().ProgramX(34), Z: -10}
+	if len(s.Moves) != 3 || s.Moves[1].To.Dist(want) > 1e-9 || s.Moves[2].From.Dist(want) > 1e-9 {
+		t.Errorf("Lathe → Expected: G38.2 to %v and on from there, Got: %v moves", want, len(s.Moves))
+	}
+	if s.Alarm != nil {
+		t.Errorf("Lathe → Expected: no alarm, Got: %v", s.Alarm)
+	}
+}
+
+func TestProbeParams(t *testing.T) {
+	// touch off the top, set G54 Z there and go to 5 above it, away and
+	// back to the trip point's X and Y, then along X
+	s := runSrc(t, "G21 G90\nG0 Z30\nG0 X-8 Y-8\nG38.2 Z0 F100\nG10 L2 P1 Z#5063\nG0 Z5\nG0 X20 Y20\nG0 X#5061 Y#5062\nG0 X3\n")
+	if len(s.Probes) != 1 || !s.Probes[0].Tripped {
+		t.Fatalf("Probe → Expected: a trip, Got: %v", s.Probes)
+	}
+	top := s.Probes[0].At.Z
+	if got := s.frame.Offset(); math.Abs(got.Z-top) > 1e-9 {
+		t.Errorf("Offset → Expected: Z%v, Got: %v", top, got)
+	}
+	want := []*tooling.Point{{X: -8, Y: -8, Z: top + 5}, {X: 20, Y: 20, Z: top + 5}, {X: -8, Y: -8, Z: top + 5}, {X: 3, Y: -8, Z: top + 5}}
+	moves := s.Moves[len(s.Moves)-4:]
+	for i, m := range moves {
+		if m.To.Dist(want[i]) > 1e-6 {
+			t.Errorf("Move %v → Expected: %v, Got: %v", m.Node.Cmd.Src(), want[i], m.To)
+		}
+	}
+	if s.Alarm != nil || len(s.Warnings) > 0 {
+		t.Errorf("Params → Expected: no alarm or warnings, Got: %v %v", s.Alarm, s.Warnings)
+	}
+}
@@ -1,87 +1,288 @@
 package sim
 
 import (
+	"errors"
 	"fmt"
 	"github.com/timleecasey/stllib/lib/aid3/gcode"
 	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 )
 
 var debugLinear = false
 var debugArc = false
 var debugPts = false
+var debugProbe = false
+var debugOutputs = false
 
 // Sim
 // TimeSlice is the time unit increment for running the sim
 // Head is the current position
 // The unit is meters, so Velocity is m/s and Head position is measured in meter with respect to the tool zero point
+// Stock is the in-process material, cut by the volume swept by each move
+// Resolution is the cell size of the stock
+// Moves are the motion commands as simulated
+// Fixtures are checked against the whole tool, Collisions are what was hit
+// Clock is the program time in seconds
+// Analysis has the cutting conditions, checked against Limits
+// AnalysisStep is the longest part of a move analysed at once, 0 for the cutter radius
+// WaitForSpindle holds the program after M3, M4 or S until the spindle is at speed
+// Warnings are for programs which run, but likely not as intended
+// Alarm is what stopped the program before its end, as a G38.2 which
+// did not trip stops it
+// Probes are the G38 moves, Params the numbered parameters they set,
+// #5061 to #5063 and #5070, which words such as X#5061 read back
+// Joints are the machine axes, Tcp is on with G43.4 so XYZ program the tool tip
+// Turned is the bar on a lathe, in place of Stock
+// Sheet is cut by a laser or plasma, in place of Stock, and Beam is what it cut
+// Print is what a printer laid down
+// Vol is the travel of the machine, nil for no limits.  Accel is how
+// fast rapids speed up in mm/s2, 0 for at once.  A tool change takes
+// ToolChangeTime seconds in a changer of ToolSlots, 0 for any number.
+// Dialect is the control reading the program.
+// WorkOffsets are G54 to G59 as the machine is set up, G10 L2 and L20
+// change them as the program runs.
 type Sim struct {
-	TimeSlice float64
-	Tool      tooling.Cnc
-	ToolHead  tooling.Head
-	Tolerance float64
-	Vol       tooling.Volume
+	TimeSlice  float64
+	Tool       tooling.Cnc
+	ToolHead   tooling.Head
+	Tolerance  float64
+	Vol        tooling.Volume
+	Stock      *tooling.Stock
+	Resolution float64
+	Moves      []*Move
+	Fixtures   []*Fixture
+	Collisions []*Collision
+
+	Clock        float64
+	Analysis     *Analysis
+	Limits       *Limits
+	AnalysisStep float64
+
+	WaitForSpindle bool
+	Warnings       []*Warning
+	Alarm          *Warning
+
+	Probes []*Probe
+	Params map[int]float64
+
+	Joints tooling.Joints
+	Tcp    bool
+
+	Turned      *tooling.RevolvedStock
+	clampLine   int
+	cycle       turnCycle
+	tree        *gcode.ParseTree
+	skipThrough *gcode.CmdNode
+	frame       *gcode.Frame
+
+	Sheet       *tooling.SheetStock
+	Beam        *BeamReport
+	beamRuns    []*beamRun
+	pierces     []*Pierce
+	pierced     bool
+	freshPierce bool
+
+	Print       *PrintReport
+	beads       []*PrintedBead
+	retractions int
+	retracted   float64
+	owed        float64
+	levelZ      float64
+
+	Events []*Event
+	OutDir string
+
+	Accel          float64
+	ToolChangeTime float64
+	ToolSlots      int
+	Dialect        int
+	WorkOffsets    []*tooling.Point
+
+	moveObservers []func(m *Move)
 }
 
 func (s *Sim) Start() {
-	s.TimeSlice = 0.001
+	s.StartWith(tooling.BuildCnc(tooling.MakeWood(15.)))
+}
+
+// StartWith
+// Start on the machine, a lathe turns bar stock and a laser or plasma
+// cuts a sheet instead of cutting a block.  A TimeSlice, Tolerance or
+// Resolution set before starting is kept, as is the machine setup.
+func (s *Sim) StartWith(tool tooling.Cnc) {
+	if s.TimeSlice == 0 {
+		s.TimeSlice = 0.001
+	}
 
-	tool := tooling.BuildCnc(tooling.MakeWood(15.))
 	head := tool.Head()
 	tool.Reset()
 
 	s.ToolHead = head
 	s.Tool = tool
 
-	s.Vol = tooling.MakeVolume(&tooling.Point{X: -20, Y: -20, Z: -20}, &tooling.Point{X: 20, Y: 20, Z: 20})
-
-	s.Tolerance = 0.01 // 0.01 mm?
+	if s.Tolerance == 0 {
+		s.Tolerance = 0.01 // 0.01 mm?
+	}
+	if s.Resolution == 0 {
+		s.Resolution = 0.25
+	}
+	s.Stock = nil
+	s.Turned = nil
+	s.Sheet = nil
+	switch t := tool.(type) {
+	case *tooling.Lathe:
+		radius, bore, length := t.Bar()
+		s.Turned = tooling.MakeRevolvedStock(radius, bore, -length, 0, s.Resolution)
+	case *tooling.BeamCutter:
+		lo, hi := t.Sheet()
+		s.Sheet = tooling.MakeSheetStock(lo, hi, math.Min(s.Resolution, t.Kerf/2))
+	case *tooling.Printer:
+		// a print starts from nothing, the beads are laid at the end
+	default:
+		lo, hi := tool.Material().Volume().Bounds()
+		s.Stock = tooling.MakeStock(lo, hi, s.Resolution)
+	}
+	s.Moves = nil
+	s.Fixtures = nil
+	s.Collisions = nil
+
+	s.Clock = 0
+	s.Analysis = MakeAnalysis()
+	s.Limits = DefaultLimits()
+	s.AnalysisStep = 0
+
+	s.WaitForSpindle = false
+	s.Warnings = nil
+	s.Alarm = nil
+	s.Probes = nil
+	s.Params = make(map[int]float64)
+
+	s.Joints = tooling.Joints{}
+	s.Tcp = false
+	s.clampLine = 0
+	s.cycle = defaultTurnCycle()
+	s.skipThrough = nil
+	s.Beam = nil
+	s.beamRuns = nil
+	s.pierces = nil
+	s.pierced = false
+	s.freshPierce = false
+	s.Print = nil
+	s.beads = nil
+	s.retractions = 0
+	s.retracted = 0
+	s.owed = 0
+	s.levelZ = 0
+	s.Events = nil
 }
 
 var cmdCnt int
 
+// errProgramEnd
+// M2 or M30, the run stops there without an alarm.
+var errProgramEnd = errors.New("program end")
+
 func (s *Sim) Run(tree *gcode.ParseTree) {
 
 	log.Printf("Start %v\n", s.Tool.Head().Pos())
 
 	cmdCnt = 0
 
-	tree.TraverseCmds(func(cn *gcode.CmdNode) error {
+	s.tree = tree
+	s.frame = gcode.NewFrame(s.lathe() != nil)
+	for i, o := range s.WorkOffsets {
+		if o != nil && i < 6 {
+			s.frame.SetOffset(i, o)
+		}
+	}
+	if err := tree.TraverseCmds(func(cn *gcode.CmdNode) error {
+		if s.skipThrough != nil {
+			// the profile of a lathe cycle
+			if cn == s.skipThrough {
+				s.skipThrough = nil
+			}
+			return nil
+		}
 		err := cmdVisitor(s, cn)
 		if debugLinear {
 			log.Printf("After %v %v F: %v\n", cn.Cmd.Src(), s.Tool.Head().Pos(), s.Tool.FeedRate())
 		}
+		if err != nil && err != errProgramEnd && s.Alarm == nil {
+			s.Alarm = &Warning{Line: cn.Cmd.Line(), Time: s.Clock, Message: err.Error()}
+		}
 		return err
-	})
+	}); err != nil && err != errProgramEnd {
+		log.Printf("ALARM %v", s.Alarm)
+	}
+
+	s.Beam = beamReport(s)
+	s.Print = printReport(s)
 
-	writePathPoints(s.ToolHead)
+	writePathPoints(s)
+	writeProfile(s)
+	writeEnergy(s)
 
 	log.Printf("Ran %v commands %v points\n", cmdCnt, s.ToolHead.PointCount())
 
-	subtractPathPoints(s)
+	logRemoved(s)
+	logBeam(s)
+	logPrint(s)
+
+	if len(s.Collisions) > 0 {
+		log.Printf("%v collisions, first %v\n", len(s.Collisions), s.Collisions[0])
+	}
+
+	logAnalysis(s)
+	logDryCuts(s)
+
+	if len(s.Warnings) > 0 {
+		log.Printf("%v warnings, first %v\n", len(s.Warnings), s.Warnings[0])
+	}
 }
 
 func cmdVisitor(s *Sim, cn *gcode.CmdNode) error {
 	var err error
 	err = nil
 
+	if read := cn.Cmd.Read(s.Params); read != cn.Cmd {
+		// the words reading #5061 and on take what the probes left there
+		cn = &gcode.CmdNode{Cmd: read, Next: cn.Next}
+	}
+	if s.frame != nil {
+		// G68, G51, G51.1, G16 and the work offsets move the moves after them
+		framed, ferr := s.frame.Apply(cn)
+		if ferr != nil {
+			s.warn(cn, s.Clock, "%v, not simulated", ferr)
+			return nil
+		}
+		cn = framed
+	}
+
 	if cn.Cmd.Coords().F != 0 {
 		s.Tool.AssignFeedRate(cn.Cmd.Coords().F)
 	}
 
 	switch cn.Cmd.CmdType() {
 	case gcode.CMD_FAST:
+		// the programmed feed is modal, and comes back after the rapid
+		feed := s.Tool.FeedRate()
 		s.Tool.AssignFeedRate(s.Tool.FastFeedRate())
 		cmdLinear(s, cn)
+		s.Tool.AssignFeedRate(feed)
 		cmdCnt++
 		break
 	case gcode.CMD_LINEAR:
 		cmdLinear(s, cn)
 		cmdCnt++
 		break
+	case gcode.CMD_PROBE:
+		err = cmdProbe(s, cn)
+		cmdCnt++
+		break
 
 	case gcode.CMD_CW_ARC:
 		cmdCwArch(s, cn)
@@ -95,18 +296,194 @@ func cmdVisitor(s *Sim, cn *gcode.CmdNode) error {
 	case gcode.CMD_TOOL_CHANGE:
 		var tool int64
 		tool, err = cmdSrcToInt(cn)
-		cmdToolChange(s, tool)
+		cmdToolChange(s, cn, tool)
 		cmdCnt++
 		break
 
 	case gcode.CMD_SPINDLE_SPEED:
-		var speed int64
-		speed, err = cmdSrcToInt(cn)
-		cmdSpindleSpeed(s, speed)
+		err = cmdSpindleSpeed(s, cn)
 		cmdCnt++
 		break
 	case gcode.CMD_SPINDLE_OFF:
-		cmdSpindleSpeed(s, 0)
+		cmdSpindleStop(s)
+		cmdCnt++
+		break
+	case gcode.CMD_SPINDLE_CW:
+		cmdSpindleStart(s, tooling.SPINDLE_CW)
+		cmdCnt++
+		break
+	case gcode.CMD_SPINDLE_CCW:
+		cmdSpindleStart(s, tooling.SPINDLE_CCW)
+		cmdCnt++
+		break
+	case gcode.CMD_SPINDLE_ORIENT:
+		cmdSpindleOrient(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_SPINDLE_GEAR:
+		err = cmdSpindleGear(s, cn)
+		cmdCnt++
+		break
+
+	case gcode.CMD_COOLANT_ON:
+		cmdOutput(s, tooling.AUX_FLOOD, true)
+		cmdCnt++
+		break
+	case gcode.CMD_COOLANT_MIST:
+		cmdOutput(s, tooling.AUX_MIST, true)
+		cmdCnt++
+		break
+	case gcode.CMD_COOLANT_THROUGH:
+		cmdOutput(s, tooling.AUX_THROUGH_SPINDLE, true)
+		cmdCnt++
+		break
+	case gcode.CMD_COOLANT_THROUGH_OFF:
+		cmdOutput(s, tooling.AUX_THROUGH_SPINDLE, false)
+		cmdCnt++
+		break
+	case gcode.CMD_COOLANT_OFF:
+		cmdCoolantOff(s)
+		cmdCnt++
+		break
+	case gcode.CMD_AIR_ON:
+		cmdOutput(s, tooling.AUX_AIR, true)
+		cmdCnt++
+		break
+	case gcode.CMD_AIR_OFF:
+		cmdOutput(s, tooling.AUX_AIR, false)
+		cmdCnt++
+		break
+	case gcode.CMD_VACUUM_ON:
+		cmdOutput(s, tooling.AUX_VACUUM, true)
+		cmdCnt++
+		break
+	case gcode.CMD_VACUUM_OFF:
+		cmdOutput(s, tooling.AUX_VACUUM, false)
+		cmdCnt++
+		break
+	case gcode.CMD_DIGITAL_OUT:
+		cmdDigitalOut(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_ANALOG_OUT:
+		cmdAnalogOut(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_WAIT_INPUT: // there are no inputs, they are always ready
+		cmdCnt++
+		break
+
+	case gcode.CMD_TOOL_LENGTH: // the tip is always the controlled point
+		cmdCnt++
+		break
+	case gcode.CMD_TCP:
+		cmdTcp(s, true)
+		cmdCnt++
+		break
+	case gcode.CMD_TOOL_LENGTH_OFF:
+		cmdTcp(s, false)
+		cmdCnt++
+		break
+
+	case gcode.CMD_DIAMETER_MODE, gcode.CMD_RADIUS_MODE, gcode.CMD_CSS, gcode.CMD_CSS_OFF, gcode.CMD_MAX_SPINDLE:
+		cmdLatheMode(s, cn)
+		cmdCnt++
+		break
+
+	case gcode.CMD_FINISH_CYCLE, gcode.CMD_TURN_CYCLE, gcode.CMD_FACE_CYCLE, gcode.CMD_PATTERN_CYCLE,
+		gcode.CMD_FACE_PECK_CYCLE, gcode.CMD_GROOVE_CYCLE, gcode.CMD_THREAD_CYCLE:
+		cmdTurnCycle(s, cn)
+		cmdCnt++
+		break
+
+	case gcode.CMD_ABSOLUTE, gcode.CMD_EXTRUDE_ABSOLUTE:
+		// the parser resolves G91 on the axes, on a printer it is E too
+		cmdExtrudeMode(s, false)
+		cmdCnt++
+		break
+	case gcode.CMD_INCREMENTAL, gcode.CMD_EXTRUDE_RELATIVE:
+		cmdExtrudeMode(s, true)
+		cmdCnt++
+		break
+	case gcode.CMD_SET_POSITION:
+		cmdSetPosition(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_HOTEND_TEMP:
+		cmdHeater(s, cn, false, false)
+		cmdCnt++
+		break
+	case gcode.CMD_HOTEND_WAIT:
+		cmdHeater(s, cn, false, true)
+		cmdCnt++
+		break
+	case gcode.CMD_BED_TEMP:
+		cmdHeater(s, cn, true, false)
+		cmdCnt++
+		break
+	case gcode.CMD_BED_WAIT:
+		cmdHeater(s, cn, true, true)
+		cmdCnt++
+		break
+	case gcode.CMD_FAN_ON:
+		cmdFan(s, cn, true)
+		cmdCnt++
+		break
+	case gcode.CMD_FAN_OFF:
+		cmdFan(s, cn, false)
+		cmdCnt++
+		break
+	case gcode.CMD_HOME:
+		cmdHome(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_BED_LEVEL:
+		cmdBedLevel(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_LEVELING:
+		cmdLeveling(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_MESH_POINT:
+		cmdMeshPoint(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_RETRACT:
+		cmdFirmwareRetract(s, cn, true)
+		cmdCnt++
+		break
+	case gcode.CMD_UNRETRACT:
+		cmdFirmwareRetract(s, cn, false)
+		cmdCnt++
+		break
+	case gcode.CMD_ACCEL:
+		cmdAccel(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_JERK:
+		cmdJerk(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_FEED_OVERRIDE:
+		cmdOverride(s, cn, false)
+		cmdCnt++
+		break
+	case gcode.CMD_FLOW_OVERRIDE:
+		cmdOverride(s, cn, true)
+		cmdCnt++
+		break
+	case gcode.CMD_MESSAGE:
+		cmdMessage(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_DWELL:
+		cmdDwell(s, cn)
+		cmdCnt++
+		break
+	case gcode.CMD_PROGRAM_END:
+		// what follows is subprograms or left over, never run
+		err = errProgramEnd
 		cmdCnt++
 		break
 
@@ -183,25 +560,26 @@ func CmdToXYZ(c *gcode.Coords, curPt *tooling.Point) *tooling.Point {
 		Z: c.Z,
 	}
 
-	if ret.X == 0 {
+	if !c.Has('X') {
 		ret.X = curPt.X
 	}
-	if ret.Y == 0 {
+	if !c.Has('Y') {
 		ret.Y = curPt.Y
 	}
-	if ret.Z == 0 {
+	if !c.Has('Z') {
 		ret.Z = curPt.Z
 	}
 
 	return ret
 }
 
-func writePathPoints(h tooling.Head) {
-
-	if f, err := os.Create("path.gcode"); err == nil {
+func writePathPoints(s *Sim) {
+	h := s.ToolHead
+	if f, err := os.Create(s.outFile("path.gcode")); err == nil {
 		defer f.Close()
+		_, err = f.WriteString("G21 G90\n")
 		h.Path(func(p *tooling.Point) {
-			ptStr := fmt.Sprintf("G1 %v %v %v\n", p.X, p.Y, p.Z)
+			ptStr := fmt.Sprintf("G1 X%v Y%v Z%v\n", p.X, p.Y, p.Z)
 			_, err = f.WriteString(ptStr)
 		})
 	} else {
@@ -209,14 +587,37 @@ func writePathPoints(h tooling.Head) {
 	}
 }
 
-func subtractPathPoints(s *Sim) {
-	h := s.ToolHead
-	v := s.Vol
-	headShape := h.Shape()
-	h.Path(func(p *tooling.Point) {
-		v.Subtract(headShape)
-	})
+// outFile
+// Where the run writes a file, in OutDir or the working directory.
+func (s *Sim) outFile(name string) string {
+	return filepath.Join(s.OutDir, name)
+}
 
+// StockFrom
+// Start from stock shaped as the mesh instead of a block, a casting or
+// a part from an earlier setup.
+func (s *Sim) StockFrom(mesh *tooling.Mesh) {
+	lo, hi := mesh.Bounds()
+	s.Stock = tooling.MakeEmptyStock(lo, hi, s.Resolution)
+	s.Stock.Fill(mesh)
+}
+
+func logRemoved(s *Sim) {
+	removed := 0.0
+	for _, m := range s.Moves {
+		if m.Removed != nil {
+			removed += m.Removed.Volume
+		}
+	}
+	if s.Stock != nil {
+		log.Printf("Removed %3.3f mm3, %3.3f mm3 of stock left\n", removed, s.Stock.Volume())
+	}
+	if s.Turned != nil {
+		log.Printf("Turned away %3.3f mm3, %3.3f mm3 of bar left\n", removed, s.Turned.Volume())
+	}
+	if s.Sheet != nil {
+		log.Printf("Cut away %3.3f mm3, %3.3f mm3 of sheet left\n", removed, s.Sheet.Volume())
+	}
 }
 
 func cmdSrcToInt(cn *gcode.CmdNode) (int64, error) {
@@ -0,0 +1,37 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+func TestProgramEnd(t *testing.T) {
+	// the subprogram after M30 is only run when called
+	s := runSrc(t, "G0 Z30\nG0 X10\nM30\nO1001\nG0 X-10\nM99\n")
+	if s.Alarm != nil {
+		t.Errorf("M30 → Expected: no alarm, Got: %v", s.Alarm)
+	}
+	if last := s.Moves[len(s.Moves)-1]; last.Node.Cmd.Line() != 2 || s.ToolHead.Pos().X != 10 {
+		t.Errorf("M30 → Expected: stopped after line 2 at X10, Got: a move at %v to %v", last.Node.Cmd.Line(), s.ToolHead.Pos())
+	}
+}
+
+func TestWorkOffsets(t *testing.T) {
+	// G54 from the machine setup, G55 from the program
+	s := runWith(t, "G0 Z30\nG0 X0 Y0\nG10 L2 P2 X-10 Y4\nG55\nG0 X0 Y0\nG53 G0 Z40\n", func(s *Sim) {
+		s.WorkOffsets = []*tooling.Point{{X: 5, Y: 5, Z: 2}}
+	})
+	var got []string
+	for _, m := range s.Moves[1:] {
+		got = append(got, m.To.String())
+	}
+	want := []string{"X:5.000, Y:5.000, Z:32.000", "X:-10.000, Y:4.000, Z:32.000", "X:-10.000, Y:4.000, Z:40.000"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Work offsets → Expected: %q, Got: %q", want, got)
+	}
+	if len(s.Warnings) > 0 {
+		t.Errorf("Work offsets → Expected: no warnings, Got: %v", s.Warnings[0])
+	}
+}
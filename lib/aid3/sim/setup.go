@@ -0,0 +1,114 @@
+package sim
+
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"strings"
+)
+
+// The controls, they read some words differently.
+// DIALECT_NONE is Marlin on a printer and LinuxCNC on the others.
+// LinuxCNC and Grbl dwell P seconds.
+// Fanuc dwells P milliseconds.
+// Haas dwells P seconds with a decimal point and milliseconds without.
+// Marlin dwells P milliseconds or S seconds.
+const (
+	DIALECT_NONE = iota
+	DIALECT_LINUXCNC
+	DIALECT_FANUC
+	DIALECT_HAAS
+	DIALECT_MARLIN
+)
+
+var dialects = map[string]int{
+	"":         DIALECT_NONE,
+	"linuxcnc": DIALECT_LINUXCNC,
+	"grbl":     DIALECT_LINUXCNC,
+	"fanuc":    DIALECT_FANUC,
+	"haas":     DIALECT_HAAS,
+	"marlin":   DIALECT_MARLIN,
+}
+
+// ParseDialect
+// The dialect by the name of its control, in any case.
+func ParseDialect(name string) (int, error) {
+	d, ok := dialects[strings.ToLower(name)]
+	if !ok {
+		return DIALECT_NONE, fmt.Errorf("unknown dialect %q, one of linuxcnc, grbl, fanuc, haas or marlin", name)
+	}
+	return d, nil
+}
+
+func (s *Sim) dialect() int {
+	if s.Dialect == DIALECT_NONE {
+		if s.printer() != nil {
+			return DIALECT_MARLIN
+		}
+		return DIALECT_LINUXCNC
+	}
+	return s.Dialect
+}
+
+// dwellSeconds
+// How long G4 waits as the control reads it.
+func (s *Sim) dwellSeconds(c *gcode.Coords) float64 {
+	switch s.dialect() {
+	case DIALECT_FANUC:
+		return c.P / 1000
+	case DIALECT_HAAS:
+		if c.Decimal('P') {
+			return c.P
+		}
+		return c.P / 1000
+	case DIALECT_MARLIN:
+		if c.Has('S') {
+			return c.S
+		}
+		return c.P / 1000
+	}
+	return c.P
+}
+
+// rapidDuration
+// A rapid starts and stops still, at Accel when the machine has one.
+// Cutting moves blend into each other and are left at their feed.
+func (s *Sim) rapidDuration(m *Move) float64 {
+	return tooling.MoveTime(m.Length(), m.Feed, s.Accel, 0)
+}
+
+// checkTravel
+// Warn when the move leaves the travel of the machine.
+func (s *Sim) checkTravel(m *Move) {
+	if s.Vol == nil || m.Node == nil {
+		return
+	}
+	lo, hi := s.Vol.Bounds()
+	for _, ax := range []struct {
+		name   string
+		v      float64
+		lo, hi float64
+	}{
+		{"X", m.To.X, lo.X, hi.X},
+		{"Y", m.To.Y, lo.Y, hi.Y},
+		{"Z", m.To.Z, lo.Z, hi.Z},
+	} {
+		if ax.v < ax.lo-s.Tolerance || ax.v > ax.hi+s.Tolerance {
+			s.warn(m.Node, s.Clock, "%v%v is past the %v to %v travel", ax.name, ax.v, ax.lo, ax.hi)
+		}
+	}
+}
+
+// changeTime
+// The changer takes ToolChangeTime for each change, a tool past its
+// slots is warned.
+func (s *Sim) changeTime(cn *gcode.CmdNode, tool int64) float64 {
+	if _, ok := s.Tool.(*tooling.Lathe); ok && tool >= 100 {
+		// T0101, the station and its offset
+		tool = tool / 100
+	}
+	if s.ToolSlots > 0 && (tool < 0 || tool > int64(s.ToolSlots)) {
+		s.warn(cn, s.Clock, "T%v is not in the %v slot changer", tool, s.ToolSlots)
+	}
+	return s.ToolChangeTime
+}
@@ -1,5 +1,132 @@
 package sim
 
-func cmdSpindleSpeed(s *Sim, speed int64) {
-	s.Tool.SpindleSpeed(speed)
+import (
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"log"
+	"math"
+	"strconv"
+)
+
+// Warning
+// Something in the program which runs, but likely not as intended.
+type Warning struct {
+	Line    int
+	Time    float64
+	Message string
+}
+
+func (w *Warning) String() string {
+	return fmt.Sprintf("line %v: %v at %3.3fs", w.Line, w.Message, w.Time)
+}
+
+func (s *Sim) warn(cn *gcode.CmdNode, at float64, format string, args ...interface{}) {
+	w := &Warning{Line: cn.Cmd.Line(), Time: at, Message: fmt.Sprintf(format, args...)}
+	log.Printf("WARNING %v", w)
+	s.Warnings = append(s.Warnings, w)
+}
+
+func cmdSpindleSpeed(s *Sim, cn *gcode.CmdNode) error {
+	speed, err := strconv.ParseFloat(cn.Cmd.Src()[1:], 64)
+	if err != nil {
+		return err
+	}
+	if b := s.beam(); b != nil {
+		b.Power(speed)
+		return nil
+	}
+	if l := s.lathe(); l != nil && cmdLatheSpeed(s, cn, l, speed) {
+		s.waitForSpindle()
+		return nil
+	}
+	sp := s.Tool.Spindle()
+	if !sp.Speed(speed, s.Clock) {
+		s.warn(cn, s.Clock, "S%v is outside gear %v, running at %v RPM", speed, sp.Gear+1, sp.Commanded)
+	}
+	s.waitForSpindle()
+	return nil
+}
+
+func cmdSpindleStart(s *Sim, direction int) {
+	s.event(EVENT_SPINDLE_ON, nil, 0)
+	if b := s.beam(); b != nil {
+		beamOn(s, b, direction)
+		return
+	}
+	s.Tool.Spindle().Start(direction, s.Clock)
+	s.waitForSpindle()
+}
+
+func cmdSpindleStop(s *Sim) {
+	s.event(EVENT_SPINDLE_OFF, nil, 0)
+	if b := s.beam(); b != nil {
+		beamOff(s, b)
+		return
+	}
+	s.Tool.Spindle().Stop(s.Clock)
+}
+
+// cmdSpindleOrient
+// M19 waits for the spindle to stop and turn to the angle given by R.
+func cmdSpindleOrient(s *Sim, cn *gcode.CmdNode) {
+	sp := s.Tool.Spindle()
+	sp.OrientTo(cn.Cmd.Coords().R, s.Clock)
+	s.Clock = sp.ReadyAt()
+}
+
+// cmdSpindleGear
+// M41, M42 and on select the first, second and later gears, M40 the
+// middle one.
+func cmdSpindleGear(s *Sim, cn *gcode.CmdNode) error {
+	code, err := strconv.Atoi(cn.Cmd.Src()[1:])
+	if err != nil {
+		return err
+	}
+	sp := s.Tool.Spindle()
+	gear := code - 41
+	if code == 40 {
+		gear = len(sp.Gears) / 2
+	}
+	if !sp.SelectGear(gear, s.Clock) {
+		s.warn(cn, s.Clock, "no spindle gear for %v", cn.Cmd.Src())
+		return nil
+	}
+	// the spindle comes to a stop to shift, and is back up to speed after
+	s.Clock = sp.ReadyAt()
+	return nil
+}
+
+// waitForSpindle
+// Controllers with spindle feedback hold the program until the
+// spindle is at speed.
+func (s *Sim) waitForSpindle() {
+	if s.WaitForSpindle {
+		s.Clock = s.Tool.Spindle().ReadyAt()
+	}
+}
+
+// checkSpindle
+// A feed move cutting stock needs the spindle turning the way the
+// cutter cuts, at speed.  The RPM in the warning is signed, negative
+// is counter clockwise.
+func (s *Sim) checkSpindle(m *Move) {
+	if m.Kind == MOVE_RAPID || m.Removed == nil || m.Removed.Cells == 0 || s.Sheet != nil {
+		return
+	}
+	sp := s.Tool.Spindle()
+	cutting := tooling.SPINDLE_CW
+	if s.ToolHead.Cutter().LeftHand {
+		cutting = tooling.SPINDLE_CCW
+	}
+
+	switch {
+	case sp.Direction == tooling.SPINDLE_STOPPED || sp.Direction == tooling.SPINDLE_ORIENTED || sp.Commanded == 0:
+		s.warn(m.Node, m.Start, "cutting with the spindle off")
+	case sp.Direction != cutting:
+		s.warn(m.Node, m.Start, "cutting with the spindle reversed")
+	case math.Abs(sp.Rpm(m.Start)) < 0.9*sp.Commanded:
+		// small changes, as under constant surface speed, are not worth a warning
+		s.warn(m.Node, m.Start, "cutting while the spindle is at %3.0f RPM, getting to %3.0f", sp.Rpm(m.Start), sp.Commanded)
+	}
 }
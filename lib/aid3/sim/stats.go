@@ -0,0 +1,324 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	EVENT_DWELL = iota
+	EVENT_TOOL_CHANGE
+	EVENT_SPINDLE_ON
+	EVENT_SPINDLE_OFF
+)
+
+// Event
+// Something the program did which is not a move, at Time seconds for
+// Duration, with Tool in the spindle.  Node is nil for the spindle.
+type Event struct {
+	Kind     int
+	Node     *gcode.CmdNode
+	Time     float64
+	Duration float64
+	Tool     int64
+}
+
+func (s *Sim) event(kind int, cn *gcode.CmdNode, duration float64) {
+	s.Events = append(s.Events, &Event{
+		Kind:     kind,
+		Node:     cn,
+		Time:     s.Clock,
+		Duration: duration,
+		Tool:     s.Tool.CurrentTool(),
+	})
+}
+
+// cmdDwell
+// G4 waits as long as the dialect reads P, or S on a printer.
+func cmdDwell(s *Sim, cn *gcode.CmdNode) {
+	secs := s.dwellSeconds(cn.Cmd.Coords())
+	if secs <= 0 {
+		return
+	}
+	s.event(EVENT_DWELL, cn, secs)
+	s.Clock += secs
+}
+
+// Totals
+// Seconds and mm of a program or a part of it.  Cutting is every move
+// which is not a rapid.
+type Totals struct {
+	CutTime       float64 `json:"cutTime" yaml:"cutTime"`
+	RapidTime     float64 `json:"rapidTime" yaml:"rapidTime"`
+	DwellTime     float64 `json:"dwellTime" yaml:"dwellTime"`
+	CutDistance   float64 `json:"cutDistance" yaml:"cutDistance"`
+	RapidDistance float64 `json:"rapidDistance" yaml:"rapidDistance"`
+}
+
+func (t *Totals) addMove(m *Move) {
+	if m.Kind == MOVE_RAPID {
+		t.RapidTime += m.Duration
+		t.RapidDistance += m.Length()
+		return
+	}
+	t.CutTime += m.Duration
+	t.CutDistance += m.Length()
+}
+
+// ToolStats
+// The totals with a tool in the spindle, Changes is how many times it
+// was changed to.
+type ToolStats struct {
+	Tool    int64 `json:"tool" yaml:"tool"`
+	Changes int   `json:"changes" yaml:"changes"`
+	Totals  `yaml:",inline"`
+}
+
+// OperationStats
+// The totals of an operation, as the CAM comments name it, or of a
+// feature type of a print.  Commands before any are in the unnamed.
+type OperationStats struct {
+	Name   string `json:"name" yaml:"name"`
+	Totals `yaml:",inline"`
+}
+
+// Bounds
+// The corners of a box, nil when there was nothing in it.
+type Bounds struct {
+	Lo *tooling.Point `json:"lo" yaml:"lo"`
+	Hi *tooling.Point `json:"hi" yaml:"hi"`
+}
+
+func (b *Bounds) add(p *tooling.Point) *Bounds {
+	if b == nil {
+		return &Bounds{Lo: &tooling.Point{X: p.X, Y: p.Y, Z: p.Z}, Hi: &tooling.Point{X: p.X, Y: p.Y, Z: p.Z}}
+	}
+	b.Lo = &tooling.Point{X: math.Min(b.Lo.X, p.X), Y: math.Min(b.Lo.Y, p.Y), Z: math.Min(b.Lo.Z, p.Z)}
+	b.Hi = &tooling.Point{X: math.Max(b.Hi.X, p.X), Y: math.Max(b.Hi.Y, p.Y), Z: math.Max(b.Hi.Z, p.Z)}
+	return b
+}
+
+// JobStats
+// What a run took.  Time is the whole program in seconds and Other
+// the time in neither moves nor dwells, waiting for the spindle,
+// heaters or pierces.  The feeds are mm/min of the cutting moves, the
+// average over their distance.  Cost is the time at HourlyRate.
+type JobStats struct {
+	Time        float64 `json:"time" yaml:"time"`
+	Totals      `yaml:",inline"`
+	OtherTime   float64           `json:"otherTime" yaml:"otherTime"`
+	SpindleTime float64           `json:"spindleTime" yaml:"spindleTime"`
+	ToolChanges int               `json:"toolChanges" yaml:"toolChanges"`
+	MinFeed     float64           `json:"minFeed" yaml:"minFeed"`
+	MaxFeed     float64           `json:"maxFeed" yaml:"maxFeed"`
+	AvgFeed     float64           `json:"avgFeed" yaml:"avgFeed"`
+	CutBounds   *Bounds           `json:"cutBounds" yaml:"cutBounds"`
+	RapidBounds *Bounds           `json:"rapidBounds" yaml:"rapidBounds"`
+	Tools       []*ToolStats      `json:"tools" yaml:"tools"`
+	Operations  []*OperationStats `json:"operations" yaml:"operations"`
+	HourlyRate  float64           `json:"hourlyRate" yaml:"hourlyRate"`
+	Cost        float64           `json:"cost" yaml:"cost"`
+}
+
+// Stats
+// The statistics of the run so far, costed at the hourly rate.
+func (s *Sim) Stats(hourlyRate float64) *JobStats {
+	ret := &JobStats{Time: s.Clock, HourlyRate: hourlyRate, MinFeed: math.Inf(1)}
+	tools := make(map[int64]*ToolStats)
+	ops := make(map[string]*OperationStats)
+	tool := func(t int64) *ToolStats {
+		if tools[t] == nil {
+			tools[t] = &ToolStats{Tool: t}
+		}
+		return tools[t]
+	}
+	op := func(cn *gcode.CmdNode) *OperationStats {
+		name := operationName(cn)
+		if ops[name] == nil {
+			ops[name] = &OperationStats{Name: name}
+			ret.Operations = append(ret.Operations, ops[name])
+		}
+		return ops[name]
+	}
+
+	feedDist := 0.0
+	for _, m := range s.Moves {
+		ret.addMove(m)
+		tool(m.Tool).addMove(m)
+		op(m.Node).addMove(m)
+		if m.Kind == MOVE_RAPID {
+			for _, p := range movePoints(m) {
+				ret.RapidBounds = ret.RapidBounds.add(p)
+			}
+			continue
+		}
+		for _, p := range movePoints(m) {
+			ret.CutBounds = ret.CutBounds.add(p)
+		}
+		if f := programmedFeed(m); f > 0 {
+			ret.MinFeed = math.Min(ret.MinFeed, f)
+			ret.MaxFeed = math.Max(ret.MaxFeed, f)
+			ret.AvgFeed += f * m.Length()
+			feedDist += m.Length()
+		}
+	}
+	if feedDist > 0 {
+		ret.AvgFeed /= feedDist
+	} else {
+		ret.MinFeed = 0
+	}
+
+	on := -1.0
+	for _, e := range s.Events {
+		switch e.Kind {
+		case EVENT_DWELL:
+			ret.DwellTime += e.Duration
+			tool(e.Tool).DwellTime += e.Duration
+			op(e.Node).DwellTime += e.Duration
+		case EVENT_TOOL_CHANGE:
+			ret.ToolChanges++
+			tool(e.Tool).Changes++
+		case EVENT_SPINDLE_ON:
+			if on < 0 {
+				on = e.Time
+			}
+		case EVENT_SPINDLE_OFF:
+			if on >= 0 {
+				ret.SpindleTime += e.Time - on
+				on = -1
+			}
+		}
+	}
+	if on >= 0 {
+		ret.SpindleTime += s.Clock - on
+	}
+
+	for _, t := range tools {
+		ret.Tools = append(ret.Tools, t)
+	}
+	sort.Slice(ret.Tools, func(i int, j int) bool {
+		return ret.Tools[i].Tool < ret.Tools[j].Tool
+	})
+	if other := ret.Time - ret.CutTime - ret.RapidTime - ret.DwellTime; other > 1e-9 {
+		ret.OtherTime = other
+	}
+	ret.Cost = ret.Time / 3600 * hourlyRate
+	return ret
+}
+
+// operationName
+// The CAM operation of the command, or the feature type of a print.
+func operationName(cn *gcode.CmdNode) string {
+	if cn == nil || cn.Cmd.Meta() == nil {
+		return ""
+	}
+	if m := cn.Cmd.Meta(); m.Operation != "" {
+		return m.Operation
+	}
+	return cn.Cmd.Meta().Feature
+}
+
+// programmedFeed
+// The feed of the move in mm/min, whichever the feed mode.
+func programmedFeed(m *Move) float64 {
+	switch m.FeedMode {
+	case tooling.FEED_INVERSE_TIME:
+		return m.Feed * m.Length()
+	case tooling.FEED_PER_REVOLUTION:
+		return m.Feed * m.Rpm
+	}
+	return m.Feed
+}
+
+// WriteJSON
+// The statistics as indented JSON.
+func (j *JobStats) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// WriteText
+// The statistics as a report to read.
+func (j *JobStats) WriteText(w io.Writer) error {
+	var err error
+	line := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format+"\n", args...)
+		}
+	}
+	line("Cycle time      %v", clockTime(j.Time))
+	line("  cutting       %v  %10.1f mm", clockTime(j.CutTime), j.CutDistance)
+	line("  rapids        %v  %10.1f mm", clockTime(j.RapidTime), j.RapidDistance)
+	line("  dwells        %v", clockTime(j.DwellTime))
+	line("  other         %v", clockTime(j.OtherTime))
+	line("Spindle on      %v", clockTime(j.SpindleTime))
+	line("Tool changes    %v", j.ToolChanges)
+	line("Feed            %.0f to %.0f mm/min, %.0f average", j.MinFeed, j.MaxFeed, j.AvgFeed)
+	if b := j.CutBounds; b != nil {
+		line("Cutting within  %v to %v", b.Lo, b.Hi)
+	}
+	if b := j.RapidBounds; b != nil {
+		line("Rapids within   %v to %v", b.Lo, b.Hi)
+	}
+	if j.HourlyRate > 0 {
+		line("Cost            %.2f at %.2f an hour", j.Cost, j.HourlyRate)
+	}
+	line("")
+	line("%-6s %8s %11s %11s %11s %12s", "Tool", "Changes", "Cutting", "Rapids", "Dwells", "Cut mm")
+	for _, t := range j.Tools {
+		line("T%-5v %8v %v %v %v %12.1f", t.Tool, t.Changes,
+			clockTime(t.CutTime), clockTime(t.RapidTime), clockTime(t.DwellTime), t.CutDistance)
+	}
+	if len(j.Operations) > 1 || (len(j.Operations) == 1 && j.Operations[0].Name != "") {
+		line("")
+		line("%-24s %11s %11s %11s %12s", "Operation", "Cutting", "Rapids", "Dwells", "Cut mm")
+		for _, o := range j.Operations {
+			name := o.Name
+			if name == "" {
+				name = "-"
+			}
+			line("%-24.24s %v %v %v %12.1f", name,
+				clockTime(o.CutTime), clockTime(o.RapidTime), clockTime(o.DwellTime), o.CutDistance)
+		}
+	}
+	return err
+}
+
+// clockTime
+// Seconds as h:mm:ss.s, eleven wide.
+func clockTime(secs float64) string {
+	h := int(secs / 3600)
+	m := int(math.Mod(secs, 3600) / 60)
+	return fmt.Sprintf("%3d:%02d:%04.1f", h, m, math.Mod(secs, 60))
+}
+
+// WriteMoves
+// The moves as CSV, one a row in program order.
+func (s *Sim) WriteMoves(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "line,kind,tool,x0,y0,z0,x1,y1,z1,feed,rpm,start,duration"); err != nil {
+		return err
+	}
+	kinds := []string{"rapid", "feed", "arc", "probe"}
+	for _, m := range s.Moves {
+		line := 0
+		if m.Node != nil {
+			line = m.Node.Cmd.Line()
+		}
+		_, err := fmt.Fprintf(w, "%v,%v,%v,%.4f,%.4f,%.4f,%.4f,%.4f,%.4f,%.1f,%.0f,%.3f,%.3f\n",
+			line, kinds[m.Kind], m.Tool, m.From.X, m.From.Y, m.From.Z, m.To.X, m.To.Y, m.To.Z,
+			programmedFeed(m), m.Rpm, m.Start, m.Duration)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package sim
+
+import (
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	s := &Sim{Clock: 20}
+	s.Moves = []*Move{
+		{Kind: MOVE_RAPID, From: &tooling.Point{Z: 5}, To: &tooling.Point{X: 10, Z: 5}, Duration: 1, Tool: 1},
+		{Kind: MOVE_FEED, From: &tooling.Point{X: 10, Z: 5}, To: &tooling.Point{X: 10, Z: -1}, Feed: 100, Duration: 3.6, Tool: 1},
+		{Kind: MOVE_FEED, From: &tooling.Point{X: 10, Z: -1}, To: &tooling.Point{X: 40, Z: -1}, Feed: 600, Duration: 3, Tool: 2},
+	}
+	s.Events = []*Event{
+		{Kind: EVENT_TOOL_CHANGE, Tool: 1},
+		{Kind: EVENT_SPINDLE_ON, Time: 2},
+		{Kind: EVENT_DWELL, Time: 8, Duration: 2, Tool: 1},
+		{Kind: EVENT_TOOL_CHANGE, Tool: 2},
+		{Kind: EVENT_SPINDLE_OFF, Time: 12},
+	}
+	st := s.Stats(36)
+	if st.CutDistance != 36 || st.RapidDistance != 10 {
+		t.Errorf("Distances → Expected: 36 cutting and 10 rapid, Got: %v and %v", st.CutDistance, st.RapidDistance)
+	}
+	if st.MinFeed != 100 || st.MaxFeed != 600 || st.AvgFeed != 100*6/36.+600*30/36. {
+		t.Errorf("Feeds → Expected: 100 to 600, Got: %v to %v avg %v", st.MinFeed, st.MaxFeed, st.AvgFeed)
+	}
+	if st.SpindleTime != 10 || st.DwellTime != 2 || st.ToolChanges != 2 {
+		t.Errorf("Events → Expected: 10s spindle, 2s dwell, 2 changes, Got: %v, %v, %v", st.SpindleTime, st.DwellTime, st.ToolChanges)
+	}
+	if math.Abs(st.OtherTime-10.4) > 1e-9 || math.Abs(st.Cost-0.2) > 1e-9 {
+		t.Errorf("Other time and cost → Expected: 10.4s and 0.2, Got: %v and %v", st.OtherTime, st.Cost)
+	}
+	if len(st.Tools) != 2 || st.Tools[0].Tool != 1 || st.Tools[0].DwellTime != 2 || st.Tools[1].CutTime != 3 {
+		t.Errorf("Per tool → Expected T1 with the dwell and T2 cutting 3s, Got: %+v %+v", st.Tools[0], st.Tools[1])
+	}
+	if st.CutBounds.Lo.Z != -1 || st.RapidBounds.Lo.Z != 5 {
+		t.Errorf("Bounds → Expected cutting down to Z-1 and rapids at Z5, Got: %v and %v", st.CutBounds.Lo, st.RapidBounds.Lo)
+	}
+}
@@ -1,10 +1,14 @@
 package sim
 
 import (
-	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"log"
 )
 
-func cmdToolChange(s *Sim, tool int64) {
-	fmt.Printf("CHANGE TOOL %v\n", tool)
+func cmdToolChange(s *Sim, cn *gcode.CmdNode, tool int64) {
+	log.Printf("CHANGE TOOL %v\n", tool)
 	s.Tool.ToolChangeTo(tool)
+	secs := s.changeTime(cn, tool)
+	s.event(EVENT_TOOL_CHANGE, cn, secs)
+	s.Clock += secs
 }
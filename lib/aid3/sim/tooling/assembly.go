@@ -0,0 +1,207 @@
+package tooling
+
+import (
+	"math"
+)
+
+const (
+	PART_ALL = iota
+	PART_CUTTING
+	PART_NON_CUTTING
+)
+
+// Body
+// A solid about the tool axis, given relative to the tool tip.
+// Extent is the widest radius and the lowest and highest points
+// above the tip, RadiusBetween the widest radius between two heights.
+// Convex bodies may be swept without sampling.
+type Body interface {
+	Distance(rel *Point) float64
+	Extent() (float64, float64, float64)
+	RadiusBetween(z0 float64, z1 float64) float64
+	Convex() bool
+}
+
+// Nose
+// The spindle nose, a cylinder above the tool holder.
+type Nose struct {
+	Diameter float64
+	Length   float64
+}
+
+func DefaultNose() *Nose {
+	return &Nose{Diameter: 80, Length: 100}
+}
+
+// CutterBody
+// One part of a cutter as a body.
+type CutterBody struct {
+	cutter *Cutter
+	part   int
+	prof   []*ProfilePoint
+}
+
+func MakeCutterBody(c *Cutter, part int) *CutterBody {
+	ret := &CutterBody{cutter: c, part: part}
+	switch part {
+	case PART_CUTTING:
+		ret.prof = c.cuttingProfile()
+	case PART_NON_CUTTING:
+		ret.prof = c.nonCuttingProfile()
+	default:
+		ret.prof = c.Profile()
+	}
+	return ret
+}
+
+func cutterPart(c *Cutter, cuttingOnly bool) Body {
+	if cuttingOnly {
+		return MakeCutterBody(c, PART_CUTTING)
+	}
+	return MakeCutterBody(c, PART_ALL)
+}
+
+func (cb *CutterBody) Distance(rel *Point) float64 {
+	return profileDistance(cb.prof, math.Sqrt(rel.X*rel.X+rel.Y*rel.Y), rel.Z)
+}
+
+func (cb *CutterBody) Extent() (float64, float64, float64) {
+	r := 0.0
+	lo := math.MaxFloat64
+	hi := -math.MaxFloat64
+	for _, p := range cb.prof {
+		r = math.Max(r, p.R)
+		lo = math.Min(lo, p.Z)
+		hi = math.Max(hi, p.Z)
+	}
+	return r, lo, hi
+}
+
+func (cb *CutterBody) RadiusBetween(z0 float64, z1 float64) float64 {
+	return profileRadiusBetween(cb.prof, z0, z1)
+}
+
+// Convex
+// The fluted part of the simple cutters is convex, shanks step
+// out to holders so the rest is not.
+func (cb *CutterBody) Convex() bool {
+	if cb.part != PART_CUTTING {
+		return false
+	}
+	switch cb.cutter.Kind {
+	case CUTTER_LOLLIPOP, CUTTER_THREAD_MILL:
+		return false
+	}
+	return true
+}
+
+// Assembly
+// A cutter part together with the spindle nose above its holder.
+type Assembly struct {
+	body   *CutterBody
+	nose   *Nose
+	noseZ  float64
+	cutter *Cutter
+}
+
+func MakeAssembly(c *Cutter, part int, nose *Nose) *Assembly {
+	return &Assembly{
+		body:   MakeCutterBody(c, part),
+		nose:   nose,
+		noseZ:  c.Length + c.HolderLength,
+		cutter: c,
+	}
+}
+
+// NoseDistance
+// The signed distance to the spindle nose, which is above the
+// holder, rel is relative to the tool tip.
+func (a *Assembly) NoseDistance(rel *Point) float64 {
+	if a.nose == nil {
+		return math.MaxFloat64
+	}
+	r := math.Sqrt(rel.X*rel.X+rel.Y*rel.Y) - a.nose.Diameter/2
+	z := math.Max(a.noseZ-rel.Z, rel.Z-(a.noseZ+a.nose.Length))
+	if r <= 0 && z <= 0 {
+		return math.Max(r, z)
+	}
+	return math.Hypot(math.Max(r, 0), math.Max(z, 0))
+}
+
+func (a *Assembly) Distance(rel *Point) float64 {
+	return math.Min(a.body.Distance(rel), a.NoseDistance(rel))
+}
+
+func (a *Assembly) Extent() (float64, float64, float64) {
+	r, lo, hi := a.body.Extent()
+	if a.nose != nil {
+		r = math.Max(r, a.nose.Diameter/2)
+		hi = math.Max(hi, a.noseZ+a.nose.Length)
+	}
+	return r, lo, hi
+}
+
+func (a *Assembly) RadiusBetween(z0 float64, z1 float64) float64 {
+	r := a.body.RadiusBetween(z0, z1)
+	if a.nose != nil && z1 >= a.noseZ && z0 <= a.noseZ+a.nose.Length {
+		r = math.Max(r, a.nose.Diameter/2)
+	}
+	return r
+}
+
+func (a *Assembly) Convex() bool {
+	return false
+}
+
+// Part
+// Name the part of the assembly which holds rel.
+func (a *Assembly) Part(rel *Point) string {
+	if a.NoseDistance(rel) < 0 {
+		return "spindle nose"
+	}
+	c := a.cutter
+	switch {
+	case rel.Z >= c.Length:
+		return "holder"
+	case rel.Z > c.FluteLength:
+		return "shank"
+	}
+	return "cutter"
+}
+
+// nonCuttingProfile
+// The profile from the end of the flutes to the top of the
+// holder, closed on the axis.
+func (c *Cutter) nonCuttingProfile() []*ProfilePoint {
+	prof := c.Profile()
+	for i, p := range prof {
+		if !p.Cutting {
+			ret := []*ProfilePoint{{R: 0, Z: p.Z}}
+			return append(ret, prof[i:]...)
+		}
+	}
+	return prof
+}
+
+// profileRadiusBetween
+// The widest the profile gets between the two heights.
+func profileRadiusBetween(prof []*ProfilePoint, z0 float64, z1 float64) float64 {
+	ret := 0.0
+	for i := 1; i < len(prof); i++ {
+		a := prof[i-1]
+		b := prof[i]
+		lo := math.Max(z0, math.Min(a.Z, b.Z))
+		hi := math.Min(z1, math.Max(a.Z, b.Z))
+		if lo > hi {
+			continue
+		}
+		if a.Z == b.Z {
+			ret = math.Max(ret, math.Max(a.R, b.R))
+			continue
+		}
+		ra := a.R + (b.R-a.R)*(lo-a.Z)/(b.Z-a.Z)
+		rb := a.R + (b.R-a.R)*(hi-a.Z)/(b.Z-a.Z)
+		ret = math.Max(ret, math.Max(ra, rb))
+	}
+	return ret
+}
@@ -0,0 +1,292 @@
+package tooling
+
+import (
+	"math"
+)
+
+const (
+	BEAM_OFF = iota
+	BEAM_CONSTANT
+	BEAM_DYNAMIC
+)
+
+// BeamCutter
+// A 2D laser or plasma cutter over a sheet.  S is the power, MaxS of
+// it is full power.  M3 turns the beam on at constant power, M4 at a
+// power following the speed as in GRBL's laser mode, so slow corners
+// are not burnt.  The beam does not cut on rapids.
+//
+// Kerf is the width the beam cuts.  Before the first cut after the
+// beam comes on, or after a rapid, it pierces: the head waits
+// PierceDelay at PierceHeight above the sheet then comes down at
+// HeightRate to CutHeight, which the torch height control holds while
+// cutting.  The height control locks below ThcLock of the feed, so
+// the torch does not dive at corners.  Accel is how fast the gantry
+// changes speed and Deviation how far from a corner it may cut to keep
+// up its speed, the junction deviation of GRBL.
+type BeamCutter struct {
+	kind       int
+	head       Head
+	zero       *Point
+	feed       float64
+	feedMode   int
+	spindle    *Spindle
+	outputs    *Outputs
+	kinematics *Kinematics
+	curTool    int64
+	tools      *ToolTable
+	nose       *Nose
+	plane      int
+	units      int
+	workVolume Volume
+	material   Material
+
+	MaxPower     float64
+	MaxS         float64
+	Kerf         float64
+	PierceDelay  float64
+	PierceHeight float64
+	CutHeight    float64
+	HeightRate   float64
+	ThcLock      float64
+	Accel        float64
+	Deviation    float64
+	Rapid        float64
+
+	mode    int
+	power   float64
+	sheetLo *Point
+	sheetHi *Point
+}
+
+// BuildLaser
+// A 40 W laser over a sheet from the origin, width along X and depth
+// along Y, its top at Z0.
+func BuildLaser(m Material, width float64, depth float64, thickness float64) *BeamCutter {
+	ret := buildBeam(CncLaserCutter, m, width, depth, thickness)
+	ret.MaxPower = 40
+	ret.Kerf = 0.15
+	ret.PierceDelay = 0.05
+	ret.Accel = 1000
+	ret.Deviation = 0.01
+	ret.Rapid = 6000
+	return ret
+}
+
+// BuildPlasma
+// A 45 A plasma torch over a sheet from the origin, with torch height
+// control.
+func BuildPlasma(m Material, width float64, depth float64, thickness float64) *BeamCutter {
+	ret := buildBeam(CncPlasmaCutter, m, width, depth, thickness)
+	ret.MaxPower = 5400
+	ret.Kerf = 1.5
+	ret.PierceDelay = 0.5
+	ret.PierceHeight = 3.8
+	ret.CutHeight = 1.5
+	ret.HeightRate = 50
+	ret.ThcLock = 0.8
+	ret.Accel = 1500
+	ret.Deviation = 0.05
+	ret.Rapid = 10000
+	return ret
+}
+
+func buildBeam(kind int, m Material, width float64, depth float64, thickness float64) *BeamCutter {
+	ret := &BeamCutter{kind: kind}
+	ret.sheetLo = &Point{Z: -thickness}
+	ret.sheetHi = &Point{X: width, Y: depth}
+	ret.workVolume = MakeVolume(&Point{Z: -thickness}, &Point{X: width, Y: depth, Z: 20})
+	ret.material = m
+	ret.tools = MakeToolTable()
+	ret.nose = DefaultNose()
+	ret.spindle = MakeSpindle()
+	ret.outputs = MakeOutputs()
+	ret.kinematics = MakeThreeAxis()
+	ret.MaxS = 1000
+
+	ret.head = &SimpleHead{
+		pos:    &Point{},
+		path:   make([]*Point, 0),
+		curVel: Still(),
+		cutter: DefaultCutter(),
+	}
+	return ret
+}
+
+//
+// Cnc
+//
+
+func (b *BeamCutter) Type() int {
+	return b.kind
+}
+
+func (b *BeamCutter) Axis() []int {
+	return []int{2}
+}
+
+func (b *BeamCutter) ZeroPoint() *Point {
+	return b.zero
+}
+
+func (b *BeamCutter) Head() Head {
+	return b.head
+}
+
+func (b *BeamCutter) FeedRate() float64 {
+	return b.feed
+}
+
+func (b *BeamCutter) AssignFeedRate(f float64) {
+	b.feed = f
+}
+
+func (b *BeamCutter) FastFeedRate() float64 {
+	return b.Rapid
+}
+
+func (b *BeamCutter) FeedMode(mode int) {
+	b.feedMode = mode
+}
+
+func (b *BeamCutter) CurrentFeedMode() int {
+	return b.feedMode
+}
+
+// Spindle
+// There is no spindle, it is never started.
+func (b *BeamCutter) Spindle() *Spindle {
+	return b.spindle
+}
+
+func (b *BeamCutter) CurrentSpindleSpeed() float64 {
+	return 0
+}
+
+func (b *BeamCutter) Outputs() *Outputs {
+	return b.outputs
+}
+
+func (b *BeamCutter) Kinematics() *Kinematics {
+	return b.kinematics
+}
+
+// SetKinematics
+// A sheet cutter has no rotaries, there is nothing to set.
+func (b *BeamCutter) SetKinematics(k *Kinematics) {
+}
+
+func (b *BeamCutter) ToolChangeTo(tool int64) {
+	b.curTool = tool
+}
+
+func (b *BeamCutter) CurrentTool() int64 {
+	return b.curTool
+}
+
+func (b *BeamCutter) Tools() *ToolTable {
+	return b.tools
+}
+
+func (b *BeamCutter) SpindleNose() *Nose {
+	return b.nose
+}
+
+func (b *BeamCutter) SelectPlane(plane int) {
+	b.plane = plane
+}
+
+func (b *BeamCutter) Plane() int {
+	return b.plane
+}
+
+func (b *BeamCutter) WorkVolume() Volume {
+	return b.workVolume
+}
+
+func (b *BeamCutter) Material() Material {
+	return b.material
+}
+
+func (b *BeamCutter) Units(units int) {
+	b.units = units
+}
+
+func (b *BeamCutter) Reset() {
+	b.zero = &Point{}
+	b.plane = PLANE_XY
+	b.spindle.Reset()
+	b.outputs.Reset()
+	b.feedMode = FEED_PER_MINUTE
+	b.feed = b.FastFeedRate()
+	b.units = UNIT_MM
+	b.mode = BEAM_OFF
+	b.power = 0
+	b.head.Reset(b.zero)
+}
+
+//
+// Beam
+//
+
+// Beam
+// Turn the beam on, BEAM_CONSTANT or BEAM_DYNAMIC, or BEAM_OFF.
+func (b *BeamCutter) Beam(mode int) {
+	b.mode = mode
+}
+
+func (b *BeamCutter) BeamMode() int {
+	return b.mode
+}
+
+// Power
+// The S word, MaxS is full power.
+func (b *BeamCutter) Power(s float64) {
+	b.power = s
+}
+
+// PowerAt
+// The beam power in W at speed under the programmed feed, in
+// proportion to the speed in the dynamic mode.
+func (b *BeamCutter) PowerAt(speed float64, feed float64) float64 {
+	if b.mode == BEAM_OFF || b.MaxS <= 0 {
+		return 0
+	}
+	p := b.MaxPower * math.Max(0, math.Min(1, b.power/b.MaxS))
+	if b.mode == BEAM_DYNAMIC && feed > 0 {
+		p *= math.Min(1, speed/feed)
+	}
+	return p
+}
+
+// PierceTime
+// How long a pierce takes, the delay and coming down to cut.
+func (b *BeamCutter) PierceTime() float64 {
+	ret := b.PierceDelay
+	if b.HeightRate > 0 && b.PierceHeight > b.CutHeight {
+		ret += (b.PierceHeight - b.CutHeight) / b.HeightRate
+	}
+	return ret
+}
+
+// Sheet
+// The corners of the sheet, its top at Z0.
+func (b *BeamCutter) Sheet() (*Point, *Point) {
+	return b.sheetLo, b.sheetHi
+}
+
+// JunctionSpeed
+// The most speed through a corner from the unit direction in to the
+// unit direction out, cutting no further than the deviation from the
+// corner at the acceleration.
+func (b *BeamCutter) JunctionSpeed(in *Point, out *Point, feed float64) float64 {
+	cos := -(in.X*out.X + in.Y*out.Y + in.Z*out.Z)
+	if cos <= -0.999999 {
+		return feed
+	}
+	if cos >= 0.999999 {
+		return 0
+	}
+	half := math.Sqrt(0.5 * (1 - cos))
+	return math.Min(feed, math.Sqrt(b.Accel*b.Deviation*half/(1-half)))
+}
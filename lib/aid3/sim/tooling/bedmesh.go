@@ -0,0 +1,94 @@
+package tooling
+
+import (
+	"math"
+)
+
+// BedMesh
+// The height of the bed over a grid of probed points, as G29 measures
+// and M421 sets it.  Z[j][i] is the point i along X and j along Y from
+// Lo.  Between points the height is bilinear, past the edges it is the
+// edge.  Above Fade the compensation is gone, 0 never fades.
+type BedMesh struct {
+	Lo       *Point
+	SpacingX float64
+	SpacingY float64
+	Z        [][]float64
+	Fade     float64
+}
+
+// MakeBedMesh
+// A flat mesh of nx by ny points over the corners.
+func MakeBedMesh(lo *Point, hi *Point, nx int, ny int) *BedMesh {
+	nx = int(math.Max(2, float64(nx)))
+	ny = int(math.Max(2, float64(ny)))
+	ret := &BedMesh{
+		Lo:       lo,
+		SpacingX: (hi.X - lo.X) / float64(nx-1),
+		SpacingY: (hi.Y - lo.Y) / float64(ny-1),
+		Z:        make([][]float64, ny),
+	}
+	for j := range ret.Z {
+		ret.Z[j] = make([]float64, nx)
+	}
+	return ret
+}
+
+// Points
+// How many points are probed.
+func (b *BedMesh) Points() int {
+	if len(b.Z) == 0 {
+		return 0
+	}
+	return len(b.Z) * len(b.Z[0])
+}
+
+// Set
+// The height at point i along X and j along Y, false when there is no
+// such point.
+func (b *BedMesh) Set(i int, j int, z float64) bool {
+	if j < 0 || j >= len(b.Z) || i < 0 || i >= len(b.Z[j]) {
+		return false
+	}
+	b.Z[j][i] = z
+	return true
+}
+
+// At
+// The height of the bed at x, y.
+func (b *BedMesh) At(x float64, y float64) float64 {
+	if b.Points() == 0 {
+		return 0
+	}
+	i, u := meshCell(x-b.Lo.X, b.SpacingX, len(b.Z[0]))
+	j, v := meshCell(y-b.Lo.Y, b.SpacingY, len(b.Z))
+	z0 := b.Z[j][i]*(1-u) + b.Z[j][i+1]*u
+	z1 := b.Z[j+1][i]*(1-u) + b.Z[j+1][i+1]*u
+	return z0*(1-v) + z1*v
+}
+
+// meshCell
+// The cell along one axis holding d and how far across it, held to
+// the first and last cells.
+func meshCell(d float64, spacing float64, n int) (int, float64) {
+	if spacing <= 0 || n < 2 {
+		return 0, 0
+	}
+	f := math.Max(0, math.Min(float64(n-1), d/spacing))
+	i := int(math.Min(float64(n-2), math.Floor(f)))
+	return i, f - float64(i)
+}
+
+// Compensation
+// How far Z is moved at p to follow the bed, less as p rises to the
+// fade height.
+func (b *BedMesh) Compensation(p *Point) float64 {
+	z := b.At(p.X, p.Y)
+	if b.Fade <= 0 {
+		return z
+	}
+	if p.Z >= b.Fade {
+		return 0
+	}
+	return z * (1 - math.Max(0, p.Z)/b.Fade)
+}
@@ -1,8 +1,7 @@
 package tooling
 
 import (
-	"fmt"
-	"math"
+	"github.com/timleecasey/stllib/lib/aid3/geom"
 )
 
 const (
@@ -15,41 +14,19 @@ const (
 	CncPlasmaCutter
 	CncWaterJetCutting
 	CncElectricDischargeMachines // (EDMs), but not the music
+	CncPrinter                   // fused filament
 )
 
-type Point struct {
-	X float64
-	Y float64
-	Z float64
-}
-
-func (p *Point) String() string {
-	return fmt.Sprintf("X:%4.3f, Y:%4.3f, Z:%4.3f", p.X, p.Y, p.Z)
-}
-
-func (p *Point) Dist(to *Point) float64 {
-	diffX := p.X - to.X
-	diffY := p.Y - to.Y
-	diffZ := p.Z - to.Z
-	return math.Sqrt((diffX * diffX) + (diffY * diffY) + (diffZ * diffZ))
-}
+// Point
+// The geom position, the same the gcode package moves in.
+type Point = geom.Point
 
 func PointAt(center *Point, radius float64, angle float64) *Point {
-	ret := &Point{
-		X: center.X + radius*math.Cos(angle),
-		Y: center.Y + radius*math.Sin(angle),
-		Z: center.Z,
-	}
-
-	return ret
+	return geom.PointAt(center, radius, angle)
 }
 
 func MidPoint(fr *Point, to *Point) *Point {
-	return &Point{
-		X: (fr.X + to.X) / 2,
-		Y: (fr.Y + to.Y) / 2,
-		Z: (fr.Z + to.Z) / 2,
-	}
+	return geom.MidPoint(fr, to)
 }
 
 const (
@@ -73,6 +50,7 @@ const (
 )
 
 type Cnc interface {
+	Type() int
 	Axis() []int
 	ZeroPoint() *Point
 	Head() Head
@@ -80,8 +58,16 @@ type Cnc interface {
 	AssignFeedRate(f float64)
 	FastFeedRate() float64
 	FeedMode(mode int)
-	SpindleSpeed(speed int64)
+	CurrentFeedMode() int
+	Spindle() *Spindle
+	CurrentSpindleSpeed() float64
+	Outputs() *Outputs
+	Kinematics() *Kinematics
+	SetKinematics(k *Kinematics)
 	ToolChangeTo(tool int64)
+	CurrentTool() int64
+	Tools() *ToolTable
+	SpindleNose() *Nose
 	SelectPlane(plane int)
 	Plane() int
 	Reset()
@@ -0,0 +1,134 @@
+package tooling
+
+import (
+	"math"
+)
+
+// Contact
+// Where a swept body first runs into filled cells.  T is the path
+// parameter of the first contact, Point the center of the cell first
+// reached and Depth the deepest the body goes into any cell over the
+// stretch of the move, about as long as the body is wide, where it
+// first touches.
+type Contact struct {
+	Point *Point
+	T     float64
+	Depth float64
+}
+
+// Contact
+// The first contact of the swept body with the material, nil when
+// the path is clear.  The move is checked a piece at a time, each
+// about as long as the body is wide, and the first piece that touches
+// is halved down to a cell, keeping the first half that touches, so
+// only the cells where the body goes in are searched along the path.
+func (s *Stock) Contact(sw *Swept) *Contact {
+	n := int(math.Max(1, math.Ceil(sw.length/math.Max(sw.reach, 2*s.cell))))
+	for p := 0; p < n; p++ {
+		t0, t1 := float64(p)/float64(n), float64(p+1)/float64(n)
+		piece := sw.piece(t0, t1)
+		if !s.touches(piece) {
+			continue
+		}
+		lo, hi := t0, t1
+		for (hi-lo)*sw.length > s.cell {
+			mid := (lo + hi) / 2
+			if s.touches(sw.piece(lo, mid)) {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		ret := s.contactIn(sw.piece(lo, hi))
+		if ret == nil {
+			// lost to rounding between the halves
+			ret = s.contactIn(piece)
+			lo, hi = t0, t1
+		}
+		ret.T = lo + ret.T*(hi-lo)
+		ret.Depth = s.depthIn(piece, ret.Depth)
+		return ret
+	}
+	return nil
+}
+
+// touches
+// Whether any filled cell is inside the swept body.
+func (s *Stock) touches(sw *Swept) bool {
+	lo, hi := sw.Bounds()
+	i0, i1, j0, j1, k0, k1 := s.cellRange(lo, hi)
+	for k := k0; k < k1; k++ {
+		for j := j0; j < j1; j++ {
+			for i := i0; i < i1; i++ {
+				if s.filled[s.index(i, j, k)] && sw.Inside(s.center(i, j, k)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// contactIn
+// The first contact along the whole of the swept body.
+func (s *Stock) contactIn(sw *Swept) *Contact {
+	var ret *Contact
+	lo, hi := sw.Bounds()
+	i0, i1, j0, j1, k0, k1 := s.cellRange(lo, hi)
+	for k := k0; k < k1; k++ {
+		for j := j0; j < j1; j++ {
+			for i := i0; i < i1; i++ {
+				if !s.filled[s.index(i, j, k)] {
+					continue
+				}
+				c := s.center(i, j, k)
+				if !sw.Inside(c) {
+					continue
+				}
+				t, d, _ := sw.entry(c)
+				if ret == nil {
+					ret = &Contact{Point: c, T: t, Depth: -d}
+					continue
+				}
+				if t < ret.T {
+					ret.Point = c
+					ret.T = t
+				}
+				if -d > ret.Depth {
+					ret.Depth = -d
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// depthIn
+// The deepest the swept body goes into a filled cell, at least depth.
+// A cell whose bound is no deeper than what is found is passed over,
+// the others are tried where the path passes nearest them before
+// searching along it.
+func (s *Stock) depthIn(sw *Swept, depth float64) float64 {
+	lo, hi := sw.Bounds()
+	i0, i1, j0, j1, k0, k1 := s.cellRange(lo, hi)
+	for k := k0; k < k1; k++ {
+		for j := j0; j < j1; j++ {
+			for i := i0; i < i1; i++ {
+				if !s.filled[s.index(i, j, k)] {
+					continue
+				}
+				c := s.center(i, j, k)
+				b := sw.bound(c)
+				if b >= -depth {
+					continue
+				}
+				depth = math.Max(depth, -sw.along(c)(sw.nearest(c)))
+				if b < -depth {
+					d, _ := sw.Closest(c)
+					depth = math.Max(depth, -d)
+				}
+			}
+		}
+	}
+	return depth
+}
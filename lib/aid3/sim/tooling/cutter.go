@@ -0,0 +1,394 @@
+package tooling
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	CUTTER_FLAT = iota
+	CUTTER_BALL
+	CUTTER_BULL_NOSE
+	CUTTER_V_BIT
+	CUTTER_DRILL
+	CUTTER_TAPERED_BALL
+	CUTTER_LOLLIPOP
+	CUTTER_THREAD_MILL
+)
+
+// arcSteps is the number of line segments used for a quarter circle
+// in a cutter profile, and revolveSteps the number of facets around
+// the tool axis when building a mesh.
+const (
+	arcSteps     = 12
+	revolveSteps = 32
+)
+
+// ProfilePoint
+// A point on the half profile of a cutter, R is the distance from the
+// tool axis and Z is the height above the tool tip.  Cutting marks if
+// the segment from this point to the next one has flutes.
+type ProfilePoint struct {
+	R       float64
+	Z       float64
+	Cutting bool
+}
+
+// Cutter
+// The geometry of a rotating tool, as a profile revolved about the
+// tool axis.  The tool tip is at the origin and the axis is +Z.
+// Lengths are in mm and angles in degrees.
+//
+// Angle is the included angle of a v-bit or a drill point.
+// TaperAngle is the per side taper of a tapered ball.
+// TipDiameter is the ball of a tapered ball or the flat of a v-bit.
+// NeckDiameter is the relief behind a lollipop or thread mill.
+// Length is the stick out of the tool from the holder.
+// MaxForce is the largest side load in N, 0 to estimate it.
+// LeftHand tools cut turning counter clockwise, M4.
+type Cutter struct {
+	Kind           int
+	Diameter       float64
+	CornerRadius   float64
+	Angle          float64
+	TaperAngle     float64
+	TipDiameter    float64
+	NeckDiameter   float64
+	Pitch          float64
+	Flutes         int
+	FluteLength    float64
+	ShankDiameter  float64
+	Length         float64
+	HolderDiameter float64
+	HolderLength   float64
+	MaxForce       float64
+	LeftHand       bool
+
+	profile []*ProfilePoint
+	mesh    *Mesh
+}
+
+func (c *Cutter) String() string {
+	return fmt.Sprintf("%v D%3.3f FL%3.3f", cutterKindName(c.Kind), c.Diameter, c.FluteLength)
+}
+
+func cutterKindName(kind int) string {
+	switch kind {
+	case CUTTER_FLAT:
+		return "flat"
+	case CUTTER_BALL:
+		return "ball"
+	case CUTTER_BULL_NOSE:
+		return "bull-nose"
+	case CUTTER_V_BIT:
+		return "v-bit"
+	case CUTTER_DRILL:
+		return "drill"
+	case CUTTER_TAPERED_BALL:
+		return "tapered-ball"
+	case CUTTER_LOLLIPOP:
+		return "lollipop"
+	case CUTTER_THREAD_MILL:
+		return "thread-mill"
+	}
+	return "unknown"
+}
+
+// withDefaults
+// Fill in the shank and holder when not given.
+func withDefaults(c *Cutter) *Cutter {
+	if c.Flutes == 0 {
+		c.Flutes = 2
+	}
+	if c.ShankDiameter == 0 {
+		c.ShankDiameter = c.Diameter
+	}
+	if c.Length < c.FluteLength {
+		c.Length = c.FluteLength + c.ShankDiameter*2
+	}
+	if c.HolderDiameter == 0 {
+		c.HolderDiameter = math.Max(c.ShankDiameter*2, 20)
+	}
+	if c.HolderLength == 0 {
+		c.HolderLength = 40
+	}
+	return c
+}
+
+func MakeFlatEndMill(diameter float64, fluteLength float64) *Cutter {
+	return withDefaults(&Cutter{Kind: CUTTER_FLAT, Diameter: diameter, FluteLength: fluteLength})
+}
+
+func MakeBallEndMill(diameter float64, fluteLength float64) *Cutter {
+	return withDefaults(&Cutter{Kind: CUTTER_BALL, Diameter: diameter, FluteLength: fluteLength})
+}
+
+func MakeBullNose(diameter float64, cornerRadius float64, fluteLength float64) *Cutter {
+	return withDefaults(&Cutter{Kind: CUTTER_BULL_NOSE, Diameter: diameter, CornerRadius: cornerRadius, FluteLength: fluteLength})
+}
+
+// MakeVBit
+// A chamfer or engraving tool, angle is the included angle.
+func MakeVBit(diameter float64, angle float64, tipDiameter float64) *Cutter {
+	c := &Cutter{Kind: CUTTER_V_BIT, Diameter: diameter, Angle: angle, TipDiameter: tipDiameter}
+	c.FluteLength = (diameter - tipDiameter) / 2 / math.Tan(radians(angle)/2)
+	return withDefaults(c)
+}
+
+// MakeDrill
+// A twist drill, pointAngle is the included angle of the point, 118 is usual.
+func MakeDrill(diameter float64, pointAngle float64, fluteLength float64) *Cutter {
+	return withDefaults(&Cutter{Kind: CUTTER_DRILL, Diameter: diameter, Angle: pointAngle, FluteLength: fluteLength})
+}
+
+// MakeTaperedBall
+// A ball of tipDiameter, tapering out at taperAngle per side until diameter.
+func MakeTaperedBall(tipDiameter float64, taperAngle float64, diameter float64, fluteLength float64) *Cutter {
+	return withDefaults(&Cutter{Kind: CUTTER_TAPERED_BALL, TipDiameter: tipDiameter, TaperAngle: taperAngle, Diameter: diameter, FluteLength: fluteLength})
+}
+
+// MakeLollipop
+// A ball of diameter on a neck, for undercuts.
+func MakeLollipop(diameter float64, neckDiameter float64, fluteLength float64) *Cutter {
+	return withDefaults(&Cutter{Kind: CUTTER_LOLLIPOP, Diameter: diameter, NeckDiameter: neckDiameter, FluteLength: fluteLength})
+}
+
+// MakeThreadMill
+// A single form 60 degree thread mill.
+func MakeThreadMill(diameter float64, pitch float64, neckDiameter float64, fluteLength float64) *Cutter {
+	return withDefaults(&Cutter{Kind: CUTTER_THREAD_MILL, Diameter: diameter, Pitch: pitch, NeckDiameter: neckDiameter, FluteLength: fluteLength})
+}
+
+// ForceLimit
+// The largest side load in N, unless given this is the load which
+// bends the shank at the holder to 1000 N/mm2, about half of what
+// carbide takes before snapping.
+func (c *Cutter) ForceLimit() float64 {
+	if c.MaxForce > 0 {
+		return c.MaxForce
+	}
+	d := math.Min(c.Diameter, c.ShankDiameter)
+	if c.NeckDiameter > 0 {
+		d = math.Min(d, c.NeckDiameter)
+	}
+	return 1000 * math.Pi * d * d * d / (32 * c.Length)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Profile
+// The half profile from the tip, on the axis, up to the top of the
+// holder, back on the axis.
+func (c *Cutter) Profile() []*ProfilePoint {
+	if c.profile == nil {
+		c.profile = c.buildProfile()
+	}
+	return c.profile
+}
+
+func (c *Cutter) buildProfile() []*ProfilePoint {
+	r := c.Diameter / 2
+	ret := []*ProfilePoint{{R: 0, Z: 0, Cutting: true}}
+	add := func(r float64, z float64, cutting bool) {
+		ret = append(ret, &ProfilePoint{R: r, Z: z, Cutting: cutting})
+	}
+	// arc about (cr, cz) from angle a0 to a1, radians
+	arc := func(cr float64, cz float64, rad float64, a0 float64, a1 float64) {
+		steps := int(math.Ceil(math.Abs(a1-a0) / (math.Pi / 2) * arcSteps))
+		for i := 1; i <= steps; i++ {
+			a := a0 + (a1-a0)*float64(i)/float64(steps)
+			add(cr+rad*math.Cos(a), cz+rad*math.Sin(a), true)
+		}
+	}
+	top := c.FluteLength
+
+	switch c.Kind {
+	case CUTTER_BALL:
+		arc(0, r, r, -math.Pi/2, 0)
+	case CUTTER_BULL_NOSE:
+		cr := math.Min(c.CornerRadius, r)
+		add(r-cr, 0, true)
+		arc(r-cr, cr, cr, -math.Pi/2, 0)
+	case CUTTER_V_BIT:
+		add(c.TipDiameter/2, 0, true)
+		add(r, (r-c.TipDiameter/2)/math.Tan(radians(c.Angle)/2), true)
+	case CUTTER_DRILL:
+		add(r, r/math.Tan(radians(c.Angle)/2), true)
+	case CUTTER_TAPERED_BALL:
+		rt := c.TipDiameter / 2
+		taper := radians(c.TaperAngle)
+		arc(0, rt, rt, -math.Pi/2, -taper)
+		tr := rt * math.Cos(taper)
+		tz := rt - rt*math.Sin(taper)
+		if taper > 0 && r > tr {
+			add(r, tz+(r-tr)/math.Tan(taper), true)
+		} else {
+			add(math.Max(r, tr), tz, true)
+		}
+	case CUTTER_LOLLIPOP:
+		neck := math.Min(c.NeckDiameter/2, r)
+		arc(0, r, r, -math.Pi/2, math.Acos(neck/r))
+		ret[len(ret)-1].Cutting = false
+		add(neck, top, false)
+	case CUTTER_THREAD_MILL:
+		neck := math.Min(c.NeckDiameter/2, r)
+		depth := r - neck
+		rise := depth * math.Tan(radians(30))
+		add(neck, 0, true)
+		add(r, rise, true)
+		add(neck, 2*rise, false)
+		add(neck, top, false)
+	default:
+		add(r, 0, true)
+	}
+
+	last := ret[len(ret)-1]
+	if last.Z < top {
+		add(last.R, top, true)
+		last = ret[len(ret)-1]
+	}
+	last.Cutting = false
+
+	shank := c.ShankDiameter / 2
+	if shank != last.R {
+		add(shank, last.Z, false)
+	}
+	add(shank, c.Length, false)
+	add(c.HolderDiameter/2, c.Length, false)
+	add(c.HolderDiameter/2, c.Length+c.HolderLength, false)
+	add(0, c.Length+c.HolderLength, false)
+
+	return ret
+}
+
+// Radius
+// The radius of the tool body at height z above the tip, 0 when
+// outside the tool.  The widest part is returned when the profile
+// folds back over itself.
+func (c *Cutter) Radius(z float64) float64 {
+	prof := c.Profile()
+	ret := 0.0
+	for i := 1; i < len(prof); i++ {
+		a := prof[i-1]
+		b := prof[i]
+		lo := math.Min(a.Z, b.Z)
+		hi := math.Max(a.Z, b.Z)
+		if z < lo || z > hi {
+			continue
+		}
+		r := math.Max(a.R, b.R)
+		if hi > lo {
+			r = a.R + (b.R-a.R)*(z-a.Z)/(b.Z-a.Z)
+		}
+		ret = math.Max(ret, r)
+	}
+	return ret
+}
+
+// Distance
+// The signed distance from p to the tool surface, negative inside,
+// where p is relative to the tool tip.  With cuttingOnly the fluted
+// part of the profile is used alone.
+func (c *Cutter) Distance(p *Point, cuttingOnly bool) float64 {
+	r := math.Sqrt(p.X*p.X + p.Y*p.Y)
+	prof := c.Profile()
+	if cuttingOnly {
+		prof = c.cuttingProfile()
+	}
+	return profileDistance(prof, r, p.Z)
+}
+
+// cuttingProfile
+// The profile up to the end of the flutes, closed on the axis.
+func (c *Cutter) cuttingProfile() []*ProfilePoint {
+	prof := c.Profile()
+	ret := make([]*ProfilePoint, 0, len(prof))
+	for _, p := range prof {
+		ret = append(ret, p)
+		if !p.Cutting {
+			break
+		}
+	}
+	last := ret[len(ret)-1]
+	return append(ret, &ProfilePoint{R: 0, Z: last.Z})
+}
+
+// profileDistance
+// Signed distance from (r, z) to the polygon made from the profile,
+// closed along the axis.  Edges on the axis are not part of the
+// revolved surface, so they are skipped for distance.
+func profileDistance(prof []*ProfilePoint, r float64, z float64) float64 {
+	best := math.MaxFloat64
+	inside := false
+	n := len(prof)
+	for i := 0; i < n; i++ {
+		a := prof[i]
+		b := prof[(i+1)%n]
+		if a.R != 0 || b.R != 0 {
+			best = math.Min(best, segmentDistance(a.R, a.Z, b.R, b.Z, r, z))
+		}
+		if (a.Z > z) != (b.Z > z) {
+			cross := a.R + (z-a.Z)*(b.R-a.R)/(b.Z-a.Z)
+			if r < cross {
+				inside = !inside
+			}
+		}
+	}
+	if inside {
+		return -best
+	}
+	return best
+}
+
+func segmentDistance(ax float64, ay float64, bx float64, by float64, px float64, py float64) float64 {
+	dx := bx - ax
+	dy := by - ay
+	l := dx*dx + dy*dy
+	t := 0.0
+	if l > 0 {
+		t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/l))
+	}
+	ex := px - (ax + t*dx)
+	ey := py - (ay + t*dy)
+	return math.Sqrt(ex*ex + ey*ey)
+}
+
+// Mesh
+// The cutter, shank and holder as a closed triangle mesh with the
+// tip at the origin.
+func (c *Cutter) Mesh() *Mesh {
+	if c.mesh == nil {
+		c.mesh = revolve(c.Profile(), revolveSteps)
+	}
+	return c.mesh
+}
+
+// revolve
+// Sweep the profile about the Z axis, each profile segment becomes
+// a band of quads, split into triangles.
+func revolve(prof []*ProfilePoint, steps int) *Mesh {
+	ret := &Mesh{}
+	at := func(p *ProfilePoint, i int) *Point {
+		a := 2 * math.Pi * float64(i%steps) / float64(steps)
+		return &Point{X: p.R * math.Cos(a), Y: p.R * math.Sin(a), Z: p.Z}
+	}
+	for j := 1; j < len(prof); j++ {
+		a := prof[j-1]
+		b := prof[j]
+		for i := 0; i < steps; i++ {
+			a0 := at(a, i)
+			a1 := at(a, i+1)
+			b0 := at(b, i)
+			b1 := at(b, i+1)
+			if a.R > 0 {
+				ret.AddTriangle(a0, a1, b1)
+			}
+			if b.R > 0 {
+				ret.AddTriangle(a0, b1, b0)
+			}
+		}
+	}
+	return ret
+}
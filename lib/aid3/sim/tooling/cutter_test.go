@@ -0,0 +1,52 @@
+package tooling
+
+import (
+	"math"
+	"testing"
+)
+
+// Helper function to check the body radius at a height
+func runRadiusTest(t *testing.T, name string, c *Cutter, z float64, expected float64) {
+	result := c.Radius(z)
+	if math.Abs(result-expected) > 0.01 {
+		t.Errorf("%s → Expected: %v, Got: %v", name, expected, result)
+	}
+}
+
+func TestCutterRadius(t *testing.T) {
+	runRadiusTest(t, "Flat end mill at the tip", MakeFlatEndMill(6, 20), 0, 3)
+	runRadiusTest(t, "Ball end mill half way up the ball", MakeBallEndMill(6, 20), 3-3*math.Cos(math.Pi/4), 3*math.Sin(math.Pi/4))
+	runRadiusTest(t, "Ball end mill above the ball", MakeBallEndMill(6, 20), 10, 3)
+	runRadiusTest(t, "Bull nose below the corner", MakeBullNose(10, 1, 20), 0, 4)
+	runRadiusTest(t, "90 degree v-bit", MakeVBit(12, 90, 0), 2, 2)
+	runRadiusTest(t, "118 degree drill tip", MakeDrill(10, 118, 40), 0, 0)
+	runRadiusTest(t, "Holder", MakeFlatEndMill(6, 20), MakeFlatEndMill(6, 20).Length+1, 10)
+}
+
+func TestCutterDistance(t *testing.T) {
+	c := MakeFlatEndMill(6, 20)
+	if d := c.Distance(&Point{X: 0, Y: 0, Z: 1}, true); d >= 0 {
+		t.Errorf("Point on the axis inside the flutes → Expected < 0, Got: %v", d)
+	}
+	if d := c.Distance(&Point{X: 5, Y: 0, Z: 1}, true); math.Abs(d-2) > 0.001 {
+		t.Errorf("Point beside the flutes → Expected: 2, Got: %v", d)
+	}
+	if d := c.Distance(&Point{X: 0, Y: 0, Z: 30}, true); d <= 0 {
+		t.Errorf("Point in the shank, cutting only → Expected > 0, Got: %v", d)
+	}
+	if d := c.Distance(&Point{X: 0, Y: 0, Z: 30}, false); d >= 0 {
+		t.Errorf("Point in the shank → Expected < 0, Got: %v", d)
+	}
+}
+
+func TestCutterMesh(t *testing.T) {
+	c := MakeLollipop(6, 3, 15)
+	m := c.Mesh()
+	if m.TriangleCount() == 0 {
+		t.Fatalf("Expected triangles in the lollipop mesh")
+	}
+	lo, hi := m.Bounds()
+	if math.Abs(lo.Z) > 0.001 || math.Abs(hi.Z-(c.Length+c.HolderLength)) > 0.001 {
+		t.Errorf("Mesh height → Expected: 0..%v, Got: %v..%v", c.Length+c.HolderLength, lo.Z, hi.Z)
+	}
+}
@@ -8,4 +8,6 @@ type Head interface {
 	Reset(zero *Point)
 	PointCount() int
 	Shape() *Mesh
+	Mount(c *Cutter)
+	Cutter() *Cutter
 }
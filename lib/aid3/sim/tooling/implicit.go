@@ -0,0 +1,54 @@
+package tooling
+
+import (
+	"math"
+)
+
+// Implicit
+// A solid given as a signed distance function, negative inside,
+// positive outside and zero on the surface.  Bounds is a box which
+// holds all of the inside.
+type Implicit interface {
+	Distance(p *Point) float64
+	Bounds() (*Point, *Point)
+}
+
+// BodyAt
+// A body with its tip at pos, the axis along +Z.
+type BodyAt struct {
+	body Body
+	pos  *Point
+}
+
+func PlaceBody(b Body, pos *Point) *BodyAt {
+	return &BodyAt{body: b, pos: pos}
+}
+
+// PlaceCutter
+// The cutter with its tip at pos, all of it or just the flutes.
+func PlaceCutter(c *Cutter, pos *Point, cuttingOnly bool) *BodyAt {
+	return PlaceBody(cutterPart(c, cuttingOnly), pos)
+}
+
+func (ba *BodyAt) Distance(p *Point) float64 {
+	return ba.body.Distance(&Point{X: p.X - ba.pos.X, Y: p.Y - ba.pos.Y, Z: p.Z - ba.pos.Z})
+}
+
+func (ba *BodyAt) Bounds() (*Point, *Point) {
+	if bb, ok := ba.body.(*OrientedBody); ok {
+		lo, hi := bb.Box()
+		return &Point{X: ba.pos.X + lo.X, Y: ba.pos.Y + lo.Y, Z: ba.pos.Z + lo.Z},
+			&Point{X: ba.pos.X + hi.X, Y: ba.pos.Y + hi.Y, Z: ba.pos.Z + hi.Z}
+	}
+	r, zlo, zhi := ba.body.Extent()
+	return &Point{X: ba.pos.X - r, Y: ba.pos.Y - r, Z: ba.pos.Z + zlo},
+		&Point{X: ba.pos.X + r, Y: ba.pos.Y + r, Z: ba.pos.Z + zhi}
+}
+
+func pointMin(a *Point, b *Point) *Point {
+	return &Point{X: math.Min(a.X, b.X), Y: math.Min(a.Y, b.Y), Z: math.Min(a.Z, b.Z)}
+}
+
+func pointMax(a *Point, b *Point) *Point {
+	return &Point{X: math.Max(a.X, b.X), Y: math.Max(a.Y, b.Y), Z: math.Max(a.Z, b.Z)}
+}
@@ -0,0 +1,211 @@
+package tooling
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	INSERT_TURNING = iota
+	INSERT_BORING
+	INSERT_GROOVING
+)
+
+// Insert
+// The cutting insert of a lathe tool, in the XZ plane with X the
+// radius.  The controlled point is the imaginary tool tip, where the
+// tangents to the nose radius along X and Z meet, at the origin.
+//
+// Angle is the included angle of a turning or boring insert, 80 for
+// a C, 55 for a D and 35 for a V insert.  Lead is how far the end
+// cutting edge leans back from the face, 5 for a 95 degree holder.
+// Size is the length of the cutting edges.  Width is the width of a
+// grooving insert and Depth how deep it can plunge.
+type Insert struct {
+	Kind       int
+	Angle      float64
+	Lead       float64
+	NoseRadius float64
+	Size       float64
+	Width      float64
+	Depth      float64
+
+	core []*Point
+}
+
+func (in *Insert) String() string {
+	switch in.Kind {
+	case INSERT_GROOVING:
+		return fmt.Sprintf("grooving W%3.3f R%3.3f", in.Width, in.NoseRadius)
+	case INSERT_BORING:
+		return fmt.Sprintf("boring %3.0f° R%3.3f", in.Angle, in.NoseRadius)
+	}
+	return fmt.Sprintf("turning %3.0f° R%3.3f", in.Angle, in.NoseRadius)
+}
+
+// MakeTurningInsert
+// An outside turning insert cutting toward -Z, its body toward +X
+// and +Z.
+func MakeTurningInsert(angle float64, lead float64, noseRadius float64, size float64) *Insert {
+	in := &Insert{Kind: INSERT_TURNING, Angle: angle, Lead: lead, NoseRadius: noseRadius, Size: size}
+	in.core = rhombus(angle, lead, noseRadius, size, 1)
+	return in
+}
+
+// MakeBoringInsert
+// An inside insert on a boring bar, cutting out toward +X.
+func MakeBoringInsert(angle float64, lead float64, noseRadius float64, size float64) *Insert {
+	in := &Insert{Kind: INSERT_BORING, Angle: angle, Lead: lead, NoseRadius: noseRadius, Size: size}
+	in.core = rhombus(angle, lead, noseRadius, size, -1)
+	return in
+}
+
+// MakeGroovingInsert
+// A square ended grooving or parting insert, the controlled point is
+// the -Z corner.
+func MakeGroovingInsert(width float64, noseRadius float64, depth float64) *Insert {
+	in := &Insert{Kind: INSERT_GROOVING, Width: width, NoseRadius: noseRadius, Depth: depth}
+	r := noseRadius
+	in.core = convexHull([]*Point{
+		{X: r, Z: r},
+		{X: r, Z: width - r},
+		{X: depth, Z: width - r},
+		{X: depth, Z: r},
+	})
+	return in
+}
+
+// DefaultInsert
+// A CNMG 80° insert with a 0.8 nose radius.
+func DefaultInsert() *Insert {
+	return MakeTurningInsert(80, 5, 0.8, 12)
+}
+
+// rhombus
+// The insert less its nose radius, the nose corner at the center of
+// the nose radius.  Side is 1 for outside tools and -1 for inside.
+func rhombus(angle float64, lead float64, r float64, size float64, side float64) []*Point {
+	a1 := radians(lead)
+	a2 := radians(lead + angle)
+	d1 := &Point{X: side * math.Cos(a1), Z: math.Sin(a1)}
+	d2 := &Point{X: side * math.Cos(a2), Z: math.Sin(a2)}
+	c := &Point{X: side * r, Z: r}
+	l := math.Max(size-2*r, 0)
+	return convexHull([]*Point{
+		c,
+		{X: c.X + l*d1.X, Z: c.Z + l*d1.Z},
+		{X: c.X + l*(d1.X+d2.X), Z: c.Z + l*(d1.Z+d2.Z)},
+		{X: c.X + l*d2.X, Z: c.Z + l*d2.Z},
+	})
+}
+
+// Distance
+// The signed distance from the insert, relative to the controlled point.
+func (in *Insert) Distance(x float64, z float64) float64 {
+	return polygonDistance(in.core, x, z) - in.NoseRadius
+}
+
+// InsertSweep
+// The area an insert sweeps moving in a line, the hull of the insert
+// at both ends grown by the nose radius.
+type InsertSweep struct {
+	hull   []*Point
+	radius float64
+}
+
+// SweepInsert
+// The insert moving from fr to to, X the radius and Z along the
+// spindle, Y is ignored.
+func SweepInsert(in *Insert, fr *Point, to *Point) *InsertSweep {
+	pts := make([]*Point, 0, 2*len(in.core))
+	for _, p := range in.core {
+		pts = append(pts, &Point{X: p.X + fr.X, Z: p.Z + fr.Z}, &Point{X: p.X + to.X, Z: p.Z + to.Z})
+	}
+	return &InsertSweep{hull: convexHull(pts), radius: in.NoseRadius}
+}
+
+func (sw *InsertSweep) Distance(x float64, z float64) float64 {
+	return polygonDistance(sw.hull, x, z) - sw.radius
+}
+
+// Bounds
+// The lowest and highest radius and Z the sweep reaches.
+func (sw *InsertSweep) Bounds() (float64, float64, float64, float64) {
+	xlo, zlo := math.MaxFloat64, math.MaxFloat64
+	xhi, zhi := -math.MaxFloat64, -math.MaxFloat64
+	for _, p := range sw.hull {
+		xlo, xhi = math.Min(xlo, p.X), math.Max(xhi, p.X)
+		zlo, zhi = math.Min(zlo, p.Z), math.Max(zhi, p.Z)
+	}
+	return xlo - sw.radius, zlo - sw.radius, xhi + sw.radius, zhi + sw.radius
+}
+
+// convexHull
+// The counter clockwise hull of points in XZ, Andrew's monotone chain.
+func convexHull(pts []*Point) []*Point {
+	sorted := make([]*Point, len(pts))
+	copy(sorted, pts)
+	sort.Slice(sorted, func(i int, j int) bool {
+		if sorted[i].X != sorted[j].X {
+			return sorted[i].X < sorted[j].X
+		}
+		return sorted[i].Z < sorted[j].Z
+	})
+	if len(sorted) < 3 {
+		return sorted
+	}
+	cross := func(o *Point, a *Point, b *Point) float64 {
+		return (a.X-o.X)*(b.Z-o.Z) - (a.Z-o.Z)*(b.X-o.X)
+	}
+	hull := make([]*Point, 0, 2*len(sorted))
+	for _, p := range sorted {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		p := sorted[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull[:len(hull)-1]
+}
+
+// polygonDistance
+// The signed distance to a counter clockwise convex polygon in XZ,
+// a single point or a segment work too.
+func polygonDistance(poly []*Point, x float64, z float64) float64 {
+	p := &Point{X: x, Z: z}
+	if len(poly) == 1 {
+		return math.Hypot(x-poly[0].X, z-poly[0].Z)
+	}
+	best := math.MaxFloat64
+	inside := len(poly) >= 3
+	for i := range poly {
+		a := poly[i]
+		b := poly[(i+1)%len(poly)]
+		best = math.Min(best, segmentDistanceXZ(a, b, p))
+		if (b.X-a.X)*(z-a.Z)-(b.Z-a.Z)*(x-a.X) < 0 {
+			inside = false
+		}
+	}
+	if inside {
+		return -best
+	}
+	return best
+}
+
+func segmentDistanceXZ(a *Point, b *Point, p *Point) float64 {
+	dx, dz := b.X-a.X, b.Z-a.Z
+	l2 := dx*dx + dz*dz
+	t := 0.0
+	if l2 > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Z-a.Z)*dz)/l2))
+	}
+	return math.Hypot(p.X-(a.X+t*dx), p.Z-(a.Z+t*dz))
+}
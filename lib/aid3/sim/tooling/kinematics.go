@@ -0,0 +1,357 @@
+package tooling
+
+import (
+	"math"
+)
+
+const (
+	KIN_THREE_AXIS = iota
+	KIN_INDEXER
+	KIN_TABLE_TABLE
+	KIN_HEAD_TABLE
+	KIN_HEAD_HEAD
+)
+
+// Rotary wrap rules, how an absolute angle is reached.
+// WRAP_NONE is a rotary with limits, moved like a linear axis.
+// WRAP_SHORTEST turns without end, the shorter way to the angle.
+// WRAP_SIGNED turns without end, A-90 goes negative to 90 and A90
+// positive to 90, as on Fanuc and Haas rotaries.
+const (
+	WRAP_NONE = iota
+	WRAP_SHORTEST
+	WRAP_SIGNED
+)
+
+// Rotary
+// One rotary axis, A about X, B about Y and C about Z at home.
+// Pivot is a point on the axis, machine coordinates for the table and
+// relative to the head pivot for the head.  Angles are degrees, positive
+// by the right hand rule.  Min and Max limit a rotary without wrap.
+type Rotary struct {
+	Name   string
+	Axis   *Point
+	Pivot  *Point
+	OnHead bool
+	Min    float64
+	Max    float64
+	Wrap   int
+}
+
+// Next
+// The angle the rotary ends at, from cur, for the programmed angle.
+func (r *Rotary) Next(cur float64, cmd float64) float64 {
+	switch r.Wrap {
+	case WRAP_SHORTEST:
+		d := math.Mod(cmd-cur, 360)
+		if d > 180 {
+			d -= 360
+		} else if d < -180 {
+			d += 360
+		}
+		return cur + d
+	case WRAP_SIGNED:
+		target := math.Mod(math.Abs(cmd), 360)
+		d := math.Mod(target-math.Mod(cur, 360)+720, 360)
+		if math.Signbit(cmd) && d != 0 {
+			d -= 360
+		}
+		return cur + d
+	}
+	return cmd
+}
+
+// InLimits
+// False when a rotary without wrap is past its travel.
+func (r *Rotary) InLimits(angle float64) bool {
+	if r.Wrap != WRAP_NONE || r.Min == r.Max {
+		return true
+	}
+	return angle >= r.Min && angle <= r.Max
+}
+
+// Joints
+// The machine axes, linear in mm and rotary in degrees.
+type Joints struct {
+	X, Y, Z float64
+	A, B, C float64
+}
+
+func (j *Joints) Angle(name string) float64 {
+	switch name {
+	case "A":
+		return j.A
+	case "B":
+		return j.B
+	}
+	return j.C
+}
+
+func (j *Joints) SetAngle(name string, angle float64) {
+	switch name {
+	case "A":
+		j.A = angle
+	case "B":
+		j.B = angle
+	default:
+		j.C = angle
+	}
+}
+
+// Pose
+// Where the tool tip is and which way the tool points, from the tip
+// up the shank, both in workpiece coordinates.
+type Pose struct {
+	Tip  *Point
+	Axis *Point
+}
+
+// Kinematics
+// How the rotaries move the tool relative to the workpiece.  Rotaries
+// are in chain order, from the machine frame out to the table or to
+// the spindle.  All the head rotaries turn about the head pivot, which
+// is PivotLength above the gauge line of the tool holder.
+type Kinematics struct {
+	Kind        int
+	Rotaries    []*Rotary
+	PivotLength float64
+}
+
+func MakeThreeAxis() *Kinematics {
+	return &Kinematics{Kind: KIN_THREE_AXIS}
+}
+
+// MakeIndexer
+// A 4th axis rotary on the table, A along X or B along Y, with its
+// axis through pivot.
+func MakeIndexer(name string, pivot *Point) *Kinematics {
+	return &Kinematics{
+		Kind:     KIN_INDEXER,
+		Rotaries: []*Rotary{homeRotary(name, pivot, false, WRAP_SHORTEST, 0, 0)},
+	}
+}
+
+// MakeTableTable
+// A trunnion table, tilting on A or B through pivot with a C rotary
+// table on the trunnion, also through pivot.
+func MakeTableTable(tilt string, pivot *Point) *Kinematics {
+	return &Kinematics{
+		Kind: KIN_TABLE_TABLE,
+		Rotaries: []*Rotary{
+			homeRotary(tilt, pivot, false, WRAP_NONE, -120, 120),
+			homeRotary("C", pivot, false, WRAP_SHORTEST, 0, 0),
+		},
+	}
+}
+
+// MakeHeadTable
+// A head tilting on A or B with a C rotary table through pivot.
+func MakeHeadTable(tilt string, pivotLength float64, pivot *Point) *Kinematics {
+	return &Kinematics{
+		Kind:        KIN_HEAD_TABLE,
+		PivotLength: pivotLength,
+		Rotaries: []*Rotary{
+			homeRotary("C", pivot, false, WRAP_SHORTEST, 0, 0),
+			homeRotary(tilt, &Point{}, true, WRAP_NONE, -110, 110),
+		},
+	}
+}
+
+// MakeHeadHead
+// A fork head, C turning a tilt on A or B.  The cables limit C.
+func MakeHeadHead(tilt string, pivotLength float64) *Kinematics {
+	return &Kinematics{
+		Kind:        KIN_HEAD_HEAD,
+		PivotLength: pivotLength,
+		Rotaries: []*Rotary{
+			homeRotary("C", &Point{}, true, WRAP_NONE, -360, 360),
+			homeRotary(tilt, &Point{}, true, WRAP_NONE, -110, 110),
+		},
+	}
+}
+
+func homeRotary(name string, pivot *Point, onHead bool, wrap int, lo float64, hi float64) *Rotary {
+	axis := &Point{Z: 1}
+	switch name {
+	case "A":
+		axis = &Point{X: 1}
+	case "B":
+		axis = &Point{Y: 1}
+	}
+	return &Rotary{Name: name, Axis: axis, Pivot: pivot, OnHead: onHead, Min: lo, Max: hi, Wrap: wrap}
+}
+
+// Rotary
+// The rotary by name, nil when the machine does not have it.
+func (k *Kinematics) Rotary(name string) *Rotary {
+	for _, r := range k.Rotaries {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func (k *Kinematics) AxisCount() int {
+	return 3 + len(k.Rotaries)
+}
+
+// Forward
+// The pose of the tool in the workpiece for the joints.  The linear
+// joints are where the tip would be with the head rotaries at zero,
+// so a 3-axis program runs unchanged.  Length is the tool length
+// below the gauge line.
+func (k *Kinematics) Forward(j *Joints, length float64) *Pose {
+	axis := k.headAxis(j)
+	l := k.PivotLength + length
+	tip := &Point{X: j.X + l*(-axis.X), Y: j.Y + l*(-axis.Y), Z: j.Z + l*(1-axis.Z)}
+	return &Pose{
+		Tip:  k.toWork(j, tip, true),
+		Axis: k.toWork(j, axis, false),
+	}
+}
+
+// Inverse
+// The joints placing the tip at the workpiece point with the rotaries
+// at the angles in j, the tool center point control of G43.4.
+func (k *Kinematics) Inverse(tip *Point, j *Joints, length float64) *Joints {
+	axis := k.headAxis(j)
+	l := k.PivotLength + length
+	m := k.toMachine(j, tip)
+	ret := *j
+	ret.X = m.X - l*(-axis.X)
+	ret.Y = m.Y - l*(-axis.Y)
+	ret.Z = m.Z - l*(1-axis.Z)
+	return &ret
+}
+
+// headAxis
+// The tool axis in the machine frame, the innermost head rotary
+// turning first.
+func (k *Kinematics) headAxis(j *Joints) *Point {
+	v := &Point{Z: 1}
+	for i := len(k.Rotaries) - 1; i >= 0; i-- {
+		r := k.Rotaries[i]
+		if r.OnHead {
+			v = rotateAbout(v, r.Axis, radians(j.Angle(r.Name)))
+		}
+	}
+	return v
+}
+
+// toWork
+// A machine point, or direction, in the frame of the workpiece on the
+// table rotaries.
+func (k *Kinematics) toWork(j *Joints, p *Point, point bool) *Point {
+	for _, r := range k.Rotaries {
+		if !r.OnHead {
+			p = rotateAround(p, r, -radians(j.Angle(r.Name)), point)
+		}
+	}
+	return p
+}
+
+func (k *Kinematics) toMachine(j *Joints, p *Point) *Point {
+	for i := len(k.Rotaries) - 1; i >= 0; i-- {
+		r := k.Rotaries[i]
+		if !r.OnHead {
+			p = rotateAround(p, r, radians(j.Angle(r.Name)), true)
+		}
+	}
+	return p
+}
+
+func rotateAround(p *Point, r *Rotary, a float64, point bool) *Point {
+	if !point {
+		return rotateAbout(p, r.Axis, a)
+	}
+	rel := rotateAbout(&Point{X: p.X - r.Pivot.X, Y: p.Y - r.Pivot.Y, Z: p.Z - r.Pivot.Z}, r.Axis, a)
+	return &Point{X: rel.X + r.Pivot.X, Y: rel.Y + r.Pivot.Y, Z: rel.Z + r.Pivot.Z}
+}
+
+// rotateAbout
+// Rotate v by a radians about the unit axis k, Rodrigues' formula.
+func rotateAbout(v *Point, k *Point, a float64) *Point {
+	c, s := math.Cos(a), math.Sin(a)
+	dot := k.X*v.X + k.Y*v.Y + k.Z*v.Z
+	cx := k.Y*v.Z - k.Z*v.Y
+	cy := k.Z*v.X - k.X*v.Z
+	cz := k.X*v.Y - k.Y*v.X
+	return &Point{
+		X: v.X*c + cx*s + k.X*dot*(1-c),
+		Y: v.Y*c + cy*s + k.Y*dot*(1-c),
+		Z: v.Z*c + cz*s + k.Z*dot*(1-c),
+	}
+}
+
+// OrientedBody
+// A body with its axis along a unit direction instead of +Z.
+type OrientedBody struct {
+	body  Body
+	axis  *Point
+	about *Point
+	angle float64
+}
+
+func OrientBody(b Body, axis *Point) *OrientedBody {
+	// the rotation taking +Z to the axis, about Z x axis
+	about := &Point{X: -axis.Y, Y: axis.X}
+	n := math.Hypot(about.X, about.Y)
+	ret := &OrientedBody{body: b, axis: axis, about: &Point{X: 1}}
+	if n > 1e-12 {
+		ret.about = &Point{X: about.X / n, Y: about.Y / n}
+		ret.angle = math.Atan2(n, axis.Z)
+	} else if axis.Z < 0 {
+		ret.angle = math.Pi
+	}
+	return ret
+}
+
+// Local
+// A point relative to the tip in the frame of the body.
+func (o *OrientedBody) Local(rel *Point) *Point {
+	return rotateAbout(rel, o.about, -o.angle)
+}
+
+func (o *OrientedBody) Distance(rel *Point) float64 {
+	return o.body.Distance(o.Local(rel))
+}
+
+// Extent
+// The body turned any way fits in a sphere, the bounds do not follow
+// the axis.
+func (o *OrientedBody) Extent() (float64, float64, float64) {
+	r, lo, hi := o.body.Extent()
+	reach := math.Hypot(r, math.Max(math.Abs(lo), math.Abs(hi)))
+	return reach, -reach, reach
+}
+
+// Box
+// The box about the body relative to the tip, a cylinder of the
+// widest radius along the axis.
+func (o *OrientedBody) Box() (*Point, *Point) {
+	r, lo, hi := o.body.Extent()
+	side := func(a float64) (float64, float64) {
+		w := r * math.Sqrt(math.Max(0, 1-a*a))
+		return math.Min(a*lo, a*hi) - w, math.Max(a*lo, a*hi) + w
+	}
+	xlo, xhi := side(o.axis.X)
+	ylo, yhi := side(o.axis.Y)
+	zlo, zhi := side(o.axis.Z)
+	return &Point{X: xlo, Y: ylo, Z: zlo}, &Point{X: xhi, Y: yhi, Z: zhi}
+}
+
+func (o *OrientedBody) RadiusBetween(z0 float64, z1 float64) float64 {
+	r, _, _ := o.Extent()
+	return r
+}
+
+func (o *OrientedBody) Convex() bool {
+	return o.body.Convex()
+}
+
+// Vertical
+// True for a tool axis close enough to +Z to be swept as a 3-axis tool.
+func Vertical(axis *Point) bool {
+	return axis == nil || (math.Abs(axis.X) < 1e-9 && math.Abs(axis.Y) < 1e-9 && axis.Z > 0)
+}
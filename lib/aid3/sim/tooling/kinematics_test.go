@@ -0,0 +1,64 @@
+package tooling
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a *Point, b *Point) bool {
+	return a.Dist(b) < 1e-9
+}
+
+func TestRotaryWrap(t *testing.T) {
+	shortest := &Rotary{Name: "C", Wrap: WRAP_SHORTEST}
+	if a := shortest.Next(350, 10); a != 370 {
+		t.Errorf("C350 to C10 the short way → Expected: 370, Got: %v", a)
+	}
+	signed := &Rotary{Name: "A", Wrap: WRAP_SIGNED}
+	if a := signed.Next(10, -90); a != -270 {
+		t.Errorf("A10 to A-90 → Expected negative to -270, Got: %v", a)
+	}
+	if a := signed.Next(10, 90); a != 90 {
+		t.Errorf("A10 to A90 → Expected: 90, Got: %v", a)
+	}
+	limited := &Rotary{Name: "B", Min: -110, Max: 110}
+	if limited.Next(0, 120) != 120 || limited.InLimits(120) {
+		t.Errorf("B120 → Expected the angle as given, out of the limits")
+	}
+}
+
+func TestIndexerForward(t *testing.T) {
+	k := MakeIndexer("A", &Point{})
+	// A90 turns the +Y side of the part up, under the tip
+	p := k.Forward(&Joints{Z: 10, A: 90}, 50)
+	if !near(p.Tip, &Point{Y: 10}) {
+		t.Errorf("Tip → Expected: Y10, Got: %v", p.Tip)
+	}
+	if !near(p.Axis, &Point{Y: 1}) {
+		t.Errorf("Axis → Expected: +Y, Got: %v", p.Axis)
+	}
+}
+
+func TestHeadTcpRoundTrip(t *testing.T) {
+	k := MakeHeadTable("B", 150, &Point{})
+	j := &Joints{B: 30, C: 45}
+	tip := &Point{X: 12, Y: -4, Z: 3}
+	back := k.Forward(k.Inverse(tip, j, 60), 60)
+	if !near(back.Tip, tip) {
+		t.Errorf("TCP round trip → Expected: %v, Got: %v", tip, back.Tip)
+	}
+	if math.Abs(back.Axis.Z-math.Cos(radians(30))) > 1e-9 {
+		t.Errorf("Tilt → Expected 30°, Got: %v", back.Axis)
+	}
+}
+
+func TestOrientedBody(t *testing.T) {
+	c := MakeFlatEndMill(6, 20)
+	b := OrientBody(MakeCutterBody(c, PART_CUTTING), &Point{X: 1})
+	if d := b.Distance(&Point{X: 10}); d >= 0 {
+		t.Errorf("Along the tilted flutes → Expected inside, Got: %v", d)
+	}
+	if d := b.Distance(&Point{Z: 10}); d <= 0 {
+		t.Errorf("Above the tip → Expected outside, Got: %v", d)
+	}
+}
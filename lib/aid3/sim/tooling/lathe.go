@@ -0,0 +1,272 @@
+package tooling
+
+import (
+	"math"
+)
+
+// Lathe
+// A 2-axis turning machine.  X is across the spindle and Z along it,
+// the head positions are the radius in X.  X words are diameters in
+// diameter mode, the default, or radii in radius mode.  Tools are
+// inserts on turret stations.  Rapid is the G0 feed in mm/min.
+type Lathe struct {
+	Rapid float64
+
+	head       Head
+	zero       *Point
+	feed       float64
+	feedMode   int
+	spindle    *Spindle
+	outputs    *Outputs
+	kinematics *Kinematics
+	curTool    int64
+	tools      *ToolTable
+	inserts    map[int64]*Insert
+	nose       *Nose
+	plane      int
+	units      int
+	workVolume Volume
+	material   Material
+
+	diameter     bool
+	css          bool
+	surfaceSpeed float64
+	maxRpm       float64
+
+	barRadius float64
+	barBore   float64
+	barLength float64
+}
+
+// BuildLathe
+// A lathe with bar stock of the radius and length held in the chuck,
+// the face of the bar at Z0 and the bar along -Z.
+func BuildLathe(m Material, radius float64, length float64) *Lathe {
+	ret := &Lathe{Rapid: 4000}
+	ret.workVolume = MakeVolume(&Point{X: 0, Y: -radius, Z: -length}, &Point{X: radius, Y: radius, Z: 0})
+	ret.material = m
+	ret.tools = MakeToolTable()
+	ret.inserts = make(map[int64]*Insert)
+	ret.nose = DefaultNose()
+	ret.spindle = MakeSpindle()
+	ret.spindle.Accel = 1500
+	ret.spindle.Gears = []GearRange{{Min: 0, Max: 4000}}
+	ret.outputs = MakeOutputs()
+	ret.kinematics = MakeThreeAxis()
+	ret.barRadius = radius
+	ret.barLength = length
+
+	ret.head = &SimpleHead{
+		pos:    &Point{},
+		path:   make([]*Point, 0),
+		curVel: Still(),
+		cutter: DefaultCutter(),
+	}
+	return ret
+}
+
+//
+// Cnc
+//
+
+func (l *Lathe) Type() int {
+	return CncLathe
+}
+
+func (l *Lathe) Axis() []int {
+	return []int{2}
+}
+
+func (l *Lathe) ZeroPoint() *Point {
+	return l.zero
+}
+
+func (l *Lathe) Head() Head {
+	return l.head
+}
+
+func (l *Lathe) FeedRate() float64 {
+	return l.feed
+}
+
+func (l *Lathe) AssignFeedRate(f float64) {
+	l.feed = f
+}
+
+func (l *Lathe) FastFeedRate() float64 {
+	return l.Rapid
+}
+
+func (l *Lathe) FeedMode(mode int) {
+	l.feedMode = mode
+}
+
+func (l *Lathe) CurrentFeedMode() int {
+	return l.feedMode
+}
+
+func (l *Lathe) Spindle() *Spindle {
+	return l.spindle
+}
+
+func (l *Lathe) CurrentSpindleSpeed() float64 {
+	return l.spindle.Commanded
+}
+
+func (l *Lathe) Outputs() *Outputs {
+	return l.outputs
+}
+
+func (l *Lathe) Kinematics() *Kinematics {
+	return l.kinematics
+}
+
+// SetKinematics
+// A lathe has no rotaries, there is nothing to set.
+func (l *Lathe) SetKinematics(k *Kinematics) {
+}
+
+// ToolChangeTo
+// T0101 is station 1 with offset 1, the station picks the insert.
+func (l *Lathe) ToolChangeTo(tool int64) {
+	if tool >= 100 {
+		tool = tool / 100
+	}
+	l.curTool = tool
+}
+
+func (l *Lathe) CurrentTool() int64 {
+	return l.curTool
+}
+
+func (l *Lathe) Tools() *ToolTable {
+	return l.tools
+}
+
+func (l *Lathe) SpindleNose() *Nose {
+	return l.nose
+}
+
+func (l *Lathe) SelectPlane(plane int) {
+	l.plane = plane
+}
+
+func (l *Lathe) Plane() int {
+	return l.plane
+}
+
+func (l *Lathe) WorkVolume() Volume {
+	return l.workVolume
+}
+
+func (l *Lathe) Material() Material {
+	return l.material
+}
+
+func (l *Lathe) Units(units int) {
+	l.units = units
+}
+
+// Reset
+// Diameter mode, feed per revolution and constant spindle speed, in
+// the XZ plane.
+func (l *Lathe) Reset() {
+	l.zero = &Point{}
+	l.plane = PLANE_XZ
+	l.spindle.Reset()
+	l.outputs.Reset()
+	l.feedMode = FEED_PER_REVOLUTION
+	l.feed = 0
+	l.units = UNIT_MM
+	l.diameter = true
+	l.css = false
+	l.surfaceSpeed = 0
+	l.maxRpm = 0
+	l.head.Reset(l.zero)
+}
+
+//
+// Turning
+//
+
+// AddInsert
+// Put an insert on a turret station.
+func (l *Lathe) AddInsert(station int64, in *Insert) {
+	l.inserts[station] = in
+}
+
+// Insert
+// The insert on the current station, the default insert when the
+// station is empty.
+func (l *Lathe) Insert() *Insert {
+	if in, ok := l.inserts[l.curTool]; ok {
+		return in
+	}
+	return DefaultInsert()
+}
+
+func (l *Lathe) DiameterMode(on bool) {
+	l.diameter = on
+}
+
+func (l *Lathe) Diameter() bool {
+	return l.diameter
+}
+
+// ProgramX
+// The radius for a programmed X word.
+func (l *Lathe) ProgramX(x float64) float64 {
+	if l.diameter {
+		return x / 2
+	}
+	return x
+}
+
+// Css
+// G96 turns on constant surface speed, S is then m/min.  G97 turns it
+// off and S is RPM again.
+func (l *Lathe) Css(on bool) {
+	l.css = on
+}
+
+func (l *Lathe) CssOn() bool {
+	return l.css
+}
+
+func (l *Lathe) SurfaceSpeed(v float64) {
+	l.surfaceSpeed = v
+}
+
+// ClampRpm
+// G50 S, the most constant surface speed may turn the spindle.
+func (l *Lathe) ClampRpm(rpm float64) {
+	l.maxRpm = rpm
+}
+
+// CssRpm
+// The RPM for the surface speed at the radius, clamped.
+func (l *Lathe) CssRpm(radius float64) float64 {
+	rpm := math.MaxFloat64
+	if radius > 0 {
+		rpm = 1000 * l.surfaceSpeed / (2 * math.Pi * radius)
+	}
+	if l.maxRpm > 0 {
+		rpm = math.Min(rpm, l.maxRpm)
+	}
+	if l.spindle.Gear < len(l.spindle.Gears) {
+		rpm = math.Min(rpm, l.spindle.Gears[l.spindle.Gear].Max)
+	}
+	return rpm
+}
+
+// Bar
+// The stock in the chuck, radius, bore and length back from Z0.
+func (l *Lathe) Bar() (float64, float64, float64) {
+	return l.barRadius, l.barBore, l.barLength
+}
+
+// Bore
+// Bar stock with a hole through it, tube.
+func (l *Lathe) Bore(radius float64) {
+	l.barBore = radius
+}
@@ -1,11 +1,23 @@
 package tooling
 
+// Material
+// The stock being cut.  Hardness is Brinell, Stiffness the elastic
+// modulus in GPa, Density g/cm3 and CuttingEnergy the specific cutting
+// energy in J/mm3.  SurfaceSpeed is the recommended range in m/min and
+// ChipLoad the recommended feed per tooth in mm for a cutter diameter.
 type Material interface {
+	Name() string
 	Hardness() float64
 	Stiffness() float64
+	Density() float64
+	CuttingEnergy() float64
+	SurfaceSpeed() (float64, float64)
+	ChipLoad(diameter float64) (float64, float64)
 	Volume() Volume
 }
 
+// Wood
+// A generic hardwood, the material package has specific woods.
 type Wood struct {
 	hardness    float64
 	stiffness   float64
@@ -14,12 +26,16 @@ type Wood struct {
 
 func MakeWood(dim float64) Material {
 	return &Wood{
-		hardness:    0.0,
-		stiffness:   0.0,
-		startVolume: MakeVolume(&Point{-dim, -dim, -dim}, &Point{dim, dim, dim}),
+		hardness:    3.5,
+		stiffness:   12.0,
+		startVolume: MakeVolume(&Point{X: -dim, Y: -dim, Z: -dim}, &Point{X: dim, Y: dim, Z: dim}),
 	}
 }
 
+func (w *Wood) Name() string {
+	return "Wood"
+}
+
 func (w *Wood) Hardness() float64 {
 	return w.hardness
 }
@@ -28,6 +44,24 @@ func (w *Wood) Stiffness() float64 {
 	return w.stiffness
 }
 
+func (w *Wood) Density() float64 {
+	return 0.65
+}
+
+func (w *Wood) CuttingEnergy() float64 {
+	return 0.07
+}
+
+func (w *Wood) SurfaceSpeed() (float64, float64) {
+	return 300, 1000
+}
+
+// ChipLoad
+// About 2% of the cutter diameter, with a wide range.
+func (w *Wood) ChipLoad(diameter float64) (float64, float64) {
+	return diameter * 0.015, diameter * 0.035
+}
+
 func (w *Wood) Volume() Volume {
 	return w.startVolume
 }
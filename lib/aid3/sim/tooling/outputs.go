@@ -0,0 +1,171 @@
+package tooling
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	AUX_FLOOD = iota
+	AUX_MIST
+	AUX_THROUGH_SPINDLE
+	AUX_AIR
+	AUX_VACUUM
+	AUX_DIGITAL
+	AUX_ANALOG
+)
+
+// OutputEvent
+// An output changing at a time in seconds of program time.  Index is
+// the pin of a digital or analog output, Value is 0 or 1 for anything
+// but analog outputs.
+type OutputEvent struct {
+	Time  float64
+	Kind  int
+	Index int
+	Value float64
+}
+
+func (e *OutputEvent) String() string {
+	if e.Kind == AUX_DIGITAL || e.Kind == AUX_ANALOG {
+		return fmt.Sprintf("%v %v = %v at %3.3fs", auxKindName(e.Kind), e.Index, e.Value, e.Time)
+	}
+	return fmt.Sprintf("%v = %v at %3.3fs", auxKindName(e.Kind), e.Value, e.Time)
+}
+
+// Outputs
+// The coolant, air, vacuum and general purpose outputs of a machine.
+// Events is every change in time order, observers see each change
+// as it happens.
+type Outputs struct {
+	Events    []*OutputEvent
+	on        map[int]bool
+	digital   map[int]bool
+	analog    map[int]float64
+	observers []func(e *OutputEvent)
+}
+
+func MakeOutputs() *Outputs {
+	ret := &Outputs{}
+	ret.Reset()
+	return ret
+}
+
+// Reset
+// Everything off, the observers are kept.
+func (o *Outputs) Reset() {
+	o.Events = nil
+	o.on = make(map[int]bool)
+	o.digital = make(map[int]bool)
+	o.analog = make(map[int]float64)
+}
+
+// Observe
+// Call f with every change from now on.
+func (o *Outputs) Observe(f func(e *OutputEvent)) {
+	o.observers = append(o.observers, f)
+}
+
+// Set
+// Turn one of flood, mist, through spindle, air or vacuum on or off.
+func (o *Outputs) Set(kind int, on bool, at float64) {
+	if o.on[kind] == on {
+		return
+	}
+	o.on[kind] = on
+	o.post(&OutputEvent{Time: at, Kind: kind, Value: boolValue(on)})
+}
+
+// Digital
+// Turn a digital output, M62 to M65 P, on or off.
+func (o *Outputs) Digital(pin int, on bool, at float64) {
+	if o.digital[pin] == on {
+		return
+	}
+	o.digital[pin] = on
+	o.post(&OutputEvent{Time: at, Kind: AUX_DIGITAL, Index: pin, Value: boolValue(on)})
+}
+
+// Analog
+// Set an analog output, M67 or M68 E with Q.
+func (o *Outputs) Analog(pin int, value float64, at float64) {
+	if o.analog[pin] == value {
+		return
+	}
+	o.analog[pin] = value
+	o.post(&OutputEvent{Time: at, Kind: AUX_ANALOG, Index: pin, Value: value})
+}
+
+// CoolantOff
+// M9, flood, mist and through spindle coolant off.
+func (o *Outputs) CoolantOff(at float64) {
+	o.Set(AUX_FLOOD, false, at)
+	o.Set(AUX_MIST, false, at)
+	o.Set(AUX_THROUGH_SPINDLE, false, at)
+}
+
+func (o *Outputs) On(kind int) bool {
+	return o.on[kind]
+}
+
+func (o *Outputs) DigitalOn(pin int) bool {
+	return o.digital[pin]
+}
+
+func (o *Outputs) AnalogValue(pin int) float64 {
+	return o.analog[pin]
+}
+
+// Coolant
+// True with any of flood, mist or through spindle coolant on.  Air
+// blast clears chips but does not cool.
+func (o *Outputs) Coolant() bool {
+	return o.on[AUX_FLOOD] || o.on[AUX_MIST] || o.on[AUX_THROUGH_SPINDLE]
+}
+
+// CoolantAt
+// Coolant from the events, at a time in the past.
+func (o *Outputs) CoolantAt(at float64) bool {
+	on := make(map[int]bool)
+	i := sort.Search(len(o.Events), func(i int) bool {
+		return o.Events[i].Time > at
+	})
+	for _, e := range o.Events[:i] {
+		on[e.Kind] = e.Value != 0
+	}
+	return on[AUX_FLOOD] || on[AUX_MIST] || on[AUX_THROUGH_SPINDLE]
+}
+
+func (o *Outputs) post(e *OutputEvent) {
+	o.Events = append(o.Events, e)
+	for _, f := range o.observers {
+		f(e)
+	}
+}
+
+func boolValue(on bool) float64 {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+func auxKindName(kind int) string {
+	switch kind {
+	case AUX_FLOOD:
+		return "flood"
+	case AUX_MIST:
+		return "mist"
+	case AUX_THROUGH_SPINDLE:
+		return "through spindle"
+	case AUX_AIR:
+		return "air blast"
+	case AUX_VACUUM:
+		return "vacuum"
+	case AUX_DIGITAL:
+		return "digital"
+	case AUX_ANALOG:
+		return "analog"
+	}
+	return "unknown"
+}
@@ -0,0 +1,32 @@
+package tooling
+
+import (
+	"testing"
+)
+
+func TestOutputsTimeline(t *testing.T) {
+	o := MakeOutputs()
+	seen := 0
+	o.Observe(func(e *OutputEvent) {
+		seen++
+	})
+
+	o.Set(AUX_MIST, true, 1)
+	o.Set(AUX_AIR, true, 2)
+	o.CoolantOff(5)
+	o.Digital(3, true, 6)
+	o.Analog(0, 2.5, 6)
+
+	if seen != 5 {
+		t.Errorf("Observed changes → Expected: 5, Got: %v", seen)
+	}
+	if !o.CoolantAt(1) || !o.CoolantAt(4.9) || o.CoolantAt(0.5) || o.CoolantAt(5) {
+		t.Errorf("Coolant → Expected on from 1s until 5s, Got: %v", o.Events)
+	}
+	if o.Coolant() || !o.On(AUX_AIR) {
+		t.Errorf("After M9 → Expected the air blast without coolant")
+	}
+	if !o.DigitalOn(3) || o.AnalogValue(0) != 2.5 {
+		t.Errorf("General outputs → Expected pin 3 on and analog 0 at 2.5")
+	}
+}
@@ -0,0 +1,459 @@
+package tooling
+
+import (
+	"math"
+)
+
+// Heater
+// A hotend or bed in °C, heating toward Target at Rate degrees a
+// second and cooling at CoolRate, down to Ambient when off.
+type Heater struct {
+	Target   float64
+	Rate     float64
+	CoolRate float64
+	Ambient  float64
+
+	from    float64
+	changed float64
+}
+
+func MakeHeater(rate float64, coolRate float64) *Heater {
+	ret := &Heater{Rate: rate, CoolRate: coolRate, Ambient: 20}
+	ret.Reset()
+	return ret
+}
+
+// Reset
+// Off and cold.
+func (h *Heater) Reset() {
+	h.Target = 0
+	h.from = h.Ambient
+	h.changed = 0
+}
+
+// Temp
+// The temperature at the time.
+func (h *Heater) Temp(at float64) float64 {
+	target := h.goal()
+	if at <= h.changed {
+		return h.from
+	}
+	rate := h.Rate
+	if target < h.from {
+		rate = h.CoolRate
+	}
+	if rate <= 0 {
+		return target
+	}
+	step := rate * (at - h.changed)
+	if math.Abs(target-h.from) <= step {
+		return target
+	}
+	if target > h.from {
+		return h.from + step
+	}
+	return h.from - step
+}
+
+// Set
+// M104 or M140, 0 turns the heater off.
+func (h *Heater) Set(target float64, at float64) {
+	h.from = h.Temp(at)
+	h.changed = at
+	h.Target = target
+}
+
+// ReadyAt
+// The time the heater reaches its target.
+func (h *Heater) ReadyAt() float64 {
+	target := h.goal()
+	rate := h.Rate
+	if target < h.from {
+		rate = h.CoolRate
+	}
+	if rate <= 0 {
+		return h.changed
+	}
+	return h.changed + math.Abs(target-h.from)/rate
+}
+
+func (h *Heater) goal() float64 {
+	if h.Target <= 0 {
+		return h.Ambient
+	}
+	return h.Target
+}
+
+// Printer
+// A fused filament printer over a bed from the origin, its top at Z0.
+// E is the filament fed in mm, absolute under M82 and relative under
+// M83, G92 sets where it is.  Filament is the diameter of the filament
+// and Nozzle of the nozzle, in mm.  Below MinExtrudeTemp the hotend
+// will not extrude.  Fan is the part cooling fan, 0 to 1.
+//
+// Mesh is the bed as G29 probes it, ProbeTime seconds a point, and it
+// is followed while Leveling is on.  G10 pulls RetractLength of
+// filament back at RetractFeed mm/min and G11 feeds it again.  Moves
+// speed up at Accel, travel at TravelAccel and retractions at
+// RetractAccel mm/s2, starting and stopping at Jerk mm/s.
+// FeedOverride and FlowOverride are M220 and M221, 1 as programmed.
+type Printer struct {
+	head       Head
+	zero       *Point
+	feed       float64
+	feedMode   int
+	spindle    *Spindle
+	outputs    *Outputs
+	kinematics *Kinematics
+	curTool    int64
+	tools      *ToolTable
+	nose       *Nose
+	plane      int
+	units      int
+	workVolume Volume
+	material   Material
+
+	Filament       float64
+	Nozzle         float64
+	MinExtrudeTemp float64
+	Hotend         *Heater
+	Bed            *Heater
+	Fan            float64
+	Rapid          float64
+
+	Mesh          *BedMesh
+	Leveling      bool
+	ProbeTime     float64
+	RetractLength float64
+	RetractFeed   float64
+	Accel         float64
+	TravelAccel   float64
+	RetractAccel  float64
+	Jerk          float64
+	FeedOverride  float64
+	FlowOverride  float64
+
+	relative  bool
+	retracted bool
+	e         float64
+	layer     float64
+	below     float64
+	bedHi     *Point
+}
+
+// BuildPrinter
+// A printer with a 0.4 mm nozzle for 1.75 mm filament, the bed width
+// along X, depth along Y and height the most it prints.
+func BuildPrinter(m Material, width float64, depth float64, height float64) *Printer {
+	ret := &Printer{}
+	ret.bedHi = &Point{X: width, Y: depth, Z: height}
+	ret.workVolume = MakeVolume(&Point{}, ret.bedHi)
+	ret.material = m
+	ret.tools = MakeToolTable()
+	ret.nose = DefaultNose()
+	ret.spindle = MakeSpindle()
+	ret.outputs = MakeOutputs()
+	ret.kinematics = MakeThreeAxis()
+	ret.Filament = 1.75
+	ret.Nozzle = 0.4
+	ret.MinExtrudeTemp = 170
+	ret.Hotend = MakeHeater(4, 2)
+	ret.Bed = MakeHeater(1, 0.3)
+	ret.Rapid = 9000
+	ret.Mesh = MakeBedMesh(&Point{}, ret.bedHi, 3, 3)
+	ret.ProbeTime = 3
+	ret.RetractLength = 0.8
+	ret.RetractFeed = 2100
+	ret.Accel = 1000
+	ret.TravelAccel = 1500
+	ret.RetractAccel = 1000
+	ret.Jerk = 8
+	ret.FeedOverride = 1
+	ret.FlowOverride = 1
+
+	ret.head = &SimpleHead{
+		pos:    &Point{},
+		path:   make([]*Point, 0),
+		curVel: Still(),
+		cutter: DefaultCutter(),
+	}
+	return ret
+}
+
+//
+// Cnc
+//
+
+func (p *Printer) Type() int {
+	return CncPrinter
+}
+
+func (p *Printer) Axis() []int {
+	return []int{3}
+}
+
+func (p *Printer) ZeroPoint() *Point {
+	return p.zero
+}
+
+func (p *Printer) Head() Head {
+	return p.head
+}
+
+func (p *Printer) FeedRate() float64 {
+	return p.feed
+}
+
+func (p *Printer) AssignFeedRate(f float64) {
+	p.feed = f
+}
+
+func (p *Printer) FastFeedRate() float64 {
+	return p.Rapid
+}
+
+func (p *Printer) FeedMode(mode int) {
+	p.feedMode = mode
+}
+
+func (p *Printer) CurrentFeedMode() int {
+	return p.feedMode
+}
+
+// Spindle
+// There is no spindle, it is never started.
+func (p *Printer) Spindle() *Spindle {
+	return p.spindle
+}
+
+func (p *Printer) CurrentSpindleSpeed() float64 {
+	return 0
+}
+
+func (p *Printer) Outputs() *Outputs {
+	return p.outputs
+}
+
+func (p *Printer) Kinematics() *Kinematics {
+	return p.kinematics
+}
+
+// SetKinematics
+// A printer has no rotaries, there is nothing to set.
+func (p *Printer) SetKinematics(k *Kinematics) {
+}
+
+func (p *Printer) ToolChangeTo(tool int64) {
+	p.curTool = tool
+}
+
+func (p *Printer) CurrentTool() int64 {
+	return p.curTool
+}
+
+func (p *Printer) Tools() *ToolTable {
+	return p.tools
+}
+
+func (p *Printer) SpindleNose() *Nose {
+	return p.nose
+}
+
+func (p *Printer) SelectPlane(plane int) {
+	p.plane = plane
+}
+
+func (p *Printer) Plane() int {
+	return p.plane
+}
+
+func (p *Printer) WorkVolume() Volume {
+	return p.workVolume
+}
+
+func (p *Printer) Material() Material {
+	return p.material
+}
+
+func (p *Printer) Units(units int) {
+	p.units = units
+}
+
+func (p *Printer) Reset() {
+	p.zero = &Point{}
+	p.plane = PLANE_XY
+	p.spindle.Reset()
+	p.outputs.Reset()
+	p.feedMode = FEED_PER_MINUTE
+	p.feed = p.FastFeedRate()
+	p.units = UNIT_MM
+	p.Hotend.Reset()
+	p.Bed.Reset()
+	p.Fan = 0
+	p.relative = false
+	p.retracted = false
+	p.Leveling = false
+	p.FeedOverride = 1
+	p.FlowOverride = 1
+	p.e = 0
+	p.layer = 0
+	p.below = 0
+	p.head.Reset(p.zero)
+}
+
+//
+// Extruder
+//
+
+// ExtrudeRelative
+// M83 when true, M82 when false.
+func (p *Printer) ExtrudeRelative(relative bool) {
+	p.relative = relative
+}
+
+func (p *Printer) Relative() bool {
+	return p.relative
+}
+
+// Extrude
+// The E word, returns how much filament is fed, negative when it is
+// pulled back.
+func (p *Printer) Extrude(e float64) float64 {
+	if p.relative {
+		p.e += e
+		return e
+	}
+	ret := e - p.e
+	p.e = e
+	return ret
+}
+
+// SetE
+// G92 E, the filament is now at e.
+func (p *Printer) SetE(e float64) {
+	p.e = e
+}
+
+func (p *Printer) E() float64 {
+	return p.e
+}
+
+// FilamentArea
+// The cross section of the filament in mm2, the volume fed per mm of
+// E.
+func (p *Printer) FilamentArea() float64 {
+	return math.Pi * p.Filament * p.Filament / 4
+}
+
+// LayerHeight
+// The height of a bead laid with the nozzle at z, down to the layer
+// under it.  A bead laid higher than the last starts a new layer, the
+// first is on the bed at Z0.
+func (p *Printer) LayerHeight(z float64) float64 {
+	if z > p.layer+1e-6 {
+		p.below = p.layer
+		p.layer = z
+	}
+	if h := z - p.below; h > 1e-6 {
+		return h
+	}
+	return p.layer - p.below
+}
+
+// Retract
+// G10 when true and G11 when false, returns the filament fed, 0 when
+// it is already there.
+func (p *Printer) Retract(retract bool) float64 {
+	if p.retracted == retract {
+		return 0
+	}
+	p.retracted = retract
+	if retract {
+		return -p.RetractLength
+	}
+	return p.RetractLength
+}
+
+// MoveTime
+// Seconds to move length mm at feed mm/min, speeding up from the jerk
+// and slowing to it again at the end.  A short move never reaches the
+// feed.
+func (p *Printer) MoveTime(length float64, feed float64, accel float64) float64 {
+	return MoveTime(length, feed, accel, p.Jerk)
+}
+
+// MoveTime
+// Seconds to move length mm at feed mm/min, speeding up at accel mm/s2
+// from v0 mm/s and slowing to it again at the end.  No accel is
+// instant.
+func MoveTime(length float64, feed float64, accel float64, v0 float64) float64 {
+	v := feed / 60
+	if v <= 0 || length <= 0 {
+		return 0
+	}
+	v0 = math.Min(v0, v)
+	if accel <= 0 || v0 == v {
+		return length / v
+	}
+	ramp := (v*v - v0*v0) / accel // both ramps
+	if ramp >= length {
+		top := math.Sqrt(v0*v0 + accel*length)
+		return 2 * (top - v0) / accel
+	}
+	return 2*(v-v0)/accel + (length-ramp)/v
+}
+
+// BedBounds
+// The corners of the bed and the most height printed.
+func (p *Printer) BedBounds() (*Point, *Point) {
+	return &Point{}, p.bedHi
+}
+
+// Bead
+// Plastic laid from fr to to with the nozzle at its top, an obround
+// Width wide and Height high swept along the line.
+type Bead struct {
+	fr     *Point
+	to     *Point
+	Width  float64
+	Height float64
+}
+
+// MakeBead
+// The bead holding volume mm3 along the line at the layer height.
+// The width spreads to fit the volume, but is never under the height.
+func MakeBead(fr *Point, to *Point, volume float64, height float64) *Bead {
+	ret := &Bead{fr: fr, to: to, Height: height}
+	area := 0.0
+	if l := fr.Dist(to); l > 0 {
+		area = volume / l
+	}
+	round := math.Pi * height * height / 4
+	if area < round || height <= 0 {
+		ret.Height = math.Sqrt(4 * area / math.Pi)
+		ret.Width = ret.Height
+		return ret
+	}
+	ret.Width = (area-round)/height + height
+	return ret
+}
+
+func (b *Bead) Distance(p *Point) float64 {
+	dx, dy := b.to.X-b.fr.X, b.to.Y-b.fr.Y
+	l2 := dx*dx + dy*dy
+	t := 0.0
+	if l2 > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-b.fr.X)*dx+(p.Y-b.fr.Y)*dy)/l2))
+	}
+	across := math.Hypot(p.X-(b.fr.X+t*dx), p.Y-(b.fr.Y+t*dy))
+	mid := b.fr.Z + t*(b.to.Z-b.fr.Z) - b.Height/2
+	return math.Hypot(math.Max(0, across-(b.Width-b.Height)/2), p.Z-mid) - b.Height/2
+}
+
+func (b *Bead) Bounds() (*Point, *Point) {
+	w := b.Width / 2
+	lo := pointMin(b.fr, b.to)
+	hi := pointMax(b.fr, b.to)
+	return &Point{X: lo.X - w, Y: lo.Y - w, Z: lo.Z - b.Height},
+		&Point{X: hi.X + w, Y: hi.Y + w, Z: hi.Z}
+}
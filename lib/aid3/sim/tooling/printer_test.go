@@ -0,0 +1,85 @@
+package tooling
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExtrude(t *testing.T) {
+	p := BuildPrinter(MakeWood(15), 200, 200, 200)
+	p.Reset()
+	if fed := p.Extrude(2); fed != 2 {
+		t.Errorf("Absolute E2 → Expected: 2, Got: %v", fed)
+	}
+	if fed := p.Extrude(1.5); fed != -0.5 {
+		t.Errorf("Absolute E1.5 → Expected a retraction of 0.5, Got: %v", fed)
+	}
+	p.SetE(0)
+	p.ExtrudeRelative(true)
+	if fed := p.Extrude(1); fed != 1 || p.E() != 1 {
+		t.Errorf("Relative E1 → Expected: 1 at E1, Got: %v at E%v", fed, p.E())
+	}
+}
+
+func TestHeater(t *testing.T) {
+	h := MakeHeater(4, 2)
+	h.Set(200, 10)
+	if at := h.ReadyAt(); at != 55 {
+		t.Errorf("Heating from 20 to 200 → Expected ready at 55s, Got: %v", at)
+	}
+	if temp := h.Temp(20); temp != 60 {
+		t.Errorf("After 10s → Expected: 60, Got: %v", temp)
+	}
+	h.Set(0, 100)
+	if temp := h.Temp(110); temp != 180 {
+		t.Errorf("Cooling for 10s → Expected: 180, Got: %v", temp)
+	}
+}
+
+func TestBead(t *testing.T) {
+	fr := &Point{X: 1, Y: 2, Z: 0.2}
+	to := &Point{X: 11, Y: 2, Z: 0.2}
+	volume := 10 * 0.45 * 0.2
+	b := MakeBead(fr, to, volume, 0.2)
+	if b.Width < 0.45 || b.Width > 0.5 {
+		t.Errorf("Bead width → Expected: about 0.47, Got: %v", b.Width)
+	}
+
+	s := MakeEmptyStock(&Point{}, &Point{X: 12, Y: 4, Z: 1}, 0.02)
+	laid := s.Deposit(b)
+	if math.Abs(laid-volume)/volume > 0.1 {
+		t.Errorf("Bead in cells → Expected: %3.3f, Got: %3.3f", volume, laid)
+	}
+	if !s.Filled(&Point{X: 6, Y: 2, Z: 0.1}) || s.Filled(&Point{X: 6, Y: 2, Z: 0.3}) {
+		t.Errorf("Bead → Expected under the nozzle only")
+	}
+}
+
+func TestBedMesh(t *testing.T) {
+	m := MakeBedMesh(&Point{}, &Point{X: 200, Y: 200}, 3, 3)
+	m.Set(2, 2, 0.4)
+	if z := m.At(150, 150); math.Abs(z-0.1) > 1e-9 {
+		t.Errorf("Between points → Expected: 0.1, Got: %v", z)
+	}
+	if z := m.At(250, 250); math.Abs(z-0.4) > 1e-9 {
+		t.Errorf("Past the edge → Expected: 0.4, Got: %v", z)
+	}
+	m.Fade = 10
+	if z := m.Compensation(&Point{X: 200, Y: 200, Z: 5}); math.Abs(z-0.2) > 1e-9 {
+		t.Errorf("Half way to the fade → Expected: 0.2, Got: %v", z)
+	}
+	if m.Set(3, 0, 1) {
+		t.Errorf("Off the mesh → Expected not set")
+	}
+}
+
+func TestMoveTime(t *testing.T) {
+	p := BuildPrinter(MakeWood(15), 200, 200, 200)
+	p.Jerk = 0
+	if d := p.MoveTime(100, 6000, 1000); math.Abs(d-1.1) > 1e-9 {
+		t.Errorf("100 mm at 100 mm/s → Expected: 1.1s, Got: %v", d)
+	}
+	if d := p.MoveTime(1, 6000, 1000); math.Abs(d-2*math.Sqrt(0.001)) > 1e-9 {
+		t.Errorf("Too short for the feed → Expected: %v, Got: %v", 2*math.Sqrt(0.001), d)
+	}
+}
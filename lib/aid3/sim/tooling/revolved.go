@@ -0,0 +1,233 @@
+package tooling
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// RevolvedStock
+// Turned material as a grid of cells in radius and Z, each cell a ring
+// about the spindle axis.  A cell is tested at its center.
+type RevolvedStock struct {
+	zlo    float64
+	cell   float64
+	nr     int
+	nz     int
+	filled []bool
+}
+
+// MakeRevolvedStock
+// Bar stock of the radius from zlo to zhi, with a bore when bore is
+// more than 0.
+func MakeRevolvedStock(radius float64, bore float64, zlo float64, zhi float64, cell float64) *RevolvedStock {
+	rs := &RevolvedStock{
+		zlo:  math.Min(zlo, zhi),
+		cell: cell,
+		nr:   int(math.Max(1, math.Ceil(radius/cell))),
+		nz:   int(math.Max(1, math.Ceil(math.Abs(zhi-zlo)/cell))),
+	}
+	rs.filled = make([]bool, rs.nr*rs.nz)
+	for k := 0; k < rs.nz; k++ {
+		for i := 0; i < rs.nr; i++ {
+			rs.filled[rs.index(i, k)] = rs.radius(i) >= bore
+		}
+	}
+	return rs
+}
+
+func (rs *RevolvedStock) index(i int, k int) int {
+	return k*rs.nr + i
+}
+
+func (rs *RevolvedStock) radius(i int) float64 {
+	return (float64(i) + 0.5) * rs.cell
+}
+
+func (rs *RevolvedStock) z(k int) float64 {
+	return rs.zlo + (float64(k)+0.5)*rs.cell
+}
+
+func (rs *RevolvedStock) Cell() float64 {
+	return rs.cell
+}
+
+// ring
+// The volume of the ring of one cell.
+func (rs *RevolvedStock) ring(i int) float64 {
+	return 2 * math.Pi * rs.radius(i) * rs.cell * rs.cell
+}
+
+func (rs *RevolvedStock) Volume() float64 {
+	ret := 0.0
+	for k := 0; k < rs.nz; k++ {
+		for i := 0; i < rs.nr; i++ {
+			if rs.filled[rs.index(i, k)] {
+				ret += rs.ring(i)
+			}
+		}
+	}
+	return ret
+}
+
+// Cut
+// Remove every ring whose center is inside the sweep.  The removal
+// bounds have the radius in X.
+func (rs *RevolvedStock) Cut(sw *InsertSweep) *Removal {
+	ret := &Removal{}
+	xlo, zlo, xhi, zhi := sw.Bounds()
+	i0 := int(math.Max(0, math.Floor(xlo/rs.cell)))
+	i1 := int(math.Min(float64(rs.nr), math.Ceil(xhi/rs.cell)))
+	k0 := int(math.Max(0, math.Floor((zlo-rs.zlo)/rs.cell)))
+	k1 := int(math.Min(float64(rs.nz), math.Ceil((zhi-rs.zlo)/rs.cell)))
+	for k := k0; k < k1; k++ {
+		for i := i0; i < i1; i++ {
+			idx := rs.index(i, k)
+			if !rs.filled[idx] {
+				continue
+			}
+			c := &Point{X: rs.radius(i), Z: rs.z(k)}
+			if sw.Distance(c.X, c.Z) >= 0 {
+				continue
+			}
+			rs.filled[idx] = false
+			ret.Cells++
+			ret.Volume += rs.ring(i)
+			if ret.Min == nil {
+				ret.Min = &Point{X: c.X, Z: c.Z}
+				ret.Max = &Point{X: c.X, Z: c.Z}
+			}
+			ret.Min = pointMin(ret.Min, c)
+			ret.Max = pointMax(ret.Max, c)
+		}
+	}
+	return ret
+}
+
+// Profile
+// The outside and inside radius of the part at each Z, from the low
+// Z end.  Both are 0 where the part has been cut off, the inside is 0
+// where there is no bore.
+func (rs *RevolvedStock) Profile() []*TurnedSection {
+	ret := make([]*TurnedSection, 0, rs.nz)
+	for k := 0; k < rs.nz; k++ {
+		sec := &TurnedSection{Z: rs.z(k)}
+		first := -1
+		for i := 0; i < rs.nr; i++ {
+			if rs.filled[rs.index(i, k)] {
+				if first < 0 {
+					first = i
+				}
+				sec.Outside = float64(i+1) * rs.cell
+			}
+		}
+		if first > 0 {
+			sec.Inside = float64(first) * rs.cell
+		}
+		ret = append(ret, sec)
+	}
+	return ret
+}
+
+// TurnedSection
+// One slice of the turned part.
+type TurnedSection struct {
+	Z       float64
+	Outside float64
+	Inside  float64
+}
+
+// WriteProfile
+// The profile as CSV, Z with the outside and inside diameters.
+func (rs *RevolvedStock) WriteProfile(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "z,outside_diameter,inside_diameter"); err != nil {
+		return err
+	}
+	for _, sec := range rs.Profile() {
+		if _, err := fmt.Fprintf(w, "%.4f,%.4f,%.4f\n", sec.Z, 2*sec.Outside, 2*sec.Inside); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mesh
+// The part as a closed mesh, each section a band of the outside and
+// inside surfaces, with rings where the radius steps.
+func (rs *RevolvedStock) Mesh() *Mesh {
+	ret := &Mesh{}
+	at := func(r float64, z float64, s int) *Point {
+		a := 2 * math.Pi * float64(s) / revolveSteps
+		return &Point{X: r * math.Cos(a), Y: r * math.Sin(a), Z: z}
+	}
+	// the flat ring from r0 out to r1 at z, facing +Z when up
+	ring := func(r0 float64, r1 float64, z float64, up bool) {
+		for s := 0; s < revolveSteps; s++ {
+			a, b := at(r0, z, s), at(r0, z, s+1)
+			c, d := at(r1, z, s+1), at(r1, z, s)
+			if up {
+				ret.AddTriangle(a, d, c)
+				if r0 > 0 {
+					ret.AddTriangle(a, c, b)
+				}
+			} else {
+				ret.AddTriangle(a, c, d)
+				if r0 > 0 {
+					ret.AddTriangle(a, b, c)
+				}
+			}
+		}
+	}
+	// the cylinder at r from z0 to z1, facing away from the axis when out
+	band := func(r float64, z0 float64, z1 float64, out bool) {
+		if r == 0 {
+			return
+		}
+		for s := 0; s < revolveSteps; s++ {
+			a, b := at(r, z0, s), at(r, z0, s+1)
+			c, d := at(r, z1, s+1), at(r, z1, s)
+			if out {
+				ret.AddTriangle(a, b, c)
+				ret.AddTriangle(a, c, d)
+			} else {
+				ret.AddTriangle(a, c, b)
+				ret.AddTriangle(a, d, c)
+			}
+		}
+	}
+	// between two sections, what only the lower has faces +Z and what
+	// only the upper has faces -Z
+	step := func(lower *TurnedSection, upper *TurnedSection, z float64) {
+		radii := []float64{lower.Inside, lower.Outside, upper.Inside, upper.Outside}
+		sort.Float64s(radii)
+		in := func(sec *TurnedSection, r float64) bool {
+			return sec.Outside > 0 && r > sec.Inside && r < sec.Outside
+		}
+		for n := 0; n+1 < len(radii); n++ {
+			r0, r1 := radii[n], radii[n+1]
+			if r1 <= r0 {
+				continue
+			}
+			mid := (r0 + r1) / 2
+			if in(lower, mid) && !in(upper, mid) {
+				ring(r0, r1, z, true)
+			} else if in(upper, mid) && !in(lower, mid) {
+				ring(r0, r1, z, false)
+			}
+		}
+	}
+
+	half := rs.cell / 2
+	prev := &TurnedSection{}
+	for _, sec := range rs.Profile() {
+		step(prev, sec, sec.Z-half)
+		if sec.Outside > 0 {
+			band(sec.Outside, sec.Z-half, sec.Z+half, true)
+			band(sec.Inside, sec.Z-half, sec.Z+half, false)
+		}
+		prev = sec
+	}
+	step(prev, &TurnedSection{}, prev.Z+half)
+	return ret
+}
@@ -0,0 +1,50 @@
+package tooling
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTurnDiameter(t *testing.T) {
+	rs := MakeRevolvedStock(20, 0, -50, 0, 0.25)
+	full := rs.Volume()
+	expected := math.Pi * 20 * 20 * 50
+	if math.Abs(full-expected)/expected > 0.01 {
+		t.Errorf("Bar volume → Expected: %3.1f, Got: %3.1f", expected, full)
+	}
+
+	// one pass from radius 20 down to radius 15, 30 long
+	in := DefaultInsert()
+	rs.Cut(SweepInsert(in, &Point{X: 15, Z: 2}, &Point{X: 15, Z: -30}))
+
+	expected = math.Pi * (20*20 - 15*15) * 30
+	removed := full - rs.Volume()
+	if math.Abs(removed-expected)/expected > 0.05 {
+		t.Errorf("Turned away → Expected: %3.1f, Got: %3.1f", expected, removed)
+	}
+
+	for _, sec := range rs.Profile() {
+		if sec.Z > -29 && math.Abs(sec.Outside-15) > 0.5 {
+			t.Errorf("Profile at Z%3.2f → Expected radius 15, Got: %3.2f", sec.Z, sec.Outside)
+			break
+		}
+		if sec.Z < -32 && sec.Outside != 20 {
+			t.Errorf("Profile at Z%3.2f → Expected radius 20, Got: %3.2f", sec.Z, sec.Outside)
+			break
+		}
+	}
+}
+
+func TestInsertNose(t *testing.T) {
+	in := DefaultInsert()
+	// the nose is tangent to X and Z at the controlled point
+	if d := in.Distance(in.NoseRadius, 0); math.Abs(d) > 1e-9 {
+		t.Errorf("Nose on X → Expected: 0, Got: %v", d)
+	}
+	if d := in.Distance(0, in.NoseRadius); math.Abs(d) > 1e-9 {
+		t.Errorf("Nose on Z → Expected: 0, Got: %v", d)
+	}
+	if d := in.Distance(0, 0); d <= 0 {
+		t.Errorf("Imaginary tip → Expected outside the insert, Got: %v", d)
+	}
+}
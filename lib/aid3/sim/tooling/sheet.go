@@ -0,0 +1,115 @@
+package tooling
+
+import (
+	"math"
+)
+
+// SheetStock
+// A flat sheet as a grid of cells in XY, each through the thickness
+// of the sheet.  A cell is tested at its center.
+type SheetStock struct {
+	lo        *Point
+	cell      float64
+	nx        int
+	ny        int
+	thickness float64
+	filled    []bool
+}
+
+// MakeSheetStock
+// The sheet between the corners, as thick as they are apart in Z.
+func MakeSheetStock(fr *Point, to *Point, cell float64) *SheetStock {
+	lo := pointMin(fr, to)
+	hi := pointMax(fr, to)
+	ss := &SheetStock{
+		lo:        lo,
+		cell:      cell,
+		nx:        int(math.Max(1, math.Ceil((hi.X-lo.X)/cell))),
+		ny:        int(math.Max(1, math.Ceil((hi.Y-lo.Y)/cell))),
+		thickness: hi.Z - lo.Z,
+	}
+	ss.filled = make([]bool, ss.nx*ss.ny)
+	for i := range ss.filled {
+		ss.filled[i] = true
+	}
+	return ss
+}
+
+func (ss *SheetStock) Cell() float64 {
+	return ss.cell
+}
+
+func (ss *SheetStock) Thickness() float64 {
+	return ss.thickness
+}
+
+func (ss *SheetStock) center(i int, j int) *Point {
+	return &Point{
+		X: ss.lo.X + (float64(i)+0.5)*ss.cell,
+		Y: ss.lo.Y + (float64(j)+0.5)*ss.cell,
+	}
+}
+
+func (ss *SheetStock) Filled(p *Point) bool {
+	i := int(math.Floor((p.X - ss.lo.X) / ss.cell))
+	j := int(math.Floor((p.Y - ss.lo.Y) / ss.cell))
+	if i < 0 || j < 0 || i >= ss.nx || j >= ss.ny {
+		return false
+	}
+	return ss.filled[j*ss.nx+i]
+}
+
+func (ss *SheetStock) Volume() float64 {
+	n := 0
+	for _, f := range ss.filled {
+		if f {
+			n++
+		}
+	}
+	return float64(n) * ss.cell * ss.cell * ss.thickness
+}
+
+// Cut
+// Remove the cells within half the kerf of the line from fr to to,
+// in XY.
+func (ss *SheetStock) Cut(fr *Point, to *Point, kerf float64) *Removal {
+	ret := &Removal{}
+	half := kerf / 2
+	i0 := int(math.Max(0, math.Floor((math.Min(fr.X, to.X)-half-ss.lo.X)/ss.cell)))
+	i1 := int(math.Min(float64(ss.nx), math.Ceil((math.Max(fr.X, to.X)+half-ss.lo.X)/ss.cell)))
+	j0 := int(math.Max(0, math.Floor((math.Min(fr.Y, to.Y)-half-ss.lo.Y)/ss.cell)))
+	j1 := int(math.Min(float64(ss.ny), math.Ceil((math.Max(fr.Y, to.Y)+half-ss.lo.Y)/ss.cell)))
+	top := ss.lo.Z + ss.thickness
+	for j := j0; j < j1; j++ {
+		for i := i0; i < i1; i++ {
+			idx := j*ss.nx + i
+			if !ss.filled[idx] {
+				continue
+			}
+			c := ss.center(i, j)
+			if segmentDistanceXY(fr, to, c) > half {
+				continue
+			}
+			ss.filled[idx] = false
+			ret.Cells++
+			ret.Volume += ss.cell * ss.cell * ss.thickness
+			if ret.Min == nil {
+				ret.Min = &Point{X: c.X, Y: c.Y, Z: ss.lo.Z}
+				ret.Max = &Point{X: c.X, Y: c.Y, Z: top}
+			}
+			ret.Min = pointMin(ret.Min, &Point{X: c.X, Y: c.Y, Z: ss.lo.Z})
+			ret.Max = pointMax(ret.Max, &Point{X: c.X, Y: c.Y, Z: top})
+		}
+	}
+	return ret
+}
+
+func segmentDistanceXY(a *Point, b *Point, p *Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	l2 := dx*dx + dy*dy
+	t := 0.0
+	if l2 > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/l2))
+	}
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
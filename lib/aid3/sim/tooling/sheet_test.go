@@ -0,0 +1,41 @@
+package tooling
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSheetKerf(t *testing.T) {
+	ss := MakeSheetStock(&Point{Z: -3}, &Point{X: 20, Y: 20}, 0.05)
+	full := ss.Volume()
+	if math.Abs(full-20*20*3) > 1e-6 {
+		t.Errorf("Sheet volume → Expected: 1200, Got: %3.3f", full)
+	}
+
+	r := ss.Cut(&Point{X: 5, Y: 10}, &Point{X: 15, Y: 10}, 0.2)
+	expected := 10 * 0.2 * 3.
+	if math.Abs(r.Volume-expected)/expected > 0.1 {
+		t.Errorf("Kerf → Expected: %3.3f, Got: %3.3f", expected, r.Volume)
+	}
+	if math.Abs(full-ss.Volume()-r.Volume) > 1e-9 {
+		t.Errorf("Sheet left → Expected: %3.3f, Got: %3.3f", full-r.Volume, ss.Volume())
+	}
+	if ss.Filled(&Point{X: 10, Y: 10}) || !ss.Filled(&Point{X: 10, Y: 10.5}) {
+		t.Errorf("Kerf → Expected cut on the line only")
+	}
+}
+
+func TestJunctionSpeed(t *testing.T) {
+	b := BuildLaser(MakeWood(15), 100, 100, 3)
+	x := &Point{X: 1}
+	y := &Point{Y: 1}
+	if v := b.JunctionSpeed(x, x, 25); v != 25 {
+		t.Errorf("Straight on → Expected: 25, Got: %v", v)
+	}
+	if v := b.JunctionSpeed(x, &Point{X: -1}, 25); v != 0 {
+		t.Errorf("Reversing → Expected: 0, Got: %v", v)
+	}
+	if v := b.JunctionSpeed(x, y, 25); v <= 0 || v >= 25 {
+		t.Errorf("Square corner → Expected under the feed, Got: %v", v)
+	}
+}
@@ -1,39 +1,53 @@
 package tooling
 
 // Simple3d
-// This simulates a 3-axis head tool
+// This simulates a 3-axis head tool, with rotaries from its kinematics
 // Each increment in the simulation
 // is a position change, represented
 // in the tool head as a list of visited
 // points.
+// Rapid is the G0 feed in mm/min.
 type Simple3d struct {
-	head         Head
-	zero         *Point
-	feed         float64
-	feedMode     int
-	spindleSpeed int64
-	curTool      int64
-	plane        int
-	units        int
-	workVolume   Volume
-	material     Material
+	Rapid float64
+
+	head       Head
+	zero       *Point
+	feed       float64
+	feedMode   int
+	spindle    *Spindle
+	outputs    *Outputs
+	kinematics *Kinematics
+	curTool    int64
+	tools      *ToolTable
+	nose       *Nose
+	plane      int
+	units      int
+	workVolume Volume
+	material   Material
 }
 
 type SimpleHead struct {
 	pos    *Point
 	path   []*Point
 	curVel *Velocity
+	cutter *Cutter
 }
 
 func BuildCnc(m Material) Cnc {
-	ret := &Simple3d{}
+	ret := &Simple3d{Rapid: 1000}
 	ret.workVolume = MakeVolume(&Point{X: -20, Y: -20, Z: -20}, &Point{X: 20, Y: 20, Z: 20})
 	ret.material = m
+	ret.tools = MakeToolTable()
+	ret.nose = DefaultNose()
+	ret.spindle = MakeSpindle()
+	ret.outputs = MakeOutputs()
+	ret.kinematics = MakeThreeAxis()
 
 	head := &SimpleHead{
-		pos:    &Point{0, 0, 0},
+		pos:    &Point{X: 0, Y: 0, Z: 0},
 		path:   make([]*Point, 0),
 		curVel: Still(),
+		cutter: DefaultCutter(),
 	}
 
 	ret.head = head
@@ -45,6 +59,10 @@ func BuildCnc(m Material) Cnc {
 // Cnc
 //
 
+func (s3d *Simple3d) Type() int {
+	return CncMilling
+}
+
 func (s3d *Simple3d) FeedRate() float64 {
 	return s3d.feed
 }
@@ -55,7 +73,7 @@ func (s3d *Simple3d) AssignFeedRate(f float64) {
 
 func (s3d *Simple3d) Axis() []int {
 	ret := make([]int, 1)
-	ret[0] = 3
+	ret[0] = s3d.kinematics.AxisCount()
 	return ret
 }
 
@@ -68,19 +86,63 @@ func (s3d *Simple3d) Head() Head {
 }
 
 func (s3d *Simple3d) FastFeedRate() float64 {
-	return 1000
+	return s3d.Rapid
 }
 
 func (s3d *Simple3d) FeedMode(mode int) {
 	s3d.feedMode = mode
 }
 
-func (s3d *Simple3d) SpindleSpeed(speed int64) {
-	s3d.spindleSpeed = speed
+func (s3d *Simple3d) CurrentFeedMode() int {
+	return s3d.feedMode
+}
+
+func (s3d *Simple3d) Spindle() *Spindle {
+	return s3d.spindle
+}
+
+// CurrentSpindleSpeed
+// The commanded RPM, the spindle may still be getting there.
+func (s3d *Simple3d) CurrentSpindleSpeed() float64 {
+	return s3d.spindle.Commanded
+}
+
+func (s3d *Simple3d) Outputs() *Outputs {
+	return s3d.outputs
+}
+
+func (s3d *Simple3d) Kinematics() *Kinematics {
+	return s3d.kinematics
+}
+
+// SetKinematics
+// Add rotaries to the machine, the 3 linear axes are always there.
+func (s3d *Simple3d) SetKinematics(k *Kinematics) {
+	s3d.kinematics = k
 }
 
+// ToolChangeTo
+// Mount the cutter from the tool table, or the default
+// cutter when the tool is not in the table.
 func (s3d *Simple3d) ToolChangeTo(tool int64) {
 	s3d.curTool = tool
+	c := s3d.tools.Lookup(tool)
+	if c == nil {
+		c = DefaultCutter()
+	}
+	s3d.head.Mount(c)
+}
+
+func (s3d *Simple3d) CurrentTool() int64 {
+	return s3d.curTool
+}
+
+func (s3d *Simple3d) Tools() *ToolTable {
+	return s3d.tools
+}
+
+func (s3d *Simple3d) SpindleNose() *Nose {
+	return s3d.nose
 }
 
 func (s3d *Simple3d) SelectPlane(plane int) {
@@ -101,7 +163,8 @@ func (s3d *Simple3d) Material() Material {
 func (s3d *Simple3d) Reset() {
 	s3d.zero = &Point{}
 	s3d.plane = PLANE_XY
-	s3d.spindleSpeed = 0
+	s3d.spindle.Reset()
+	s3d.outputs.Reset()
 	s3d.feedMode = FEED_PER_MINUTE
 	s3d.feed = s3d.FastFeedRate()
 	s3d.units = UNIT_MM
@@ -151,18 +214,16 @@ func (h *SimpleHead) MarkVelocity(fr *Point, to *Point) {
 	h.curVel.Z = fr.Z - to.Z
 }
 
+// Shape
+// The mesh of the mounted cutter, with the tip at the origin.
 func (h *SimpleHead) Shape() *Mesh {
-	ret := &Mesh{}
-	var p1, p2, p3, p4 *Point
-	p1 = &Point{1, 0, 0}
-	p2 = &Point{-1, 0, 0}
-	p3 = &Point{0, 1, 0}
-	p3 = &Point{0, 0, 1}
-
-	ret.AddTriangle(p1, p2, p3) // bottom side
-	ret.AddTriangle(p4, p3, p1) // right side
-	ret.AddTriangle(p4, p2, p3) // left side
-	ret.AddTriangle(p4, p1, p2) // back side
+	return h.cutter.Mesh()
+}
 
-	return ret
+func (h *SimpleHead) Mount(c *Cutter) {
+	h.cutter = c
+}
+
+func (h *SimpleHead) Cutter() *Cutter {
+	return h.cutter
 }
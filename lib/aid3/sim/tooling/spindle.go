@@ -0,0 +1,177 @@
+package tooling
+
+import (
+	"math"
+)
+
+const (
+	SPINDLE_STOPPED = iota
+	SPINDLE_CW
+	SPINDLE_CCW
+	SPINDLE_ORIENTED
+)
+
+// GearRange
+// The speeds in RPM a spindle gear can turn.
+type GearRange struct {
+	Min float64
+	Max float64
+}
+
+// Spindle
+// The spindle as commanded and as it is turning.  Times are seconds
+// of program time, RPM is signed, positive for clockwise.
+// Accel is how fast the spindle changes speed in RPM per second.
+// OrientTime is how long M19 takes once the spindle has stopped.
+// Gears are the ranges for M41, M42 and on, Gear the one in use.
+// Programmed is the last S word, Commanded that clamped to the gear.
+type Spindle struct {
+	Direction  int
+	Programmed float64
+	Commanded  float64
+	Orient     float64
+	Accel      float64
+	OrientTime float64
+	Gears      []GearRange
+	Gear       int
+
+	from    float64
+	changed float64
+}
+
+// MakeSpindle
+// A router spindle, one gear to 24000 RPM reached in about 4s.
+func MakeSpindle() *Spindle {
+	return &Spindle{
+		Accel:      6000,
+		OrientTime: 0.5,
+		Gears:      []GearRange{{Min: 0, Max: 24000}},
+	}
+}
+
+// Reset
+// Stopped, in the first gear.
+func (sp *Spindle) Reset() {
+	sp.Direction = SPINDLE_STOPPED
+	sp.Programmed = 0
+	sp.Commanded = 0
+	sp.Orient = 0
+	sp.Gear = 0
+	sp.from = 0
+	sp.changed = 0
+}
+
+// Rpm
+// The signed speed the spindle is actually turning at the time.
+func (sp *Spindle) Rpm(at float64) float64 {
+	target := sp.target()
+	if sp.Accel <= 0 || at <= sp.changed {
+		if sp.Accel <= 0 {
+			return target
+		}
+		return sp.from
+	}
+	step := sp.Accel * (at - sp.changed)
+	if math.Abs(target-sp.from) <= step {
+		return target
+	}
+	if target > sp.from {
+		return sp.from + step
+	}
+	return sp.from - step
+}
+
+// ReadyAt
+// The time the spindle reaches the commanded speed.
+func (sp *Spindle) ReadyAt() float64 {
+	if sp.Accel <= 0 {
+		return sp.changed
+	}
+	ret := sp.changed + math.Abs(sp.target()-sp.from)/sp.Accel
+	if sp.Direction == SPINDLE_ORIENTED {
+		ret += sp.OrientTime
+	}
+	return ret
+}
+
+// AtSpeed
+// True when the spindle is turning as commanded.
+func (sp *Spindle) AtSpeed(at float64) bool {
+	return at >= sp.ReadyAt()
+}
+
+// Start
+// M3 or M4 at the time, spinning up to the commanded speed.
+func (sp *Spindle) Start(direction int, at float64) {
+	sp.change(at)
+	sp.Direction = direction
+}
+
+// Stop
+// M5, spinning down to a stop.
+func (sp *Spindle) Stop(at float64) {
+	sp.change(at)
+	sp.Direction = SPINDLE_STOPPED
+}
+
+// OrientTo
+// M19, stop and hold the spindle at the angle in degrees.
+func (sp *Spindle) OrientTo(angle float64, at float64) {
+	sp.change(at)
+	sp.Direction = SPINDLE_ORIENTED
+	sp.Orient = angle
+}
+
+// Speed
+// The S word, clamped to the range of the gear in use.  Returns
+// false when the speed had to be clamped.
+func (sp *Spindle) Speed(rpm float64, at float64) bool {
+	sp.Programmed = rpm
+	return sp.clamp(at)
+}
+
+// clamp
+// The programmed speed into the range of the gear in use.
+func (sp *Spindle) clamp(at float64) bool {
+	rpm := sp.Programmed
+	sp.change(at)
+	sp.Commanded = rpm
+	if sp.Gear < 0 || sp.Gear >= len(sp.Gears) {
+		return true
+	}
+	g := sp.Gears[sp.Gear]
+	if rpm > g.Max {
+		sp.Commanded = g.Max
+	} else if rpm < g.Min && rpm != 0 {
+		sp.Commanded = g.Min
+	}
+	return sp.Commanded == rpm
+}
+
+// SelectGear
+// Change to a gear range, 0 for the first, the programmed speed
+// clamped again to the new range.  Returns false for a gear the
+// spindle does not have.
+func (sp *Spindle) SelectGear(gear int, at float64) bool {
+	if gear < 0 || gear >= len(sp.Gears) {
+		return false
+	}
+	sp.Gear = gear
+	sp.clamp(at)
+	return true
+}
+
+func (sp *Spindle) change(at float64) {
+	sp.from = sp.Rpm(at)
+	sp.changed = at
+}
+
+func (sp *Spindle) target() float64 {
+	switch sp.Direction {
+	case SPINDLE_CW:
+		return sp.Commanded
+	case SPINDLE_CCW:
+		return -sp.Commanded
+	}
+	return 0
+}
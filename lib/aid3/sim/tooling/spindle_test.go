@@ -0,0 +1,48 @@
+package tooling
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSpindleSpinUp(t *testing.T) {
+	sp := MakeSpindle()
+	sp.Speed(12000, 0)
+	sp.Start(SPINDLE_CW, 1)
+	if rpm := sp.Rpm(2); math.Abs(rpm-6000) > 1e-9 {
+		t.Errorf("One second after M3 → Expected: 6000, Got: %v", rpm)
+	}
+	if sp.AtSpeed(2.5) || !sp.AtSpeed(3) {
+		t.Errorf("At speed → Expected at 3s, Got ready at %v", sp.ReadyAt())
+	}
+
+	// reversing runs down through zero
+	sp.Start(SPINDLE_CCW, 3)
+	if rpm := sp.Rpm(4); math.Abs(rpm-6000) > 1e-9 {
+		t.Errorf("One second after M4 → Expected: 6000, Got: %v", rpm)
+	}
+	if rpm := sp.Rpm(10); rpm != -12000 {
+		t.Errorf("Reversed → Expected: -12000, Got: %v", rpm)
+	}
+}
+
+func TestSpindleGearClamp(t *testing.T) {
+	sp := MakeSpindle()
+	sp.Gears = []GearRange{{Min: 50, Max: 1500}, {Min: 1000, Max: 8000}}
+	if sp.Speed(3000.5, 0) {
+		t.Errorf("S3000.5 in low gear → Expected to be clamped")
+	}
+	if sp.Commanded != 1500 {
+		t.Errorf("Clamped speed → Expected: 1500, Got: %v", sp.Commanded)
+	}
+	// the programmed speed comes back in the gear that turns it
+	if !sp.SelectGear(1, 0) || sp.Commanded != 3000.5 {
+		t.Errorf("S3000.5 after M42 → Expected: 3000.5, Got: %v", sp.Commanded)
+	}
+	if !sp.SelectGear(0, 0) || sp.Commanded != 1500 {
+		t.Errorf("S3000.5 back in low gear → Expected: 1500, Got: %v", sp.Commanded)
+	}
+	if !sp.SelectGear(1, 0) || sp.SelectGear(2, 0) {
+		t.Errorf("Gear select → Expected only gears 0 and 1")
+	}
+}
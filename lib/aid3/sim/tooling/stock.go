@@ -0,0 +1,330 @@
+package tooling
+
+import (
+	"math"
+	"sort"
+)
+
+// Stock
+// The in-process material as a grid of cubic cells, each either
+// filled or cut away.  A cell is tested at its center.
+type Stock struct {
+	lo     *Point
+	cell   float64
+	nx     int
+	ny     int
+	nz     int
+	filled []bool
+}
+
+// Removal
+// What a cut took out of the stock, Min and Max bound the removed
+// cells and are nil when nothing was removed.
+type Removal struct {
+	Volume float64
+	Cells  int
+	Min    *Point
+	Max    *Point
+}
+
+// Add
+// Merge another removal into this one.
+func (r *Removal) Add(o *Removal) {
+	if o == nil || o.Cells == 0 {
+		return
+	}
+	r.Volume += o.Volume
+	r.Cells += o.Cells
+	if r.Min == nil {
+		r.Min = &Point{X: o.Min.X, Y: o.Min.Y, Z: o.Min.Z}
+		r.Max = &Point{X: o.Max.X, Y: o.Max.Y, Z: o.Max.Z}
+		return
+	}
+	r.Min = pointMin(r.Min, o.Min)
+	r.Max = pointMax(r.Max, o.Max)
+}
+
+// MakeStock
+// A solid block from fr to to, with cells of the given size.
+func MakeStock(fr *Point, to *Point, cell float64) *Stock {
+	s := MakeEmptyStock(fr, to, cell)
+	for i := range s.filled {
+		s.filled[i] = true
+	}
+	return s
+}
+
+// MakeEmptyStock
+// A grid from fr to to with nothing in it.
+func MakeEmptyStock(fr *Point, to *Point, cell float64) *Stock {
+	lo := pointMin(fr, to)
+	hi := pointMax(fr, to)
+	s := &Stock{
+		lo:   lo,
+		cell: cell,
+		nx:   int(math.Max(1, math.Ceil((hi.X-lo.X)/cell))),
+		ny:   int(math.Max(1, math.Ceil((hi.Y-lo.Y)/cell))),
+		nz:   int(math.Max(1, math.Ceil((hi.Z-lo.Z)/cell))),
+	}
+	s.filled = make([]bool, s.nx*s.ny*s.nz)
+	return s
+}
+
+func (s *Stock) Clone() *Stock {
+	ret := *s
+	ret.filled = make([]bool, len(s.filled))
+	copy(ret.filled, s.filled)
+	return &ret
+}
+
+func (s *Stock) Cell() float64 {
+	return s.cell
+}
+
+func (s *Stock) Bounds() (*Point, *Point) {
+	return &Point{X: s.lo.X, Y: s.lo.Y, Z: s.lo.Z},
+		&Point{
+			X: s.lo.X + float64(s.nx)*s.cell,
+			Y: s.lo.Y + float64(s.ny)*s.cell,
+			Z: s.lo.Z + float64(s.nz)*s.cell,
+		}
+}
+
+// BoundingBox
+// The size of the grid along each axis.
+func (s *Stock) BoundingBox() *Point {
+	lo, hi := s.Bounds()
+	return &Point{X: hi.X - lo.X, Y: hi.Y - lo.Y, Z: hi.Z - lo.Z}
+}
+
+func (s *Stock) index(i int, j int, k int) int {
+	return (k*s.ny+j)*s.nx + i
+}
+
+func (s *Stock) center(i int, j int, k int) *Point {
+	return &Point{
+		X: s.lo.X + (float64(i)+0.5)*s.cell,
+		Y: s.lo.Y + (float64(j)+0.5)*s.cell,
+		Z: s.lo.Z + (float64(k)+0.5)*s.cell,
+	}
+}
+
+// cellRange
+// The cells which overlap the box, clipped to the grid, the upper
+// values are exclusive.
+func (s *Stock) cellRange(lo *Point, hi *Point) (int, int, int, int, int, int) {
+	clip := func(v float64, n int) int {
+		return int(math.Max(0, math.Min(float64(n), v)))
+	}
+	i0 := clip(math.Floor((lo.X-s.lo.X)/s.cell), s.nx)
+	i1 := clip(math.Ceil((hi.X-s.lo.X)/s.cell), s.nx)
+	j0 := clip(math.Floor((lo.Y-s.lo.Y)/s.cell), s.ny)
+	j1 := clip(math.Ceil((hi.Y-s.lo.Y)/s.cell), s.ny)
+	k0 := clip(math.Floor((lo.Z-s.lo.Z)/s.cell), s.nz)
+	k1 := clip(math.Ceil((hi.Z-s.lo.Z)/s.cell), s.nz)
+	return i0, i1, j0, j1, k0, k1
+}
+
+// Filled
+// True when the cell holding p has material.
+func (s *Stock) Filled(p *Point) bool {
+	i := int(math.Floor((p.X - s.lo.X) / s.cell))
+	j := int(math.Floor((p.Y - s.lo.Y) / s.cell))
+	k := int(math.Floor((p.Z - s.lo.Z) / s.cell))
+	if i < 0 || j < 0 || k < 0 || i >= s.nx || j >= s.ny || k >= s.nz {
+		return false
+	}
+	return s.filled[s.index(i, j, k)]
+}
+
+// Volume
+// The amount of material left.
+func (s *Stock) Volume() float64 {
+	cnt := 0
+	for _, f := range s.filled {
+		if f {
+			cnt++
+		}
+	}
+	return float64(cnt) * s.cell * s.cell * s.cell
+}
+
+// Cut
+// Remove every filled cell whose center is inside f.
+func (s *Stock) Cut(f Implicit) *Removal {
+	ret := &Removal{}
+	inside := func(p *Point) bool {
+		return f.Distance(p) < 0
+	}
+	if sw, ok := f.(*Swept); ok {
+		inside = sw.Inside
+	}
+	lo, hi := f.Bounds()
+	i0, i1, j0, j1, k0, k1 := s.cellRange(lo, hi)
+	for k := k0; k < k1; k++ {
+		for j := j0; j < j1; j++ {
+			for i := i0; i < i1; i++ {
+				idx := s.index(i, j, k)
+				if !s.filled[idx] {
+					continue
+				}
+				c := s.center(i, j, k)
+				if !inside(c) {
+					continue
+				}
+				s.filled[idx] = false
+				ret.Cells++
+				if ret.Min == nil {
+					ret.Min = &Point{X: c.X, Y: c.Y, Z: c.Z}
+					ret.Max = &Point{X: c.X, Y: c.Y, Z: c.Z}
+				}
+				ret.Min = pointMin(ret.Min, c)
+				ret.Max = pointMax(ret.Max, c)
+			}
+		}
+	}
+	ret.Volume = float64(ret.Cells) * s.cell * s.cell * s.cell
+	return ret
+}
+
+// Deposit
+// Fill every empty cell whose center is inside f, as a printer lays
+// down a bead.  Returns the volume added.
+func (s *Stock) Deposit(f Implicit) float64 {
+	lo, hi := f.Bounds()
+	i0, i1, j0, j1, k0, k1 := s.cellRange(lo, hi)
+	cnt := 0
+	for k := k0; k < k1; k++ {
+		for j := j0; j < j1; j++ {
+			for i := i0; i < i1; i++ {
+				idx := s.index(i, j, k)
+				if s.filled[idx] || f.Distance(s.center(i, j, k)) >= 0 {
+					continue
+				}
+				s.filled[idx] = true
+				cnt++
+			}
+		}
+	}
+	return float64(cnt) * s.cell * s.cell * s.cell
+}
+
+// Intersect
+// Keep only the cells also filled in o, a grid of the same size.
+func (s *Stock) Intersect(o *Stock) {
+	for i := range s.filled {
+		s.filled[i] = s.filled[i] && i < len(o.filled) && o.filled[i]
+	}
+}
+
+// Subtract
+// Remove the cells inside the closed mesh.
+func (s *Stock) Subtract(mesh *Mesh) {
+	s.insideMesh(mesh, func(idx int) {
+		s.filled[idx] = false
+	})
+}
+
+// insideMesh
+// Visit the cells whose centers are inside the closed mesh.  Each row
+// of cells along X is crossed with the triangles, and the cells
+// between odd and even crossings are inside.
+func (s *Stock) insideMesh(mesh *Mesh, f func(idx int)) {
+	lo, hi := mesh.Bounds()
+	_, _, j0, j1, k0, k1 := s.cellRange(lo, hi)
+	for k := k0; k < k1; k++ {
+		for j := j0; j < j1; j++ {
+			c := s.center(0, j, k)
+			// just off the cell centers, so a mesh on the grid is never
+			// crossed at its edges and counted twice
+			xs := rowCrossings(mesh, c.Y+s.cell*1.3e-6, c.Z+s.cell*0.7e-6)
+			for n := 0; n+1 < len(xs); n += 2 {
+				i0 := int(math.Max(0, math.Ceil((xs[n]-s.lo.X)/s.cell-0.5)))
+				i1 := int(math.Min(float64(s.nx-1), math.Floor((xs[n+1]-s.lo.X)/s.cell-0.5)))
+				for i := i0; i <= i1; i++ {
+					f(s.index(i, j, k))
+				}
+			}
+		}
+	}
+}
+
+// rowCrossings
+// The sorted X values where the line at y, z crosses the mesh.
+func rowCrossings(mesh *Mesh, y float64, z float64) []float64 {
+	ret := make([]float64, 0)
+	mesh.Triangles(func(a *Point, b *Point, c *Point) {
+		// barycentric test in the YZ projection
+		d := (b.Y-a.Y)*(c.Z-a.Z) - (c.Y-a.Y)*(b.Z-a.Z)
+		if d == 0 {
+			return
+		}
+		u := ((y-a.Y)*(c.Z-a.Z) - (c.Y-a.Y)*(z-a.Z)) / d
+		v := ((b.Y-a.Y)*(z-a.Z) - (y-a.Y)*(b.Z-a.Z)) / d
+		if u < 0 || v < 0 || u+v >= 1 {
+			return
+		}
+		ret = append(ret, a.X+u*(b.X-a.X)+v*(c.X-a.X))
+	})
+	sort.Float64s(ret)
+	return ret
+}
+
+// Mesh
+// The faces between filled and empty cells, as triangles.
+func (s *Stock) Mesh() *Mesh {
+	ret := &Mesh{}
+	filled := func(i int, j int, k int) bool {
+		if i < 0 || j < 0 || k < 0 || i >= s.nx || j >= s.ny || k >= s.nz {
+			return false
+		}
+		return s.filled[s.index(i, j, k)]
+	}
+	corner := func(i int, j int, k int) *Point {
+		return &Point{
+			X: s.lo.X + float64(i)*s.cell,
+			Y: s.lo.Y + float64(j)*s.cell,
+			Z: s.lo.Z + float64(k)*s.cell,
+		}
+	}
+	quad := func(a *Point, b *Point, c *Point, d *Point) {
+		ret.AddTriangle(a, b, c)
+		ret.AddTriangle(a, c, d)
+	}
+	for k := 0; k < s.nz; k++ {
+		for j := 0; j < s.ny; j++ {
+			for i := 0; i < s.nx; i++ {
+				if !s.filled[s.index(i, j, k)] {
+					continue
+				}
+				if !filled(i-1, j, k) {
+					quad(corner(i, j, k), corner(i, j, k+1), corner(i, j+1, k+1), corner(i, j+1, k))
+				}
+				if !filled(i+1, j, k) {
+					quad(corner(i+1, j, k), corner(i+1, j+1, k), corner(i+1, j+1, k+1), corner(i+1, j, k+1))
+				}
+				if !filled(i, j-1, k) {
+					quad(corner(i, j, k), corner(i+1, j, k), corner(i+1, j, k+1), corner(i, j, k+1))
+				}
+				if !filled(i, j+1, k) {
+					quad(corner(i, j+1, k), corner(i, j+1, k+1), corner(i+1, j+1, k+1), corner(i+1, j+1, k))
+				}
+				if !filled(i, j, k-1) {
+					quad(corner(i, j, k), corner(i, j+1, k), corner(i+1, j+1, k), corner(i+1, j, k))
+				}
+				if !filled(i, j, k+1) {
+					quad(corner(i, j, k+1), corner(i+1, j, k+1), corner(i+1, j+1, k+1), corner(i, j+1, k+1))
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// Fill
+// Add material to the cells inside the closed mesh.
+func (s *Stock) Fill(mesh *Mesh) {
+	s.insideMesh(mesh, func(idx int) {
+		s.filled[idx] = true
+	})
+}
@@ -0,0 +1,106 @@
+package tooling
+
+import (
+	"math"
+)
+
+// Polygonize
+// A closed mesh of the surface of f, sampled on a grid of the given
+// cell size.  This is a surface net, each grid cell the surface passes
+// through gets one vertex, at the average of where the surface crosses
+// the cell edges, and each crossed grid edge becomes a quad joining the
+// four cells around it.
+func Polygonize(f Implicit, cell float64) *Mesh {
+	lo, hi := f.Bounds()
+	lo = &Point{X: lo.X - cell, Y: lo.Y - cell, Z: lo.Z - cell}
+	nx := int(math.Ceil((hi.X-lo.X)/cell)) + 2
+	ny := int(math.Ceil((hi.Y-lo.Y)/cell)) + 2
+	nz := int(math.Ceil((hi.Z-lo.Z)/cell)) + 2
+
+	at := func(i int, j int, k int) *Point {
+		return &Point{X: lo.X + float64(i)*cell, Y: lo.Y + float64(j)*cell, Z: lo.Z + float64(k)*cell}
+	}
+	idx := func(i int, j int, k int) int {
+		return (k*ny+j)*nx + i
+	}
+
+	values := make([]float64, nx*ny*nz)
+	for k := 0; k < nz; k++ {
+		for j := 0; j < ny; j++ {
+			for i := 0; i < nx; i++ {
+				values[idx(i, j, k)] = f.Distance(at(i, j, k))
+			}
+		}
+	}
+
+	corners := [8][3]int{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1, 1, 0}, {0, 0, 1}, {1, 0, 1}, {0, 1, 1}, {1, 1, 1}}
+	edges := [12][2]int{{0, 1}, {2, 3}, {4, 5}, {6, 7}, {0, 2}, {1, 3}, {4, 6}, {5, 7}, {0, 4}, {1, 5}, {2, 6}, {3, 7}}
+
+	verts := make(map[int]*Point)
+	for k := 0; k < nz-1; k++ {
+		for j := 0; j < ny-1; j++ {
+			for i := 0; i < nx-1; i++ {
+				var v [8]float64
+				in := 0
+				for c, o := range corners {
+					v[c] = values[idx(i+o[0], j+o[1], k+o[2])]
+					if v[c] < 0 {
+						in++
+					}
+				}
+				if in == 0 || in == 8 {
+					continue
+				}
+				sum := &Point{}
+				cnt := 0
+				for _, e := range edges {
+					a, b := v[e[0]], v[e[1]]
+					if (a < 0) == (b < 0) {
+						continue
+					}
+					t := a / (a - b)
+					pa := corners[e[0]]
+					pb := corners[e[1]]
+					sum.X += float64(pa[0]) + t*float64(pb[0]-pa[0])
+					sum.Y += float64(pa[1]) + t*float64(pb[1]-pa[1])
+					sum.Z += float64(pa[2]) + t*float64(pb[2]-pa[2])
+					cnt++
+				}
+				base := at(i, j, k)
+				verts[idx(i, j, k)] = &Point{
+					X: base.X + sum.X/float64(cnt)*cell,
+					Y: base.Y + sum.Y/float64(cnt)*cell,
+					Z: base.Z + sum.Z/float64(cnt)*cell,
+				}
+			}
+		}
+	}
+
+	ret := &Mesh{}
+	quad := func(inside bool, a *Point, b *Point, c *Point, d *Point) {
+		if inside {
+			ret.AddTriangle(a, b, c)
+			ret.AddTriangle(a, c, d)
+		} else {
+			ret.AddTriangle(a, d, c)
+			ret.AddTriangle(a, c, b)
+		}
+	}
+	for k := 1; k < nz-1; k++ {
+		for j := 1; j < ny-1; j++ {
+			for i := 1; i < nx-1; i++ {
+				v0 := values[idx(i, j, k)] < 0
+				if v0 != (values[idx(i+1, j, k)] < 0) {
+					quad(v0, verts[idx(i, j-1, k-1)], verts[idx(i, j, k-1)], verts[idx(i, j, k)], verts[idx(i, j-1, k)])
+				}
+				if v0 != (values[idx(i, j+1, k)] < 0) {
+					quad(v0, verts[idx(i-1, j, k-1)], verts[idx(i-1, j, k)], verts[idx(i, j, k)], verts[idx(i, j, k-1)])
+				}
+				if v0 != (values[idx(i, j, k+1)] < 0) {
+					quad(v0, verts[idx(i-1, j-1, k)], verts[idx(i, j-1, k)], verts[idx(i, j, k)], verts[idx(i-1, j, k)])
+				}
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,461 @@
+package tooling
+
+import (
+	"math"
+)
+
+// goldenSteps bounds the refinement of the closest tool position,
+// sweptEpsilon is the parameter resolution, in mm along the path.
+const (
+	goldenSteps  = 60
+	sweptEpsilon = 1e-5
+)
+
+// Swept
+// The volume swept by a cutter, or any body about the tool axis,
+// with a fixed +Z axis, moving along a line, an arc or a helix.  The
+// distance to the swept volume is the distance to the closest placement
+// of the body along the path, so it does not depend on how finely the
+// move is sampled in time.
+type Swept struct {
+	body Body
+	from *Point
+	to   *Point
+
+	arc    bool
+	center *Point
+	plane  int
+	start  float64
+	sweep  float64
+	radius float64
+
+	length float64
+	reach  float64
+	lo     *Point
+	hi     *Point
+
+	// the last radius bound asked for, cells come a layer at a time
+	layerZ float64
+	layerR float64
+	layer  bool
+}
+
+// SweepLine
+// The volume swept by the cutter tip moving in a line from fr to to.
+func SweepLine(c *Cutter, fr *Point, to *Point, cuttingOnly bool) *Swept {
+	return SweepBody(cutterPart(c, cuttingOnly), fr, to)
+}
+
+// SweepBody
+// The volume swept by the body moving in a line from fr to to.
+func SweepBody(b Body, fr *Point, to *Point) *Swept {
+	s := &Swept{
+		body:   b,
+		from:   fr,
+		to:     to,
+		length: fr.Dist(to),
+	}
+	s.reach, _, _ = b.Extent()
+
+	loA, hiA := PlaceBody(b, fr).Bounds()
+	loB, hiB := PlaceBody(b, to).Bounds()
+	s.lo = pointMin(loA, loB)
+	s.hi = pointMax(hiA, hiB)
+	return s
+}
+
+// SweepArc
+// The volume swept by the cutter tip moving along an arc about center
+// in the given plane, ccw when counter clockwise looking down on the
+// plane.  A change along the plane normal makes a helix.  When fr and
+// to are the same point the arc is a full circle.
+func SweepArc(c *Cutter, fr *Point, to *Point, center *Point, plane int, ccw bool, cuttingOnly bool) *Swept {
+	return SweepBodyArc(cutterPart(c, cuttingOnly), fr, to, center, plane, ccw)
+}
+
+// SweepBodyArc
+// The volume swept by the body moving along an arc or helix.
+func SweepBodyArc(b Body, fr *Point, to *Point, center *Point, plane int, ccw bool) *Swept {
+	s := &Swept{
+		body:   b,
+		from:   fr,
+		to:     to,
+		arc:    true,
+		center: center,
+		plane:  plane,
+	}
+	s.reach, _, _ = b.Extent()
+
+	_, _, w0 := toPlane(plane, fr)
+	_, _, w1 := toPlane(plane, to)
+	cu, cv, _ := toPlane(plane, center)
+	s.radius, s.start, s.sweep = ArcAngles(fr, to, center, plane, ccw)
+	s.length = math.Hypot(s.radius*s.sweep, w1-w0)
+
+	// The whole circle bounds the arc, then grow by the body.
+	r, zlo, zhi := b.Extent()
+	lo := fromPlane(plane, cu-s.radius, cv-s.radius, math.Min(w0, w1))
+	hi := fromPlane(plane, cu+s.radius, cv+s.radius, math.Max(w0, w1))
+	s.lo = &Point{X: math.Min(lo.X, hi.X) - r, Y: math.Min(lo.Y, hi.Y) - r, Z: math.Min(lo.Z, hi.Z) + zlo}
+	s.hi = &Point{X: math.Max(lo.X, hi.X) + r, Y: math.Max(lo.Y, hi.Y) + r, Z: math.Max(lo.Z, hi.Z) + zhi}
+	return s
+}
+
+// ArcAngles
+// The radius, start angle and signed sweep in radians of an arc in
+// the plane, positive sweeps are counter clockwise.
+func ArcAngles(fr *Point, to *Point, center *Point, plane int, ccw bool) (float64, float64, float64) {
+	u0, v0, _ := toPlane(plane, fr)
+	u1, v1, _ := toPlane(plane, to)
+	cu, cv, _ := toPlane(plane, center)
+
+	radius := math.Hypot(u0-cu, v0-cv)
+	start := math.Atan2(v0-cv, u0-cu)
+	sweep := math.Atan2(v1-cv, u1-cu) - start
+	if ccw {
+		for sweep <= 1e-9 {
+			sweep += 2 * math.Pi
+		}
+	} else {
+		for sweep >= -1e-9 {
+			sweep -= 2 * math.Pi
+		}
+	}
+	return radius, start, sweep
+}
+
+// ArcLength
+// The length along an arc or helix.
+func ArcLength(fr *Point, to *Point, center *Point, plane int, ccw bool) float64 {
+	radius, _, sweep := ArcAngles(fr, to, center, plane, ccw)
+	_, _, w0 := toPlane(plane, fr)
+	_, _, w1 := toPlane(plane, to)
+	return math.Hypot(radius*sweep, w1-w0)
+}
+
+// ArcAt
+// The point at t along an arc or helix, from 0 at fr to 1 at to.
+func ArcAt(fr *Point, to *Point, center *Point, plane int, ccw bool, t float64) *Point {
+	radius, start, sweep := ArcAngles(fr, to, center, plane, ccw)
+	_, _, w0 := toPlane(plane, fr)
+	_, _, w1 := toPlane(plane, to)
+	cu, cv, _ := toPlane(plane, center)
+	a := start + t*sweep
+	return fromPlane(plane, cu+radius*math.Cos(a), cv+radius*math.Sin(a), w0+t*(w1-w0))
+}
+
+// RadiusCenter
+// The center of an arc in the plane given by its radius, negative for
+// the arc over half a circle, at the height of the start.
+func RadiusCenter(fr *Point, to *Point, r float64, plane int, ccw bool) *Point {
+	u0, v0, w0 := toPlane(plane, fr)
+	u1, v1, _ := toPlane(plane, to)
+	du, dv := u1-u0, v1-v0
+	d := math.Hypot(du, dv)
+	if d == 0 {
+		return fromPlane(plane, u0, v0, w0)
+	}
+	h := math.Sqrt(math.Max(0, r*r-d*d/4))
+	// counter clockwise arcs under half a circle turn about the left
+	side := 1.0
+	if !ccw {
+		side = -side
+	}
+	if r < 0 {
+		side = -side
+	}
+	return fromPlane(plane, u0+du/2-side*h*dv/d, v0+dv/2+side*h*du/d, w0)
+}
+
+// toPlane
+// Split a point into the two in plane coordinates and the normal.
+func toPlane(plane int, p *Point) (float64, float64, float64) {
+	switch plane {
+	case PLANE_XZ:
+		return p.Z, p.X, p.Y
+	case PLANE_YZ:
+		return p.Y, p.Z, p.X
+	}
+	return p.X, p.Y, p.Z
+}
+
+func fromPlane(plane int, u float64, v float64, w float64) *Point {
+	switch plane {
+	case PLANE_XZ:
+		return &Point{X: v, Y: w, Z: u}
+	case PLANE_YZ:
+		return &Point{X: w, Y: u, Z: v}
+	}
+	return &Point{X: u, Y: v, Z: w}
+}
+
+// At
+// The tip position at t, from 0 at the start to 1 at the end.
+func (s *Swept) At(t float64) *Point {
+	if !s.arc {
+		return &Point{
+			X: s.from.X + t*(s.to.X-s.from.X),
+			Y: s.from.Y + t*(s.to.Y-s.from.Y),
+			Z: s.from.Z + t*(s.to.Z-s.from.Z),
+		}
+	}
+	_, _, w0 := toPlane(s.plane, s.from)
+	_, _, w1 := toPlane(s.plane, s.to)
+	cu, cv, _ := toPlane(s.plane, s.center)
+	a := s.start + t*s.sweep
+	return fromPlane(s.plane, cu+s.radius*math.Cos(a), cv+s.radius*math.Sin(a), w0+t*(w1-w0))
+}
+
+func (s *Swept) Length() float64 {
+	return s.length
+}
+
+func (s *Swept) Body() Body {
+	return s.body
+}
+
+func (s *Swept) Bounds() (*Point, *Point) {
+	return s.lo, s.hi
+}
+
+// Distance
+// The signed distance to the swept volume.  Away from the path the
+// distance to the bounds is used, which is never more than the true
+// distance.
+func (s *Swept) Distance(p *Point) float64 {
+	if d := s.bound(p); d > 0 {
+		return d
+	}
+	d, _ := s.Closest(p)
+	return d
+}
+
+// Inside
+// Whether p is inside the swept volume, as the sign of Distance but
+// mostly without searching along the path: far cells are culled by
+// the bound, and most cells inside are inside the body where the path
+// passes nearest them.
+func (s *Swept) Inside(p *Point) bool {
+	if s.bound(p) >= 0 {
+		return false
+	}
+	if s.along(p)(s.nearest(p)) < 0 {
+		return true
+	}
+	d, _ := s.Closest(p)
+	return d < 0
+}
+
+// bound
+// A lower bound on the distance from p to the swept volume, cheap
+// enough for every cell of the bounds: the box, then how far p is
+// from the path less how wide the body is.  An arc is as far as the
+// nearer end off its sector.
+func (s *Swept) bound(p *Point) float64 {
+	if d := boxDistance(s.lo, s.hi, p); d > 0 {
+		return d
+	}
+	if !s.arc {
+		return segmentDistance2d(s.from, s.to, p) - s.radiusAt(p.Z, s.from.Z, s.to.Z)
+	}
+	pu, pv, pw := toPlane(s.plane, p)
+	cu, cv, _ := toPlane(s.plane, s.center)
+	_, _, w0 := toPlane(s.plane, s.from)
+	_, _, w1 := toPlane(s.plane, s.to)
+	in := math.Abs(math.Hypot(pu-cu, pv-cv) - s.radius)
+	if math.Abs(s.sweep) < 2*math.Pi-1e-9 && !s.inSector(math.Atan2(pv-cv, pu-cu)) {
+		u0, v0, _ := toPlane(s.plane, s.from)
+		u1, v1, _ := toPlane(s.plane, s.to)
+		in = math.Min(math.Hypot(pu-u0, pv-v0), math.Hypot(pu-u1, pv-v1))
+	}
+	if s.plane == PLANE_XY {
+		return in - s.radiusAt(p.Z, w0, w1)
+	}
+	// the path is not along the tool axis, the body may reach any way
+	r, lo, hi := s.body.Extent()
+	normal := math.Max(0, math.Max(math.Min(w0, w1)-pw, pw-math.Max(w0, w1)))
+	return math.Hypot(in, normal) - math.Hypot(r, math.Max(math.Abs(lo), math.Abs(hi)))
+}
+
+// radiusAt
+// The widest the body is at height z with the tip anywhere from z0
+// to z1.
+func (s *Swept) radiusAt(z float64, z0 float64, z1 float64) float64 {
+	if !s.layer || s.layerZ != z {
+		s.layerZ = z
+		s.layerR = s.body.RadiusBetween(z-math.Max(z0, z1), z-math.Min(z0, z1))
+		s.layer = true
+	}
+	return s.layerR
+}
+
+// inSector
+// Whether the angle in the plane is within the sweep of the arc.
+func (s *Swept) inSector(a float64) bool {
+	rel := a - s.start
+	if s.sweep < 0 {
+		rel = -rel
+	}
+	rel = math.Mod(rel, 2*math.Pi)
+	if rel < 0 {
+		rel += 2 * math.Pi
+	}
+	return rel <= math.Abs(s.sweep)
+}
+
+// nearest
+// The path parameter nearest p, along a line or round an arc.
+func (s *Swept) nearest(p *Point) float64 {
+	if s.length == 0 {
+		return 0
+	}
+	if !s.arc {
+		d := &Point{X: s.to.X - s.from.X, Y: s.to.Y - s.from.Y, Z: s.to.Z - s.from.Z}
+		t := ((p.X-s.from.X)*d.X + (p.Y-s.from.Y)*d.Y + (p.Z-s.from.Z)*d.Z) / (s.length * s.length)
+		return math.Max(0, math.Min(1, t))
+	}
+	pu, pv, _ := toPlane(s.plane, p)
+	cu, cv, _ := toPlane(s.plane, s.center)
+	rel := math.Atan2(pv-cv, pu-cu) - s.start
+	if s.sweep < 0 {
+		rel = -rel
+	}
+	rel = math.Mod(rel, 2*math.Pi)
+	if rel < 0 {
+		rel += 2 * math.Pi
+	}
+	return math.Max(0, math.Min(1, rel/math.Abs(s.sweep)))
+}
+
+// piece
+// The part of the sweep between two path parameters.
+func (s *Swept) piece(t0 float64, t1 float64) *Swept {
+	if !s.arc {
+		return SweepBody(s.body, s.At(t0), s.At(t1))
+	}
+	return SweepBodyArc(s.body, s.At(t0), s.At(t1), s.center, s.plane, s.sweep > 0)
+}
+
+// Closest
+// The signed distance from p to the cutter at its closest placement
+// along the path, and the path parameter of that placement.
+func (s *Swept) Closest(p *Point) (float64, float64) {
+	f := s.along(p)
+
+	if s.length == 0 {
+		return f(0), 0
+	}
+
+	//
+	// A convex cutter on a straight line gives a convex distance
+	// along the line, everything else is bracketed by sampling first.
+	//
+	lo, hi := 0.0, 1.0
+	if s.arc || !s.body.Convex() {
+		step := math.Max(math.Min(s.reach/4, 1), 0.05)
+		n := int(math.Ceil(s.length/step)) + 1
+		best := math.MaxFloat64
+		bestI := 0
+		for i := 0; i <= n; i++ {
+			if d := f(float64(i) / float64(n)); d < best {
+				best = d
+				bestI = i
+			}
+		}
+		lo = math.Max(0, float64(bestI-1)/float64(n))
+		hi = math.Min(1, float64(bestI+1)/float64(n))
+	}
+
+	t, d := goldenMin(f, lo, hi, sweptEpsilon/math.Max(s.length, 1))
+	if d0 := f(0); d0 < d {
+		t, d = 0, d0
+	}
+	if d1 := f(1); d1 < d {
+		t, d = 1, d1
+	}
+	return d, t
+}
+
+// along
+// The distance from p to the body as it moves along the path.
+func (s *Swept) along(p *Point) func(t float64) float64 {
+	return func(t float64) float64 {
+		at := s.At(t)
+		return s.body.Distance(&Point{X: p.X - at.X, Y: p.Y - at.Y, Z: p.Z - at.Z})
+	}
+}
+
+// Entry
+// The path parameter where the body first reaches p, false when
+// it never does.
+func (s *Swept) Entry(p *Point) (float64, bool) {
+	t, _, ok := s.entry(p)
+	return t, ok
+}
+
+// entry
+// Entry and the signed distance at the closest placement.
+func (s *Swept) entry(p *Point) (float64, float64, bool) {
+	f := s.along(p)
+	d, t := s.Closest(p)
+	if d >= 0 {
+		return 0, d, false
+	}
+	if f(0) < 0 {
+		return 0, d, true
+	}
+	lo, hi := 0.0, t
+	for i := 0; i < goldenSteps && (hi-lo)*s.length > sweptEpsilon; i++ {
+		mid := (lo + hi) / 2
+		if f(mid) < 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi, d, true
+}
+
+// goldenMin
+// Golden section search for the minimum of f between lo and hi.
+func goldenMin(f func(t float64) float64, lo float64, hi float64, eps float64) (float64, float64) {
+	g := (math.Sqrt(5) - 1) / 2
+	a := hi - g*(hi-lo)
+	b := lo + g*(hi-lo)
+	fa := f(a)
+	fb := f(b)
+	for i := 0; i < goldenSteps && hi-lo > eps; i++ {
+		if fa < fb {
+			hi = b
+			b = a
+			fb = fa
+			a = hi - g*(hi-lo)
+			fa = f(a)
+		} else {
+			lo = a
+			a = b
+			fa = fb
+			b = lo + g*(hi-lo)
+			fb = f(b)
+		}
+	}
+	if fa < fb {
+		return a, fa
+	}
+	return b, fb
+}
+
+// boxDistance
+// The distance from p to the box, 0 when inside.
+func boxDistance(lo *Point, hi *Point, p *Point) float64 {
+	dx := math.Max(0, math.Max(lo.X-p.X, p.X-hi.X))
+	dy := math.Max(0, math.Max(lo.Y-p.Y, p.Y-hi.Y))
+	dz := math.Max(0, math.Max(lo.Z-p.Z, p.Z-hi.Z))
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+}
+
+// segmentDistance2d
+// The distance from p to the segment fr, to looking down Z.
+func segmentDistance2d(fr *Point, to *Point, p *Point) float64 {
+	return segmentDistance(fr.X, fr.Y, to.X, to.Y, p.X, p.Y)
+}
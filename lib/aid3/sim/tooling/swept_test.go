@@ -0,0 +1,125 @@
+package tooling
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSweptLineSlot(t *testing.T) {
+	stock := MakeStock(&Point{X: -20, Y: -20, Z: -10}, &Point{X: 20, Y: 20, Z: 0}, 0.2)
+	c := MakeFlatEndMill(6, 20)
+	rm := stock.Cut(SweepLine(c, &Point{X: -10, Y: 0, Z: -2}, &Point{X: 10, Y: 0, Z: -2}, true))
+
+	// a 20 long slot 6 wide and 2 deep, with round ends
+	expected := (20*6 + math.Pi*9) * 2
+	if math.Abs(rm.Volume-expected)/expected > 0.05 {
+		t.Errorf("Slot volume → Expected: %v, Got: %v", expected, rm.Volume)
+	}
+	if math.Abs(rm.Min.Z-(-1.9)) > 0.001 {
+		t.Errorf("Slot floor → Expected: -1.9, Got: %v", rm.Min.Z)
+	}
+}
+
+func TestSweptIndependentOfSampling(t *testing.T) {
+	c := MakeBallEndMill(6, 20)
+	s := SweepLine(c, &Point{X: 0, Y: 0, Z: 0}, &Point{X: 100, Y: 0, Z: 0}, true)
+
+	// half way between any time slice is still on the floor of the slot
+	for _, x := range []float64{0.5, 33.3, 77.77} {
+		if d := s.Distance(&Point{X: x, Y: 0, Z: 0.01}); d >= 0 {
+			t.Errorf("Point on the slot floor at %v → Expected < 0, Got: %v", x, d)
+		}
+	}
+	if d := s.Distance(&Point{X: 50, Y: 4, Z: 3}); math.Abs(d-1) > 0.001 {
+		t.Errorf("Point beside the slot → Expected: 1, Got: %v", d)
+	}
+}
+
+func TestSweptArc(t *testing.T) {
+	c := MakeFlatEndMill(2, 10)
+	// quarter circle radius 10 about the origin, counter clockwise
+	s := SweepArc(c, &Point{X: 10, Y: 0, Z: 0}, &Point{X: 0, Y: 10, Z: 0}, &Point{X: 0, Y: 0, Z: 0}, PLANE_XY, true, true)
+	if math.Abs(s.Length()-math.Pi*5) > 0.001 {
+		t.Errorf("Arc length → Expected: %v, Got: %v", math.Pi*5, s.Length())
+	}
+	if d := s.Distance(&Point{X: 10 * math.Cos(math.Pi/4), Y: 10 * math.Sin(math.Pi/4), Z: 1}); d >= 0 {
+		t.Errorf("Point on the arc → Expected < 0, Got: %v", d)
+	}
+	// clockwise goes the long way round, through -Y
+	cw := SweepArc(c, &Point{X: 10, Y: 0, Z: 0}, &Point{X: 0, Y: 10, Z: 0}, &Point{X: 0, Y: 0, Z: 0}, PLANE_XY, false, true)
+	if d := cw.Distance(&Point{X: 0, Y: -10, Z: 1}); d >= 0 {
+		t.Errorf("Point on the clockwise arc → Expected < 0, Got: %v", d)
+	}
+	if d := s.Distance(&Point{X: 0, Y: -10, Z: 1}); d <= 0 {
+		t.Errorf("Point off the counter clockwise arc → Expected > 0, Got: %v", d)
+	}
+}
+
+func TestPolygonizeClosed(t *testing.T) {
+	m := Polygonize(PlaceCutter(MakeBallEndMill(6, 10), &Point{}, true), 0.5)
+	if m.TriangleCount() == 0 {
+		t.Fatalf("Expected triangles from the ball end mill")
+	}
+	lo, hi := m.Bounds()
+	if lo.Z > 0.1 || hi.Z < 9.9 || hi.X < 2.9 {
+		t.Errorf("Mesh bounds → Got: %v %v", lo, hi)
+	}
+}
+
+func TestContactFirstPoint(t *testing.T) {
+	stock := MakeStock(&Point{X: 0, Y: -5, Z: -5}, &Point{X: 10, Y: 5, Z: 0}, 0.25)
+	c := MakeFlatEndMill(6, 10)
+
+	// the shank is clear when the flutes are deep enough
+	shank := SweepBody(MakeCutterBody(c, PART_NON_CUTTING), &Point{X: -20, Y: 0, Z: -4}, &Point{X: 20, Y: 0, Z: -4})
+	if contact := stock.Contact(shank); contact != nil {
+		t.Errorf("Shank above the stock → Expected no contact, Got: %v", contact.Point)
+	}
+
+	// a rapid through the block touches first at the near face
+	rapid := SweepBody(MakeAssembly(c, PART_ALL, DefaultNose()), &Point{X: -20, Y: 0, Z: -4}, &Point{X: 20, Y: 0, Z: -4})
+	contact := stock.Contact(rapid)
+	if contact == nil {
+		t.Fatalf("Rapid through the stock → Expected contact")
+	}
+	if contact.Point.X > 0.25 {
+		t.Errorf("First contact → Expected near X 0, Got: %v", contact.Point)
+	}
+	if contact.Depth < 2.5 {
+		t.Errorf("Depth → Expected at least 2.5, Got: %v", contact.Depth)
+	}
+}
+
+// a 30 mm block at 0.25 mm, as the simulator's default stock was
+func benchStock() *Stock {
+	return MakeStock(&Point{X: -15, Y: -15, Z: -30}, &Point{X: 15, Y: 15, Z: 0}, 0.25)
+}
+
+func BenchmarkCutLine(b *testing.B) {
+	c := MakeFlatEndMill(6, 20)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		stock := benchStock()
+		b.StartTimer()
+		stock.Cut(SweepLine(c, &Point{X: -10, Y: -10, Z: -2}, &Point{X: 10, Y: -10, Z: -2}, true))
+	}
+}
+
+func BenchmarkCutArc(b *testing.B) {
+	c := MakeFlatEndMill(6, 20)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		stock := benchStock()
+		b.StartTimer()
+		stock.Cut(SweepArc(c, &Point{X: 10, Y: 0, Z: -2}, &Point{X: -10, Y: 0, Z: -2}, &Point{X: 0, Y: 0, Z: -2}, PLANE_XY, true, true))
+	}
+}
+
+// a rapid with the holder and nose straight through the block
+func BenchmarkContact(b *testing.B) {
+	stock := benchStock()
+	a := MakeAssembly(MakeFlatEndMill(6, 20), PART_ALL, DefaultNose())
+	for i := 0; i < b.N; i++ {
+		stock.Contact(SweepBody(a, &Point{X: -40, Y: 0, Z: -5}, &Point{X: 40, Y: 0, Z: -5}))
+	}
+}
@@ -0,0 +1,35 @@
+package tooling
+
+// ToolTable
+// The cutters loaded in the tool changer, by tool number.
+type ToolTable struct {
+	tools map[int64]*Cutter
+}
+
+func MakeToolTable() *ToolTable {
+	return &ToolTable{
+		tools: make(map[int64]*Cutter),
+	}
+}
+
+// DefaultCutter
+// The tool used when a tool number is not in the table.
+func DefaultCutter() *Cutter {
+	return MakeFlatEndMill(6, 20)
+}
+
+func (tt *ToolTable) Add(tool int64, c *Cutter) {
+	tt.tools[tool] = c
+}
+
+// Lookup
+// The cutter for the tool number, nil when not loaded.
+func (tt *ToolTable) Lookup(tool int64) *Cutter {
+	return tt.tools[tool]
+}
+
+func (tt *ToolTable) Traverse(f func(tool int64, c *Cutter)) {
+	for k, v := range tt.tools {
+		f(k, v)
+	}
+}
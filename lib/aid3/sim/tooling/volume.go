@@ -9,6 +9,7 @@ import (
 // https://www.reddit.com/r/golang/comments/az81nt/module_for_reading_and_writing_
 type Volume interface {
 	BoundingBox() *Point
+	Bounds() (*Point, *Point)
 	Subtract(mesh *Mesh)
 	AddTriangle(p1 *Point, p2 *Point, p3 *Point)
 }
@@ -16,8 +17,8 @@ type Volume interface {
 func MakeVolume(fr *Point, to *Point) Volume {
 	ret := &Mesh{
 		shape: nil,
-		bbMin: &Point{0, 0, 0},
-		bbMax: &Point{0, 0, 0},
+		bbMin: &Point{X: 0, Y: 0, Z: 0},
+		bbMax: &Point{X: 0, Y: 0, Z: 0},
 	}
 	ret.bbMin.X = math.Min(fr.X, to.X)
 	ret.bbMin.Y = math.Min(fr.Y, to.Y)
@@ -49,6 +50,8 @@ type Mesh struct {
 	bbMax *Point
 	// offset says to shift the shape by the point
 	offset *Point
+	// count is the number of triangles in shape
+	count int
 }
 
 func (m *Mesh) BoundingBox() *Point {
@@ -71,9 +74,93 @@ func (m *Mesh) Subtract(vol *Mesh) {
 // AddTriangle
 // Extend the shape by this triangle
 func (m *Mesh) AddTriangle(p1 *Point, p2 *Point, p3 *Point) {
+	if p1 == nil || p2 == nil || p3 == nil {
+		return
+	}
+	t := &triangle{
+		pts:    []*Point{p1, p2, p3},
+		normal: normalOf(p1, p2, p3),
+		next:   m.shape,
+	}
+	m.shape = t
 
+	if m.bbMin == nil || m.bbMax == nil || m.count == 0 {
+		m.bbMin = &Point{X: p1.X, Y: p1.Y, Z: p1.Z}
+		m.bbMax = &Point{X: p1.X, Y: p1.Y, Z: p1.Z}
+	}
+	for _, p := range t.pts {
+		m.bbMin.X = math.Min(m.bbMin.X, p.X)
+		m.bbMin.Y = math.Min(m.bbMin.Y, p.Y)
+		m.bbMin.Z = math.Min(m.bbMin.Z, p.Z)
+		m.bbMax.X = math.Max(m.bbMax.X, p.X)
+		m.bbMax.Y = math.Max(m.bbMax.Y, p.Y)
+		m.bbMax.Z = math.Max(m.bbMax.Z, p.Z)
+	}
+	m.count++
 }
 
+// TranslateTo
+// The same shape, shifted by p.  The triangles are shared
+// with the original mesh.
 func (m *Mesh) TranslateTo(p *Point) *Mesh {
-	return nil
+	off := &Point{X: p.X, Y: p.Y, Z: p.Z}
+	if m.offset != nil {
+		off.X += m.offset.X
+		off.Y += m.offset.Y
+		off.Z += m.offset.Z
+	}
+	return &Mesh{
+		shape:  m.shape,
+		bbMin:  m.bbMin,
+		bbMax:  m.bbMax,
+		offset: off,
+		count:  m.count,
+	}
+}
+
+// TriangleCount
+// The number of triangles within the mesh
+func (m *Mesh) TriangleCount() int {
+	return m.count
+}
+
+// Triangles
+// Visit each triangle, with the offset applied.
+func (m *Mesh) Triangles(f func(p1 *Point, p2 *Point, p3 *Point)) {
+	for t := m.shape; t != nil; t = t.next {
+		f(m.shifted(t.pts[0]), m.shifted(t.pts[1]), m.shifted(t.pts[2]))
+	}
+}
+
+// Bounds
+// The minimal and maximal points of the mesh, with the offset applied.
+func (m *Mesh) Bounds() (*Point, *Point) {
+	if m.bbMin == nil || m.bbMax == nil {
+		return &Point{}, &Point{}
+	}
+	return m.shifted(m.bbMin), m.shifted(m.bbMax)
+}
+
+func (m *Mesh) shifted(p *Point) *Point {
+	if m.offset == nil {
+		return p
+	}
+	return &Point{X: p.X + m.offset.X, Y: p.Y + m.offset.Y, Z: p.Z + m.offset.Z}
+}
+
+func normalOf(p1 *Point, p2 *Point, p3 *Point) *Point {
+	ux, uy, uz := p2.X-p1.X, p2.Y-p1.Y, p2.Z-p1.Z
+	vx, vy, vz := p3.X-p1.X, p3.Y-p1.Y, p3.Z-p1.Z
+	n := &Point{
+		X: uy*vz - uz*vy,
+		Y: uz*vx - ux*vz,
+		Z: ux*vy - uy*vx,
+	}
+	l := math.Sqrt(n.X*n.X + n.Y*n.Y + n.Z*n.Z)
+	if l > 0 {
+		n.X /= l
+		n.Y /= l
+		n.Z /= l
+	}
+	return n
 }
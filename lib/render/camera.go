@@ -0,0 +1,164 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+// Camera
+// Where the render is seen from, Eye looking at At with Up up the
+// image.  Fov is the vertical angle of a perspective camera in degrees,
+// 0 for an orthographic one.  Scale is mm across the height of an
+// orthographic image, 0 to fit the scene.
+type Camera struct {
+	Eye   *tooling.Point
+	At    *tooling.Point
+	Up    *tooling.Point
+	Fov   float64
+	Scale float64
+}
+
+// the directions from the scene to the eye of the views
+var presets = map[string]*tooling.Point{
+	"top":    {X: 0, Y: 0, Z: 1},
+	"bottom": {X: 0, Y: 0, Z: -1},
+	"front":  {X: 0, Y: -1, Z: 0},
+	"back":   {X: 0, Y: 1, Z: 0},
+	"left":   {X: -1, Y: 0, Z: 0},
+	"right":  {X: 1, Y: 0, Z: 0},
+	"iso":    {X: 1, Y: -1, Z: 1},
+}
+
+// Views
+// The names of the standard views.
+func Views() []string {
+	var ret []string
+	for nm := range presets {
+		ret = append(ret, nm)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// View
+// A camera looking at the box lo to hi from the named view, far enough
+// away to take it all in.  Fov is 0 for orthographic.
+func View(name string, lo *tooling.Point, hi *tooling.Point, fov float64) (*Camera, error) {
+	dir, ok := presets[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown view %q, not one of %v", name, strings.Join(Views(), ", "))
+	}
+	if fov < 0 || fov >= 180 {
+		return nil, fmt.Errorf("field of view %v is not between 0 and 180", fov)
+	}
+	up := &tooling.Point{Z: 1}
+	if dir.X == 0 && dir.Y == 0 {
+		up = &tooling.Point{Y: 1}
+	}
+	at := tooling.MidPoint(lo, hi)
+	r := math.Max(lo.Dist(hi)/2, 1)
+	d := 2 * r
+	if fov > 0 {
+		d = r / math.Sin(fov*math.Pi/360)
+	}
+	n := unit(dir)
+	return &Camera{Eye: add(at, scale(n, d)), At: at, Up: up, Fov: fov}, nil
+}
+
+// frame
+// The camera as the axes of the image, right, up and into the scene.
+type frame struct {
+	eye     *tooling.Point
+	right   *tooling.Point
+	up      *tooling.Point
+	forward *tooling.Point
+	focal   float64 // px from the eye to the image, perspective only
+	scale   float64 // px a mm, orthographic only
+	cx, cy  float64
+}
+
+func (c *Camera) frame(w int, h int) (*frame, error) {
+	fwd := unit(sub(c.At, c.Eye))
+	if fwd == nil {
+		return nil, fmt.Errorf("the eye is where it looks at")
+	}
+	right := unit(cross(fwd, c.Up))
+	if right == nil {
+		return nil, fmt.Errorf("up %v is along the view", c.Up)
+	}
+	f := &frame{eye: c.Eye, right: right, up: cross(right, fwd), forward: fwd, cx: float64(w) / 2, cy: float64(h) / 2}
+	if c.Fov > 0 {
+		f.focal = float64(h) / 2 / math.Tan(c.Fov*math.Pi/360)
+	} else if c.Scale > 0 {
+		f.scale = float64(h) / c.Scale
+	}
+	return f, nil
+}
+
+// project
+// The point on the image, x right and y down, and its depth along the
+// view.
+func (f *frame) project(p *tooling.Point) (float64, float64, float64) {
+	rel := sub(p, f.eye)
+	x, y, z := dot(rel, f.right), dot(rel, f.up), dot(rel, f.forward)
+	if f.focal > 0 {
+		return f.cx + x*f.focal/z, f.cy - y*f.focal/z, z
+	}
+	return f.cx + x*f.scale, f.cy - y*f.scale, z
+}
+
+// fit
+// The orthographic scale showing the box with a margin.
+func (f *frame) fit(lo *tooling.Point, hi *tooling.Point, w int, h int) {
+	ext := 0.0
+	for i := 0; i < 8; i++ {
+		p := &tooling.Point{X: lo.X, Y: lo.Y, Z: lo.Z}
+		if i&1 != 0 {
+			p.X = hi.X
+		}
+		if i&2 != 0 {
+			p.Y = hi.Y
+		}
+		if i&4 != 0 {
+			p.Z = hi.Z
+		}
+		rel := sub(p, f.eye)
+		x, y := math.Abs(dot(rel, f.right)), math.Abs(dot(rel, f.up))
+		ext = math.Max(ext, math.Max(x*float64(h)/float64(w), y))
+	}
+	f.scale = float64(h) / 2 / math.Max(ext*1.05, 1e-6)
+}
+
+func sub(a *tooling.Point, b *tooling.Point) *tooling.Point {
+	return &tooling.Point{X: a.X - b.X, Y: a.Y - b.Y, Z: a.Z - b.Z}
+}
+
+func add(a *tooling.Point, b *tooling.Point) *tooling.Point {
+	return &tooling.Point{X: a.X + b.X, Y: a.Y + b.Y, Z: a.Z + b.Z}
+}
+
+func scale(a *tooling.Point, s float64) *tooling.Point {
+	return &tooling.Point{X: a.X * s, Y: a.Y * s, Z: a.Z * s}
+}
+
+func dot(a *tooling.Point, b *tooling.Point) float64 {
+	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
+
+func cross(a *tooling.Point, b *tooling.Point) *tooling.Point {
+	return &tooling.Point{X: a.Y*b.Z - a.Z*b.Y, Y: a.Z*b.X - a.X*b.Z, Z: a.X*b.Y - a.Y*b.X}
+}
+
+// unit
+// The point as a direction of length 1, nil when it has none.
+func unit(a *tooling.Point) *tooling.Point {
+	l := math.Sqrt(dot(a, a))
+	if l < 1e-12 {
+		return nil
+	}
+	return scale(a, 1/l)
+}
@@ -0,0 +1,305 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+const (
+	SHADE_LAMBERT = iota
+	SHADE_PHONG
+)
+
+// Options
+// What Render draws.  The camera is the iso view fitted to the scene
+// when nil.  Lambert shading is diffuse only, Phong adds a highlight,
+// both from a light over the eye's shoulder and both from the face
+// normals, an STL has no other.  Edges draws the creases sharper than
+// EdgeAngle degrees and the open edges.  Path is a polyline drawn over
+// the mesh where it is not hidden, the tool path.
+type Options struct {
+	Width      int
+	Height     int
+	Camera     *Camera
+	Shading    int
+	Color      color.RGBA
+	Background color.RGBA
+	Edges      bool
+	EdgeAngle  float64
+	EdgeColor  color.RGBA
+	Path       []*tooling.Point
+	PathColor  color.RGBA
+}
+
+func MakeOptions() *Options {
+	return &Options{
+		Width:      800,
+		Height:     600,
+		Shading:    SHADE_PHONG,
+		Color:      color.RGBA{R: 170, G: 180, B: 195, A: 255},
+		Background: color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		EdgeAngle:  30,
+		EdgeColor:  color.RGBA{R: 40, G: 40, B: 40, A: 255},
+		PathColor:  color.RGBA{R: 220, G: 40, B: 40, A: 255},
+	}
+}
+
+// raster
+// The image and its depth buffer, nearer is smaller.
+type raster struct {
+	img   *image.RGBA
+	depth []float64
+	f     *frame
+	bias  float64 // how far behind a surface a line still shows
+}
+
+// Render
+// The mesh shaded into an image with a depth buffer, no cgo or GPU.
+// Either the mesh or the path may be empty.
+func Render(m *tooling.Mesh, o *Options) (*image.RGBA, error) {
+	if o.Width <= 0 || o.Height <= 0 {
+		return nil, fmt.Errorf("size %vx%v is not above 0", o.Width, o.Height)
+	}
+	lo, hi := sceneBounds(m, o.Path)
+	cam := o.Camera
+	if cam == nil {
+		var err error
+		if cam, err = View("iso", lo, hi, 0); err != nil {
+			return nil, err
+		}
+	}
+	f, err := cam.frame(o.Width, o.Height)
+	if err != nil {
+		return nil, err
+	}
+	if cam.Fov == 0 && cam.Scale == 0 {
+		f.fit(lo, hi, o.Width, o.Height)
+	}
+
+	r := &raster{
+		img:   image.NewRGBA(image.Rect(0, 0, o.Width, o.Height)),
+		depth: make([]float64, o.Width*o.Height),
+		f:     f,
+		bias:  math.Max(lo.Dist(hi), 1) * 2e-3,
+	}
+	for i := range r.depth {
+		r.depth[i] = math.Inf(1)
+		r.img.SetRGBA(i%o.Width, i/o.Width, o.Background)
+	}
+	if m != nil {
+		light := unit(add(scale(f.forward, -1), add(scale(f.up, 0.5), scale(f.right, -0.3))))
+		m.Triangles(func(a *tooling.Point, b *tooling.Point, c *tooling.Point) {
+			r.triangle(a, b, c, o, light)
+		})
+		if o.Edges {
+			for _, e := range featureEdges(m, o.EdgeAngle) {
+				r.line(e[0], e[1], o.EdgeColor)
+			}
+		}
+	}
+	for i := 1; i < len(o.Path); i++ {
+		r.line(o.Path[i-1], o.Path[i], o.PathColor)
+	}
+	return r.img, nil
+}
+
+// WritePNG
+// The render as a PNG.
+func WritePNG(w io.Writer, m *tooling.Mesh, o *Options) error {
+	img, err := Render(m, o)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// sceneBounds
+// The box around the mesh and the path.
+func sceneBounds(m *tooling.Mesh, path []*tooling.Point) (*tooling.Point, *tooling.Point) {
+	var lo, hi *tooling.Point
+	grow := func(p *tooling.Point) {
+		if lo == nil {
+			lo, hi = &tooling.Point{X: p.X, Y: p.Y, Z: p.Z}, &tooling.Point{X: p.X, Y: p.Y, Z: p.Z}
+			return
+		}
+		lo.X, lo.Y, lo.Z = math.Min(lo.X, p.X), math.Min(lo.Y, p.Y), math.Min(lo.Z, p.Z)
+		hi.X, hi.Y, hi.Z = math.Max(hi.X, p.X), math.Max(hi.Y, p.Y), math.Max(hi.Z, p.Z)
+	}
+	if m != nil && m.TriangleCount() > 0 {
+		mlo, mhi := m.Bounds()
+		grow(mlo)
+		grow(mhi)
+	}
+	for _, p := range path {
+		grow(p)
+	}
+	if lo == nil {
+		return &tooling.Point{}, &tooling.Point{}
+	}
+	return lo, hi
+}
+
+// shade
+// The color of a face with normal n seen along view, lit from light.
+// The normal is turned to the eye, an STL is not always wound outward.
+func shade(n *tooling.Point, view *tooling.Point, light *tooling.Point, o *Options) color.RGBA {
+	if dot(n, view) > 0 {
+		n = scale(n, -1)
+	}
+	diffuse := math.Max(dot(n, light), 0)
+	spec := 0.0
+	if o.Shading == SHADE_PHONG {
+		// the light reflected about the normal, toward the eye
+		refl := sub(scale(n, 2*dot(n, light)), light)
+		spec = 0.35 * math.Pow(math.Max(-dot(refl, view), 0), 24)
+	}
+	k := 0.25 + 0.75*diffuse
+	ch := func(v uint8) uint8 {
+		return uint8(math.Min(255, float64(v)*k+255*spec))
+	}
+	return color.RGBA{R: ch(o.Color.R), G: ch(o.Color.G), B: ch(o.Color.B), A: 255}
+}
+
+// triangle
+// Fill the triangle where it is nearer than what is drawn, the depth
+// interpolated on the image, in 1/depth for a perspective camera.
+func (r *raster) triangle(a *tooling.Point, b *tooling.Point, c *tooling.Point, o *Options, light *tooling.Point) {
+	n := unit(cross(sub(b, a), sub(c, a)))
+	if n == nil {
+		return
+	}
+	view := r.f.forward
+	if r.f.focal > 0 {
+		view = unit(sub(tooling.MidPoint(a, tooling.MidPoint(b, c)), r.f.eye))
+		if view == nil {
+			return
+		}
+	}
+	col := shade(n, view, light, o)
+
+	var xs, ys, zs [3]float64
+	for i, p := range []*tooling.Point{a, b, c} {
+		x, y, z := r.f.project(p)
+		if r.f.focal > 0 {
+			if z <= 1e-6 {
+				// behind the eye, not clipped
+				return
+			}
+			z = -1 / z
+		}
+		xs[i], ys[i], zs[i] = x, y, z
+	}
+	area := (xs[1]-xs[0])*(ys[2]-ys[0]) - (xs[2]-xs[0])*(ys[1]-ys[0])
+	if math.Abs(area) < 1e-12 {
+		return
+	}
+	bounds := r.img.Bounds()
+	x0 := int(math.Max(math.Floor(math.Min(xs[0], math.Min(xs[1], xs[2]))), 0))
+	x1 := int(math.Min(math.Ceil(math.Max(xs[0], math.Max(xs[1], xs[2]))), float64(bounds.Dx()-1)))
+	y0 := int(math.Max(math.Floor(math.Min(ys[0], math.Min(ys[1], ys[2]))), 0))
+	y1 := int(math.Min(math.Ceil(math.Max(ys[0], math.Max(ys[1], ys[2]))), float64(bounds.Dy()-1)))
+	for y := y0; y <= y1; y++ {
+		py := float64(y) + 0.5
+		for x := x0; x <= x1; x++ {
+			px := float64(x) + 0.5
+			w0 := ((xs[1]-px)*(ys[2]-py) - (xs[2]-px)*(ys[1]-py)) / area
+			w1 := ((xs[2]-px)*(ys[0]-py) - (xs[0]-px)*(ys[2]-py)) / area
+			w2 := 1 - w0 - w1
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			z := w0*zs[0] + w1*zs[1] + w2*zs[2]
+			i := y*bounds.Dx() + x
+			if z < r.depth[i] {
+				r.depth[i] = z
+				r.img.SetRGBA(x, y, col)
+			}
+		}
+	}
+}
+
+// line
+// A line a pixel wide, where it is not behind what is drawn.
+func (r *raster) line(a *tooling.Point, b *tooling.Point, col color.RGBA) {
+	ax, ay, az := r.f.project(a)
+	bx, by, bz := r.f.project(b)
+	if r.f.focal > 0 && (az <= 1e-6 || bz <= 1e-6) {
+		return
+	}
+	steps := int(math.Ceil(math.Max(math.Abs(bx-ax), math.Abs(by-ay))))
+	if steps < 1 {
+		steps = 1
+	}
+	bounds := r.img.Bounds()
+	for k := 0; k <= steps; k++ {
+		t := float64(k) / float64(steps)
+		x, y := int(math.Floor(ax+(bx-ax)*t)), int(math.Floor(ay+(by-ay)*t))
+		if x < 0 || y < 0 || x >= bounds.Dx() || y >= bounds.Dy() {
+			continue
+		}
+		// depth along the view is linear in t for either camera
+		z := az + (bz-az)*t
+		bias := r.bias
+		if r.f.focal > 0 {
+			z, bias = -1/z, r.bias/(z*z)
+		}
+		i := y*bounds.Dx() + x
+		if z <= r.depth[i]+bias {
+			r.img.SetRGBA(x, y, col)
+		}
+	}
+}
+
+// featureEdges
+// The edges where the faces either side meet sharper than angle
+// degrees, and those with only one face.
+func featureEdges(m *tooling.Mesh, angle float64) [][2]*tooling.Point {
+	type key [6]int64
+	q := func(p *tooling.Point) [3]int64 {
+		return [3]int64{int64(math.Round(p.X * 1e4)), int64(math.Round(p.Y * 1e4)), int64(math.Round(p.Z * 1e4))}
+	}
+	type edge struct {
+		a, b    *tooling.Point
+		normals []*tooling.Point
+	}
+	edges := map[key]*edge{}
+	var order []key
+	m.Triangles(func(a *tooling.Point, b *tooling.Point, c *tooling.Point) {
+		n := unit(cross(sub(b, a), sub(c, a)))
+		if n == nil {
+			return
+		}
+		for _, e := range [][2]*tooling.Point{{a, b}, {b, c}, {c, a}} {
+			qa, qb := q(e[0]), q(e[1])
+			if qb[0] < qa[0] || (qb[0] == qa[0] && (qb[1] < qa[1] || (qb[1] == qa[1] && qb[2] < qa[2]))) {
+				qa, qb = qb, qa
+			}
+			k := key{qa[0], qa[1], qa[2], qb[0], qb[1], qb[2]}
+			if edges[k] == nil {
+				edges[k] = &edge{a: e[0], b: e[1]}
+				order = append(order, k)
+			}
+			edges[k].normals = append(edges[k].normals, n)
+		}
+	})
+	cos := math.Cos(angle * math.Pi / 180)
+	var ret [][2]*tooling.Point
+	for _, k := range order {
+		e := edges[k]
+		sharp := len(e.normals) == 1
+		for i := 1; i < len(e.normals) && !sharp; i++ {
+			// either winding, a crease is the same both ways
+			sharp = math.Abs(dot(e.normals[0], e.normals[i])) < cos
+		}
+		if sharp {
+			ret = append(ret, [2]*tooling.Point{e.a, e.b})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,112 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+// box
+// The closed box lo to hi wound outward.
+func box(lo *tooling.Point, hi *tooling.Point) *tooling.Mesh {
+	m := &tooling.Mesh{}
+	c := func(i int) *tooling.Point {
+		p := &tooling.Point{X: lo.X, Y: lo.Y, Z: lo.Z}
+		if i&1 != 0 {
+			p.X = hi.X
+		}
+		if i&2 != 0 {
+			p.Y = hi.Y
+		}
+		if i&4 != 0 {
+			p.Z = hi.Z
+		}
+		return p
+	}
+	for _, f := range [][4]int{{0, 2, 3, 1}, {4, 5, 7, 6}, {0, 1, 5, 4}, {2, 6, 7, 3}, {0, 4, 6, 2}, {1, 3, 7, 5}} {
+		m.AddTriangle(c(f[0]), c(f[1]), c(f[2]))
+		m.AddTriangle(c(f[0]), c(f[2]), c(f[3]))
+	}
+	return m
+}
+
+func TestRender(t *testing.T) {
+	m := box(&tooling.Point{X: -10, Y: -10, Z: -5}, &tooling.Point{X: 10, Y: 10, Z: 5})
+	o := MakeOptions()
+	o.Width, o.Height = 200, 100
+
+	top, err := View("top", &tooling.Point{X: -10, Y: -10, Z: -5}, &tooling.Point{X: 10, Y: 10, Z: 5}, 0)
+	if err != nil {
+		t.Fatalf("View → %v", err)
+	}
+	o.Camera = top
+	img, err := Render(m, o)
+	if err != nil {
+		t.Fatalf("Render → %v", err)
+	}
+	if img.RGBAAt(100, 50) == o.Background {
+		t.Errorf("Top, middle → Expected: the box, Got: the background")
+	}
+	if img.RGBAAt(5, 50) != o.Background {
+		t.Errorf("Top, left edge → Expected: the background, the box is square in a wide image, Got: %v", img.RGBAAt(5, 50))
+	}
+
+	// the top faces the light over the shoulder more than the sides do
+	o.Camera = nil
+	iso, _ := Render(m, o)
+	if brightness(iso.RGBAAt(100, 25)) <= brightness(iso.RGBAAt(80, 70)) {
+		t.Errorf("Iso → Expected: the top lighter than the front, Got: %v and %v", iso.RGBAAt(100, 25), iso.RGBAAt(80, 70))
+	}
+
+	o.Camera = top
+	o.Edges = true
+	o.Path = []*tooling.Point{{X: -20, Y: 5, Z: 20}, {X: 20, Y: 5, Z: 20}, {X: 20, Y: 5, Z: -20}, {X: -20, Y: -5, Z: -20}}
+	img, _ = Render(m, o)
+	edges := 0
+	for x := 0; x < o.Width; x++ {
+		if img.RGBAAt(x, 50) == o.EdgeColor {
+			edges++
+		}
+	}
+	if edges < 2 {
+		t.Errorf("Edges → Expected: the sides of the box across the middle, Got: %v pixels", edges)
+	}
+	path := 0
+	for y := 0; y < o.Height; y++ {
+		if img.RGBAAt(100, y) == o.PathColor {
+			path++
+		}
+	}
+	if path != 1 {
+		t.Errorf("Path above and below the box → Expected: drawn across the middle once, hidden under it, Got: %v pixels", path)
+	}
+
+	persp, err := View("iso", &tooling.Point{X: -10, Y: -10, Z: -5}, &tooling.Point{X: 10, Y: 10, Z: 5}, 40)
+	if err != nil {
+		t.Fatalf("View → %v", err)
+	}
+	o.Camera, o.Path, o.Edges = persp, nil, false
+	var b bytes.Buffer
+	if err := WritePNG(&b, m, o); err != nil {
+		t.Fatalf("WritePNG → %v", err)
+	}
+	dec, err := png.Decode(&b)
+	if err != nil || dec.Bounds() != image.Rect(0, 0, 200, 100) {
+		t.Fatalf("PNG → Expected: 200x100, Got: %v %v", dec, err)
+	}
+	if c := color.RGBAModel.Convert(dec.At(100, 50)).(color.RGBA); c == o.Background {
+		t.Errorf("Perspective, middle → Expected: the box, Got: the background")
+	}
+
+	if _, err := View("above", &tooling.Point{}, &tooling.Point{}, 0); err == nil {
+		t.Errorf("View above → Expected: an error")
+	}
+}
+
+func brightness(c color.RGBA) int {
+	return int(c.R) + int(c.G) + int(c.B)
+}
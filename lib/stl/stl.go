@@ -1,8 +1,12 @@
 package stl
 
 import (
+	"bufio"
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
 	"github.com/timleecasey/stllib/lib/threed"
-	"log"
+	"io"
+	"math"
 	"neilpa.me/go-stl"
 	"os"
 )
@@ -63,14 +67,18 @@ func boundsOnPoint(d *threed.Dim, p *threed.Point) {
 
 func LoadModel(nm string) (*Model, error) {
 	ret := Model{}
-	objectList := make([]*Trap, 10)
+	objectList := make([]*Trap, 0)
 	ret.Objs = &objectList
 	err := ret.openStl(nm)
 	if err != nil {
 		return nil, err
 	}
 
-	var bounds threed.Dim
+	inf := math.Inf(1)
+	bounds := threed.Dim{
+		From: threed.Point{X: inf, Y: inf, Z: inf},
+		To:   threed.Point{X: -inf, Y: -inf, Z: -inf},
+	}
 	ret.traverse(func(t *Trap) {
 		boundsOnPoint(&bounds, &t.A)
 		boundsOnPoint(&bounds, &t.B)
@@ -85,32 +93,31 @@ func LoadModel(nm string) (*Model, error) {
 func (m *Model) openStl(nm string) error {
 	f, err := os.Open(nm)
 	if err != nil {
-		log.Fatal(err)
 		return err
 	}
 	defer f.Close()
 
 	mesh, err := stl.Decode(f)
 	if err != nil {
-		log.Fatal(err)
-		return err
+		return fmt.Errorf("decode %v: %w", nm, err)
 	}
 
 	for _, face := range mesh.Faces {
+		// each vertex is x, y, z
 		x := threed.Point{
-			X: float64(face.Verts[X_PT][FIRST]),
-			Y: float64(face.Verts[Y_PT][FIRST]),
-			Z: float64(face.Verts[Z_PT][FIRST]),
+			X: float64(face.Verts[FIRST][X_PT]),
+			Y: float64(face.Verts[FIRST][Y_PT]),
+			Z: float64(face.Verts[FIRST][Z_PT]),
 		}
 		y := threed.Point{
-			X: float64(face.Verts[X_PT][SECOND]),
-			Y: float64(face.Verts[Y_PT][SECOND]),
-			Z: float64(face.Verts[Z_PT][SECOND]),
+			X: float64(face.Verts[SECOND][X_PT]),
+			Y: float64(face.Verts[SECOND][Y_PT]),
+			Z: float64(face.Verts[SECOND][Z_PT]),
 		}
 		z := threed.Point{
-			X: float64(face.Verts[X_PT][THIRD]),
-			Y: float64(face.Verts[Y_PT][THIRD]),
-			Z: float64(face.Verts[Z_PT][THIRD]),
+			X: float64(face.Verts[THIRD][X_PT]),
+			Y: float64(face.Verts[THIRD][Y_PT]),
+			Z: float64(face.Verts[THIRD][Z_PT]),
 		}
 
 		normalPt := threed.Point{
@@ -138,3 +145,79 @@ func (m *Model) traverse(v TrapVisitor) {
 		v(t)
 	}
 }
+
+// Mesh
+// The model as a mesh for the simulator, to compare with a print.
+func (m *Model) Mesh() *tooling.Mesh {
+	ret := &tooling.Mesh{}
+	m.traverse(func(t *Trap) {
+		a, b, c := t.A, t.B, t.C
+		ret.AddTriangle(&a, &b, &c)
+	})
+	return ret
+}
+
+// WriteMesh
+// The mesh as a binary STL, the normals from the winding.
+func WriteMesh(w io.Writer, m *tooling.Mesh, comment string) error {
+	bw := bufio.NewWriter(w)
+	enc, err := stl.NewBinaryEncoder(bw, comment, m.TriangleCount())
+	if err != nil {
+		return err
+	}
+	m.Triangles(func(a *tooling.Point, b *tooling.Point, c *tooling.Point) {
+		if err != nil {
+			return
+		}
+		ux, uy, uz := b.X-a.X, b.Y-a.Y, b.Z-a.Z
+		vx, vy, vz := c.X-a.X, c.Y-a.Y, c.Z-a.Z
+		n := [3]float64{uy*vz - uz*vy, uz*vx - ux*vz, ux*vy - uy*vx}
+		l := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+		face := stl.Face{Verts: [3][3]float32{vertex(a), vertex(b), vertex(c)}}
+		if l > 0 {
+			face.Normal = [3]float32{float32(n[0] / l), float32(n[1] / l), float32(n[2] / l)}
+		}
+		err = enc.WriteFace(face)
+	})
+	if err != nil {
+		return err
+	}
+	// the count was given up front, closing the encoder would only
+	// close w, which is the caller's
+	return bw.Flush()
+}
+
+func vertex(p *tooling.Point) [3]float32 {
+	return [3]float32{float32(p.X), float32(p.Y), float32(p.Z)}
+}
+
+// Triangles
+// How many triangles are in the model.
+func (m *Model) Triangles() int {
+	return len(*m.Objs)
+}
+
+// Volume
+// The volume enclosed, by the signed tetrahedra to the origin.  Only
+// meaningful for a closed model with its triangles wound outward.
+func (m *Model) Volume() float64 {
+	ret := 0.0
+	m.traverse(func(t *Trap) {
+		a, b, c := t.A, t.B, t.C
+		ret += a.X*(b.Y*c.Z-b.Z*c.Y) - a.Y*(b.X*c.Z-b.Z*c.X) + a.Z*(b.X*c.Y-b.Y*c.X)
+	})
+	return ret / 6
+}
+
+// Area
+// The surface area of the triangles.
+func (m *Model) Area() float64 {
+	ret := 0.0
+	m.traverse(func(t *Trap) {
+		ux, uy, uz := t.B.X-t.A.X, t.B.Y-t.A.Y, t.B.Z-t.A.Z
+		vx, vy, vz := t.C.X-t.A.X, t.C.Y-t.A.Y, t.C.Z-t.A.Z
+		nx, ny, nz := uy*vz-uz*vy, uz*vx-ux*vz, ux*vy-uy*vx
+		ret += math.Sqrt(nx*nx+ny*ny+nz*nz) / 2
+	})
+	return ret
+}
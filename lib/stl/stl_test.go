@@ -0,0 +1,43 @@
+package stl
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+)
+
+func TestWriteAndLoad(t *testing.T) {
+	// a tetrahedron wound outward, 10 along each axis
+	o, x, y, z := &tooling.Point{}, &tooling.Point{X: 10}, &tooling.Point{Y: 10}, &tooling.Point{Z: 10}
+	mesh := &tooling.Mesh{}
+	mesh.AddTriangle(o, y, x)
+	mesh.AddTriangle(o, x, z)
+	mesh.AddTriangle(o, z, y)
+	mesh.AddTriangle(x, y, z)
+
+	fileNm := filepath.Join(t.TempDir(), "tetra.stl")
+	f, err := os.Create(fileNm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteMesh(f, mesh, "tetra"); err != nil {
+		t.Fatalf("Write → %v", err)
+	}
+
+	m, err := LoadModel(fileNm)
+	if err != nil {
+		t.Fatalf("Load → %v", err)
+	}
+	if b := m.Bounds(); b.To.X != 10 || b.To.Y != 10 || b.To.Z != 10 || b.From.X != 0 {
+		t.Errorf("Bounds → Expected: 0 to 10, Got: %v to %v", b.From, b.To)
+	}
+	if v := m.Volume(); math.Abs(v-1000./6) > 1e-3 {
+		t.Errorf("Volume → Expected: %v, Got: %v", 1000./6, v)
+	}
+	if _, err = LoadModel(filepath.Join(t.TempDir(), "missing.stl")); err == nil {
+		t.Errorf("Missing file → Expected an error")
+	}
+}
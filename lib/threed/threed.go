@@ -8,12 +8,8 @@ const (
 	Boundary
 )
 
-//// a 3d point
-//type Point struct {
-//	X float64
-//	Y float64
-//	Z float64
-//}
+// a 3d point, the same as the simulator's
+type Point = tooling.Point
 
 type Side int
 
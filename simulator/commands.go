@@ -0,0 +1,834 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"github.com/timleecasey/stllib/lib/render"
+	"github.com/timleecasey/stllib/lib/stl"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// flagSet
+// The flags of a command, failing quietly so the command can exit 2.
+func flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: simulator %v\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs
+// Parse the flags, one file must follow them.
+func parseArgs(fs *flag.FlagSet, args []string) (string, bool) {
+	if err := fs.Parse(args); err != nil {
+		return "", false
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", false
+	}
+	return fs.Arg(0), true
+}
+
+// scratch
+// A directory for the outputs of a run nobody asked for, removed by
+// the returned func.
+func scratch(cmd string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "simulator-"+cmd)
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, func() { os.RemoveAll(dir) }, nil
+}
+
+// writeJSON
+// v as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// ProgramSummary
+// What a program holds, as parse reports it.
+type ProgramSummary struct {
+	File       string            `json:"file"`
+	Commands   int               `json:"commands"`
+	Lines      int               `json:"lines"`
+	Layers     int               `json:"layers"`
+	Operations []string          `json:"operations"`
+	Tools      []int             `json:"tools"`
+	Settings   map[string]string `json:"settings"`
+}
+
+func summarize(fileNm string, tree *gcode.ParseTree) *ProgramSummary {
+	ret := &ProgramSummary{File: fileNm, Operations: []string{}, Tools: []int{}, Settings: tree.SlicerSettings()}
+	seenOp := make(map[string]bool)
+	seenTool := make(map[int]bool)
+	tree.TraverseCmds(func(cn *gcode.CmdNode) error {
+		ret.Commands++
+		if l := cn.Cmd.Line(); l > ret.Lines {
+			ret.Lines = l
+		}
+		if m := cn.Cmd.Meta(); m != nil {
+			if m.Layer+1 > ret.Layers {
+				ret.Layers = m.Layer + 1
+			}
+			if m.Operation != "" && !seenOp[m.Operation] {
+				seenOp[m.Operation] = true
+				ret.Operations = append(ret.Operations, m.Operation)
+			}
+		}
+		if cn.Cmd.CmdType() == gcode.CMD_TOOL_CHANGE {
+			var num int
+			if _, err := fmt.Sscanf(cn.Cmd.Src()[1:], "%d", &num); err == nil && !seenTool[num] {
+				seenTool[num] = true
+				ret.Tools = append(ret.Tools, num)
+			}
+		}
+		return nil
+	})
+	sort.Ints(ret.Tools)
+	return ret
+}
+
+func (p *ProgramSummary) writeText(w io.Writer, tree *gcode.ParseTree) error {
+	_, err := fmt.Fprintf(w, "%v: %v commands over %v lines\n", p.File, p.Commands, p.Lines)
+	if err == nil && p.Layers > 0 {
+		_, err = fmt.Fprintf(w, "Layers      %v\n", p.Layers)
+	}
+	if err == nil && len(p.Operations) > 0 {
+		_, err = fmt.Fprintf(w, "Operations  %v\n", len(p.Operations))
+		for _, op := range p.Operations {
+			if err == nil {
+				_, err = fmt.Fprintf(w, "  %v\n", op)
+			}
+		}
+	}
+	if err == nil && len(p.Tools) > 0 {
+		_, err = fmt.Fprintf(w, "Tools       %v\n", len(p.Tools))
+		for _, num := range p.Tools {
+			desc := ""
+			if info := tree.Tool(num); info != nil {
+				desc = fmt.Sprintf("D %v %v", info.Diameter, info.Description)
+			}
+			if err == nil {
+				_, err = fmt.Fprintf(w, "  T%-4v %v\n", num, desc)
+			}
+		}
+	}
+	if err == nil && len(p.Settings) > 0 {
+		_, err = fmt.Fprintf(w, "Settings    %v\n", len(p.Settings))
+	}
+	return err
+}
+
+// parseCmd
+// simulator parse [-format text|json] file.nc
+func parseCmd(args []string) int {
+	fs := flagSet("parse")
+	format := fs.String("format", "text", "text or json")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	tree, err := gcode.Parse(fileNm)
+	if err != nil {
+		return fail("parse", EXIT_FAILED, "could not parse %v: %v", fileNm, err)
+	}
+	p := summarize(fileNm, tree)
+	switch *format {
+	case "text":
+		err = p.writeText(os.Stdout, tree)
+	case "json":
+		err = writeJSON(os.Stdout, p)
+	default:
+		return fail("parse", EXIT_USAGE, "unknown format %q", *format)
+	}
+	if err != nil {
+		return fail("parse", EXIT_FAILED, "%v", err)
+	}
+	return EXIT_OK
+}
+
+// lintCmd
+// simulator lint [-rules mill] [-severity rule=level,...] [-sim] [machine flags] file.nc
+// The linter's diagnostics as file:line:column, with -sim also the
+// warnings and collisions of a run.  Exits 3 when there is anything at
+// warning or above.
+func lintCmd(args []string) int {
+	fs := flagSet("lint")
+	o := &options{}
+	o.flags(fs)
+	set := fs.String("rules", "", "rule set, one of mill, lathe, laser, plasma or printer, the machine's without one")
+	sevs := fs.String("severity", "", "rule=off|info|warning|error pairs split by commas")
+	safeZ := fs.Float64("safe-z", 1, "rapids across below it are into the stock, program units")
+	stockTop := fs.Float64("stock-top", 0, "rapids down below it are into the stock, program units")
+	arcTol := fs.Float64("arc-tolerance", 0.005, "most difference of an arc's start and end radius in mm")
+	withSim := fs.Bool("sim", false, "also simulate, adding its warnings and collisions")
+	format := fs.String("format", "text", "text or json")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if *format != "text" && *format != "json" {
+		return fail("lint", EXIT_USAGE, "unknown format %q", *format)
+	}
+	if *set == "" {
+		kind, err := o.machineType()
+		if err != nil {
+			return fail("lint", EXIT_FAILED, "%v", err)
+		}
+		*set = kind
+	}
+	cfg, err := gcode.MakeLintConfig(*set)
+	if err != nil {
+		return fail("lint", EXIT_USAGE, "%v", err)
+	}
+	if err = cfg.Set(*sevs); err != nil {
+		return fail("lint", EXIT_USAGE, "%v", err)
+	}
+	cfg.SafeZ, cfg.StockTop, cfg.ArcTolerance = *safeZ, *stockTop, *arcTol
+
+	tree, err := gcode.Parse(fileNm)
+	if err != nil {
+		return fail("lint", EXIT_FAILED, "could not parse %v: %v", fileNm, err)
+	}
+	diags := tree.Lint(cfg)
+	if *withSim {
+		dir, done, err := scratch("lint")
+		if err != nil {
+			return fail("lint", EXIT_FAILED, "%v", err)
+		}
+		defer done()
+		s, err := o.build()
+		if err != nil {
+			return fail("lint", EXIT_FAILED, "%v", err)
+		}
+		s.OutDir = dir
+		s.Run(tree)
+		diags = append(diags, simDiagnostics(tree, s)...)
+		sort.SliceStable(diags, func(i int, j int) bool {
+			return diags[i].Line < diags[j].Line
+		})
+	}
+
+	findings := 0
+	for _, d := range diags {
+		if d.Severity >= gcode.SEVERITY_WARNING {
+			findings++
+		}
+	}
+	if *format == "json" {
+		err = writeJSON(os.Stdout, &LintReport{File: fileNm, Diagnostics: diags})
+	} else {
+		for _, d := range diags {
+			if _, err = fmt.Printf("%v:%v\n", fileNm, d); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return fail("lint", EXIT_FAILED, "%v", err)
+	}
+	if findings > 0 {
+		return EXIT_FINDINGS
+	}
+	return EXIT_OK
+}
+
+// LintReport
+// The diagnostics of a file, as lint writes them in JSON.
+type LintReport struct {
+	File        string              `json:"file"`
+	Diagnostics []*gcode.Diagnostic `json:"diagnostics"`
+}
+
+// simDiagnostics
+// The warnings, collisions and alarm of a run as diagnostics, at the
+// column of the command on their line.
+func simDiagnostics(tree *gcode.ParseTree, s *sim.Sim) []*gcode.Diagnostic {
+	cols := make(map[int]int)
+	tree.TraverseCmds(func(cn *gcode.CmdNode) error {
+		if _, ok := cols[cn.Cmd.Line()]; !ok {
+			cols[cn.Cmd.Line()] = cn.Cmd.Column()
+		}
+		return nil
+	})
+	var ret []*gcode.Diagnostic
+	for _, w := range s.Warnings {
+		ret = append(ret, &gcode.Diagnostic{Rule: "sim", Severity: gcode.SEVERITY_WARNING,
+			Line: w.Line, Column: cols[w.Line], Message: w.Message})
+	}
+	for _, c := range s.Collisions {
+		ret = append(ret, &gcode.Diagnostic{Rule: "collision", Severity: gcode.SEVERITY_ERROR,
+			Line: c.Line, Column: cols[c.Line],
+			Message: fmt.Sprintf("%v hits %v at %v, %3.3f deep", c.Part, c.Against, c.Point, c.Depth)})
+	}
+	if a := s.Alarm; a != nil {
+		ret = append(ret, &gcode.Diagnostic{Rule: "alarm", Severity: gcode.SEVERITY_ERROR,
+			Line: a.Line, Column: cols[a.Line], Message: "stops the program, " + a.Message})
+	}
+	return ret
+}
+
+// simCmd
+// simulator sim [machine flags] [-out dir] file.nc
+// Exits 3 when the tool hit something or the program stopped on an
+// alarm, as a probe which did not trip.
+func simCmd(args []string) int {
+	fs := flagSet("sim")
+	o := &options{}
+	o.flags(fs)
+	out := fs.String("out", ".", "directory for path.gcode and the other outputs")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return fail("sim", EXIT_FAILED, "%v", err)
+	}
+	s, _, err := o.run(fileNm, *out)
+	if err != nil {
+		return fail("sim", EXIT_FAILED, "%v", err)
+	}
+	if len(s.Collisions) > 0 {
+		return fail("sim", EXIT_FINDINGS, "%v collisions, first %v", len(s.Collisions), s.Collisions[0])
+	}
+	if s.Alarm != nil {
+		return fail("sim", EXIT_FINDINGS, "stopped at %v", s.Alarm)
+	}
+	return EXIT_OK
+}
+
+// statsCmd
+// simulator stats [machine flags] [-rate 60] [-format text|json] [-o file] file.nc
+func statsCmd(args []string) int {
+	fs := flagSet("stats")
+	o := &options{}
+	o.flags(fs)
+	rate := fs.Float64("rate", 0, "machine hourly rate for the cost")
+	format := fs.String("format", "text", "text or json")
+	outNm := fs.String("o", "", "file to write, stdout when none")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if *format != "text" && *format != "json" {
+		return fail("stats", EXIT_USAGE, "unknown format %q", *format)
+	}
+	dir, done, err := scratch("stats")
+	if err != nil {
+		return fail("stats", EXIT_FAILED, "%v", err)
+	}
+	defer done()
+	s, _, err := o.run(fileNm, dir)
+	if err != nil {
+		return fail("stats", EXIT_FAILED, "%v", err)
+	}
+	w, closeOut, err := output(*outNm)
+	if err != nil {
+		return fail("stats", EXIT_FAILED, "%v", err)
+	}
+	st := s.Stats(*rate)
+	if *format == "json" {
+		err = st.WriteJSON(w)
+	} else {
+		err = st.WriteText(w)
+	}
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fail("stats", EXIT_FAILED, "could not write the statistics: %v", err)
+	}
+	return EXIT_OK
+}
+
+// finished
+// The mesh of what is left after the run, the stock of a mill, the
+// turned bar or what a printer laid down.
+func finished(s *sim.Sim) (*tooling.Mesh, error) {
+	switch {
+	case s.Stock != nil:
+		return s.Stock.Mesh(), nil
+	case s.Turned != nil:
+		return s.Turned.Mesh(), nil
+	case s.Print != nil && s.Print.Printed != nil:
+		return s.Print.Printed.Mesh(), nil
+	case s.Sheet != nil:
+		return nil, fmt.Errorf("a cut sheet has no mesh, export the moves with -format csv")
+	}
+	return nil, fmt.Errorf("nothing was made")
+}
+
+// exportCmd
+// simulator export [machine flags] [-format stl|csv] -o file file.nc
+func exportCmd(args []string) int {
+	fs := flagSet("export")
+	o := &options{}
+	o.flags(fs)
+	format := fs.String("format", "stl", "stl for the finished stock, csv for the moves")
+	outNm := fs.String("o", "", "file to write")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if *format != "stl" && *format != "csv" {
+		return fail("export", EXIT_USAGE, "unknown format %q", *format)
+	}
+	if *outNm == "" && *format == "stl" {
+		return fail("export", EXIT_USAGE, "-o is needed for binary STL")
+	}
+	dir, done, err := scratch("export")
+	if err != nil {
+		return fail("export", EXIT_FAILED, "%v", err)
+	}
+	defer done()
+	s, _, err := o.run(fileNm, dir)
+	if err != nil {
+		return fail("export", EXIT_FAILED, "%v", err)
+	}
+	var mesh *tooling.Mesh
+	if *format == "stl" {
+		if mesh, err = finished(s); err != nil {
+			return fail("export", EXIT_FAILED, "%v", err)
+		}
+	}
+	w, closeOut, err := output(*outNm)
+	if err != nil {
+		return fail("export", EXIT_FAILED, "%v", err)
+	}
+	if mesh != nil {
+		err = stl.WriteMesh(w, mesh, "simulated "+fileNm)
+	} else {
+		err = s.WriteMoves(w)
+	}
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fail("export", EXIT_FAILED, "could not write %v: %v", *outNm, err)
+	}
+	return EXIT_OK
+}
+
+// plotCmd
+// simulator plot [machine flags] [-view xy,xz,yz] [-color tool|feed] [-size 600] [-rapids] [-o file.svg] file.nc
+// The simulated tool path as SVG, the views side by side.
+func plotCmd(args []string) int {
+	fs := flagSet("plot")
+	o := &options{}
+	o.flags(fs)
+	cfg := sim.MakePlotConfig()
+	viewsStr := fs.String("view", strings.Join(cfg.Views, ","), "projections to draw side by side, of xy, xz and yz")
+	fs.StringVar(&cfg.ColorBy, "color", cfg.ColorBy, "tool or feed")
+	fs.Float64Var(&cfg.Size, "size", cfg.Size, "px along the longer side of a view")
+	fs.BoolVar(&cfg.Rapids, "rapids", cfg.Rapids, "draw the rapids, leave them out with -rapids=false")
+	outNm := fs.String("o", "", "file to write, stdout without one")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	cfg.Views = strings.Split(*viewsStr, ",")
+	if err := (&sim.Sim{}).WriteSVG(io.Discard, cfg); err != nil {
+		return fail("plot", EXIT_USAGE, "%v", err)
+	}
+	dir, done, err := scratch("plot")
+	if err != nil {
+		return fail("plot", EXIT_FAILED, "%v", err)
+	}
+	defer done()
+	s, _, err := o.run(fileNm, dir)
+	if err != nil {
+		return fail("plot", EXIT_FAILED, "%v", err)
+	}
+	w, closeOut, err := output(*outNm)
+	if err != nil {
+		return fail("plot", EXIT_FAILED, "%v", err)
+	}
+	err = s.WriteSVG(w, cfg)
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fail("plot", EXIT_FAILED, "could not write %v: %v", *outNm, err)
+	}
+	return EXIT_OK
+}
+
+// renderCmd
+// simulator render [machine flags] [-view iso] [-fov 0] [-shading phong|lambert] [-edges] [-path] -o file.png file.stl|file.nc
+// A shaded PNG of a model, or of the stock a program leaves with its
+// tool path over it.
+func renderCmd(args []string) int {
+	fs := flagSet("render")
+	o := &options{}
+	o.flags(fs)
+	ro := render.MakeOptions()
+	view := fs.String("view", "iso", "one of "+strings.Join(render.Views(), ", "))
+	fov := fs.Float64("fov", 0, "perspective field of view in degrees, 0 for orthographic")
+	shading := fs.String("shading", "phong", "phong or lambert")
+	fs.IntVar(&ro.Width, "width", ro.Width, "image width in px")
+	fs.IntVar(&ro.Height, "height", ro.Height, "image height in px")
+	fs.BoolVar(&ro.Edges, "edges", ro.Edges, "draw the sharp and open edges")
+	fs.Float64Var(&ro.EdgeAngle, "edge-angle", ro.EdgeAngle, "degrees between faces an edge is drawn at")
+	path := fs.Bool("path", false, "draw the tool path over the stock of a program")
+	outNm := fs.String("o", "", "PNG file to write")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	switch *shading {
+	case "phong":
+		ro.Shading = render.SHADE_PHONG
+	case "lambert":
+		ro.Shading = render.SHADE_LAMBERT
+	default:
+		return fail("render", EXIT_USAGE, "unknown shading %q", *shading)
+	}
+	if *outNm == "" {
+		return fail("render", EXIT_USAGE, "-o is needed for a PNG")
+	}
+	if _, err := render.View(*view, &tooling.Point{}, &tooling.Point{}, *fov); err != nil {
+		return fail("render", EXIT_USAGE, "%v", err)
+	}
+
+	var mesh *tooling.Mesh
+	if strings.EqualFold(filepath.Ext(fileNm), ".stl") {
+		model, err := stl.LoadModel(fileNm)
+		if err != nil {
+			return fail("render", EXIT_FAILED, "%v", err)
+		}
+		mesh = model.Mesh()
+	} else {
+		dir, done, err := scratch("render")
+		if err != nil {
+			return fail("render", EXIT_FAILED, "%v", err)
+		}
+		defer done()
+		s, _, err := o.run(fileNm, dir)
+		if err != nil {
+			return fail("render", EXIT_FAILED, "%v", err)
+		}
+		if mesh, err = finished(s); err != nil && !*path {
+			return fail("render", EXIT_FAILED, "%v", err)
+		}
+		if *path {
+			s.ToolHead.Path(func(p *tooling.Point) {
+				ro.Path = append(ro.Path, p)
+			})
+		}
+	}
+	lo, hi := &tooling.Point{}, &tooling.Point{}
+	if mesh != nil && mesh.TriangleCount() > 0 {
+		lo, hi = mesh.Bounds()
+	} else if len(ro.Path) > 0 {
+		lo, hi = ro.Path[0], ro.Path[0]
+	}
+	for _, p := range ro.Path {
+		lo = &tooling.Point{X: math.Min(lo.X, p.X), Y: math.Min(lo.Y, p.Y), Z: math.Min(lo.Z, p.Z)}
+		hi = &tooling.Point{X: math.Max(hi.X, p.X), Y: math.Max(hi.Y, p.Y), Z: math.Max(hi.Z, p.Z)}
+	}
+	var err error
+	if ro.Camera, err = render.View(*view, lo, hi, *fov); err != nil {
+		return fail("render", EXIT_USAGE, "%v", err)
+	}
+	w, closeOut, err := output(*outNm)
+	if err != nil {
+		return fail("render", EXIT_FAILED, "%v", err)
+	}
+	err = render.WritePNG(w, mesh, ro)
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fail("render", EXIT_FAILED, "could not write %v: %v", *outNm, err)
+	}
+	return EXIT_OK
+}
+
+// optimizeCmd
+// simulator optimize [-tolerance 0.01] [-arcs] [-o file] file.nc
+// The program with its G1 runs merged and fitted with arcs, what was
+// saved to stderr.
+func optimizeCmd(args []string) int {
+	fs := flagSet("optimize")
+	cfg := gcode.MakeOptimizeConfig()
+	fs.Float64Var(&cfg.Tolerance, "tolerance", cfg.Tolerance, "most the new path strays from the old in mm")
+	fs.BoolVar(&cfg.Arcs, "arcs", cfg.Arcs, "fit G2 and G3 arcs, only merge lines with -arcs=false")
+	fs.IntVar(&cfg.MinSegments, "min-segments", cfg.MinSegments, "fewest G1 segments an arc replaces")
+	fs.Float64Var(&cfg.MaxRadius, "max-radius", cfg.MaxRadius, "largest arc radius in mm")
+	outNm := fs.String("o", "", "file to write, stdout without one")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if cfg.Tolerance <= 0 || cfg.MinSegments < 2 {
+		return fail("optimize", EXIT_USAGE, "-tolerance must be above 0 and -min-segments at least 2")
+	}
+	tree, err := gcode.Parse(fileNm)
+	if err != nil {
+		return fail("optimize", EXIT_FAILED, "could not parse %v: %v", fileNm, err)
+	}
+	opt, stats := tree.Optimize(cfg)
+	w, closeOut, err := output(*outNm)
+	if err != nil {
+		return fail("optimize", EXIT_FAILED, "%v", err)
+	}
+	err = opt.Write(w)
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fail("optimize", EXIT_FAILED, "could not write %v: %v", *outNm, err)
+	}
+	fmt.Fprintf(os.Stderr, "%v: %v commands to %v, %v segments as %v lines and %v arcs\n",
+		fileNm, stats.Before, stats.After, stats.Segments, stats.Lines, stats.Arcs)
+	return EXIT_OK
+}
+
+// transformCmd
+// simulator transform [-mirror x] [-scale 2] [-rotate 0,0,90] [-origin x,y,z] [-translate x,y,z] [-o file] file.nc
+// The program is mirrored, scaled then rotated about X, Y and Z about the
+// origin, then translated.
+func transformCmd(args []string) int {
+	fs := flagSet("transform")
+	mirror := fs.String("mirror", "", "axes to mirror, x, y, z or several as xy")
+	scale := fs.String("scale", "", "factor, or x,y,z factors")
+	rotate := fs.String("rotate", "", "degrees about x,y,z, turned in that order")
+	origin := fs.String("origin", "0,0,0", "x,y,z the program is mirrored, scaled and rotated about")
+	translate := fs.String("translate", "", "x,y,z to move the program by")
+	outNm := fs.String("o", "", "file to write, stdout without one")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	a, err := transformOf(*mirror, *scale, *rotate, *origin, *translate)
+	if err != nil {
+		return fail("transform", EXIT_USAGE, "%v", err)
+	}
+	tree, err := gcode.Parse(fileNm)
+	if err != nil {
+		return fail("transform", EXIT_FAILED, "could not parse %v: %v", fileNm, err)
+	}
+	moved, err := tree.Transform(a)
+	if err != nil {
+		return fail("transform", EXIT_FAILED, "%v: %v", fileNm, err)
+	}
+	w, closeOut, err := output(*outNm)
+	if err != nil {
+		return fail("transform", EXIT_FAILED, "%v", err)
+	}
+	err = moved.Write(w)
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fail("transform", EXIT_FAILED, "could not write %v: %v", *outNm, err)
+	}
+	return EXIT_OK
+}
+
+// transformOf
+// The affine of the transform flags.
+func transformOf(mirror string, scale string, rotate string, origin string, translate string) (*tooling.Affine, error) {
+	o, err := triple("origin", origin, false)
+	if err != nil {
+		return nil, err
+	}
+	steps := []*tooling.Affine{tooling.Translate(-o[0], -o[1], -o[2])}
+	for _, r := range strings.ToLower(mirror) {
+		switch r {
+		case 'x':
+			steps = append(steps, tooling.Mirror(tooling.X))
+		case 'y':
+			steps = append(steps, tooling.Mirror(tooling.Y))
+		case 'z':
+			steps = append(steps, tooling.Mirror(tooling.Z))
+		default:
+			return nil, fmt.Errorf("-mirror %q is not of x, y and z", mirror)
+		}
+	}
+	if scale != "" {
+		s, err := triple("scale", scale, true)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, tooling.Scale(s[0], s[1], s[2]))
+	}
+	if rotate != "" {
+		r, err := triple("rotate", rotate, false)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, tooling.RotateX(r[0]), tooling.RotateY(r[1]), tooling.RotateZ(r[2]))
+	}
+	steps = append(steps, tooling.Translate(o[0], o[1], o[2]))
+	if translate != "" {
+		t, err := triple("translate", translate, false)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, tooling.Translate(t[0], t[1], t[2]))
+	}
+	return tooling.Compose(steps...), nil
+}
+
+// triple
+// The x,y,z of a flag, one value standing for all three when one may.
+func triple(name string, s string, one bool) ([3]float64, error) {
+	ret := [3]float64{}
+	fields := strings.Split(s, ",")
+	if one && len(fields) == 1 {
+		fields = []string{s, s, s}
+	}
+	if len(fields) != 3 {
+		return ret, fmt.Errorf("-%v %q is not x,y,z", name, s)
+	}
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return ret, fmt.Errorf("-%v %q: %q is not a number", name, s, f)
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+// VoxelReport
+// What voxelize filled.
+type VoxelReport struct {
+	File       string         `json:"file"`
+	Resolution float64        `json:"resolution"`
+	Cells      int            `json:"cells"`
+	Volume     float64        `json:"volume"`
+	Model      float64        `json:"modelVolume"`
+	Lo         *tooling.Point `json:"lo"`
+	Hi         *tooling.Point `json:"hi"`
+}
+
+// voxelizeCmd
+// simulator voxelize [-resolution 0.25] [-format text|json] [-o file.stl] model.stl
+func voxelizeCmd(args []string) int {
+	fs := flagSet("voxelize")
+	res := fs.Float64("resolution", 0.25, "cell size in mm")
+	format := fs.String("format", "text", "text or json")
+	outNm := fs.String("o", "", "STL file for the filled cells")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if *res <= 0 {
+		return fail("voxelize", EXIT_USAGE, "resolution must be positive")
+	}
+	if *format != "text" && *format != "json" {
+		return fail("voxelize", EXIT_USAGE, "unknown format %q", *format)
+	}
+	model, err := stl.LoadModel(fileNm)
+	if err != nil {
+		return fail("voxelize", EXIT_FAILED, "%v", err)
+	}
+	mesh := model.Mesh()
+	lo, hi := mesh.Bounds()
+	vox := tooling.MakeEmptyStock(lo, hi, *res)
+	vox.Fill(mesh)
+	cell := vox.Cell()
+	r := &VoxelReport{
+		File:       fileNm,
+		Resolution: cell,
+		Cells:      int(vox.Volume()/(cell*cell*cell) + 0.5),
+		Volume:     vox.Volume(),
+		Model:      model.Volume(),
+		Lo:         lo,
+		Hi:         hi,
+	}
+	if *format == "json" {
+		err = writeJSON(os.Stdout, r)
+	} else {
+		_, err = fmt.Printf("%v: %v cells of %v mm, %3.3f mm3 against %3.3f mm3 of the model\n",
+			r.File, r.Cells, r.Resolution, r.Volume, r.Model)
+	}
+	if err != nil {
+		return fail("voxelize", EXIT_FAILED, "%v", err)
+	}
+	if *outNm != "" {
+		w, closeOut, err := output(*outNm)
+		if err != nil {
+			return fail("voxelize", EXIT_FAILED, "%v", err)
+		}
+		err = stl.WriteMesh(w, vox.Mesh(), "voxelized "+fileNm)
+		if cerr := closeOut(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fail("voxelize", EXIT_FAILED, "could not write %v: %v", *outNm, err)
+		}
+	}
+	return EXIT_OK
+}
+
+// StlInfo
+// The measure of a model, stl-info reports it.
+type StlInfo struct {
+	File      string         `json:"file"`
+	Triangles int            `json:"triangles"`
+	Lo        *tooling.Point `json:"lo"`
+	Hi        *tooling.Point `json:"hi"`
+	Size      *tooling.Point `json:"size"`
+	Volume    float64        `json:"volume"`
+	Area      float64        `json:"area"`
+}
+
+// stlInfoCmd
+// simulator stl-info [-format text|json] model.stl
+func stlInfoCmd(args []string) int {
+	fs := flagSet("stl-info")
+	format := fs.String("format", "text", "text or json")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	model, err := stl.LoadModel(fileNm)
+	if err != nil {
+		return fail("stl-info", EXIT_FAILED, "%v", err)
+	}
+	lo, hi := model.Mesh().Bounds()
+	info := &StlInfo{
+		File:      fileNm,
+		Triangles: model.Triangles(),
+		Lo:        lo,
+		Hi:        hi,
+		Size:      &tooling.Point{X: hi.X - lo.X, Y: hi.Y - lo.Y, Z: hi.Z - lo.Z},
+		Volume:    model.Volume(),
+		Area:      model.Area(),
+	}
+	switch *format {
+	case "text":
+		_, err = fmt.Printf("%v\nTriangles  %v\nBounds     %v to %v\nSize       %v\nVolume     %3.3f mm3\nArea       %3.3f mm2\n",
+			info.File, info.Triangles, info.Lo, info.Hi, info.Size, info.Volume, info.Area)
+	case "json":
+		err = writeJSON(os.Stdout, info)
+	default:
+		return fail("stl-info", EXIT_USAGE, "unknown format %q", *format)
+	}
+	if err != nil {
+		return fail("stl-info", EXIT_FAILED, "%v", err)
+	}
+	return EXIT_OK
+}
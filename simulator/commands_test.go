@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const cliSrc = `G21 G90 G17
+T1 M6
+S10000 M3
+G0 Z5
+G0 X0 Y0
+G1 Z-1 F200
+G1 X10 F800
+G2 X20 Y0 I5 J0
+G0 Z5
+M5
+M30
+`
+
+// writeFile
+// The text as the named file in dir, its path.
+func writeFile(t *testing.T, dir string, name string, text string) string {
+	fileNm := filepath.Join(dir, name)
+	if err := os.WriteFile(fileNm, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileNm
+}
+
+// captured
+// Run the command, what it wrote to stdout and stderr kept.
+func captured(t *testing.T, run func(args []string) int, args []string) (int, string, string) {
+	dir := t.TempDir()
+	out, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errOut, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = out, errOut
+	code := run(args)
+	os.Stdout, os.Stderr = stdout, stderr
+	out.Close()
+	errOut.Close()
+	o, _ := os.ReadFile(out.Name())
+	e, _ := os.ReadFile(errOut.Name())
+	return code, string(o), string(e)
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	good := writeFile(t, dir, "good.nc", cliSrc)
+	bad := writeFile(t, dir, "bad.nc", "G21 G90\nG0 X1 Y@2\n")
+	risky := writeFile(t, dir, "risky.nc", "G21 G90\nT1 M6\nS10000 M3\nG0 Z5\nG0 X0 Y0 Z-2\nM30\n")
+	cfg := writeFile(t, dir, "mill.yaml", "type: mill\nstock: {size: 40x40x10, material: Oak}\nresolution: 1\n")
+	out := filepath.Join(dir, "out")
+
+	tests := []struct {
+		name   string
+		run    func(args []string) int
+		args   []string
+		code   int
+		stdout string // in what it printed
+		stderr string // in its diagnostic
+		file   string // written, and in it
+		in     string
+	}{
+		{name: "parse", run: parseCmd, args: []string{good}, code: EXIT_OK, stdout: "commands"},
+		{name: "parse json", run: parseCmd, args: []string{"-format", "json", good}, code: EXIT_OK, stdout: `"commands": 15`},
+		{name: "parse broken", run: parseCmd, args: []string{bad}, code: EXIT_FAILED, stderr: "could not parse " + bad},
+		{name: "parse format", run: parseCmd, args: []string{"-format", "xml", good}, code: EXIT_USAGE, stderr: `unknown format "xml"`},
+		{name: "parse no file", run: parseCmd, args: nil, code: EXIT_USAGE, stderr: "usage: simulator parse"},
+		{name: "parse two files", run: parseCmd, args: []string{good, good}, code: EXIT_USAGE, stderr: "usage: simulator parse"},
+		{name: "parse flag", run: parseCmd, args: []string{"-nosuch", good}, code: EXIT_USAGE, stderr: "-nosuch"},
+
+		{name: "lint", run: lintCmd, args: []string{"-config", cfg, good}, code: EXIT_OK},
+		{name: "lint findings", run: lintCmd, args: []string{"-config", cfg, risky}, code: EXIT_FINDINGS, stdout: risky + ":5:"},
+		{name: "lint json", run: lintCmd, args: []string{"-format", "json", risky}, code: EXIT_FINDINGS, stdout: `"rule": "rapid-into-stock"`},
+		{name: "lint sim", run: lintCmd, args: []string{"-config", cfg, "-sim", good}, code: EXIT_FINDINGS, stdout: good + ":6:1: warning: cutting while the spindle is at"},
+		{name: "lint broken", run: lintCmd, args: []string{bad}, code: EXIT_FAILED, stderr: "could not parse " + bad},
+		{name: "lint rules", run: lintCmd, args: []string{"-rules", "nosuch", good}, code: EXIT_USAGE, stderr: "nosuch"},
+		{name: "lint format", run: lintCmd, args: []string{"-format", "xml", good}, code: EXIT_USAGE, stderr: `unknown format "xml"`},
+
+		{name: "stats", run: statsCmd, args: []string{"-config", cfg, "-format", "json", "-o", filepath.Join(dir, "stats.json"), good}, code: EXIT_OK,
+			file: filepath.Join(dir, "stats.json"), in: "{"},
+		{name: "stats text", run: statsCmd, args: []string{"-config", cfg, good}, code: EXIT_OK, stdout: "T1"},
+		{name: "stats broken", run: statsCmd, args: []string{bad}, code: EXIT_FAILED, stderr: "could not parse " + bad},
+		{name: "stats format", run: statsCmd, args: []string{"-format", "xml", good}, code: EXIT_USAGE, stderr: `unknown format "xml"`},
+		{name: "stats config", run: statsCmd, args: []string{"-config", filepath.Join(dir, "nosuch.yaml"), good}, code: EXIT_FAILED, stderr: "nosuch.yaml"},
+
+		{name: "sim", run: simCmd, args: []string{"-config", cfg, "-out", out, good}, code: EXIT_OK,
+			file: filepath.Join(out, "path.gcode"), in: "G21 G90\n"},
+		{name: "sim broken", run: simCmd, args: []string{"-out", out, bad}, code: EXIT_FAILED, stderr: "could not parse " + bad},
+		{name: "sim no file", run: simCmd, args: []string{"-out", out}, code: EXIT_USAGE, stderr: "usage: simulator sim"},
+
+		{name: "export", run: exportCmd, args: []string{"-config", cfg, "-o", filepath.Join(dir, "stock.stl"), good}, code: EXIT_OK,
+			file: filepath.Join(dir, "stock.stl"), in: "simulated " + good},
+		{name: "export csv", run: exportCmd, args: []string{"-config", cfg, "-format", "csv", "-o", filepath.Join(dir, "moves.csv"), good}, code: EXIT_OK,
+			file: filepath.Join(dir, "moves.csv"), in: ","},
+		{name: "export no out", run: exportCmd, args: []string{good}, code: EXIT_USAGE, stderr: "-o is needed"},
+		{name: "export format", run: exportCmd, args: []string{"-format", "obj", "-o", filepath.Join(dir, "x.obj"), good}, code: EXIT_USAGE, stderr: `unknown format "obj"`},
+		{name: "export broken", run: exportCmd, args: []string{"-o", filepath.Join(dir, "broken.stl"), bad}, code: EXIT_FAILED, stderr: "could not parse " + bad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := captured(t, tt.run, tt.args)
+			if code != tt.code {
+				t.Errorf("Exit → Expected: %v, Got: %v\n%v", tt.code, code, stderr)
+			}
+			if !strings.Contains(stdout, tt.stdout) {
+				t.Errorf("Stdout → Expected: %q in, Got: %q", tt.stdout, stdout)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("Stderr → Expected: %q in, Got: %q", tt.stderr, stderr)
+			}
+			if tt.file == "" {
+				return
+			}
+			b, err := os.ReadFile(tt.file)
+			if err != nil || !strings.Contains(string(b), tt.in) {
+				t.Errorf("%v → Expected: %q in, Got: %.80q %v", tt.file, tt.in, b, err)
+			}
+		})
+	}
+
+	var stats map[string]interface{}
+	if b, err := os.ReadFile(filepath.Join(dir, "stats.json")); err != nil || json.Unmarshal(b, &stats) != nil {
+		t.Errorf("Stats JSON → Expected: to parse, Got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "broken.stl")); err == nil {
+		t.Errorf("Export broken → Expected: no file written, Got: broken.stl")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim"
+	"github.com/timleecasey/stllib/lib/aid3/sim/machine"
+	"github.com/timleecasey/stllib/lib/aid3/sim/material"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// options
+// The flags shared by the commands which simulate.  A flag given
+// overrides the machine config.
+type options struct {
+	config     string
+	machine    string
+	material   string
+	stock      string
+	stockStl   string
+	tools      string
+	tolerance  float64
+	slice      float64
+	resolution float64
+}
+
+func (o *options) flags(fs *flag.FlagSet) {
+	fs.StringVar(&o.config, "config", "", "machine config, JSON or YAML")
+	fs.StringVar(&o.machine, "machine", "", "machine profile, one of "+strings.Join(machine.Names(), ", ")+", mill without a config")
+	fs.StringVar(&o.material, "material", "", "stock material, one of "+strings.Join(material.Builtin().Names(), ", ")+", Oak without a config")
+	fs.StringVar(&o.stock, "stock", "", "stock as WxDxH, a lathe bar as DxL, or corners x0,y0,z0:x1,y1,z1 in mm")
+	fs.StringVar(&o.stockStl, "stock-stl", "", "STL file of the stock, in place of a block")
+	fs.StringVar(&o.tools, "tools", "", "tool table, JSON or YAML")
+	fs.Float64Var(&o.tolerance, "tolerance", 0, "path tolerance in mm, 0 for the default")
+	fs.Float64Var(&o.slice, "slice", 0, "time slice in seconds, 0 for the default")
+	fs.Float64Var(&o.resolution, "resolution", 0, "stock cell size in mm, 0 for the default")
+}
+
+// build
+// The machine with its stock and tools, started and ready to run.
+func (o *options) build() (*sim.Sim, error) {
+	cfg := machine.Default()
+	if o.config != "" {
+		var err error
+		if cfg, err = machine.Load(o.config); err != nil {
+			return nil, err
+		}
+	}
+	if o.machine != "" {
+		cfg.Type = o.machine
+	}
+	if cfg.Stock == nil {
+		cfg.Stock = &machine.StockSpec{}
+	}
+	if o.material != "" {
+		cfg.Stock.Material = o.material
+	}
+	if o.stock != "" {
+		cfg.Stock.Size = o.stock
+		cfg.Stock.Lo, cfg.Stock.Hi = nil, nil
+	}
+	if o.stockStl != "" {
+		// the flag is from here, not from the config
+		abs, err := filepath.Abs(o.stockStl)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Stock.Stl = abs
+	}
+	if o.tools != "" {
+		specs, err := machine.LoadTools(o.tools)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Changer == nil {
+			cfg.Changer = &machine.ChangerSpec{}
+		}
+		cfg.Changer.Tools = append(cfg.Changer.Tools, specs...)
+	}
+	if o.tolerance > 0 {
+		cfg.Tolerance = o.tolerance
+	}
+	if o.slice > 0 {
+		cfg.TimeSlice = o.slice
+	}
+	if o.resolution > 0 {
+		cfg.Resolution = o.resolution
+	}
+	return cfg.Sim()
+}
+
+// machineType
+// The kind of machine the flags or the config give, mill when neither
+// does.
+func (o *options) machineType() (string, error) {
+	if o.machine != "" {
+		return o.machine, nil
+	}
+	if o.config != "" {
+		cfg, err := machine.Load(o.config)
+		if err != nil {
+			return "", err
+		}
+		if cfg.Type != "" {
+			return cfg.Type, nil
+		}
+	}
+	return "mill", nil
+}
+
+// run
+// Parse the file and simulate it, the outputs go to outDir.
+func (o *options) run(fileNm string, outDir string) (*sim.Sim, *gcode.ParseTree, error) {
+	tree, err := gcode.Parse(fileNm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse %v: %w", fileNm, err)
+	}
+	s, err := o.build()
+	if err != nil {
+		return nil, nil, err
+	}
+	s.OutDir = outDir
+	s.Run(tree)
+	return s, tree, nil
+}
+
+// output
+// The file to write to, stdout when there is none.  The returned close
+// reports the errors of writing.
+func output(fileNm string) (io.Writer, func() error, error) {
+	if fileNm == "" || fileNm == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(fileNm)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+
+	"github.com/timleecasey/stllib/lib/aid3/gcode"
+	"github.com/timleecasey/stllib/lib/aid3/sim"
+	"github.com/timleecasey/stllib/lib/aid3/sim/tooling"
+	"github.com/timleecasey/stllib/lib/stl"
+)
+
+// viewer
+// The browser viewer, WebGL without anything from the network.
+//
+//go:embed viewer
+var viewer embed.FS
+
+// how many stock meshes the server keeps
+const stockCache = 16
+
+// where serve listens without -addr
+const serveAddr = "127.0.0.1:8080"
+
+// server
+// A simulated program for the browser.  The run is done once up front,
+// the stock at a time by running again to the move ending there.
+type server struct {
+	o      *options
+	fileNm string
+	dir    string
+	src    []byte
+	tree   *gcode.ParseTree
+	s      *sim.Sim
+	mux    *http.ServeMux
+
+	mu     sync.Mutex
+	stocks map[int][]byte // binary STL after the first n moves
+}
+
+// ServedBlock
+// A command of the program, where it is in the source and as written.
+type ServedBlock struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Code   string `json:"code"`
+	Block  string `json:"block"`
+}
+
+// ServedProgram
+// The program as /api/program serves it.
+type ServedProgram struct {
+	Summary *ProgramSummary `json:"summary"`
+	Blocks  []*ServedBlock  `json:"blocks"`
+}
+
+// ServedMove
+// A move as /api/path serves it, its head path as x, y, z and the
+// seconds from the start of the program.
+type ServedMove struct {
+	Line     int          `json:"line"`
+	Kind     string       `json:"kind"`
+	Tool     int64        `json:"tool"`
+	Feed     float64      `json:"feed"`
+	Start    float64      `json:"start"`
+	Duration float64      `json:"duration"`
+	Points   [][4]float64 `json:"points"`
+}
+
+// ServedPath
+// The moves, the time of the whole program and where it went.
+type ServedPath struct {
+	Time  float64        `json:"time"`
+	Lo    *tooling.Point `json:"lo"`
+	Hi    *tooling.Point `json:"hi"`
+	Moves []*ServedMove  `json:"moves"`
+}
+
+func newServer(o *options, fileNm string, dir string) (*server, error) {
+	src, err := os.ReadFile(fileNm)
+	if err != nil {
+		return nil, err
+	}
+	s, tree, err := o.run(fileNm, dir)
+	if err != nil {
+		return nil, err
+	}
+	sv := &server{o: o, fileNm: fileNm, dir: dir, src: src, tree: tree, s: s, mux: http.NewServeMux(), stocks: make(map[int][]byte)}
+	files, err := fs.Sub(viewer, "viewer")
+	if err != nil {
+		return nil, err
+	}
+	sv.mux.Handle("GET /", http.FileServer(http.FS(files)))
+	sv.mux.HandleFunc("GET /api/program", sv.program)
+	sv.mux.HandleFunc("GET /api/source", sv.source)
+	sv.mux.HandleFunc("GET /api/path", sv.path)
+	sv.mux.HandleFunc("GET /api/stock", sv.stock)
+	return sv, nil
+}
+
+func (sv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sv.mux.ServeHTTP(w, r)
+}
+
+func (sv *server) program(w http.ResponseWriter, r *http.Request) {
+	p := &ServedProgram{Summary: summarize(sv.fileNm, sv.tree), Blocks: []*ServedBlock{}}
+	sv.tree.TraverseCmds(func(cn *gcode.CmdNode) error {
+		c := cn.Cmd
+		p.Blocks = append(p.Blocks, &ServedBlock{Line: c.Line(), Column: c.Column(), Code: c.Src(), Block: c.Block()})
+		return nil
+	})
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, p)
+}
+
+func (sv *server) source(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(sv.src)
+}
+
+// path
+// The moves with a time on each point of the head path, spread over
+// the move by the distance along it.
+func (sv *server) path(w http.ResponseWriter, r *http.Request) {
+	var pts []*tooling.Point
+	sv.s.ToolHead.Path(func(p *tooling.Point) {
+		pts = append(pts, p)
+	})
+	kinds := []string{"rapid", "feed", "arc", "probe"}
+	ret := &ServedPath{Lo: &tooling.Point{}, Hi: &tooling.Point{}, Moves: []*ServedMove{}}
+	first := true
+	for _, m := range sv.s.Moves {
+		if m.To == nil {
+			continue
+		}
+		sm := &ServedMove{Kind: kinds[m.Kind], Tool: m.Tool, Feed: m.Feed, Start: m.Start, Duration: m.Duration}
+		if m.Node != nil {
+			sm.Line = m.Node.Cmd.Line()
+		}
+		mp := []*tooling.Point{m.From}
+		if m.First < m.Last && m.Last <= len(pts) {
+			mp = append(mp, pts[m.First:m.Last]...)
+		}
+		if mp[len(mp)-1].Dist(m.To) > 1e-9 {
+			mp = append(mp, m.To)
+		}
+		length := 0.0
+		for i := 1; i < len(mp); i++ {
+			length += mp[i-1].Dist(mp[i])
+		}
+		along := 0.0
+		for i, p := range mp {
+			if i > 0 {
+				along += mp[i-1].Dist(p)
+			}
+			t := m.Start
+			if length > 0 {
+				t += m.Duration * along / length
+			}
+			sm.Points = append(sm.Points, [4]float64{p.X, p.Y, p.Z, t})
+			if first {
+				ret.Lo, ret.Hi, first = &tooling.Point{X: p.X, Y: p.Y, Z: p.Z}, &tooling.Point{X: p.X, Y: p.Y, Z: p.Z}, false
+			}
+			ret.Lo = &tooling.Point{X: math.Min(ret.Lo.X, p.X), Y: math.Min(ret.Lo.Y, p.Y), Z: math.Min(ret.Lo.Z, p.Z)}
+			ret.Hi = &tooling.Point{X: math.Max(ret.Hi.X, p.X), Y: math.Max(ret.Hi.Y, p.Y), Z: math.Max(ret.Hi.Z, p.Z)}
+		}
+		ret.Time = math.Max(ret.Time, m.Start+m.Duration)
+		ret.Moves = append(ret.Moves, sm)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, ret)
+}
+
+// stock
+// /api/stock?t=seconds, the stock as a binary STL once the moves
+// ending by then are done, the whole program without t.  The moves it
+// is after are in X-Moves.
+func (sv *server) stock(w http.ResponseWriter, r *http.Request) {
+	n := len(sv.s.Moves)
+	if ts := r.URL.Query().Get("t"); ts != "" {
+		t, err := strconv.ParseFloat(ts, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("t %q is not a number of seconds", ts), http.StatusBadRequest)
+			return
+		}
+		n = 0
+		for n < len(sv.s.Moves) && sv.s.Moves[n].Start+sv.s.Moves[n].Duration <= t+1e-9 {
+			n++
+		}
+	}
+	b, err := sv.stockAfter(n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "model/stl")
+	w.Header().Set("X-Moves", strconv.Itoa(n))
+	w.Write(b)
+}
+
+// stockAfter
+// The stock after the first n moves, from the run when it is all of
+// them, from a run stopped watching the moves when not.
+func (sv *server) stockAfter(n int) ([]byte, error) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if b, ok := sv.stocks[n]; ok {
+		return b, nil
+	}
+	var mesh *tooling.Mesh
+	var err error
+	switch {
+	case n >= len(sv.s.Moves):
+		mesh, err = finished(sv.s)
+	case n == 0:
+		var s *sim.Sim
+		if s, err = sv.o.build(); err == nil {
+			mesh, err = finished(s)
+		}
+	default:
+		var s *sim.Sim
+		if s, err = sv.o.build(); err != nil {
+			return nil, err
+		}
+		s.OutDir = sv.dir
+		done := 0
+		s.ObserveMoves(func(m *sim.Move) {
+			if done++; done == n {
+				mesh, err = finished(s)
+			}
+		})
+		s.Run(sv.tree)
+		if mesh == nil && err == nil {
+			err = fmt.Errorf("the run stopped before move %v", n)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = stl.WriteMesh(&buf, mesh, "stock after "+strconv.Itoa(n)+" moves"); err != nil {
+		return nil, err
+	}
+	if len(sv.stocks) >= stockCache {
+		for k := range sv.stocks {
+			delete(sv.stocks, k)
+			break
+		}
+	}
+	sv.stocks[n] = buf.Bytes()
+	return sv.stocks[n], nil
+}
+
+// loopback
+// Whether the address only listens on this machine.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveCmd
+// simulator serve [machine flags] [-addr 127.0.0.1:8080] file.nc
+// The program in a browser viewer on this machine until interrupted.
+func serveCmd(args []string) int {
+	fs := flagSet("serve")
+	o := &options{}
+	o.flags(fs)
+	addr := fs.String("addr", serveAddr, "where to listen, only on this machine")
+	fileNm, ok := parseArgs(fs, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if !loopback(*addr) {
+		return fail("serve", EXIT_USAGE, "%v is not a loopback address, the viewer is only served locally", *addr)
+	}
+	dir, done, err := scratch("serve")
+	if err != nil {
+		return fail("serve", EXIT_FAILED, "%v", err)
+	}
+	defer done()
+	sv, err := newServer(o, fileNm, dir)
+	if err != nil {
+		return fail("serve", EXIT_FAILED, "%v", err)
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fail("serve", EXIT_FAILED, "%v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	hs := &http.Server{Handler: sv}
+	go func() {
+		<-ctx.Done()
+		hs.Shutdown(context.Background())
+	}()
+	fmt.Fprintf(os.Stderr, "%v: serving on http://%v/, interrupt to stop\n", fileNm, ln.Addr())
+	if err = hs.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return fail("serve", EXIT_FAILED, "%v", err)
+	}
+	return EXIT_OK
+}
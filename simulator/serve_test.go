@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const served = "G21 G90\nG0 X0 Y0 Z20\nG1 Z-1 F300\nG1 X10\nG0 Z20\n"
+
+// serveSrc
+// A server over the program on a small block, for httptest.
+func serveSrc(t *testing.T, src string) *server {
+	dir := t.TempDir()
+	fileNm := filepath.Join(dir, "p.nc")
+	if err := os.WriteFile(fileNm, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sv, err := newServer(&options{stock: "40x40x10", resolution: 1}, fileNm, dir)
+	if err != nil {
+		t.Fatalf("newServer → %v", err)
+	}
+	return sv
+}
+
+// get
+// The response of the server to a GET of target, its body read.
+func get(t *testing.T, sv *server, target string) (*http.Response, []byte) {
+	w := httptest.NewRecorder()
+	sv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	res := w.Result()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, body
+}
+
+func TestServeJSON(t *testing.T) {
+	sv := serveSrc(t, served)
+
+	res, body := get(t, sv, "/api/program")
+	var p ServedProgram
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("Program → Expected: 200 JSON, Got: %v %v", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("Program → %v", err)
+	}
+	var codes []string
+	for _, b := range p.Blocks {
+		codes = append(codes, b.Code)
+	}
+	if got := strings.Join(codes, " "); got != "G21 G90 G0 G1 G1 G0" || p.Blocks[2].Line != 2 {
+		t.Errorf("Blocks → Expected: G21 G90 G0 G1 G1 G0 with G0 on line 2, Got: %v", got)
+	}
+	if p.Summary == nil || p.Summary.Commands != 6 {
+		t.Errorf("Summary → Expected: 6 commands, Got: %+v", p.Summary)
+	}
+
+	res, body = get(t, sv, "/api/source")
+	if res.StatusCode != http.StatusOK || string(body) != served {
+		t.Errorf("Source → Expected: %q, Got: %v %q", served, res.StatusCode, body)
+	}
+
+	res, body = get(t, sv, "/api/path")
+	var path ServedPath
+	if err := json.Unmarshal(body, &path); err != nil {
+		t.Fatalf("Path → %v", err)
+	}
+	if len(path.Moves) != len(sv.s.Moves) || len(path.Moves) != 4 {
+		t.Fatalf("Moves → Expected: 4, Got: %v", len(path.Moves))
+	}
+	for i, m := range path.Moves {
+		first, last := m.Points[0], m.Points[len(m.Points)-1]
+		if m.Line != i+2 || math.Abs(first[3]-m.Start) > 1e-9 || math.Abs(last[3]-m.Start-m.Duration) > 1e-9 {
+			t.Errorf("Move %v → Expected: line %v from %v to %v, Got: %+v", i, i+2, m.Start, m.Start+m.Duration, m)
+		}
+	}
+	if cut := path.Moves[2]; cut.Kind != "feed" || cut.Feed != 300 || cut.Points[len(cut.Points)-1][0] != 10 {
+		t.Errorf("Cut → Expected: feed at 300 to X10, Got: %+v", cut)
+	}
+	if path.Lo.Z != -1 || path.Hi.X != 10 || path.Hi.Z != 20 {
+		t.Errorf("Bounds → Expected: Z-1 to X10 Z20, Got: %v to %v", path.Lo, path.Hi)
+	}
+	end := path.Moves[3]
+	if math.Abs(path.Time-end.Start-end.Duration) > 1e-9 || path.Time <= 0 {
+		t.Errorf("Time → Expected: %v, Got: %v", end.Start+end.Duration, path.Time)
+	}
+}
+
+func TestServeStock(t *testing.T) {
+	sv := serveSrc(t, served)
+	tests := []struct {
+		target string
+		moves  int
+	}{
+		{"/api/stock", 4},
+		{"/api/stock?t=0", 0},
+		{"/api/stock?t=" + strconv.FormatFloat(sv.s.Moves[1].Start+sv.s.Moves[1].Duration, 'g', -1, 64), 2},
+		{"/api/stock?t=1e9", 4},
+	}
+	for _, tt := range tests {
+		res, body := get(t, sv, tt.target)
+		if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "model/stl" {
+			t.Errorf("%v → Expected: 200 model/stl, Got: %v %v", tt.target, res.StatusCode, res.Header.Get("Content-Type"))
+			continue
+		}
+		if got := res.Header.Get("X-Moves"); got != strconv.Itoa(tt.moves) {
+			t.Errorf("%v X-Moves → Expected: %v, Got: %v", tt.target, tt.moves, got)
+		}
+		// binary STL, an 80 byte header, the count, 50 bytes a triangle
+		if len(body) < 84 {
+			t.Errorf("%v → Expected: a binary STL, Got: %v bytes", tt.target, len(body))
+			continue
+		}
+		n := binary.LittleEndian.Uint32(body[80:84])
+		if n == 0 || len(body) != 84+50*int(n) {
+			t.Errorf("%v → Expected: %v triangles in %v bytes, Got: %v bytes", tt.target, n, 84+50*int(n), len(body))
+		}
+	}
+
+	res, body := get(t, sv, "/api/stock?t=soon")
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "soon") {
+		t.Errorf("Bad t → Expected: 400, Got: %v %q", res.StatusCode, body)
+	}
+}
+
+func TestServeViewer(t *testing.T) {
+	sv := serveSrc(t, served)
+	res, body := get(t, sv, "/")
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(body), `<canvas id="gl">`) {
+		t.Errorf("Index → Expected: the viewer page, Got: %v %.80q", res.StatusCode, body)
+	}
+	res, body = get(t, sv, "/viewer.js")
+	if res.StatusCode != http.StatusOK || len(body) == 0 {
+		t.Errorf("Script → Expected: 200, Got: %v %v bytes", res.StatusCode, len(body))
+	}
+	if res, _ = get(t, sv, "/serve.go"); res.StatusCode != http.StatusNotFound {
+		t.Errorf("Outside → Expected: 404, Got: %v", res.StatusCode)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:8080", true},
+		{"localhost:8080", true},
+		{"[::1]:8080", true},
+		{"127.1.2.3:0", true},
+		{"0.0.0.0:8080", false},
+		{":8080", false},
+		{"[::]:8080", false},
+		{"192.168.1.10:8080", false},
+		{"example.com:8080", false},
+		{"127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := loopback(tt.addr); got != tt.want {
+			t.Errorf("loopback(%q) → Expected: %v, Got: %v", tt.addr, tt.want, got)
+		}
+	}
+}
+
+func TestServeRefusesRemote(t *testing.T) {
+	fileNm := filepath.Join(t.TempDir(), "p.nc")
+	if err := os.WriteFile(fileNm, []byte(served), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []string{"0.0.0.0:8080", ":8080"} {
+		if got := serveCmd([]string{"-addr", addr, fileNm}); got != EXIT_USAGE {
+			t.Errorf("serve -addr %v → Expected: %v, Got: %v", addr, EXIT_USAGE, got)
+		}
+	}
+	if !loopback(serveAddr) {
+		t.Errorf("Default → Expected: %v on loopback", serveAddr)
+	}
+}
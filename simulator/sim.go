@@ -1,19 +1,89 @@
 package main
 
 import (
-	"github.com/timleecasey/stllib/lib/aid3/gcode"
-	"github.com/timleecasey/stllib/lib/aid3/sim"
-	"log"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
 )
 
+// The exit codes, findings are warnings or collisions from lint or sim.
+const (
+	EXIT_OK = iota
+	EXIT_FAILED
+	EXIT_USAGE
+	EXIT_FINDINGS
+)
+
+// command
+// A subcommand, run with the arguments after its name.
+type command struct {
+	usage string
+	help  string
+	run   func(args []string) int
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"parse":     {"parse [-format text|json] file.nc", "summarize the commands, layers, operations and tools", parseCmd},
+		"lint":      {"lint [-rules mill] [-severity rule=level,...] [-sim] [machine flags] file.nc", "check the program for unsafe or suspicious code", lintCmd},
+		"sim":       {"sim [machine flags] [-out dir] file.nc", "simulate the program, writing the outputs to the out directory", simCmd},
+		"stats":     {"stats [machine flags] [-rate 60] [-format text|json] [-o file] file.nc", "cycle time, per tool and per operation times and cost", statsCmd},
+		"optimize":  {"optimize [-tolerance 0.01] [-arcs] [-o file] file.nc", "merge G1 runs into longer lines and G2/G3 arcs", optimizeCmd},
+		"transform": {"transform [-mirror x] [-scale 2] [-rotate 0,0,90] [-origin x,y,z] [-translate x,y,z] [-o file] file.nc", "mirror, scale, rotate and move the program, arcs and all", transformCmd},
+		"export":    {"export [machine flags] [-format stl|csv] -o file file.nc", "write the finished stock as STL or the moves as CSV", exportCmd},
+		"plot":      {"plot [machine flags] [-view xy,xz,yz] [-color tool|feed] [-size 600] [-o file.svg] file.nc", "draw the simulated tool path as SVG, by tool or feed", plotCmd},
+		"render":    {"render [machine flags] [-view iso] [-fov 0] [-shading phong|lambert] [-edges] [-path] -o file.png file.stl|file.nc", "a shaded PNG of a model, or of the stock a program leaves", renderCmd},
+		"serve":     {"serve [machine flags] [-addr 127.0.0.1:8080] file.nc", "view the simulation in a browser, served on this machine only", serveCmd},
+		"voxelize":  {"voxelize [-resolution 0.25] [-format text|json] [-o file.stl] model.stl", "fill the model with cells, the volume and optionally the cells as STL", voxelizeCmd},
+		"stl-info":  {"stl-info [-format text|json] model.stl", "triangles, bounds, size, volume and area of a model", stlInfoCmd},
+	}
+}
+
 func main() {
-	gcodeFileNm := os.Args[1]
-	tree, err := gcode.Parse(gcodeFileNm)
-	if err != nil {
-		log.Printf("Could not parse %v: %v", gcodeFileNm, err)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(EXIT_USAGE)
 	}
-	s := &sim.Sim{}
-	s.Start()
-	s.Run(tree)
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage()
+		os.Exit(EXIT_OK)
+	}
+	c := commands[name]
+	if c == nil {
+		if _, err := os.Stat(name); err == nil {
+			// simulator file.nc, as before the subcommands
+			os.Exit(simCmd(os.Args[1:]))
+		}
+		fmt.Fprintf(os.Stderr, "simulator: unknown command %q\n", name)
+		usage()
+		os.Exit(EXIT_USAGE)
+	}
+	os.Exit(c.run(os.Args[2:]))
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for nm := range commands {
+		names = append(names, nm)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	fmt.Fprintf(&b, "usage: simulator <command> [flags] file\n\n")
+	for _, nm := range names {
+		fmt.Fprintf(&b, "  %-9s %v\n", nm, commands[nm].help)
+	}
+	fmt.Fprintf(&b, "\nmachine flags are -config, -machine, -material, -stock, -stock-stl, -tools, -tolerance, -slice and -resolution,\n")
+	fmt.Fprintf(&b, "simulator <command> -h lists them.  Exits 1 on failure, 2 on bad usage and 3 on findings.\n")
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+// fail
+// Report the failure of the command and return its exit code.
+func fail(cmd string, code int, format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "simulator %v: %v\n", cmd, fmt.Sprintf(format, args...))
+	return code
 }
@@ -0,0 +1,443 @@
+// The simulator's viewer: the head path and the stock in WebGL, a
+// timeline to scrub and the source beside it.  Everything comes from
+// the server it was loaded from.
+'use strict';
+
+const KIND_COLORS = {
+  rapid: [0.85, 0.3, 0.3],
+  feed: [0.15, 0.35, 0.8],
+  arc: [0.1, 0.6, 0.3],
+  probe: [0.6, 0.2, 0.7],
+};
+
+const canvas = document.getElementById('gl');
+const gl = canvas.getContext('webgl', { antialias: true });
+const info = document.getElementById('info');
+const slider = document.getElementById('time');
+const clock = document.getElementById('clock');
+const sourceEl = document.getElementById('source');
+
+const state = {
+  path: null,       // as /api/path serves it
+  segs: [],         // {move, a, b, t0, t1} in time order
+  time: 0,
+  picked: -1,       // the move picked
+  lines: [],        // the source line divs
+  yaw: -0.6, pitch: 0.6, dist: 100, center: [0, 0, 0],
+  stockCount: 0,
+  stockSeq: 0,
+  playing: false,
+};
+
+// matrices, column major as WebGL takes them
+
+function perspective(fovy, aspect, near, far) {
+  const f = 1 / Math.tan(fovy / 2), nf = 1 / (near - far);
+  return new Float32Array([f / aspect, 0, 0, 0, 0, f, 0, 0, 0, 0, (far + near) * nf, -1, 0, 0, 2 * far * near * nf, 0]);
+}
+
+function lookAt(eye, at, up) {
+  const sub = (a, b) => [a[0] - b[0], a[1] - b[1], a[2] - b[2]];
+  const norm = (a) => { const l = Math.hypot(a[0], a[1], a[2]) || 1; return [a[0] / l, a[1] / l, a[2] / l]; };
+  const cross = (a, b) => [a[1] * b[2] - a[2] * b[1], a[2] * b[0] - a[0] * b[2], a[0] * b[1] - a[1] * b[0]];
+  const dot = (a, b) => a[0] * b[0] + a[1] * b[1] + a[2] * b[2];
+  const z = norm(sub(eye, at)), x = norm(cross(up, z)), y = cross(z, x);
+  return new Float32Array([x[0], y[0], z[0], 0, x[1], y[1], z[1], 0, x[2], y[2], z[2], 0, -dot(x, eye), -dot(y, eye), -dot(z, eye), 1]);
+}
+
+function multiply(a, b) {
+  const r = new Float32Array(16);
+  for (let c = 0; c < 4; c++) {
+    for (let i = 0; i < 4; i++) {
+      let s = 0;
+      for (let k = 0; k < 4; k++) s += a[k * 4 + i] * b[c * 4 + k];
+      r[c * 4 + i] = s;
+    }
+  }
+  return r;
+}
+
+function eye() {
+  const c = state.center, d = state.dist;
+  return [c[0] + d * Math.cos(state.pitch) * Math.cos(state.yaw),
+    c[1] + d * Math.cos(state.pitch) * Math.sin(state.yaw),
+    c[2] + d * Math.sin(state.pitch)];
+}
+
+function viewProjection() {
+  const aspect = canvas.width / canvas.height;
+  const proj = perspective(Math.PI / 4, aspect, state.dist / 100, state.dist * 10);
+  return multiply(proj, lookAt(eye(), state.center, [0, 0, 1]));
+}
+
+// shaders
+
+function program(vs, fs) {
+  const compile = (type, src) => {
+    const s = gl.createShader(type);
+    gl.shaderSource(s, src);
+    gl.compileShader(s);
+    if (!gl.getShaderParameter(s, gl.COMPILE_STATUS)) throw new Error(gl.getShaderInfoLog(s));
+    return s;
+  };
+  const p = gl.createProgram();
+  gl.attachShader(p, compile(gl.VERTEX_SHADER, vs));
+  gl.attachShader(p, compile(gl.FRAGMENT_SHADER, fs));
+  gl.linkProgram(p);
+  if (!gl.getProgramParameter(p, gl.LINK_STATUS)) throw new Error(gl.getProgramInfoLog(p));
+  return p;
+}
+
+const lineProg = program(`
+attribute vec3 pos;
+attribute vec3 color;
+attribute float rapid;
+uniform mat4 vp;
+uniform float size;
+varying vec3 vColor;
+varying float vRapid;
+void main() {
+  gl_Position = vp * vec4(pos, 1.0);
+  gl_PointSize = size;
+  vColor = color;
+  vRapid = rapid;
+}`, `
+precision mediump float;
+uniform float hideRapids;
+varying vec3 vColor;
+varying float vRapid;
+void main() {
+  if (vRapid > 0.5 && hideRapids > 0.5) discard;
+  gl_FragColor = vec4(vColor, 1.0);
+}`);
+
+const meshProg = program(`
+attribute vec3 pos;
+attribute vec3 normal;
+uniform mat4 vp;
+varying vec3 vNormal;
+void main() {
+  gl_Position = vp * vec4(pos, 1.0);
+  vNormal = normal;
+}`, `
+precision mediump float;
+uniform vec3 light;
+varying vec3 vNormal;
+void main() {
+  float d = abs(dot(normalize(vNormal), light));
+  gl_FragColor = vec4(vec3(0.62, 0.66, 0.72) * (0.35 + 0.65 * d), 1.0);
+}`);
+
+const buffers = {
+  path: gl.createBuffer(),
+  picked: gl.createBuffer(),
+  head: gl.createBuffer(),
+  stock: gl.createBuffer(),
+};
+
+// vertices as x, y, z, r, g, b, rapid
+function linesData(segs, color) {
+  const d = new Float32Array(segs.length * 14);
+  segs.forEach((s, i) => {
+    const m = state.path.moves[s.move];
+    const c = color || KIND_COLORS[m.kind] || [0, 0, 0];
+    const r = m.kind === 'rapid' ? 1 : 0;
+    d.set([s.a[0], s.a[1], s.a[2], c[0], c[1], c[2], r, s.b[0], s.b[1], s.b[2], c[0], c[1], c[2], r], i * 14);
+  });
+  return d;
+}
+
+function drawLines(buffer, mode, count, size) {
+  if (count <= 0) return;
+  gl.useProgram(lineProg);
+  gl.bindBuffer(gl.ARRAY_BUFFER, buffer);
+  const pos = gl.getAttribLocation(lineProg, 'pos');
+  const color = gl.getAttribLocation(lineProg, 'color');
+  const rapid = gl.getAttribLocation(lineProg, 'rapid');
+  gl.enableVertexAttribArray(pos);
+  gl.enableVertexAttribArray(color);
+  gl.enableVertexAttribArray(rapid);
+  gl.vertexAttribPointer(pos, 3, gl.FLOAT, false, 28, 0);
+  gl.vertexAttribPointer(color, 3, gl.FLOAT, false, 28, 12);
+  gl.vertexAttribPointer(rapid, 1, gl.FLOAT, false, 28, 24);
+  gl.uniformMatrix4fv(gl.getUniformLocation(lineProg, 'vp'), false, viewProjection());
+  gl.uniform1f(gl.getUniformLocation(lineProg, 'size'), size || 1);
+  gl.uniform1f(gl.getUniformLocation(lineProg, 'hideRapids'), document.getElementById('rapids').checked ? 0 : 1);
+  gl.drawArrays(mode, 0, count);
+}
+
+function drawStock() {
+  if (!state.stockCount || !document.getElementById('stock').checked) return;
+  gl.useProgram(meshProg);
+  gl.bindBuffer(gl.ARRAY_BUFFER, buffers.stock);
+  const pos = gl.getAttribLocation(meshProg, 'pos');
+  const normal = gl.getAttribLocation(meshProg, 'normal');
+  gl.enableVertexAttribArray(pos);
+  gl.enableVertexAttribArray(normal);
+  gl.vertexAttribPointer(pos, 3, gl.FLOAT, false, 24, 0);
+  gl.vertexAttribPointer(normal, 3, gl.FLOAT, false, 24, 12);
+  gl.uniformMatrix4fv(gl.getUniformLocation(meshProg, 'vp'), false, viewProjection());
+  const e = eye(), c = state.center;
+  const l = [e[0] - c[0], e[1] - c[1], e[2] - c[2] + state.dist / 2];
+  const n = Math.hypot(l[0], l[1], l[2]);
+  gl.uniform3f(gl.getUniformLocation(meshProg, 'light'), l[0] / n, l[1] / n, l[2] / n);
+  gl.drawArrays(gl.TRIANGLES, 0, state.stockCount);
+}
+
+// the number of segments done by time t
+function segmentsBy(t) {
+  let lo = 0, hi = state.segs.length;
+  while (lo < hi) {
+    const mid = (lo + hi) >> 1;
+    if (state.segs[mid].t1 <= t) lo = mid + 1; else hi = mid;
+  }
+  return lo;
+}
+
+// where the head is at time t
+function headAt(t) {
+  const segs = state.segs;
+  if (!segs.length) return null;
+  const i = Math.min(segmentsBy(t), segs.length - 1);
+  const s = segs[i];
+  const f = s.t1 > s.t0 ? Math.min(Math.max((t - s.t0) / (s.t1 - s.t0), 0), 1) : 1;
+  return [s.a[0] + (s.b[0] - s.a[0]) * f, s.a[1] + (s.b[1] - s.a[1]) * f, s.a[2] + (s.b[2] - s.a[2]) * f];
+}
+
+function draw() {
+  const w = canvas.clientWidth * devicePixelRatio, h = canvas.clientHeight * devicePixelRatio;
+  if (canvas.width !== w || canvas.height !== h) {
+    canvas.width = w;
+    canvas.height = h;
+  }
+  gl.viewport(0, 0, canvas.width, canvas.height);
+  gl.clearColor(0.957, 0.961, 0.969, 1);
+  gl.clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT);
+  gl.enable(gl.DEPTH_TEST);
+  if (!state.path) return;
+  drawStock();
+  drawLines(buffers.path, gl.LINES, 2 * segmentsBy(state.time));
+  gl.disable(gl.DEPTH_TEST);
+  if (state.picked >= 0) drawLines(buffers.picked, gl.LINES, state.pickedCount);
+  const head = headAt(state.time);
+  if (head) {
+    gl.bindBuffer(gl.ARRAY_BUFFER, buffers.head);
+    gl.bufferData(gl.ARRAY_BUFFER, new Float32Array([head[0], head[1], head[2], 0, 0, 0, 0]), gl.DYNAMIC_DRAW);
+    drawLines(buffers.head, gl.POINTS, 1, 8);
+  }
+}
+
+// the timeline
+
+function setTime(t, fromSlider) {
+  const total = state.path.time;
+  state.time = Math.min(Math.max(t, 0), total);
+  if (!fromSlider) slider.value = total > 0 ? Math.round(1000 * state.time / total) : 1000;
+  clock.textContent = state.time.toFixed(2) + ' / ' + total.toFixed(2) + ' s';
+  const i = Math.min(segmentsBy(state.time), state.segs.length - 1);
+  if (i >= 0) markLine('moving', state.path.moves[state.segs[i].move].line, false);
+  loadStockSoon();
+  draw();
+}
+
+let stockTimer = 0;
+function loadStockSoon() {
+  clearTimeout(stockTimer);
+  stockTimer = setTimeout(loadStock, 250);
+}
+
+// the stock at the time on the slider, a binary STL
+async function loadStock() {
+  const seq = ++state.stockSeq;
+  const res = await fetch('api/stock?t=' + state.time);
+  if (seq !== state.stockSeq) return;
+  if (!res.ok) {
+    state.stockCount = 0;
+    draw();
+    return;
+  }
+  const buf = await res.arrayBuffer();
+  if (seq !== state.stockSeq) return;
+  const dv = new DataView(buf);
+  const n = dv.getUint32(80, true);
+  const d = new Float32Array(n * 18);
+  for (let i = 0; i < n; i++) {
+    const o = 84 + i * 50;
+    const v = [];
+    for (let k = 0; k < 9; k++) v.push(dv.getFloat32(o + 12 + 4 * k, true));
+    // the normal from the winding, some STLs leave theirs 0
+    const ux = v[3] - v[0], uy = v[4] - v[1], uz = v[5] - v[2];
+    const wx = v[6] - v[0], wy = v[7] - v[1], wz = v[8] - v[2];
+    const nx = uy * wz - uz * wy, ny = uz * wx - ux * wz, nz = ux * wy - uy * wx;
+    for (let k = 0; k < 3; k++) d.set([v[3 * k], v[3 * k + 1], v[3 * k + 2], nx, ny, nz], i * 18 + k * 6);
+  }
+  gl.bindBuffer(gl.ARRAY_BUFFER, buffers.stock);
+  gl.bufferData(gl.ARRAY_BUFFER, d, gl.STATIC_DRAW);
+  state.stockCount = n * 3;
+  draw();
+}
+
+function play() {
+  if (!state.playing) return;
+  const total = state.path.time;
+  // the whole program in about 20 seconds
+  const t = state.time + total / 20 / 60;
+  if (t >= total) {
+    state.playing = false;
+    document.getElementById('play').textContent = 'play';
+  }
+  setTime(t >= total ? total : t);
+  requestAnimationFrame(play);
+}
+
+// picking and the source
+
+function markLine(cls, line, scroll) {
+  for (const el of sourceEl.querySelectorAll('.' + cls)) el.classList.remove(cls);
+  const el = state.lines[line - 1];
+  if (!el) return;
+  el.classList.add(cls);
+  if (scroll) el.scrollIntoView({ block: 'center' });
+}
+
+function pick(move) {
+  state.picked = move;
+  if (move < 0) {
+    info.textContent = 'nothing picked';
+    markLine('picked', 0, false);
+    draw();
+    return;
+  }
+  const m = state.path.moves[move];
+  const segs = state.segs.filter((s) => s.move === move);
+  gl.bindBuffer(gl.ARRAY_BUFFER, buffers.picked);
+  gl.bufferData(gl.ARRAY_BUFFER, linesData(segs, [1, 0.75, 0]), gl.STATIC_DRAW);
+  state.pickedCount = segs.length * 2;
+  info.textContent = 'line ' + m.line + ': ' + m.kind + ' T' + m.tool + (m.kind === 'rapid' ? '' : ' F' + m.feed) +
+    ', ' + m.start.toFixed(2) + ' s for ' + m.duration.toFixed(2) + ' s';
+  markLine('picked', m.line, true);
+  draw();
+}
+
+// the move nearest the click, within a few pixels, of those drawn
+function pickAt(x, y) {
+  const vp = viewProjection();
+  const w = canvas.clientWidth, h = canvas.clientHeight;
+  const screen = (p) => {
+    const cx = vp[0] * p[0] + vp[4] * p[1] + vp[8] * p[2] + vp[12];
+    const cy = vp[1] * p[0] + vp[5] * p[1] + vp[9] * p[2] + vp[13];
+    const cw = vp[3] * p[0] + vp[7] * p[1] + vp[11] * p[2] + vp[15];
+    if (cw <= 0) return null;
+    return [(cx / cw + 1) * w / 2, (1 - cy / cw) * h / 2];
+  };
+  const rapids = document.getElementById('rapids').checked;
+  let best = -1, bestD = 8;
+  const n = segmentsBy(state.time);
+  for (let i = 0; i < n; i++) {
+    const s = state.segs[i];
+    if (!rapids && state.path.moves[s.move].kind === 'rapid') continue;
+    const a = screen(s.a), b = screen(s.b);
+    if (!a || !b) continue;
+    const dx = b[0] - a[0], dy = b[1] - a[1];
+    const l2 = dx * dx + dy * dy;
+    const f = l2 > 0 ? Math.min(Math.max(((x - a[0]) * dx + (y - a[1]) * dy) / l2, 0), 1) : 0;
+    const d = Math.hypot(a[0] + dx * f - x, a[1] + dy * f - y);
+    if (d < bestD) {
+      bestD = d;
+      best = s.move;
+    }
+  }
+  pick(best);
+}
+
+function controls() {
+  let drag = null;
+  canvas.addEventListener('contextmenu', (e) => e.preventDefault());
+  canvas.addEventListener('mousedown', (e) => {
+    drag = { x: e.clientX, y: e.clientY, moved: false, pan: e.button === 2 || e.shiftKey };
+  });
+  window.addEventListener('mousemove', (e) => {
+    if (!drag) return;
+    const dx = e.clientX - drag.x, dy = e.clientY - drag.y;
+    if (Math.abs(dx) + Math.abs(dy) > 2) drag.moved = true;
+    drag.x = e.clientX;
+    drag.y = e.clientY;
+    if (drag.pan) {
+      // along the screen, scaled to the distance
+      const k = state.dist / canvas.clientHeight;
+      const sy = Math.sin(state.yaw), cy = Math.cos(state.yaw);
+      state.center[0] += (sy * dx - cy * Math.sin(state.pitch) * dy) * k;
+      state.center[1] += (-cy * dx - sy * Math.sin(state.pitch) * dy) * k;
+      state.center[2] += Math.cos(state.pitch) * dy * k;
+    } else {
+      state.yaw -= dx * 0.01;
+      state.pitch = Math.min(Math.max(state.pitch + dy * 0.01, -1.55), 1.55);
+    }
+    draw();
+  });
+  window.addEventListener('mouseup', (e) => {
+    if (drag && !drag.moved && e.target === canvas) {
+      const r = canvas.getBoundingClientRect();
+      pickAt(e.clientX - r.left, e.clientY - r.top);
+    }
+    drag = null;
+  });
+  canvas.addEventListener('wheel', (e) => {
+    e.preventDefault();
+    state.dist *= Math.exp(e.deltaY * 0.001);
+    draw();
+  }, { passive: false });
+  slider.addEventListener('input', () => setTime(state.path.time * slider.value / 1000, true));
+  document.getElementById('stock').addEventListener('change', draw);
+  document.getElementById('rapids').addEventListener('change', draw);
+  document.getElementById('play').addEventListener('click', (e) => {
+    state.playing = !state.playing;
+    e.target.textContent = state.playing ? 'pause' : 'play';
+    if (state.playing) {
+      if (state.time >= state.path.time) state.time = 0;
+      requestAnimationFrame(play);
+    }
+  });
+  window.addEventListener('resize', draw);
+}
+
+async function load() {
+  const [program, source, path] = await Promise.all([
+    fetch('api/program').then((r) => r.json()),
+    fetch('api/source').then((r) => r.text()),
+    fetch('api/path').then((r) => r.json()),
+  ]);
+  document.title = program.summary.file + ' - simulator';
+
+  source.split('\n').forEach((text, i) => {
+    const el = document.createElement('div');
+    const n = document.createElement('span');
+    n.className = 'n';
+    n.textContent = i + 1;
+    el.appendChild(n);
+    el.appendChild(document.createTextNode(text || ' '));
+    el.addEventListener('click', () => pick(path.moves.findIndex((m) => m.line === i + 1)));
+    sourceEl.appendChild(el);
+    state.lines.push(el);
+  });
+
+  state.path = path;
+  path.moves.forEach((m, i) => {
+    for (let k = 1; k < m.points.length; k++) {
+      const a = m.points[k - 1], b = m.points[k];
+      state.segs.push({ move: i, a: a, b: b, t0: a[3], t1: b[3] });
+    }
+  });
+  gl.bindBuffer(gl.ARRAY_BUFFER, buffers.path);
+  gl.bufferData(gl.ARRAY_BUFFER, linesData(state.segs), gl.STATIC_DRAW);
+
+  const lo = path.lo, hi = path.hi;
+  state.center = [(lo.X + hi.X) / 2, (lo.Y + hi.Y) / 2, (lo.Z + hi.Z) / 2];
+  state.dist = Math.max(Math.hypot(hi.X - lo.X, hi.Y - lo.Y, hi.Z - lo.Z) * 1.5, 10);
+  info.textContent = program.summary.file + ': ' + program.blocks.length + ' commands, ' +
+    path.moves.length + ' moves, click a move to find its line';
+  controls();
+  setTime(path.time);
+}
+
+load().catch((e) => { info.textContent = 'could not load: ' + e.message; });